@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	访问统计（按令牌 / 按 IP，每日汇总）
+	- HTTP：请求数、各端点次数、响应字节数
+	- WS：连接次数、连接时长、推送字节数（断开时记入）
+	- 按北京时间切日，持久化到 data/access.json；保留天数见 Config.Retention.AccessDays
+	- 用于识别滥用方、为吊销令牌提供依据：GET /api/admin/access
+*/
+
+const (
+	accessMaxKeys      = 5000 // per dimension per day; the rest is folded into "other"
+	accessMaxEndpoints = 50   // per key
+	accessOther        = "other"
+)
+
+type AccessStat struct {
+	Requests   uint64            `json:"requests"`
+	Bytes      uint64            `json:"bytes"`
+	Endpoints  map[string]uint64 `json:"endpoints"`
+	WSConnects uint64            `json:"wsConnects"`
+	WSSeconds  float64           `json:"wsSeconds"`
+	WSBytes    uint64            `json:"wsBytes"`
+	LastSeen   string            `json:"lastSeen"`
+}
+
+type accessDay struct {
+	ByIP    map[string]*AccessStat `json:"byIp"`
+	ByToken map[string]*AccessStat `json:"byToken"`
+}
+
+var (
+	accessPath = filepath.Join(dataDir, "access.json")
+
+	accessMu    sync.Mutex
+	accessDays  = map[string]*accessDay{} // Beijing date -> rollup
+	accessDirty bool
+)
+
+func loadAccess() {
+	b, err := os.ReadFile(accessPath)
+	if err != nil {
+		return
+	}
+	var days map[string]*accessDay
+	if err := json.Unmarshal(b, &days); err != nil || days == nil {
+		logger.Printf("ACCESS_LOAD_ERROR: %v", err)
+		return
+	}
+	accessMu.Lock()
+	accessDays = days
+	accessMu.Unlock()
+}
+
+func accessStatLocked(m map[string]*AccessStat, key string) *AccessStat {
+	st := m[key]
+	if st == nil {
+		if len(m) >= accessMaxKeys {
+			key = accessOther
+			if st = m[key]; st != nil {
+				return st
+			}
+		}
+		st = &AccessStat{Endpoints: map[string]uint64{}}
+		m[key] = st
+	}
+	return st
+}
+
+// accessRecord applies fn to today's IP (and token, if any) rollups
+func accessRecord(ip, token string, now time.Time, fn func(*AccessStat)) {
+	d := beijingDate(now)
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	day := accessDays[d]
+	if day == nil {
+		day = &accessDay{ByIP: map[string]*AccessStat{}, ByToken: map[string]*AccessStat{}}
+		accessDays[d] = day
+	}
+	seen := now.UTC().Format(time.RFC3339)
+	st := accessStatLocked(day.ByIP, ip)
+	fn(st)
+	st.LastSeen = seen
+	if token != "" {
+		st = accessStatLocked(day.ByToken, token)
+		fn(st)
+		st.LastSeen = seen
+	}
+	accessDirty = true
+}
+
+func accessRecordWS(ip, token string, connected time.Time, bytes uint64) {
+	now := time.Now()
+	accessRecord(ip, token, now, func(st *AccessStat) {
+		st.WSConnects++
+		st.WSSeconds += now.Sub(connected).Seconds()
+		st.WSBytes += bytes
+	})
+}
+
+// requestToken returns the consumer token only when it is a known one
+func requestToken(r *http.Request) string {
+	if tok, ok := tokenOK(r); ok {
+		return tok
+	}
+	return ""
+}
+
+func hostOnly(remoteAddr string) string {
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return h
+	}
+	return remoteAddr
+}
+
+// countingWriter counts response bytes and keeps the status; keeps Flusher (SSE) and Hijacker (WS) working
+type countingWriter struct {
+	http.ResponseWriter
+	n      uint64
+	status int
+}
+
+func (cw *countingWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.n += uint64(n)
+	return n, err
+}
+
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	cw.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func withAccessStats(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &countingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		now := time.Now()
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		accessLogCLF(r, cw.status, cw.n, now)
+		path := r.URL.Path
+		accessRecord(hostOnly(r.RemoteAddr), requestToken(r), now, func(st *AccessStat) {
+			st.Requests++
+			st.Bytes += cw.n // WS traffic is added on disconnect
+			if _, ok := st.Endpoints[path]; !ok && len(st.Endpoints) >= accessMaxEndpoints {
+				path = accessOther
+			}
+			st.Endpoints[path]++
+		})
+	})
+}
+
+func flushAccess() {
+	if persistPaused.Load() {
+		return
+	}
+	accessMu.Lock()
+	if !accessDirty {
+		accessMu.Unlock()
+		return
+	}
+	b, err := json.Marshal(accessDays)
+	accessDirty = false
+	accessMu.Unlock()
+	if err != nil {
+		return
+	}
+
+	tmp := accessPath + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, accessPath)
+	}
+	if err != nil {
+		logger.Printf("ACCESS_SAVE_ERROR: %v", err)
+		accessMu.Lock()
+		accessDirty = true
+		accessMu.Unlock()
+	}
+}
+
+func accessLoop() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for range t.C {
+		flushAccess()
+	}
+}
+
+// trimAccess drops rollups older than days; returns days removed
+func trimAccess(days int) int {
+	cutoff := beijingDate(time.Now().AddDate(0, 0, -days))
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	n := 0
+	for d := range accessDays {
+		if d < cutoff {
+			delete(accessDays, d)
+			n++
+		}
+	}
+	if n > 0 {
+		accessDirty = true
+	}
+	return n
+}
+
+// AccessRow is one consumer in GET /api/admin/access
+type AccessRow struct {
+	Key string `json:"key"` // IP, or token redacted to its first 6 chars
+	AccessStat
+}
+
+// GET /api/admin/access?by=token|ip&date=YYYY-MM-DD&sort=requests|bytes|ws&top=20
+func apiAccess(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	by := q.Get("by")
+	if by == "" {
+		by = "token"
+	}
+	if by != "token" && by != "ip" {
+		httpError(w, r, "by must be token or ip", http.StatusBadRequest)
+		return
+	}
+	date := q.Get("date")
+	if date == "" {
+		date = beijingDate(time.Now())
+	}
+	top := queryInt(r, "top", 20, 1, 500)
+
+	accessMu.Lock()
+	dates := make([]string, 0, len(accessDays))
+	for d := range accessDays {
+		dates = append(dates, d)
+	}
+	var rows []AccessRow
+	if day := accessDays[date]; day != nil {
+		m := day.ByToken
+		if by == "ip" {
+			m = day.ByIP
+		}
+		rows = make([]AccessRow, 0, len(m))
+		for k, st := range m {
+			row := AccessRow{Key: k, AccessStat: *st}
+			row.Endpoints = make(map[string]uint64, len(st.Endpoints))
+			for p, n := range st.Endpoints {
+				row.Endpoints[p] = n
+			}
+			if by == "token" && len(k) > 6 && k != accessOther {
+				row.Key = k[:6] + "..."
+			}
+			rows = append(rows, row)
+		}
+	}
+	accessMu.Unlock()
+
+	metric := func(a AccessRow) float64 {
+		switch q.Get("sort") {
+		case "bytes":
+			return float64(a.Bytes + a.WSBytes)
+		case "ws":
+			return a.WSSeconds
+		}
+		return float64(a.Requests)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if mi, mj := metric(rows[i]), metric(rows[j]); mi != mj {
+			return mi > mj
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	total := len(rows)
+	if len(rows) > top {
+		rows = rows[:top]
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	mustJSON(w, 200, map[string]any{"date": date, "by": by, "total": total, "rows": append([]AccessRow{}, rows...), "dates": dates})
+}
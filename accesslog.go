@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	HTTP 访问日志（Apache Combined Log Format，给 GoAccess / awstats 直接用）
+	- 功能开关 access-log-clf（默认关闭，/api/admin/features 即时开关）
+	- 每个请求一行，写到 logs/YYYY-MM-DD.access.log，按天切换；
+	  与主日志一起按 Retention.LogsDays 清理
+	- 格式：host - user [time] "METHOD uri PROTO" status bytes "referer" "user-agent"
+	  user 固定为 -；uri 中的 token 参数打码；WS 升级记为 101、字节数 0（推送流量见 /api/admin/access）
+*/
+
+const featAccessLogCLF = "access-log-clf"
+
+var (
+	clfMu   sync.Mutex
+	clfFile *os.File
+	clfDay  string
+)
+
+// clfQuote escapes a header value for a quoted CLF field; empty = "-"
+func clfQuote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`).Replace(s)
+	return `"` + s + `"`
+}
+
+// clfURI: request URI with access tokens redacted
+func clfURI(r *http.Request) string {
+	u := *r.URL
+	q := u.Query()
+	if q.Has("token") {
+		q.Set("token", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.RequestURI()
+}
+
+func clfLine(r *http.Request, status int, bytes uint64, now time.Time) string {
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprint(bytes)
+	}
+	return fmt.Sprintf("%s - - [%s] %s %d %s %s %s\n",
+		remoteIP(r), now.Format("02/Jan/2006:15:04:05 -0700"),
+		clfQuote(r.Method+" "+clfURI(r)+" "+r.Proto), status, size,
+		clfQuote(r.Referer()), clfQuote(r.UserAgent()))
+}
+
+// accessLogCLF appends one request; the file is switched at the local date change
+func accessLogCLF(r *http.Request, status int, bytes uint64, now time.Time) {
+	if !featureOn(featAccessLogCLF) || persistPaused.Load() {
+		return
+	}
+	line := clfLine(r, status, bytes, now)
+	clfMu.Lock()
+	defer clfMu.Unlock()
+	if day := now.Format("2006-01-02"); day != clfDay || clfFile == nil {
+		if clfFile != nil {
+			_ = clfFile.Close()
+			clfFile = nil
+		}
+		f, err := os.OpenFile(filepath.Join(logDir, day+".access.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			if clfDay != day {
+				logger.Printf("ACCESS_LOG_ERROR: %v", err)
+			}
+			clfDay = day
+			return
+		}
+		clfFile, clfDay = f, day
+	}
+	_, _ = clfFile.WriteString(line)
+}
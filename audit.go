@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	判定抽样核验（完整链路的自动完整性审计）
+	- 周期从最近已判定的区块中随机抽一个
+	- 用「另一个」节点按高度重新拉取（/wallet/getblockbynum），重新计算 ON/OFF
+	- hash 或判定结果不一致：MAJOR 告警 + 记录
+	- 未配置 nodeUrl 时关闭；模拟器产生的区块、以及来自同一节点的区块不参与
+*/
+
+const (
+	auditSampleWindow = 200 // sample among the last N judged blocks
+	auditKeepMismatch = 20
+)
+
+type AuditConfig struct {
+	NodeURL         string `json:"nodeUrl"`         // independent node; must differ from the block source
+	APIKey          string `json:"apiKey"`          // optional (TRON-PRO-API-KEY)
+	IntervalSeconds int    `json:"intervalSeconds"` // default 300
+}
+
+type AuditMismatch struct {
+	CheckedAt   string `json:"checkedAt"`
+	Height      int64  `json:"height"`
+	Source      string `json:"source"`
+	Hash        string `json:"hash"`
+	State       string `json:"state"`
+	AuditHash   string `json:"auditHash"`
+	AuditState  string `json:"auditState"`
+	HashDiffers bool   `json:"hashDiffers"` // reorg or a source serving a wrong block
+}
+
+type AuditStats struct {
+	Checks     uint64          `json:"checks"`
+	Matches    uint64          `json:"matches"`
+	Mismatches uint64          `json:"mismatches"`
+	Errors     uint64          `json:"errors"`
+	LastCheck  string          `json:"lastCheck"`
+	LastError  string          `json:"lastError"`
+	Recent     []AuditMismatch `json:"recent"` // newest first
+}
+
+var (
+	auditMu    sync.Mutex
+	auditStats = AuditStats{Recent: []AuditMismatch{}}
+)
+
+var errAuditSkip = errors.New("audit: nothing to sample")
+
+func auditSettings() AuditConfig {
+	cfgMu.RLock()
+	ac := cfg.Audit
+	cfgMu.RUnlock()
+	return ac.withDefaults()
+}
+
+func (ac AuditConfig) withDefaults() AuditConfig {
+	ac.NodeURL = strings.TrimRight(strings.TrimSpace(ac.NodeURL), "/")
+	if ac.IntervalSeconds <= 0 {
+		ac.IntervalSeconds = 300
+	}
+	return ac
+}
+
+// sameNode: the audit must not ask the node that produced the block
+func sameNode(a, b string) bool {
+	ua, err1 := url.Parse(a)
+	ub, err2 := url.Parse(b)
+	if err1 != nil || err2 != nil {
+		return strings.EqualFold(a, b)
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+func auditLoop() {
+	for {
+		ac := auditSettings()
+		time.Sleep(time.Duration(ac.IntervalSeconds) * time.Second)
+		if ac.NodeURL == "" || !featureOn(featJudgeAudit) {
+			continue
+		}
+		if _, err := auditOnce(ac); err != nil && !errors.Is(err, errAuditSkip) {
+			logger.Printf("AUDIT_ERROR: %v", err)
+		}
+	}
+}
+
+// auditOnce samples one recent block; returns the mismatch (nil when it matched)
+func auditOnce(ac AuditConfig) (*AuditMismatch, error) {
+	if ac.NodeURL == "" {
+		return nil, errors.New("audit: nodeUrl not configured")
+	}
+
+	var pool []chartBlock
+	for _, b := range recentBlocks(auditSampleWindow) {
+		if b.Source != sourceSimulator && !sameNode(ac.NodeURL, sourceURL(b.Source)) {
+			pool = append(pool, b)
+		}
+	}
+	if len(pool) == 0 {
+		return nil, errAuditSkip
+	}
+	b := pool[rand.Intn(len(pool))]
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	height, hash, err := fetchBlockByNum(client, ac.NodeURL, ac.APIKey, b.Height)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditStats.Checks++
+	auditStats.LastCheck = now
+	if err == nil && height != b.Height {
+		err = fmt.Errorf("audit: asked for %d, got %d", b.Height, height)
+	}
+	if err != nil {
+		auditStats.Errors++
+		auditStats.LastError = err.Error()
+		return nil, err
+	}
+
+	state, _ := blockStateByHash(hash)
+	hashDiffers := !strings.EqualFold(hash, b.Hash)
+	if !hashDiffers && state == b.State {
+		auditStats.Matches++
+		return nil, nil
+	}
+
+	m := AuditMismatch{
+		CheckedAt: now, Height: b.Height, Source: b.Source,
+		Hash: b.Hash, State: b.State, AuditHash: hash, AuditState: state,
+		HashDiffers: hashDiffers,
+	}
+	auditStats.Mismatches++
+	auditStats.Recent = append([]AuditMismatch{m}, auditStats.Recent...)
+	if len(auditStats.Recent) > auditKeepMismatch {
+		auditStats.Recent = auditStats.Recent[:auditKeepMismatch]
+	}
+	logger.Printf("MAJOR AUDIT_MISMATCH height=%d source=%s hash=%s state=%s auditHash=%s auditState=%s",
+		m.Height, m.Source, m.Hash, m.State, m.AuditHash, m.AuditState)
+	return &m, nil
+}
+
+func fetchBlockByNum(client *http.Client, nodeURL, apiKey string, num int64) (height int64, hash string, err error) {
+	body, _ := json.Marshal(map[string]int64{"num": num})
+	req, _ := http.NewRequest("POST", nodeURL+"/wallet/getblockbynum", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, "", statusError(resp)
+	}
+
+	var out tronNowBlockResp // same block shape as getnowblock
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, "", err
+	}
+	if out.BlockID == "" {
+		return 0, "", fmt.Errorf("audit: block %d not found", num)
+	}
+	return out.BlockHeader.RawData.Number, out.BlockID, nil
+}
+
+// GET  /api/admin/audit -> config + stats
+// POST /api/admin/audit -> run one sampling check now
+func apiAudit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		ac := auditSettings()
+		if len(ac.APIKey) > 6 {
+			ac.APIKey = ac.APIKey[:6] + "..."
+		}
+		auditMu.Lock()
+		st := auditStats
+		st.Recent = append([]AuditMismatch{}, st.Recent...)
+		auditMu.Unlock()
+		mustJSON(w, 200, map[string]any{"config": ac, "stats": st})
+	case "POST":
+		if !featureOn(featJudgeAudit) {
+			httpError(w, r, "feature "+featJudgeAudit+" is off", http.StatusConflict)
+			return
+		}
+		ac := auditSettings()
+		if ac.NodeURL == "" {
+			httpError(w, r, "audit.nodeUrl not configured", http.StatusBadRequest)
+			return
+		}
+		m, err := auditOnce(ac)
+		if errors.Is(err, errAuditSkip) {
+			httpError(w, r, "no recent blocks from another node to sample", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadGateway)
+			return
+		}
+		mustJSON(w, 200, map[string]any{"ok": true, "match": m == nil, "mismatch": m})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	区块缺口回填
+	- 新区块高度比上一块大 1 以上 => 按高度拉取缺失的区块（getblockbynum / eth_getBlockByNumber）
+	- 按高度顺序送入判定与状态机，计数不再悄悄跳块
+	- 每个源可单独配置按高度查询：byNumMethod / byNumUrl / byNumBody（{height} {heightHex}）
+	- 缺口超过 maxBlocks 时不回填（只记日志），避免长时间断线后一次性灌入大量旧区块
+	- 回填请求与轮询一样计入健康分、统计、熔断、限速与退避（fetchSourceTimedAt）：
+	  发出前等限速令牌，熔断打开 / 退避中 / Retry-After 则停止；同时在途的请求数
+	  与竞速共用 tuning.raceMaxInFlight（为 0 时 backfillParallel）
+	- 回填在监听循环内同步进行（缺失的高度必须先于新块判定），整体不超过 backfillBudget；
+	  第一个失败的高度之后的块不再送入判定
+*/
+
+const (
+	backfillDefaultMax = 20
+	backfillParallel   = 4 // requests at once when tuning.raceMaxInFlight is 0
+	backfillBudget     = 10 * time.Second
+	backfillWaitStep   = 50 * time.Millisecond
+)
+
+type BackfillConfig struct {
+	MaxBlocks int `json:"maxBlocks"` // 0 = 20
+}
+
+func (bc BackfillConfig) withDefaults() BackfillConfig {
+	if bc.MaxBlocks <= 0 {
+		bc.MaxBlocks = backfillDefaultMax
+	}
+	if bc.MaxBlocks > 200 {
+		bc.MaxBlocks = 200
+	}
+	return bc
+}
+
+func backfillSettings() BackfillConfig {
+	cfgMu.RLock()
+	bc := cfg.Backfill
+	cfgMu.RUnlock()
+	return bc.withDefaults()
+}
+
+var (
+	bfMu  sync.Mutex
+	bfTop int64 // highest height seen; lagging sources must not reopen old gaps
+)
+
+// byNumFetch fetches one height with the source's accounting (health, stats, breaker, limiter, backoff)
+type byNumFetch func(ctx context.Context, num int64) sourceResult
+
+// byNumGate: nil when the source may take one more request now; errBackfillWait = no token yet
+type byNumGate func(now time.Time) error
+
+var errBackfillWait = errors.New("waiting for a rate limit token")
+
+// fetchSourceByNum runs the source's by-height request
+func fetchSourceByNum(ctx context.Context, client *http.Client, sc SourceConfig, num int64) (int64, string, string, error) {
+	return fetchSource(ctx, client, byNumRequest(sc, num))
+}
+
+// byNumRequest turns sc into its by-height request for num
+func byNumRequest(sc SourceConfig, num int64) SourceConfig {
+	fill := strings.NewReplacer("{height}", strconv.FormatInt(num, 10), "{heightHex}", "0x"+strconv.FormatInt(num, 16))
+	q := sc
+	q.Method = sc.ByNumMethod
+	if q.Method == "" {
+		q.Method = sc.Method
+	}
+	if q.Method == "" {
+		q.Method = "POST" // push sources
+	}
+	q.URL, q.URLs = fill.Replace(sc.ByNumURL), nil
+	q.Body = fill.Replace(sc.ByNumBody)
+	return q
+}
+
+// backfillFetcher prefers the source that produced the block, then any enabled
+// source with a by-height lookup, then TronGrid with the configured keys
+func backfillFetcher(via string) (string, byNumGate, byNumFetch) {
+	var pick *SourceConfig
+	now := time.Now()
+	for _, sc := range quotaSources(maintenanceSources(enabledSources(), now), now) {
+		if sc.ByNumURL == "" {
+			continue
+		}
+		if sc.ID == via || pick == nil {
+			c := sc
+			pick = &c
+			if sc.ID == via {
+				break
+			}
+		}
+	}
+	if pick != nil {
+		sc := *pick
+		one := []SourceConfig{sc}
+		gate := func(now time.Time) error {
+			switch {
+			case !backoffReady(sc.ID, now):
+				return errors.New("backing off")
+			case !limiterReady(sc.ID, now):
+				return errors.New("rate limited (Retry-After)")
+			case len(limitSources(one, now)) == 0:
+				return errBackfillWait
+			case len(breakerSources(one, now)) == 0:
+				return errors.New("circuit open")
+			}
+			return nil
+		}
+		return sc.ID, gate, func(ctx context.Context, n int64) sourceResult {
+			return fetchSourceTimedAt(ctx, sc, n)
+		}
+	}
+
+	cfgMu.RLock()
+	keys := append([]string(nil), cfg.APIKeys...)
+	cfgMu.RUnlock()
+	if via != sourceTronGrid || len(keys) == 0 {
+		return "", nil, nil
+	}
+	gate := func(now time.Time) error {
+		if !backoffReady(sourceTronGrid, now) || !limiterReady(sourceTronGrid, now) {
+			return errors.New("backing off")
+		}
+		return nil
+	}
+	return sourceTronGrid, gate, func(ctx context.Context, n int64) sourceResult {
+		start := time.Now()
+		key := pickKey(sourceTronGrid, keys, "", start)
+		res := sourceResult{id: sourceTronGrid}
+		res.height, res.hash, res.err = fetchBlockByNum(sourceClient(SourceConfig{ID: sourceTronGrid}), defaultNodeURL, key, n)
+		reportKey(sourceTronGrid, key, res.err)
+		sourceStatsRecord(sourceTronGrid, res.err, time.Since(start))
+		limiterRecord(sourceTronGrid, res.err)
+		backoffRecord(sourceTronGrid, res.err)
+		return res
+	}
+}
+
+type backfillResult struct {
+	height  int64
+	hash    string
+	timeISO string
+	err     error
+}
+
+// backfillGap feeds the heights between the previous top and next to the judge in order
+func backfillGap(next int64, via string, rules Rules) {
+	bfMu.Lock()
+	prev := bfTop
+	if next > bfTop {
+		bfTop = next
+	}
+	bfMu.Unlock()
+	if prev <= 0 || next <= prev+1 || !featureOn(featGapBackfill) {
+		return
+	}
+	gap := next - prev - 1
+	max := backfillSettings().MaxBlocks
+	if gap > int64(max) {
+		logger.Printf("BACKFILL_SKIPPED from=%d to=%d gap=%d max=%d", prev+1, next-1, gap, max)
+		return
+	}
+	name, gate, fetch := backfillFetcher(via)
+	if fetch == nil {
+		logger.Printf("BACKFILL_UNAVAILABLE from=%d to=%d via=%s (no source with a by-height lookup)", prev+1, next-1, via)
+		return
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), backfillBudget)
+	defer cancel()
+	slots := raceBudgetSettings().maxInFlight
+	if slots == 0 {
+		slots = backfillParallel
+	}
+	sem := make(chan struct{}, slots)
+	res := make([]backfillResult, gap)
+	var wg sync.WaitGroup
+launch:
+	for i := range res {
+		n := prev + 1 + int64(i)
+		sem <- struct{}{}
+		for {
+			err := gate(time.Now())
+			if err == nil {
+				break
+			}
+			if errors.Is(err, errBackfillWait) && ctx.Err() == nil {
+				time.Sleep(backfillWaitStep)
+				continue
+			}
+			if errors.Is(err, errBackfillWait) {
+				err = fmt.Errorf("no rate limit token within %s", backfillBudget)
+			}
+			res[i].err = err
+			<-sem
+			break launch // later heights would only be fed after this one
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			r := &res[i]
+			got := fetch(ctx, n)
+			r.height, r.hash, r.timeISO, r.err = got.height, got.hash, got.timeISO, got.err
+			if r.err == nil && r.height != n {
+				r.err = fmt.Errorf("asked for height %d, got %d", n, r.height)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	filled := 0
+	for _, r := range res {
+		if r.err != nil {
+			logger.Printf("BACKFILL_ERROR height=%d via=%s err=%v", prev+1+int64(filled), name, r.err)
+			break
+		}
+		confirmBlock(r.height, r.hash, r.timeISO, rules, &latencyTrace{Source: name})
+		filled++
+	}
+	logger.Printf("BACKFILL_DONE from=%d to=%d filled=%d via=%s ms=%d", prev+1, next-1, filled, name, time.Since(start).Milliseconds())
+}
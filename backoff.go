@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+	源错误指数退避（带抖动）
+	- 超时 / 连接错误 / 5xx / 429 => 该源等待 baseMs × 2^(n-1)（上限 capMs），再乘 0.5~1.0 的随机抖动
+	- 成功一次立即清零；其它 4xx 属于配置问题，不退避（交给熔断器）
+	- 429 / 503 带 Retry-After 时由限速器按服务商要求的时长暂停（ratelimit.go），不再叠加退避
+	- 退避中的源本节拍跳过，不再“每个 tick 都重试一次”
+	- TronGrid 默认源同样适用（id = trongrid）；状态见 GET /api/sources 的 backoff 字段
+*/
+
+type BackoffConfig struct {
+	BaseMS int `json:"baseMs"` // 0 = 1000
+	CapMS  int `json:"capMs"`  // 0 = 60000
+}
+
+func (bc BackoffConfig) withDefaults() BackoffConfig {
+	if bc.BaseMS <= 0 {
+		bc.BaseMS = 1000
+	}
+	if bc.CapMS <= 0 {
+		bc.CapMS = 60000
+	}
+	if bc.CapMS < bc.BaseMS {
+		bc.CapMS = bc.BaseMS
+	}
+	return bc
+}
+
+func backoffSettings() BackoffConfig {
+	cfgMu.RLock()
+	bc := cfg.Backoff
+	cfgMu.RUnlock()
+	return bc.withDefaults()
+}
+
+type BackoffState struct {
+	Failures int    `json:"failures"`
+	WaitMS   int64  `json:"waitMs"`
+	Until    string `json:"until"`
+
+	until time.Time
+}
+
+var (
+	boMu     sync.Mutex
+	boStates = map[string]*BackoffState{}
+	boRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// isTransient: worth retrying later (network, timeout, 5xx, 429)
+func isTransient(err error) bool {
+	var he *httpStatusError
+	if errors.As(err, &he) {
+		return he.Code >= 500 || he.Code == 429
+	}
+	return true
+}
+
+// backoffDelay: base·2^(n-1) capped, with 50–100% jitter
+func backoffDelay(n int, bc BackoffConfig) time.Duration {
+	d := time.Duration(bc.BaseMS) * time.Millisecond
+	limit := time.Duration(bc.CapMS) * time.Millisecond
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return time.Duration(float64(d) * (0.5 + boRand.Float64()*0.5))
+}
+
+// backoffRecord resets on success and pushes the next attempt out on transient errors
+func backoffRecord(id string, err error) {
+	bc := backoffSettings()
+	boMu.Lock()
+	defer boMu.Unlock()
+	if err == nil || !isTransient(err) || retryAfterOf(err) > 0 {
+		delete(boStates, id)
+		return
+	}
+	st := boStates[id]
+	if st == nil {
+		st = &BackoffState{}
+		boStates[id] = st
+	}
+	st.Failures++
+	wait := backoffDelay(st.Failures, bc)
+	st.until = time.Now().Add(wait)
+	st.WaitMS = wait.Milliseconds()
+	st.Until = st.until.UTC().Format(time.RFC3339Nano)
+	logger.Printf("SOURCE_BACKOFF id=%s failures=%d waitMs=%d", id, st.Failures, st.WaitMS)
+}
+
+func backoffReady(id string, now time.Time) bool {
+	boMu.Lock()
+	defer boMu.Unlock()
+	st := boStates[id]
+	return st == nil || !now.Before(st.until)
+}
+
+// backoffSources drops sources still waiting out their backoff
+func backoffSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if backoffReady(sc.ID, now) {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+func backoffSnapshot() map[string]BackoffState {
+	boMu.Lock()
+	defer boMu.Unlock()
+	out := make(map[string]BackoffState, len(boStates))
+	for id, st := range boStates {
+		out[id] = *st
+	}
+	return out
+}
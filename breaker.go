@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+	每源熔断器（closed → open → half-open）
+	- 连续失败 breaker.failures 次（默认 5）=> open：不再每个节拍请求已经挂掉的端点
+	- open 经过 breaker.cooldownSeconds（默认 30）=> half-open：只放行一次探测请求
+	  探测成功 => closed；失败 => 重新 open 并再等一个冷却期
+	- 状态切换写日志 BREAKER_OPEN / BREAKER_HALF_OPEN / BREAKER_CLOSED
+	- 受 feature flag "source-breaker" 控制；状态见 GET /api/sources 的 breaker 字段
+*/
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+type BreakerConfig struct {
+	Failures        int `json:"failures"`        // consecutive errors to open; 0 = 5
+	CooldownSeconds int `json:"cooldownSeconds"` // open -> half-open; 0 = 30
+}
+
+func (bc BreakerConfig) withDefaults() BreakerConfig {
+	if bc.Failures <= 0 {
+		bc.Failures = 5
+	}
+	if bc.CooldownSeconds <= 0 {
+		bc.CooldownSeconds = 30
+	}
+	return bc
+}
+
+func breakerSettings() BreakerConfig {
+	cfgMu.RLock()
+	bc := cfg.Breaker
+	cfgMu.RUnlock()
+	return bc.withDefaults()
+}
+
+type BreakerState struct {
+	State     string `json:"state"`
+	Failures  int    `json:"failures"` // consecutive
+	OpenedAt  string `json:"openedAt,omitempty"`
+	NextProbe string `json:"nextProbe,omitempty"`
+
+	openedAt time.Time
+	probing  bool // half-open probe in flight
+}
+
+var (
+	brMu     sync.Mutex
+	brStates = map[string]*BreakerState{}
+)
+
+func breakerStateLocked(id string) *BreakerState {
+	st := brStates[id]
+	if st == nil {
+		st = &BreakerState{State: breakerClosed}
+		brStates[id] = st
+	}
+	return st
+}
+
+// breakerSources keeps closed circuits and lets one probe through each cooled-down open circuit
+func breakerSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	if !featureOn(featSourceBreaker) {
+		return srcs
+	}
+	cooldown := time.Duration(breakerSettings().CooldownSeconds) * time.Second
+	brMu.Lock()
+	defer brMu.Unlock()
+	var out []SourceConfig
+	for _, sc := range srcs {
+		st := breakerStateLocked(sc.ID)
+		switch st.State {
+		case breakerOpen:
+			if now.Sub(st.openedAt) < cooldown {
+				continue
+			}
+			st.State, st.probing = breakerHalfOpen, true
+			logger.Printf("BREAKER_HALF_OPEN id=%s", sc.ID)
+		case breakerHalfOpen:
+			if st.probing {
+				continue // one probe at a time
+			}
+			st.probing = true
+		}
+		out = append(out, sc)
+	}
+	return out
+}
+
+// breakerRecord feeds one fetch outcome into the source's circuit
+func breakerRecord(id string, err error) {
+	if !featureOn(featSourceBreaker) {
+		return
+	}
+	bc := breakerSettings()
+	brMu.Lock()
+	defer brMu.Unlock()
+	st := breakerStateLocked(id)
+	if err == nil {
+		if st.State != breakerClosed {
+			logger.Printf("BREAKER_CLOSED id=%s", id)
+		}
+		*st = BreakerState{State: breakerClosed}
+		return
+	}
+	st.Failures++
+	st.probing = false
+	if st.State == breakerHalfOpen || (st.State == breakerClosed && st.Failures >= bc.Failures) {
+		st.State = breakerOpen
+		st.openedAt = time.Now()
+		st.OpenedAt = st.openedAt.UTC().Format(time.RFC3339)
+		st.NextProbe = st.openedAt.Add(time.Duration(bc.CooldownSeconds) * time.Second).UTC().Format(time.RFC3339)
+		logger.Printf("BREAKER_OPEN id=%s failures=%d cooldownSeconds=%d err=%q", id, st.Failures, bc.CooldownSeconds, err.Error())
+	}
+}
+
+func breakerSnapshot() map[string]BreakerState {
+	brMu.Lock()
+	defer brMu.Unlock()
+	out := make(map[string]BreakerState, len(brStates))
+	for id, st := range brStates {
+		out[id] = *st
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+//go:build chaos
+
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	故障注入（仅 -tags chaos 构建时存在，且仅管理员可用）
+	- 指定来源强制报错 / 延迟响应
+	- 丢弃 WS 消息
+	- 模拟区块缺口（丢弃接下来 N 个新块）
+	用于演练告警与切换流程；DELETE 立即恢复
+*/
+
+type ChaosState struct {
+	FetchErrorSource string  `json:"fetchErrorSource"` // URL substring or "*"; empty = off
+	FetchErrorCount  int     `json:"fetchErrorCount"`  // remaining injected errors; <0 = until cleared
+	DelayMS          int     `json:"delayMS"`          // added before every fetch
+	DropWSRate       float64 `json:"dropWSRate"`       // 0..1 probability per broadcast
+	DropWSTopic      string  `json:"dropWSTopic"`      // empty = all topics
+	GapBlocks        int     `json:"gapBlocks"`        // next N block heights are swallowed
+}
+
+var (
+	chaosMu       sync.Mutex
+	chaos         ChaosState
+	chaosGapUntil int64 // last height of the active simulated gap (0 = none)
+)
+
+func chaosBeforeFetch(source string) error {
+	chaosMu.Lock()
+	delay := time.Duration(chaos.DelayMS) * time.Millisecond
+	var err error
+	if chaos.FetchErrorSource != "" && chaos.FetchErrorCount != 0 &&
+		(chaos.FetchErrorSource == "*" || strings.Contains(source, chaos.FetchErrorSource)) {
+		if chaos.FetchErrorCount > 0 {
+			chaos.FetchErrorCount--
+		}
+		err = errors.New("chaos: injected fetch error")
+	}
+	chaosMu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return err
+}
+
+func chaosSkipBlock(height int64) bool {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	if chaos.GapBlocks > 0 && chaosGapUntil == 0 {
+		chaosGapUntil = height + int64(chaos.GapBlocks) - 1
+		chaos.GapBlocks = 0
+		logger.Printf("CHAOS_GAP_START from=%d to=%d", height, chaosGapUntil)
+	}
+	if chaosGapUntil == 0 {
+		return false
+	}
+	if height <= chaosGapUntil {
+		return true
+	}
+	chaosGapUntil = 0
+	return false
+}
+
+func chaosDropWS(topic string) bool {
+	chaosMu.Lock()
+	rate, only := chaos.DropWSRate, chaos.DropWSTopic
+	chaosMu.Unlock()
+	if rate <= 0 || (only != "" && only != topic) {
+		return false
+	}
+	return rand.Float64() < rate
+}
+
+// GET state / POST set (replaces) / DELETE clear
+func apiChaos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		chaosMu.Lock()
+		st := chaos
+		chaosMu.Unlock()
+		mustJSON(w, 200, st)
+	case "POST":
+		var st ChaosState
+		if err := readJSON(r, &st); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		st.DelayMS = clamp(st.DelayMS, 0, 60000)
+		if st.DropWSRate < 0 || st.DropWSRate > 1 {
+			httpError(w, r, "dropWSRate must be within 0..1", http.StatusBadRequest)
+			return
+		}
+		chaosMu.Lock()
+		chaos = st
+		chaosGapUntil = 0
+		chaosMu.Unlock()
+		logger.Printf("CHAOS_SET source=%q errors=%d delayMS=%d dropWS=%.2f(%s) gap=%d rid=%s",
+			st.FetchErrorSource, st.FetchErrorCount, st.DelayMS, st.DropWSRate, st.DropWSTopic, st.GapBlocks, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "chaos": st})
+	case "DELETE":
+		chaosMu.Lock()
+		chaos = ChaosState{}
+		chaosGapUntil = 0
+		chaosMu.Unlock()
+		logger.Printf("CHAOS_CLEARED rid=%s", requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+func registerChaosRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/admin/chaos", requireAdmin(apiChaos))
+	logger.Println("CHAOS_ENABLED (built with -tags chaos)")
+}
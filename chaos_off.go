@@ -0,0 +1,16 @@
+//go:build !chaos
+
+package main
+
+import "net/http"
+
+// Failure injection is compiled in only with `go build -tags chaos`.
+// These no-op hooks keep the production pipeline free of chaos logic.
+
+func chaosBeforeFetch(source string) error { return nil }
+
+func chaosSkipBlock(height int64) bool { return false }
+
+func chaosDropWS(topic string) bool { return false }
+
+func registerChaosRoutes(mux *http.ServeMux) {}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	图表数据（服务端预聚合，手机端不拉原始历史）
+	GET /api/charts/blocks-per-minute?minutes=60
+	GET /api/charts/states?n=100
+	GET /api/charts/sources
+	GET /api/charts/triggers?hours=24
+	仅内存保存，重启清空
+*/
+
+const (
+	chartBlocksMax  = 4000 // ~3.3h of 3s blocks
+	chartSignalsMax = 2000
+	chartMachine    = "local" // single-machine deployment
+)
+
+const (
+	sourceTronGrid  = "trongrid"
+	sourceSimulator = "simulator"
+)
+
+type chartBlock struct {
+	Height int64
+	Hash   string
+	State  string
+	Seen   time.Time // local receive time (simulated blocks carry synthetic timestamps)
+	Source string
+}
+
+type chartSignal struct {
+	Signal
+	Seen time.Time
+}
+
+var (
+	chartMu      sync.Mutex
+	chartBlocks  []chartBlock  // oldest first
+	chartSignals []chartSignal // oldest first
+	chartWins    = map[string]uint64{}
+)
+
+func chartRecordBlock(height int64, hash, state string, tr *latencyTrace) {
+	src := sourceTronGrid
+	if tr != nil && tr.Source != "" {
+		src = tr.Source
+	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	chartBlocks = append(chartBlocks, chartBlock{Height: height, Hash: hash, State: state, Seen: time.Now(), Source: src})
+	if len(chartBlocks) > chartBlocksMax {
+		chartBlocks = append(chartBlocks[:0], chartBlocks[len(chartBlocks)-chartBlocksMax:]...)
+	}
+	chartWins[src]++
+}
+
+// recentBlocks returns a copy of the last n judged blocks (oldest first)
+func recentBlocks(n int) []chartBlock {
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	from := len(chartBlocks) - n
+	if from < 0 {
+		from = 0
+	}
+	return append([]chartBlock(nil), chartBlocks[from:]...)
+}
+
+func chartRecordSignal(s Signal) {
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	chartSignals = append(chartSignals, chartSignal{Signal: s, Seen: time.Now()})
+	if len(chartSignals) > chartSignalsMax {
+		chartSignals = append(chartSignals[:0], chartSignals[len(chartSignals)-chartSignalsMax:]...)
+	}
+}
+
+func queryInt(r *http.Request, name string, def, lo, hi int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return def
+	}
+	return clamp(n, lo, hi)
+}
+
+type MinuteBucket struct {
+	Minute string `json:"minute"` // RFC3339, UTC, truncated to minute
+	Blocks int    `json:"blocks"`
+	On     int    `json:"on"`
+	Off    int    `json:"off"`
+}
+
+type StatePoint struct {
+	Height int64  `json:"height"`
+	State  string `json:"state"`
+}
+
+type SourceShare struct {
+	Source string  `json:"source"`
+	Blocks uint64  `json:"blocks"`
+	Share  float64 `json:"share"` // 0..1
+}
+
+type TriggerPoint struct {
+	Time   string `json:"time"`
+	Type   string `json:"type"`
+	Height int64  `json:"height"`
+	State  string `json:"state"`
+}
+
+func apiCharts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/charts"), "/")
+
+	chartMu.Lock()
+	defer chartMu.Unlock()
+
+	switch name {
+	case "blocks-per-minute":
+		minutes := queryInt(r, "minutes", 60, 1, 240)
+		end := time.Now().UTC().Truncate(time.Minute)
+		start := end.Add(-time.Duration(minutes-1) * time.Minute)
+		buckets := make([]MinuteBucket, minutes)
+		for i := range buckets {
+			buckets[i].Minute = start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+		}
+		for _, b := range chartBlocks {
+			i := int(b.Seen.UTC().Truncate(time.Minute).Sub(start) / time.Minute)
+			if i < 0 || i >= minutes {
+				continue
+			}
+			buckets[i].Blocks++
+			if b.State == "ON" {
+				buckets[i].On++
+			} else {
+				buckets[i].Off++
+			}
+		}
+		mustJSON(w, 200, map[string]any{"minutes": minutes, "buckets": buckets})
+
+	case "states":
+		n := queryInt(r, "n", 100, 1, chartBlocksMax)
+		from := len(chartBlocks) - n
+		if from < 0 {
+			from = 0
+		}
+		points := make([]StatePoint, 0, len(chartBlocks)-from)
+		on, off := 0, 0
+		for _, b := range chartBlocks[from:] {
+			points = append(points, StatePoint{Height: b.Height, State: b.State})
+			if b.State == "ON" {
+				on++
+			} else {
+				off++
+			}
+		}
+		mustJSON(w, 200, map[string]any{"n": len(points), "on": on, "off": off, "points": points})
+
+	case "sources":
+		var total uint64
+		for _, c := range chartWins {
+			total += c
+		}
+		shares := make([]SourceShare, 0, len(chartWins))
+		for src, c := range chartWins {
+			s := SourceShare{Source: src, Blocks: c}
+			if total > 0 {
+				s.Share = float64(c) / float64(total)
+			}
+			shares = append(shares, s)
+		}
+		sort.Slice(shares, func(i, j int) bool { return shares[i].Blocks > shares[j].Blocks })
+		mustJSON(w, 200, map[string]any{"total": total, "sources": shares})
+
+	case "triggers":
+		hours := queryInt(r, "hours", 24, 1, 168)
+		cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+		points := []TriggerPoint{}
+		for _, s := range chartSignals {
+			if s.Seen.Before(cutoff) {
+				continue
+			}
+			points = append(points, TriggerPoint{Time: s.TimeISO, Type: s.Type, Height: s.Height, State: s.State})
+		}
+		mustJSON(w, 200, map[string]any{
+			"hours":    hours,
+			"machines": map[string][]TriggerPoint{chartMachine: points},
+		})
+
+	default:
+		httpError(w, r, "unknown chart (blocks-per-minute|states|sources|triggers)", http.StatusNotFound)
+	}
+}
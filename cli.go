@@ -0,0 +1,416 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	命令行管理（同一个二进制，第一个参数是子命令时不启动服务）
+	- tron-signal admin-password -user NAME [-password P]  重设管理员（经本机恢复端口，会话全部失效；不给 -password 从标准输入读一行）
+	- tron-signal token                                   新建访问 token（经恢复端口）
+	- tron-signal status                                  恢复端口的状态（用户名、封禁数、监听地址）
+	- tron-signal sources [-json]                         列出 data/config.json 中的数据源（密钥打码）
+	- tron-signal validate [-config PATH]                 校验配置文件（与启动时的诊断相同），有 error 时退出码 1
+	- tron-signal backup [-out DIR]                       把 data/ 打包成 tron-signal-backup-YYYYMMDD-HHMMSS.tar.gz
+	- tron-signal logs [-n 50] [-f]                       显示当天日志的最后 N 行，-f 持续跟随（跨天自动切换文件）
+	恢复端口见 Config.Recovery（默认 127.0.0.1:8089），-addr 可覆盖；服务未运行时这几个子命令直接报错
+	读写 data/ 的子命令在工作目录下执行（与服务相同）
+*/
+
+type cliCommand struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var cliCommands = []cliCommand{
+	{"admin-password", "reset the admin login via the recovery listener", cliAdminPassword},
+	{"token", "create an access token via the recovery listener", cliToken},
+	{"status", "show the recovery listener status", cliStatus},
+	{"sources", "list configured sources (keys redacted)", cliSources},
+	{"validate", "check a config file, exit 1 on errors", cliValidate},
+	{"backup", "write a tar.gz of the data directory", cliBackup},
+	{"logs", "print (and follow) today's log", cliLogs},
+}
+
+// runCLI handles a subcommand in os.Args; false = start the server
+func runCLI() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		cliUsage(os.Stdout)
+		os.Exit(0)
+	}
+	for _, c := range cliCommands {
+		if c.name != name {
+			continue
+		}
+		logger = log.New(io.Discard, "", 0) // shared helpers log; the CLI prints its own results
+		if err := c.run(os.Args[2:]); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			}
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if strings.HasPrefix(name, "-") {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	cliUsage(os.Stderr)
+	os.Exit(2)
+	return false
+}
+
+func cliUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: tron-signal [command] [flags]   (no command = run the server)")
+	fmt.Fprintln(w)
+	for _, c := range cliCommands {
+		fmt.Fprintf(w, "  %-15s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w, "\nrun 'tron-signal <command> -h' for its flags")
+}
+
+// cliRecoveryAddr: -addr, else Recovery.Addr from the config file
+func cliRecoveryAddr(flagAddr string) string {
+	if flagAddr != "" {
+		return flagAddr
+	}
+	c, _ := loadConfig()
+	return c.Recovery.withDefaults().Addr
+}
+
+// cliRecovery calls the local recovery listener and decodes the JSON reply into out
+func cliRecovery(addr, method, path string, body any, out any) error {
+	var rd io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, "http://"+addr+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Recovery", "yes")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("recovery listener %s: %w (is the server running?)", addr, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("recovery listener: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return json.Unmarshal(b, out)
+}
+
+func cliAdminPassword(args []string) error {
+	fs := flag.NewFlagSet("admin-password", flag.ContinueOnError)
+	user := fs.String("user", "", "admin username (required)")
+	pass := fs.String("password", "", "new password; empty = read one line from stdin")
+	addr := fs.String("addr", "", "recovery listener address (default from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*user) == "" {
+		return errors.New("-user is required")
+	}
+	if *pass == "" {
+		fmt.Fprint(os.Stderr, "new password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read password: %w", err)
+		}
+		*pass = strings.TrimRight(line, "\r\n")
+	}
+	if *pass == "" {
+		return errors.New("empty password")
+	}
+	var res struct {
+		SessionsDropped int `json:"sessionsDropped"`
+	}
+	if err := cliRecovery(cliRecoveryAddr(*addr), "POST", "/recovery/password", map[string]string{"username": *user, "password": *pass}, &res); err != nil {
+		return err
+	}
+	fmt.Printf("admin %s set, %d session(s) dropped\n", *user, res.SessionsDropped)
+	return nil
+}
+
+func cliToken(args []string) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	addr := fs.String("addr", "", "recovery listener address (default from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := cliRecovery(cliRecoveryAddr(*addr), "POST", "/recovery/token", map[string]string{}, &res); err != nil {
+		return err
+	}
+	fmt.Println(res.Token)
+	return nil
+}
+
+func cliStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	addr := fs.String("addr", "", "recovery listener address (default from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var res map[string]any
+	if err := cliRecovery(cliRecoveryAddr(*addr), "GET", "/recovery", nil, &res); err != nil {
+		return err
+	}
+	b, _ := json.MarshalIndent(res, "", "  ")
+	fmt.Println(string(b))
+	return nil
+}
+
+func cliSources(args []string) error {
+	fs := flag.NewFlagSet("sources", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the source configs as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	out := make([]SourceConfig, len(c.Sources))
+	for i, sc := range c.Sources {
+		out[i] = redactSource(sc)
+	}
+	if *asJSON {
+		b, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(out) == 0 {
+		fmt.Println("no sources configured")
+		return nil
+	}
+	fmt.Printf("%-20s %-8s %-5s %s\n", "ID", "KIND", "ON", "URL")
+	for _, sc := range out {
+		kind := sc.Kind
+		if kind == "" {
+			kind = "poll"
+		}
+		on := "no"
+		if sc.Enabled {
+			on = "yes"
+		}
+		fmt.Printf("%-20s %-8s %-5s %s\n", sc.ID, kind, on, redactURL(sc.URL))
+	}
+	return nil
+}
+
+func cliValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	path := fs.String("config", configPath, "config file to check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*path); err != nil {
+		return err
+	}
+	loadSecrets()
+	c, err := loadConfigFile(*path)
+	issues := diagnoseConfig(&c, err, *path)
+	n := map[string]int{}
+	for _, is := range issues {
+		n[is.Level]++
+		field := is.Field
+		if field == "" {
+			field = "-"
+		}
+		fmt.Printf("%-7s %-30s %s\n", is.Level, field, is.Message)
+	}
+	fmt.Printf("%s: %d error(s), %d warning(s), %d info\n", *path, n["error"], n["warning"], n["info"])
+	if n["error"] > 0 {
+		return errors.New("config has errors")
+	}
+	return nil
+}
+
+func cliBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	outDir := fs.String("out", ".", "directory for the archive")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name := filepath.Join(*outDir, "tron-signal-backup-"+time.Now().Format("20060102-150405")+".tar.gz")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	files, size, err := writeBackup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	fmt.Printf("%s: %d file(s), %d bytes before compression\n", name, files, size)
+	return nil
+}
+
+// writeBackup tars dataDir into w (gzip); in-progress .tmp files are skipped
+func writeBackup(w io.Writer) (int, int64, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var files int
+	var size int64
+	err := filepath.WalkDir(dataDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(path)
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		n, err := io.Copy(tw, src)
+		files++
+		size += n
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	return files, size, err
+}
+
+func cliLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	lines := fs.Int("n", 50, "lines from the end")
+	follow := fs.Bool("f", false, "keep printing new lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	today := func() string { return filepath.Join(logDir, time.Now().Format("2006-01-02")+".log") }
+	path := today()
+	f, err := os.Open(path)
+	if err != nil {
+		path, err = cliLatestLog()
+		if err != nil {
+			return err
+		}
+		f, err = os.Open(path)
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { f.Close() }()
+	if err := cliTail(f, *lines); err != nil {
+		return err
+	}
+	if !*follow {
+		return nil
+	}
+	for {
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+		if next := today(); next != path {
+			if nf, err := os.Open(next); err == nil {
+				_, _ = io.Copy(os.Stdout, f) // rest of the previous day
+				f.Close()
+				f, path = nf, next
+			}
+		}
+	}
+}
+
+// cliLatestLog: newest logs/YYYY-MM-DD.log when today's does not exist yet
+func cliLatestLog() (string, error) {
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, e := range entries {
+		if n := e.Name(); strings.HasSuffix(n, ".log") && !strings.HasSuffix(n, ".access.log") {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return "", errors.New("no log files in " + logDir)
+	}
+	sort.Strings(names)
+	return filepath.Join(logDir, names[len(names)-1]), nil
+}
+
+// cliTail prints the last n lines of f and leaves it positioned at the end
+func cliTail(f *os.File, n int) error {
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	const chunk = 64 << 10
+	end := st.Size()
+	start := end
+	var buf []byte
+	for start > 0 && bytes.Count(buf, []byte("\n")) <= n {
+		step := min(int64(chunk), start)
+		start -= step
+		b := make([]byte, step)
+		if _, err := f.ReadAt(b, start); err != nil {
+			return err
+		}
+		buf = append(b, buf...)
+	}
+	all := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+	if start > 0 && len(all) > 0 {
+		all = all[1:] // partial first line
+	}
+	if len(all) > n {
+		all = all[len(all)-n:]
+	}
+	if n > 0 && len(buf) > 0 {
+		fmt.Println(strings.Join(all, "\n"))
+	}
+	_, err = f.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	响应压缩（手机走蜂窝网络看面板时省流量）
+	- 按 Accept-Encoding 协商 gzip / deflate（q 值为 0 的不用；都支持时优先 gzip）
+	- 只压缩文本类响应：JSON、CSV、text/*、JS、SVG；小于 minBytes（默认 1024）的原样返回
+	- 不压缩：SSE（/sse/*、text/event-stream）、WebSocket 升级、HEAD、Range 请求、已带 Content-Encoding 的响应
+	- compression.disabled=true 关闭；访问统计里的字节数是压缩后的实际传输量
+*/
+
+const compressMinDefault = 1024
+
+type CompressionConfig struct {
+	Disabled bool `json:"disabled"`
+	MinBytes int  `json:"minBytes"` // 0 = 1024
+}
+
+func (cc CompressionConfig) withDefaults() CompressionConfig {
+	if cc.MinBytes <= 0 {
+		cc.MinBytes = compressMinDefault
+	}
+	return cc
+}
+
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+// negotiateEncoding picks gzip or deflate from Accept-Encoding; "" = identity
+func negotiateEncoding(accept string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		v := 1.0
+		if k, val, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				v = f
+			}
+		}
+		q[name] = v
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"gzip", "deflate"} {
+		v, ok := q[enc]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = enc, v
+		}
+	}
+	return best
+}
+
+func compressibleType(ct string) bool {
+	ct = strings.ToLower(ct)
+	switch {
+	case strings.HasPrefix(ct, "text/event-stream"):
+		return false
+	case strings.HasPrefix(ct, "text/"),
+		strings.Contains(ct, "json"),
+		strings.Contains(ct, "javascript"),
+		strings.Contains(ct, "csv"),
+		strings.HasPrefix(ct, "image/svg+xml"):
+		return true
+	}
+	return false
+}
+
+// compressWriter buffers the first minBytes, then decides: small or binary bodies go out as is
+type compressWriter struct {
+	http.ResponseWriter
+	enc     string
+	min     int
+	status  int
+	buf     []byte
+	decided bool
+	zw      io.WriteCloser // nil = identity
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.decided {
+		if cw.zw != nil {
+			return cw.zw.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.min {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide commits the headers; big = body reached minBytes
+func (cw *compressWriter) decide(big bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if big && cw.status == http.StatusOK && h.Get("Content-Encoding") == "" && compressibleType(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.enc)
+		if cw.enc == "gzip" {
+			gz := gzipPool.Get().(*gzip.Writer)
+			gz.Reset(cw.ResponseWriter)
+			cw.zw = gz
+		} else {
+			cw.zw = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// finish flushes whatever is buffered and closes the encoder
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 {
+			return // handler wrote nothing (hijacked, or net/http sends the implicit 200)
+		}
+		cw.decide(false)
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+		if gz, ok := cw.zw.(*gzip.Writer); ok {
+			gzipPool.Put(gz)
+		}
+		cw.zw = nil
+	}
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(len(cw.buf) >= cw.min)
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	cw.decided = true
+	return hj.Hijack()
+}
+
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfgMu.RLock()
+		cc := cfg.Compression.withDefaults()
+		cfgMu.RUnlock()
+		enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if cc.Disabled || enc == "" || r.Method == "HEAD" || r.Header.Get("Range") != "" ||
+			r.Header.Get("Upgrade") != "" || strings.HasPrefix(r.URL.Path, "/sse/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, enc: enc, min: cc.MinBytes}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+/*
+	确认深度（confirmations）
+	- Config.Confirmations = N > 0 时，区块等链上高度达到 h+N 后才送入判定，避开分叉重组的哈希
+	- 等待期间同一高度出现新哈希 => 以最新的为准（重组替换）
+	- 标记 confirmed 的源（固化节点 /walletsolidity）返回的区块已不可逆，直接判定
+	- N = 0（默认）保持原行为：收到即判定
+*/
+
+const maxConfirmations = 100
+
+type pendingBlock struct {
+	hash    string
+	timeISO string
+	source  string
+}
+
+var (
+	confMu      sync.Mutex
+	confPending = map[int64]pendingBlock{}
+	confTip     int64 // highest height seen
+	confJudged  int64 // highest height released to the judge
+)
+
+func confirmationDepth() int {
+	cfgMu.RLock()
+	n := cfg.Confirmations
+	cfgMu.RUnlock()
+	return clampConfirmations(n)
+}
+
+func clampConfirmations(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > maxConfirmations {
+		return maxConfirmations
+	}
+	return n
+}
+
+// sourceConfirmed: blocks from this source are already final (solidity node)
+func sourceConfirmed(id string) bool {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	for _, sc := range cfg.Sources {
+		if sc.ID == id {
+			return sc.Confirmed
+		}
+	}
+	return false
+}
+
+type confirmedBlock struct {
+	height int64
+	pendingBlock
+}
+
+// confirmBlock is the single entry to the judge for fetched and backfilled blocks
+func confirmBlock(height int64, hash, timeISO string, rules Rules, tr *latencyTrace) {
+	n := confirmationDepth()
+	final := tr != nil && sourceConfirmed(tr.Source)
+
+	confMu.Lock()
+	if height > confTip {
+		confTip = height
+	}
+	var ready []confirmedBlock
+	if n == 0 || final {
+		// everything waiting up to this height is settled now, in order
+		ready = releaseLocked(func(h int64) bool { return h < height })
+		if height > confJudged {
+			confJudged = height
+		}
+		delete(confPending, height)
+		confMu.Unlock()
+		processReady(ready, rules)
+		processBlock(height, hash, parseISOOrNow(timeISO), rules, tr)
+		return
+	}
+	if height > confJudged {
+		confPending[height] = pendingBlock{hash: hash, timeISO: timeISO, source: sourceOf(tr)}
+	}
+	tip := confTip
+	ready = releaseLocked(func(h int64) bool { return h <= tip-int64(n) })
+	confMu.Unlock()
+	processReady(ready, rules)
+}
+
+// releaseLocked pops pending blocks matching ok, oldest first
+func releaseLocked(ok func(int64) bool) []confirmedBlock {
+	var out []confirmedBlock
+	for h, pb := range confPending {
+		if ok(h) {
+			out = append(out, confirmedBlock{height: h, pendingBlock: pb})
+			delete(confPending, h)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].height < out[j].height })
+	if len(out) > 0 && out[len(out)-1].height > confJudged {
+		confJudged = out[len(out)-1].height
+	}
+	return out
+}
+
+func processReady(ready []confirmedBlock, rules Rules) {
+	for _, b := range ready {
+		// held blocks are not timed: the wait would swamp the latency stages
+		processBlock(b.height, b.hash, parseISOOrNow(b.timeISO), rules, &latencyTrace{Source: b.source})
+	}
+}
+
+func sourceOf(tr *latencyTrace) string {
+	if tr == nil {
+		return ""
+	}
+	return tr.Source
+}
+
+func pendingConfirmations() int {
+	confMu.Lock()
+	defer confMu.Unlock()
+	return len(confPending)
+}
+
+// GET  /api/admin/confirmations
+// POST /api/admin/confirmations {"confirmations": 19}
+func apiConfirmations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"confirmations": confirmationDepth(), "pending": pendingConfirmations()})
+	case "POST":
+		var in struct {
+			Confirmations int `json:"confirmations"`
+		}
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.Confirmations < 0 || in.Confirmations > maxConfirmations {
+			httpError(w, r, "confirmations must be 0..100", http.StatusBadRequest)
+			return
+		}
+
+		cfgMu.Lock()
+		cfg.Confirmations = in.Confirmations
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+
+		logger.Printf("CONFIRMATIONS_UPDATED confirmations=%d rid=%s", in.Confirmations, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "confirmations": in.Confirmations})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	多源哈希共识
+	- consensus.minAgree = N ≥ 2 时：不再“谁先返回用谁”，同一高度+哈希需至少 N 个不同的源报告一致才接受
+	- 轮询源每个节拍全部查询（fetchAll），推送源收到即投票；落后的源追上后仍计票（保留最近 consensusWindow 个高度）
+	- 同一高度出现不同哈希 => CONSENSUS_CONFLICT（每个高度记一次），防止单个坏源 / 分叉源污染判定
+	- 只接受比已接受高度更高的区块；中间缺口仍由回填补齐
+	- N ≤ 1（默认）保持原行为
+*/
+
+const (
+	consensusWindow   = 64 // heights kept in the vote table
+	consensusMaxAgree = 16
+)
+
+type ConsensusConfig struct {
+	MinAgree int `json:"minAgree"` // 0/1 = first-to-return
+}
+
+type consensusVote struct {
+	timeISO string
+	sources map[string]bool
+}
+
+type ConsensusStats struct {
+	Accepted  uint64 `json:"accepted"`
+	Conflicts uint64 `json:"conflicts"`
+	Dropped   uint64 `json:"dropped"` // heights passed over without agreement
+	Pending   int    `json:"pending"`
+	LastAgree string `json:"lastAgree,omitempty"` // …hash[-8:]=agreeing sources
+}
+
+var (
+	consMu           sync.Mutex
+	consVotes        = map[int64]map[string]*consensusVote{} // height -> hash -> vote
+	consConflict     = map[int64]bool{}
+	consAccepted     int64
+	consAcceptedHash string
+	consLate         = map[string]bool{} // sources already logged disagreeing with the accepted block
+	consStats        ConsensusStats
+)
+
+func consensusMin() int {
+	cfgMu.RLock()
+	n := cfg.Consensus.MinAgree
+	cfgMu.RUnlock()
+	if n > consensusMaxAgree {
+		return consensusMaxAgree
+	}
+	return n
+}
+
+type agreedBlock struct {
+	height  int64
+	hash    string
+	timeISO string
+	source  string // the vote that completed the quorum
+}
+
+// consensusVoteFor records one source's view; returns the block when this vote completes the quorum
+func consensusVoteFor(source string, height int64, hash, timeISO string, need int) (agreedBlock, bool) {
+	consMu.Lock()
+	defer consMu.Unlock()
+
+	if height <= consAccepted {
+		if height == consAccepted && hash != consAcceptedHash && !consLate[source] {
+			consLate[source] = true
+			consStats.Conflicts++
+			logger.Printf("CONSENSUS_CONFLICT height=%d votes=%s late=%s", height, describeVotes(map[string]*consensusVote{
+				consAcceptedHash: {sources: map[string]bool{"agreed": true}},
+				hash:             {sources: map[string]bool{source: true}},
+			}), source)
+		}
+		return agreedBlock{}, false
+	}
+	byHash := consVotes[height]
+	if byHash == nil {
+		byHash = map[string]*consensusVote{}
+		consVotes[height] = byHash
+	}
+	v := byHash[hash]
+	if v == nil {
+		v = &consensusVote{timeISO: timeISO, sources: map[string]bool{}}
+		byHash[hash] = v
+	}
+	v.sources[source] = true
+	if len(byHash) > 1 && !consConflict[height] {
+		consConflict[height] = true
+		consStats.Conflicts++
+		logger.Printf("CONSENSUS_CONFLICT height=%d votes=%s", height, describeVotes(byHash))
+	}
+	consPruneLocked(height)
+
+	if len(v.sources) < need {
+		return agreedBlock{}, false
+	}
+	consAccepted, consAcceptedHash = height, hash
+	consLate = map[string]bool{}
+	consStats.Accepted++
+	consStats.LastAgree = describeVotes(map[string]*consensusVote{hash: v})
+	// anything at or below the accepted height can no longer win
+	for h := range consVotes {
+		if h <= height {
+			if h < height && len(consVotes[h]) > 0 {
+				consStats.Dropped++
+			}
+			delete(consVotes, h)
+			delete(consConflict, h)
+		}
+	}
+	return agreedBlock{height: height, hash: hash, timeISO: v.timeISO, source: source}, true
+}
+
+func consPruneLocked(top int64) {
+	for h := range consVotes {
+		if h <= top-consensusWindow {
+			delete(consVotes, h)
+			delete(consConflict, h)
+		}
+	}
+}
+
+// pollConsensus polls every due source and votes; returns the highest block that reached agreement
+func pollConsensus(due []SourceConfig, need int) (agreedBlock, bool, error) {
+	results, err := fetchAll(due)
+	if err != nil {
+		return agreedBlock{}, false, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].height < results[j].height })
+	var best agreedBlock
+	agreed := false
+	for _, res := range results {
+		if ab, ok := consensusVoteFor(res.id, res.height, res.hash, res.timeISO, need); ok {
+			best, agreed = ab, true
+		}
+	}
+	return best, agreed, nil
+}
+
+// describeVotes: "…hash[-8:]=src1+src2 ..." sorted for stable logs (the tail is what rules read)
+func describeVotes(byHash map[string]*consensusVote) string {
+	parts := make([]string, 0, len(byHash))
+	for h, v := range byHash {
+		ids := make([]string, 0, len(v.sources))
+		for id := range v.sources {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		short := h
+		if len(short) > 8 {
+			short = "…" + short[len(short)-8:]
+		}
+		parts = append(parts, short+"="+strings.Join(ids, "+"))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func consensusSnapshot() ConsensusStats {
+	consMu.Lock()
+	defer consMu.Unlock()
+	st := consStats
+	st.Pending = len(consVotes)
+	return st
+}
+
+// GET  /api/admin/consensus
+// POST /api/admin/consensus {"minAgree": 2}
+func apiConsensus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"minAgree": consensusMin(), "enabledSources": len(enabledSources()), "stats": consensusSnapshot()})
+	case "POST":
+		var in ConsensusConfig
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.MinAgree < 0 || in.MinAgree > consensusMaxAgree {
+			httpError(w, r, "minAgree must be 0..16", http.StatusBadRequest)
+			return
+		}
+		if n := len(enabledSources()); in.MinAgree > 1 && in.MinAgree > n {
+			httpError(w, r, "minAgree exceeds the number of enabled sources", http.StatusBadRequest)
+			return
+		}
+
+		cfgMu.Lock()
+		cfg.Consensus = in
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+
+		logger.Printf("CONSENSUS_UPDATED minAgree=%d rid=%s", in.MinAgree, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "minAgree": in.MinAgree})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+	每日计数（今日触发次数等）
+	- 按北京时间 0 点切日（UTC+8，无夏令时，不依赖系统 tzdata）
+	- 持久化到 data/daily.json，重启不清零；历史保留天数见 Config.Retention.DailyDays
+*/
+
+// hard cap between compactor runs; the retention policy trims further
+const dailyHistoryMax = 366
+
+var (
+	dailyPath = filepath.Join(dataDir, "daily.json")
+	beijing   = time.FixedZone("CST", 8*3600)
+)
+
+type DailyCounters struct {
+	Date     string `json:"date"` // YYYY-MM-DD (Beijing)
+	Blocks   uint64 `json:"blocks"`
+	On       uint64 `json:"on"`
+	Off      uint64 `json:"off"`
+	Hit      uint64 `json:"hit"`
+	HitMiss  uint64 `json:"hitMiss"`
+	Triggers uint64 `json:"triggers"` // ON + OFF
+}
+
+type dailyFile struct {
+	Today   DailyCounters   `json:"today"`
+	History []DailyCounters `json:"history"` // newest first
+}
+
+var (
+	dailyMu    sync.Mutex
+	daily      dailyFile
+	dailyDirty bool
+)
+
+func beijingDate(t time.Time) string {
+	return t.In(beijing).Format("2006-01-02")
+}
+
+func loadDaily() {
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+
+	b, err := os.ReadFile(dailyPath)
+	if err == nil {
+		if err := json.Unmarshal(b, &daily); err != nil {
+			logger.Printf("DAILY_LOAD_ERROR: %v", err)
+			daily = dailyFile{}
+		}
+	} else if !os.IsNotExist(err) {
+		logger.Printf("DAILY_LOAD_ERROR: %v", err)
+	}
+	rolloverLocked(time.Now())
+}
+
+// rolloverLocked moves Today into History when the Beijing date changed
+func rolloverLocked(now time.Time) {
+	d := beijingDate(now)
+	if daily.Today.Date == d {
+		return
+	}
+	if daily.Today.Date != "" {
+		daily.History = append([]DailyCounters{daily.Today}, daily.History...)
+		if len(daily.History) > dailyHistoryMax {
+			daily.History = daily.History[:dailyHistoryMax]
+		}
+		logger.Printf("DAILY_ROLLOVER date=%s blocks=%d triggers=%d hit=%d",
+			daily.Today.Date, daily.Today.Blocks, daily.Today.Triggers, daily.Today.Hit)
+	}
+	daily.Today = DailyCounters{Date: d}
+	dailyDirty = true
+}
+
+func dailyRecordBlock(signals []Signal) {
+	rollupRecordBlock(signals)
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	rolloverLocked(time.Now())
+	daily.Today.Blocks++
+	for _, s := range signals {
+		switch s.Type {
+		case "ON":
+			daily.Today.On++
+			daily.Today.Triggers++
+		case "OFF":
+			daily.Today.Off++
+			daily.Today.Triggers++
+		case "HIT":
+			daily.Today.Hit++
+		}
+	}
+	dailyDirty = true
+}
+
+func dailyRecordHitMiss() {
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	rolloverLocked(time.Now())
+	daily.Today.HitMiss++
+	dailyDirty = true
+}
+
+func dailySnapshot() DailyCounters {
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	rolloverLocked(time.Now())
+	return daily.Today
+}
+
+func flushDaily() {
+	if persistPaused.Load() {
+		return // disk low (watchdog); stays dirty until resumed
+	}
+	dailyMu.Lock()
+	if !dailyDirty {
+		dailyMu.Unlock()
+		return
+	}
+	b, err := json.MarshalIndent(daily, "", "  ")
+	dailyDirty = false
+	dailyMu.Unlock()
+	if err != nil {
+		return
+	}
+
+	tmp := dailyPath + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, dailyPath)
+	}
+	if err != nil {
+		logger.Printf("DAILY_SAVE_ERROR: %v", err)
+		dailyMu.Lock()
+		dailyDirty = true
+		dailyMu.Unlock()
+	}
+}
+
+// dailyLoop flushes periodically and forces the midnight rollover even when idle
+func dailyLoop() {
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for range t.C {
+		dailyMu.Lock()
+		rolloverLocked(time.Now())
+		dailyMu.Unlock()
+		flushDaily()
+	}
+}
+
+// trimDailyHistory drops history older than days; returns entries removed
+func trimDailyHistory(days int) int {
+	cutoff := beijingDate(time.Now().AddDate(0, 0, -days))
+
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	kept := daily.History[:0]
+	for _, d := range daily.History {
+		if d.Date >= cutoff {
+			kept = append(kept, d)
+		}
+	}
+	removed := len(daily.History) - len(kept)
+	daily.History = kept
+	if removed > 0 {
+		dailyDirty = true
+	}
+	return removed
+}
+
+// GET /api/daily -> {"today":{...},"history":[...]}
+func apiDaily(w http.ResponseWriter, r *http.Request) {
+	dailyMu.Lock()
+	rolloverLocked(time.Now())
+	out := dailyFile{
+		Today:   daily.Today,
+		History: append([]DailyCounters(nil), daily.History...),
+	}
+	dailyMu.Unlock()
+	mustJSON(w, 200, out)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"time"
+)
+
+/*
+	判定结果去抖（状态机之前的可选过滤）
+	- rules.debounce = K（2..20；0 / 1 = 关闭）：与当前稳定状态相反的结果要连续出现 K 块才算数
+	- 翻转中的区块先暂存：
+	    连续满 K 块 => 按原结果依次送入状态机，稳定状态随之切换
+	    K 块内又回到稳定状态 => 这次翻转视为噪声，暂存的区块按稳定状态送入（写 JUDGE_DEBOUNCED）
+	- 每个高度都会送入状态机（HIT 的 t+x 判定不会漏块），代价是信号最多延后 K-1 块
+	- 只影响状态机；区块推送、图表、统计里的判定结果仍是原始值
+	- 修改 debounce 或进入仅观察模式时清空暂存
+*/
+
+const maxDebounce = 20
+
+type debounceBlock struct {
+	height int64
+	hash   string
+	state  string
+	t      time.Time
+}
+
+var (
+	// guarded by rtMu (same lifecycle as the state machine)
+	dbStable string
+	dbHeld   []debounceBlock
+	dbK      int
+)
+
+// debounceFeed takes one judged block and returns the blocks the state machine should see now
+func debounceFeed(b debounceBlock, k int) []debounceBlock {
+	rtMu.Lock()
+	defer rtMu.Unlock()
+	if k != dbK {
+		dbK, dbStable = k, ""
+		held := dbHeld
+		dbHeld = nil
+		if len(held) > 0 {
+			return append(held, b) // setting changed mid-flip: release as judged
+		}
+	}
+	if k <= 1 {
+		return []debounceBlock{b}
+	}
+	if dbStable == "" || b.state == dbStable {
+		if dbStable == "" {
+			dbStable = b.state
+		}
+		held := dbHeld
+		dbHeld = nil
+		if len(held) > 0 {
+			logger.Printf("JUDGE_DEBOUNCED from=%d to=%d flipped=%s kept=%s k=%d", held[0].height, held[len(held)-1].height, held[0].state, dbStable, k)
+			for i := range held {
+				held[i].state = dbStable
+			}
+		}
+		return append(held, b)
+	}
+	dbHeld = append(dbHeld, b)
+	if len(dbHeld) < k {
+		return nil
+	}
+	dbStable = b.state
+	held := dbHeld
+	dbHeld = nil
+	return held
+}
+
+// debounceResetLocked: rtMu held
+func debounceResetLocked() {
+	dbStable, dbHeld = "", nil
+}
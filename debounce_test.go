@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestDebounceFeed(t *testing.T) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0) // JUDGE_DEBOUNCED
+	}
+	type step struct {
+		k     int
+		state string
+		want  string // released blocks as height+state, comma separated
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"off passes everything", []step{
+			{0, "ON", "1ON"}, {0, "OFF", "2OFF"}, {0, "ON", "3ON"},
+		}},
+		{"k=1 is off", []step{
+			{1, "ON", "1ON"}, {1, "OFF", "2OFF"},
+		}},
+		{"single flip is noise", []step{
+			{3, "ON", "1ON"}, {3, "OFF", ""}, {3, "ON", "2ON,3ON"}, {3, "ON", "4ON"},
+		}},
+		{"two-block flip is noise at k=3", []step{
+			{3, "ON", "1ON"}, {3, "OFF", ""}, {3, "OFF", ""}, {3, "ON", "2ON,3ON,4ON"},
+		}},
+		{"k consecutive blocks confirm the flip", []step{
+			{3, "ON", "1ON"}, {3, "OFF", ""}, {3, "OFF", ""}, {3, "OFF", "2OFF,3OFF,4OFF"},
+			{3, "OFF", "5OFF"}, {3, "ON", ""},
+		}},
+		{"k=2 confirms on the second block", []step{
+			{2, "OFF", "1OFF"}, {2, "ON", ""}, {2, "ON", "2ON,3ON"}, {2, "OFF", ""}, {2, "ON", "4ON,5ON"},
+		}},
+		{"k raised mid-flip releases as judged", []step{
+			{3, "ON", "1ON"}, {3, "OFF", ""}, {5, "OFF", "2OFF,3OFF"},
+			// stable state is picked up again from the next block
+			{5, "ON", "4ON"}, {5, "OFF", ""},
+		}},
+		{"k turned off mid-flip releases as judged", []step{
+			{3, "ON", "1ON"}, {3, "OFF", ""}, {0, "ON", "2OFF,3ON"}, {0, "OFF", "4OFF"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rtMu.Lock()
+			dbK = 0
+			debounceResetLocked()
+			rtMu.Unlock()
+			for i, s := range tt.steps {
+				out := debounceFeed(debounceBlock{height: int64(i + 1), state: s.state}, s.k)
+				var got []string
+				for _, b := range out {
+					got = append(got, fmt.Sprintf("%d%s", b.height, b.state))
+				}
+				if g := strings.Join(got, ","); g != s.want {
+					t.Fatalf("step %d (k=%d %s): released %q, want %q", i+1, s.k, s.state, g, s.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+	降级模式（所有源都失败时）
+	- 连续 degradeAfterFailures 次拉取失败 => 进入降级：MAJOR 日志 + WS system 事件
+	- 降级期间继续提供缓存的状态 / 区块（status.stale=true + staleAgeSeconds）
+	- 一轮拉取全部失败就已经 stale（staleReason=sources_down），不必等到降级；
+	  只是“没有新块”（高度提示 / 同一块）不算失败，stale=false —— 界面据此区分“链上暂无新块”与“源挂了”
+	  最后一块只在状态里继续提供，不会再次送去判定
+	- 只按 degradeProbeEvery 低频探测，任一次成功即恢复
+	- /api/status：phase（running / fail_wait / paused）、nextRetry（降级时下一次探测时间）、consecutiveFailures
+*/
+
+const (
+	phaseRunning  = "running"
+	phaseFailWait = "fail_wait" // degraded, waiting for the next probe
+	phasePaused   = "paused"    // listener gate closed (no session / nothing to poll)
+)
+
+// Status.StaleReason
+const (
+	staleSourcesDown = "sources_down" // the last fetch cycle failed on every source
+	staleDegraded    = "degraded"
+	staleWarmStart   = "warm_start" // no live block yet, previous run's snapshot
+)
+
+const (
+	degradeAfterFailures = 5
+	degradeProbeEvery    = 10 * time.Second
+
+	topicSystem = "system"
+)
+
+// SystemEvent is pushed on the "system" topic
+type SystemEvent struct {
+	Type       string  `json:"type"` // "degraded"|"recovered"
+	Code       string  `json:"code"` // stable event code (events.go)
+	Reason     string  `json:"reason,omitempty"`
+	Since      string  `json:"since"`
+	AgeSeconds float64 `json:"ageSeconds"` // since the last successful fetch
+	TimeISO    string  `json:"time"`
+}
+
+var (
+	degMu        sync.Mutex
+	degFailures  int
+	degActive    bool
+	degSince     time.Time
+	degLastProbe time.Time
+	degLastOK    time.Time
+)
+
+// degradeShouldFetch rate-limits fetches to slow probes while degraded
+func degradeShouldFetch(now time.Time) bool {
+	degMu.Lock()
+	defer degMu.Unlock()
+	if !degActive {
+		return true
+	}
+	if now.Sub(degLastProbe) < degradeProbeEvery {
+		return false
+	}
+	degLastProbe = now
+	return true
+}
+
+func degradeFailure(err error) {
+	now := time.Now()
+	degMu.Lock()
+	degFailures++
+	enter := !degActive && degFailures >= degradeAfterFailures
+	if enter {
+		degActive, degSince, degLastProbe = true, now, now
+	}
+	first := degFailures == 1
+	ev := degradeEventLocked("degraded", now)
+	degMu.Unlock()
+	if !enter {
+		if first {
+			broadcastStatus() // stale from the first failed cycle
+		}
+		return
+	}
+	ev.Reason = err.Error()
+	logger.Printf("MAJOR DEGRADED_ENTER failures=%d ageSeconds=%.0f err=%v", degradeAfterFailures, ev.AgeSeconds, err)
+	broadcastWS(topicSystem, ev)
+	broadcastStatus()
+}
+
+func degradeSuccess() {
+	now := time.Now()
+	degMu.Lock()
+	recovered, wasStale := degActive, degFailures > 0
+	degFailures = 0
+	ev := degradeEventLocked("recovered", now)
+	degActive = false
+	degLastOK = now
+	degMu.Unlock()
+	if !recovered {
+		if wasStale {
+			broadcastStatus()
+		}
+		return
+	}
+	logger.Printf("DEGRADED_RECOVER downSeconds=%.0f", now.Sub(degSince).Seconds())
+	broadcastWS(topicSystem, ev)
+}
+
+func degradeEventLocked(typ string, now time.Time) SystemEvent {
+	name := "DEGRADED_ENTER"
+	if typ == "recovered" {
+		name = "DEGRADED_RECOVER"
+	}
+	ev := SystemEvent{Type: typ, Code: eventCode(name), Since: isoOrEmpty(degSince), TimeISO: now.UTC().Format(time.RFC3339Nano)}
+	if !degLastOK.IsZero() {
+		ev.AgeSeconds = now.Sub(degLastOK).Seconds()
+	}
+	return ev
+}
+
+// degradeState: stale flag, age of the cached data and why, for status payloads
+func degradeState() (stale bool, ageSeconds float64, reason string) {
+	degMu.Lock()
+	defer degMu.Unlock()
+	switch {
+	case degActive:
+		reason = staleDegraded
+	case degFailures > 0:
+		reason = staleSourcesDown
+	default:
+		return false, 0, ""
+	}
+	if degLastOK.IsZero() {
+		if degActive {
+			return true, time.Since(degSince).Seconds(), reason
+		}
+		return true, 0, reason
+	}
+	return true, time.Since(degLastOK).Seconds(), reason
+}
+
+// degradeRetry: consecutive failed fetch cycles and, while degraded, when the next probe goes out
+func degradeRetry() (failures int, next time.Time) {
+	degMu.Lock()
+	defer degMu.Unlock()
+	if degActive {
+		next = degLastProbe.Add(degradeProbeEvery)
+	}
+	return degFailures, next
+}
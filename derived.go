@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	管理员自定义派生字段（看板要新数字时不用改代码）
+	- derived：[{"name":"onRatio","expr":"today.on / max(today.on + today.off, 1)"}]，
+	  服务端计算后放进 /api/status（以及 SSE / WS 的 status）的 derived 字段
+	- 变量：status 里的数值 / 布尔字段（嵌套用点号，如 today.on、lastShutdown.uptimeSeconds；布尔 = 1/0），
+	  加上 arrival.*（出块到达间隔统计，见 jitter.go）、block.*（最近一块的补充字段，见 enrich.go）；可用变量列表见 GET /api/admin/derived
+	- 表达式：数字、变量、+ - * / %、比较（< <= > >= == !=，结果 1/0）、&& || !、括号、min() max() abs()
+	- 保存时解析并试算（引用了当前不存在的变量只给 warning：值为 0 的字段不出现在 status 里）；运行时变量缺失或结果不是有限数（如除以 0）=> 该字段本次不输出
+	- GET / POST {"name","expr","description"} / DELETE ?name=   最多 derivedMax 个
+	- 本项目没有 Prometheus 输出，派生字段只出现在 status 里
+*/
+
+const (
+	derivedMax     = 32
+	derivedExprMax = 500
+)
+
+type DerivedField struct {
+	Name        string `json:"name"`
+	Expr        string `json:"expr"`
+	Description string `json:"description,omitempty"`
+}
+
+var derivedName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,39}$`)
+
+// derivedExpr evaluates against the variable set; ok=false when a variable is missing
+type derivedExpr func(vars map[string]float64) (float64, bool)
+
+// derivedVars flattens the numeric / boolean leaves of st plus arrival statistics
+func derivedVars(st Status) map[string]float64 {
+	vars := map[string]float64{}
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch x := v.(type) {
+		case map[string]any:
+			for k, c := range x {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, c)
+			}
+		case json.Number:
+			if f, err := x.Float64(); err == nil {
+				vars[prefix] = f
+			}
+		case bool:
+			vars[prefix] = 0
+			if x {
+				vars[prefix] = 1
+			}
+		}
+	}
+	flatten := func(prefix string, v any) {
+		b, _ := json.Marshal(v)
+		dec := json.NewDecoder(strings.NewReader(string(b)))
+		dec.UseNumber()
+		var doc any
+		if dec.Decode(&doc) == nil {
+			walk(prefix, doc)
+		}
+	}
+	st.Derived = nil
+	flatten("", st)
+	flatten("arrival", arrivalSnapshot())
+	flatten("block", enrichSnapshot())
+	return vars
+}
+
+// derivedValues computes every configured field; fields that cannot be computed are left out
+func derivedValues(st Status) map[string]float64 {
+	cfgMu.RLock()
+	defs := append([]DerivedField(nil), cfg.Derived...)
+	cfgMu.RUnlock()
+	if len(defs) == 0 {
+		return nil
+	}
+	vars := derivedVars(st)
+	out := make(map[string]float64, len(defs))
+	for _, d := range defs {
+		e, err := parseDerived(d.Expr)
+		if err != nil {
+			continue
+		}
+		if v, ok := e(vars); ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			out[d.Name] = v
+		}
+	}
+	return out
+}
+
+// ---------- expression parser (recursive descent) ----------
+
+type derivedParser struct {
+	toks []string
+	pos  int
+}
+
+var derivedToken = regexp.MustCompile(`\s*(\d+(?:\.\d+)?(?:[eE][-+]?\d+)?|[A-Za-z_][A-Za-z0-9_.]*|&&|\|\||<=|>=|==|!=|[-+*/%()<>!,])`)
+
+func parseDerived(src string) (derivedExpr, error) {
+	if len(src) > derivedExprMax {
+		return nil, fmt.Errorf("expression longer than %d characters", derivedExprMax)
+	}
+	p := &derivedParser{}
+	rest := src
+	for strings.TrimSpace(rest) != "" {
+		m := derivedToken.FindStringSubmatchIndex(rest)
+		if m == nil || m[0] != 0 {
+			return nil, fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+		}
+		p.toks = append(p.toks, rest[m[2]:m[3]])
+		rest = rest[m[1]:]
+	}
+	if len(p.toks) == 0 {
+		return nil, errors.New("empty expression")
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	return e, nil
+}
+
+// derivedRefs lists the variable names used by src
+func derivedRefs(src string) []string {
+	var out []string
+	toks := derivedToken.FindAllStringSubmatch(src, -1)
+	for i, m := range toks {
+		t := m[1]
+		isCall := derivedFuncs[t] != nil && i+1 < len(toks) && toks[i+1][1] == "("
+		if (t[0] == '_' || (t[0]|0x20 >= 'a' && t[0]|0x20 <= 'z')) && !isCall {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (p *derivedParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *derivedParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func bool01(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// binary builds one precedence level: operand (op operand)*
+func (p *derivedParser) binary(ops map[string]func(a, b float64) float64, operand func() (derivedExpr, error)) (derivedExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		f, ok := ops[p.peek()]
+		if !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(vars map[string]float64) (float64, bool) {
+			a, ok1 := l(vars)
+			b, ok2 := right(vars)
+			return f(a, b), ok1 && ok2
+		}
+	}
+}
+
+func (p *derivedParser) or() (derivedExpr, error) {
+	return p.binary(map[string]func(a, b float64) float64{
+		"||": func(a, b float64) float64 { return bool01(a != 0 || b != 0) },
+	}, p.and)
+}
+
+func (p *derivedParser) and() (derivedExpr, error) {
+	return p.binary(map[string]func(a, b float64) float64{
+		"&&": func(a, b float64) float64 { return bool01(a != 0 && b != 0) },
+	}, p.compare)
+}
+
+func (p *derivedParser) compare() (derivedExpr, error) {
+	return p.binary(map[string]func(a, b float64) float64{
+		"<":  func(a, b float64) float64 { return bool01(a < b) },
+		"<=": func(a, b float64) float64 { return bool01(a <= b) },
+		">":  func(a, b float64) float64 { return bool01(a > b) },
+		">=": func(a, b float64) float64 { return bool01(a >= b) },
+		"==": func(a, b float64) float64 { return bool01(a == b) },
+		"!=": func(a, b float64) float64 { return bool01(a != b) },
+	}, p.sum)
+}
+
+func (p *derivedParser) sum() (derivedExpr, error) {
+	return p.binary(map[string]func(a, b float64) float64{
+		"+": func(a, b float64) float64 { return a + b },
+		"-": func(a, b float64) float64 { return a - b },
+	}, p.product)
+}
+
+func (p *derivedParser) product() (derivedExpr, error) {
+	return p.binary(map[string]func(a, b float64) float64{
+		"*": func(a, b float64) float64 { return a * b },
+		"/": func(a, b float64) float64 { return a / b },
+		"%": math.Mod,
+	}, p.unary)
+}
+
+func (p *derivedParser) unary() (derivedExpr, error) {
+	switch p.peek() {
+	case "-", "!":
+		op := p.next()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(vars map[string]float64) (float64, bool) {
+			v, ok := e(vars)
+			if op == "-" {
+				return -v, ok
+			}
+			return bool01(v == 0), ok
+		}, nil
+	}
+	return p.primary()
+}
+
+var derivedFuncs = map[string]func(args []float64) (float64, bool){
+	"min": func(a []float64) (float64, bool) {
+		if len(a) == 0 {
+			return 0, false
+		}
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m, true
+	},
+	"max": func(a []float64) (float64, bool) {
+		if len(a) == 0 {
+			return 0, false
+		}
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m, true
+	},
+	"abs": func(a []float64) (float64, bool) {
+		if len(a) != 1 {
+			return 0, false
+		}
+		return math.Abs(a[0]), true
+	},
+}
+
+func (p *derivedParser) primary() (derivedExpr, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, errors.New("unexpected end of expression")
+	case t == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing )")
+		}
+		return e, nil
+	case t[0] >= '0' && t[0] <= '9':
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]float64) (float64, bool) { return v, true }, nil
+	case derivedFuncs[t] != nil && p.peek() == "(":
+		p.next()
+		var args []derivedExpr
+		for p.peek() != ")" {
+			a, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if p.peek() == "," {
+				p.next()
+			} else if p.peek() != ")" {
+				return nil, fmt.Errorf("%s(): expected , or )", t)
+			}
+		}
+		p.next()
+		f := derivedFuncs[t]
+		return func(vars map[string]float64) (float64, bool) {
+			vals := make([]float64, len(args))
+			for i, a := range args {
+				v, ok := a(vars)
+				if !ok {
+					return 0, false
+				}
+				vals[i] = v
+			}
+			return f(vals)
+		}, nil
+	case t[0] == '_' || (t[0]|0x20 >= 'a' && t[0]|0x20 <= 'z'):
+		return func(vars map[string]float64) (float64, bool) {
+			v, ok := vars[t]
+			return v, ok
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t)
+}
+
+// ---------- API ----------
+
+// GET    /api/admin/derived                       definitions + current values + variables
+// POST   /api/admin/derived {"name","expr","description"}   add / replace (parsed and test-evaluated)
+// DELETE /api/admin/derived?name=...
+func apiDerived(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		st := currentStatus()
+		vars := derivedVars(st)
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		cfgMu.RLock()
+		defs := append([]DerivedField{}, cfg.Derived...)
+		cfgMu.RUnlock()
+		mustJSON(w, 200, map[string]any{"derived": defs, "values": st.Derived, "variables": names})
+
+	case "POST":
+		var d DerivedField
+		if err := readJSON(r, &d); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.Name, d.Expr, d.Description = strings.TrimSpace(d.Name), strings.TrimSpace(d.Expr), strings.TrimSpace(d.Description)
+		if !derivedName.MatchString(d.Name) {
+			httpError(w, r, "name must start with a letter: A-Z a-z 0-9 _ (max 40)", http.StatusBadRequest)
+			return
+		}
+		e, err := parseDerived(d.Expr)
+		if err != nil {
+			httpError(w, r, "expr: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		vars := derivedVars(currentStatus())
+		v, ok := e(vars)
+
+		cfgMu.Lock()
+		next := make([]DerivedField, 0, len(cfg.Derived)+1)
+		replaced := false
+		for _, old := range cfg.Derived {
+			if old.Name == d.Name {
+				old, replaced = d, true
+			}
+			next = append(next, old)
+		}
+		if !replaced {
+			next = append(next, d)
+		}
+		if len(next) > derivedMax {
+			cfgMu.Unlock()
+			httpError(w, r, fmt.Sprintf("at most %d derived fields", derivedMax), http.StatusBadRequest)
+			return
+		}
+		cfg.Derived = next
+		err = saveConfigLocked(cfg)
+		cfgMu.Unlock()
+		if err != nil {
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		logger.Printf("DERIVED_UPDATED name=%s expr=%q rid=%s", d.Name, d.Expr, requestID(r))
+		out := map[string]any{"ok": true, "name": d.Name}
+		if ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			out["value"] = v
+		}
+		// zero-valued status fields are omitted, so an absent name is only a warning
+		var missing []string
+		for _, ref := range derivedRefs(d.Expr) {
+			if _, known := vars[ref]; !known {
+				missing = append(missing, ref)
+			}
+		}
+		if len(missing) > 0 {
+			out["warning"] = "not present right now (typo, or currently zero and omitted): " + strings.Join(missing, ", ")
+		}
+		mustJSON(w, 200, out)
+
+	case "DELETE":
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		cfgMu.Lock()
+		next := make([]DerivedField, 0, len(cfg.Derived))
+		for _, d := range cfg.Derived {
+			if d.Name != name {
+				next = append(next, d)
+			}
+		}
+		found := len(next) != len(cfg.Derived)
+		var err error
+		if found {
+			cfg.Derived = next
+			err = saveConfigLocked(cfg)
+		}
+		cfgMu.Unlock()
+		if !found {
+			httpError(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		logger.Printf("DERIVED_DELETED name=%s rid=%s", name, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
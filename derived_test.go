@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseDerived(t *testing.T) {
+	vars := map[string]float64{"today.on": 3, "today.off": 1, "zero": 0, "listening": 1}
+	tests := []struct {
+		expr    string
+		want    float64
+		wantOK  bool   // false: a variable is missing at evaluation time
+		wantErr string // parse error
+	}{
+		// precedence and associativity
+		{expr: "1 + 2 * 3", want: 7, wantOK: true},
+		{expr: "(1 + 2) * 3", want: 9, wantOK: true},
+		{expr: "10 - 4 - 3", want: 3, wantOK: true},
+		{expr: "24 / 4 / 2", want: 3, wantOK: true},
+		{expr: "7 % 4 * 2", want: 6, wantOK: true},
+		{expr: "1 + 7 % 4", want: 4, wantOK: true},
+		{expr: "1.5e2 + .5", wantErr: "unexpected"},
+		{expr: "1.5e2 + 0.5", want: 150.5, wantOK: true},
+
+		// unary
+		{expr: "-3 + 5", want: 2, wantOK: true},
+		{expr: "--3", want: 3, wantOK: true},
+		{expr: "-(2 * 3)", want: -6, wantOK: true},
+		{expr: "!0", want: 1, wantOK: true},
+		{expr: "!5", want: 0, wantOK: true},
+		{expr: "!!5", want: 1, wantOK: true},
+		{expr: "!zero == 1", want: 1, wantOK: true}, // ! binds tighter than ==
+
+		// comparisons and logic (1/0)
+		{expr: "today.on > today.off", want: 1, wantOK: true},
+		{expr: "today.on <= 2", want: 0, wantOK: true},
+		{expr: "3 >= 3 && 2 != 2", want: 0, wantOK: true},
+		{expr: "1 < 2 == 1", want: 1, wantOK: true},
+		{expr: "zero || listening", want: 1, wantOK: true},
+		{expr: "1 + 1 == 2 && 2 * 2 == 4", want: 1, wantOK: true},
+
+		// functions and variables
+		{expr: "today.on / max(today.on + today.off, 1)", want: 0.75, wantOK: true},
+		{expr: "min(4, 2, 8)", want: 2, wantOK: true},
+		{expr: "abs(zero - today.on)", want: 3, wantOK: true},
+		{expr: "max", wantOK: false}, // a variable named max
+		{expr: "min()", wantOK: false},
+		{expr: "abs(1, 2)", wantOK: false},
+
+		// missing variables
+		{expr: "nope + 1", wantOK: false},
+		{expr: "today.on + nope * 0", wantOK: false},
+		{expr: "max(nope, 1)", wantOK: false},
+		{expr: "-nope", wantOK: false},
+
+		// parse errors
+		{expr: "", wantErr: "empty"},
+		{expr: "   ", wantErr: "empty"},
+		{expr: "1 +", wantErr: "unexpected end"},
+		{expr: "(1 + 2", wantErr: "missing )"},
+		{expr: "1 + 2)", wantErr: `unexpected ")"`},
+		{expr: "1 2", wantErr: `unexpected "2"`},
+		{expr: "min(1 2)", wantErr: "expected , or )"},
+		{expr: "min(1,", wantErr: "unexpected end"},
+		{expr: "a $ b", wantErr: "unexpected"},
+		{expr: "* 2", wantErr: `unexpected "*"`},
+		{expr: strings.Repeat("1+", derivedExprMax/2) + "1", wantErr: "longer than"},
+	}
+	for _, tt := range tests {
+		e, err := parseDerived(tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		got, ok := e(vars)
+		if ok != tt.wantOK || (ok && math.Abs(got-tt.want) > 1e-9) {
+			t.Errorf("%q = %v, %v; want %v, %v", tt.expr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseDerivedLengthLimit(t *testing.T) {
+	at := strings.Repeat(" ", derivedExprMax-1) + "1"
+	if _, err := parseDerived(at); err != nil {
+		t.Errorf("%d characters: %v", derivedExprMax, err)
+	}
+	if _, err := parseDerived(at + "1"); err == nil {
+		t.Errorf("%d characters: want error", derivedExprMax+1)
+	}
+}
+
+func TestDerivedRefs(t *testing.T) {
+	got := strings.Join(derivedRefs("max(today.on, 1) + min + abs(x) - arrival.p95"), ",")
+	if want := "today.on,min,x,arrival.p95"; got != want {
+		t.Errorf("refs = %s, want %s", got, want)
+	}
+}
+
+func TestDerivedValuesDropsNonFinite(t *testing.T) {
+	cfgMu.Lock()
+	saved := cfg.Derived
+	cfg.Derived = []DerivedField{
+		{Name: "one", Expr: "1"},
+		{Name: "divZero", Expr: "1 / 0"},
+		{Name: "nan", Expr: "0 / 0"},
+		{Name: "missing", Expr: "nope + 1"},
+		{Name: "broken", Expr: "1 +"},
+	}
+	cfgMu.Unlock()
+	t.Cleanup(func() {
+		cfgMu.Lock()
+		cfg.Derived = saved
+		cfgMu.Unlock()
+	})
+	got := derivedValues(Status{})
+	if len(got) != 1 || got["one"] != 1 {
+		t.Errorf("derivedValues = %v, want only one=1", got)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	响应结构自动识别（新建自定义源时预填映射）
+	- POST /api/sources/detect：请求体同 POST /api/sources（url / method / body / headers / apiKey，
+	  映射路径可留空）；请求一次、不保存，遍历响应给出候选：
+	    高度：整数（数字 / 十进制 / 0x 十六进制字符串），键名含 number / height 加分
+	    哈希：64 位十六进制字符串，键名含 hash / blockID 加分；parentHash / *Root / 交易里的字段减分
+	    时间：秒或毫秒级 epoch，键名含 time 加分；timeUnit 只是提示，映射时按数值大小自动识别
+	- 数组只看第一个元素；最多 detectMaxLeaves 个叶子节点
+	- suggested 为各项得分最高且为正分的候选，并用它试映射一次（preview）；识别不出时附带返回内容
+*/
+
+const (
+	detectMaxLeaves = 2000
+	detectMaxDepth  = 10
+	detectTop       = 5
+)
+
+var detectHex64 = regexp.MustCompile(`^(0x|0X)?[0-9a-fA-F]{64}$`)
+
+type DetectCandidate struct {
+	Path     string `json:"path"`
+	Value    any    `json:"value"`
+	Score    int    `json:"score"`
+	TimeUnit string `json:"timeUnit,omitempty"` // ms | s (time candidates)
+}
+
+type detectLeaf struct {
+	path    string
+	key     string // last object key, lower case
+	inArray bool
+	depth   int
+	value   any
+}
+
+// detectLeaves flattens doc into scalar leaves with jsonPathLookup-compatible paths
+func detectLeaves(doc any) []detectLeaf {
+	var out []detectLeaf
+	var walk func(v any, path, key string, inArray bool, depth int)
+	walk = func(v any, path, key string, inArray bool, depth int) {
+		if len(out) >= detectMaxLeaves || depth > detectMaxDepth {
+			return
+		}
+		switch x := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if strings.ContainsAny(k, ".[]") {
+					continue // not addressable with the dotted path syntax
+				}
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				walk(x[k], p, strings.ToLower(k), inArray, depth+1)
+			}
+		case []any:
+			if len(x) > 0 {
+				walk(x[0], path+"[0]", key, true, depth+1)
+			}
+		default:
+			out = append(out, detectLeaf{path: path, key: key, inArray: inArray, depth: depth, value: v})
+		}
+	}
+	walk(doc, "", "", false, 0)
+	return out
+}
+
+// detectPenalty: deeper and array-nested leaves (transactions, logs) are less likely the block header
+func detectPenalty(l detectLeaf) int {
+	p := l.depth / 2
+	if l.inArray && !strings.HasPrefix(l.path, "[") {
+		p += 3
+	}
+	if strings.Contains(strings.ToLower(l.path), "transaction") {
+		p += 3
+	}
+	return p
+}
+
+func detectCandidates(doc any) (height, hash, tm []DetectCandidate) {
+	for _, l := range detectLeaves(doc) {
+		if s, ok := l.value.(string); ok && detectHex64.MatchString(s) {
+			score := 1
+			switch {
+			case l.key == "blockid" || l.key == "hash" || l.key == "blockhash":
+				score += 5
+			case strings.Contains(l.key, "hash") || strings.HasSuffix(l.key, "id"):
+				score += 2
+			}
+			if strings.Contains(l.key, "parent") || strings.HasSuffix(l.key, "root") || strings.Contains(l.key, "tx") {
+				score -= 4
+			}
+			hash = append(hash, DetectCandidate{Path: l.path, Value: s, Score: score - detectPenalty(l)})
+			continue
+		}
+		if _, isBool := l.value.(bool); isBool || l.value == nil {
+			continue
+		}
+		n, err := jsonInt(l.value)
+		if err != nil || n <= 0 {
+			continue
+		}
+		switch {
+		case n >= 1e9 && n < 1e10, n >= 1e12 && n < 1e13: // epoch seconds / milliseconds (2001..2286)
+			unit, score := "s", 1
+			if n >= 1e12 {
+				unit = "ms"
+			}
+			if strings.Contains(l.key, "time") {
+				score += 5
+			}
+			tm = append(tm, DetectCandidate{Path: l.path, Value: l.value, Score: score - detectPenalty(l), TimeUnit: unit})
+		case n < 1e9:
+			score := 0
+			switch {
+			case l.key == "number" || l.key == "height" || l.key == "blocknumber" || l.key == "block_number":
+				score += 5
+			case strings.Contains(l.key, "number") || strings.Contains(l.key, "height") || strings.Contains(l.key, "num"):
+				score += 2
+			}
+			if score == 0 || strings.Contains(l.key, "version") {
+				continue // bare integers (sizes, gas, counts) are noise
+			}
+			height = append(height, DetectCandidate{Path: l.path, Value: l.value, Score: score - detectPenalty(l)})
+		}
+	}
+	top := func(c []DetectCandidate) []DetectCandidate {
+		sort.SliceStable(c, func(i, j int) bool { return c[i].Score > c[j].Score })
+		if len(c) > detectTop {
+			c = c[:detectTop]
+		}
+		return c
+	}
+	return top(height), top(hash), top(tm)
+}
+
+// POST /api/sources/detect -> same body as POST /api/sources; mapping paths may be empty
+func apiSourceDetect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var sc SourceConfig
+	if err := readJSON(r, &sc); err != nil {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sc.Preset != "" {
+		p, ok := findPreset(sc.Preset)
+		if !ok {
+			httpError(w, r, "unknown preset", http.StatusBadRequest)
+			return
+		}
+		sc = applyPreset(sc, p)
+	}
+	// paths are what we are looking for; placeholders keep sanitizeSource happy
+	if strings.TrimSpace(sc.HeightPath) == "" {
+		sc.HeightPath = "$"
+	}
+	if strings.TrimSpace(sc.HashPath) == "" {
+		sc.HashPath = "$"
+	}
+	sc, err := sanitizeSource(sc)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isPushSource(sc) {
+		httpError(w, r, "push sources cannot be sampled with a single request", http.StatusBadRequest)
+		return
+	}
+	cfgMu.RLock()
+	for _, cur := range cfg.Sources {
+		if cur.ID == sc.ID {
+			sc = unredactKeys(sc, cur)
+		}
+	}
+	cfgMu.RUnlock()
+
+	start := time.Now()
+	doc, err := fetchSourceDoc(r.Context(), sourceClient(sc), sc)
+	ms := time.Since(start).Milliseconds()
+	if err != nil {
+		httpError(w, r, "fetch: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	height, hash, tm := detectCandidates(doc)
+	// a best guess that only scored on shape (roots, tx ids) is listed but not suggested
+	suggested := map[string]string{}
+	if len(height) > 0 && height[0].Score > 0 {
+		suggested["heightPath"] = height[0].Path
+	}
+	if len(hash) > 0 && hash[0].Score > 0 {
+		suggested["hashPath"] = hash[0].Path
+	}
+	if len(tm) > 0 && tm[0].Score > 0 {
+		suggested["timePath"], suggested["timeUnit"] = tm[0].Path, tm[0].TimeUnit
+	}
+	var preview map[string]any
+	if suggested["heightPath"] != "" && suggested["hashPath"] != "" {
+		try := sc
+		try.HeightPath, try.HashPath, try.TimePath = suggested["heightPath"], suggested["hashPath"], suggested["timePath"]
+		if h, x, t, err := mapSourceResponse(try, doc); err == nil {
+			preview = map[string]any{"height": h, "hash": x, "time": t}
+		}
+	}
+	resp := map[string]any{"ok": preview != nil, "latencyMs": ms, "suggested": suggested,
+		"candidates": map[string]any{"height": height, "hash": hash, "time": tm}}
+	if preview != nil {
+		resp["preview"] = preview
+	} else {
+		sample, _ := json.Marshal(doc)
+		if len(sample) > 2048 {
+			sample = append(sample[:2048], "..."...)
+		}
+		resp["response"] = string(sample)
+	}
+	logger.Printf("SOURCE_DETECT url=%s height=%s hash=%s time=%s rid=%s", redactURL(sc.URL),
+		suggested["heightPath"], suggested["hashPath"], suggested["timePath"], requestID(r))
+	mustJSON(w, 200, resp)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	启动时的配置校验报告
+	- 未知字段（被静默丢弃）、套用的默认值、被跳过 / 停用的无效条目
+	- GET /api/admin/config/diagnostics
+*/
+
+type ConfigIssue struct {
+	Level   string `json:"level"` // "error"|"warning"|"info"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	diagMu     sync.Mutex
+	diagIssues = []ConfigIssue{}
+	diagAt     string
+)
+
+// diagnoseConfig validates the loaded config and normalizes c in place
+// (rules/apiKeys clamped, invalid sources disabled, bad explorer template cleared)
+func diagnoseConfig(c *Config, loadErr error, path string) []ConfigIssue {
+	issues := []ConfigIssue{}
+	add := func(level, field, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if loadErr != nil {
+		add("error", "", "config unreadable, running on defaults: %v", loadErr)
+	}
+
+	// unknown fields: present (non-zero) on disk but gone after decoding
+	if raw, err := os.ReadFile(path); err == nil && loadErr == nil {
+		var disk any
+		if json.Unmarshal(raw, &disk) == nil {
+			onDisk, effective := map[string]string{}, map[string]string{}
+			flattenJSON("", disk, onDisk)
+			flattenJSON("", toJSONValue(*c), effective)
+			for k, v := range onDisk {
+				if _, ok := effective[k]; ok {
+					continue
+				}
+				switch v {
+				case `""`, "0", "false", "null":
+					continue
+				}
+				add("warning", strings.TrimPrefix(k, "."), "unknown field dropped")
+			}
+		}
+	}
+
+	// defaults applied
+	defaults := []struct {
+		field         string
+		before, after any
+	}{
+		{"retention", c.Retention, c.Retention.withDefaults()},
+		{"watchdog", c.Watchdog, c.Watchdog.withDefaults()},
+		{"audit.intervalSeconds", c.Audit.IntervalSeconds, c.Audit.withDefaults().IntervalSeconds},
+		{"backfill.maxBlocks", c.Backfill.MaxBlocks, c.Backfill.withDefaults().MaxBlocks},
+	}
+	for _, d := range defaults {
+		for _, line := range diffJSON(d.field, d.before, d.after) {
+			kv := strings.SplitN(line, ": ", 2)
+			add("info", kv[0], "default applied: %s", kv[1])
+		}
+	}
+	if len(c.Listeners) == 0 {
+		add("info", "listeners", "default applied: single %s admin listener", listenAddr)
+	} else if n := len(normalizeListeners(c.Listeners)); n != len(c.Listeners) {
+		add("warning", "listeners", "%d listener(s) skipped (empty or duplicate addr)", len(c.Listeners)-n)
+	}
+
+	// normalized the same way the admin API would
+	if keys := sanitizeAPIKeys(c.APIKeys); len(keys) != len(c.APIKeys) {
+		add("warning", "apiKeys", "%d of %d keys ignored (empty, duplicate or over the limit of 3)", len(c.APIKeys)-len(keys), len(c.APIKeys))
+		c.APIKeys = keys
+	}
+	if rr := sanitizeRules(c.Rules); rr != c.Rules {
+		for _, line := range diffJSON("rules", c.Rules, rr) {
+			kv := strings.SplitN(line, ": ", 2)
+			if strings.HasPrefix(kv[1], "0 ->") || strings.HasPrefix(kv[1], `"" ->`) {
+				add("info", kv[0], "default applied: %s", kv[1])
+			} else {
+				add("warning", kv[0], "out of range, clamped: %s", kv[1])
+			}
+		}
+		c.Rules = rr
+	}
+	if n := clampConfirmations(c.Confirmations); n != c.Confirmations {
+		add("warning", "confirmations", "out of range, clamped: %d -> %d", c.Confirmations, n)
+		c.Confirmations = n
+	}
+	if t := c.Tuning.BaseTickMS; t != 0 && (t < tickMinMS || t > tickMaxMS) {
+		c.Tuning.BaseTickMS = clampInt(t, tickMinMS, tickMaxMS)
+		add("warning", "tuning.baseTickMs", "out of range, clamped: %d -> %d", t, c.Tuning.BaseTickMS)
+	}
+	if s := c.Tuning.Strategy; !validStrategy(s) {
+		c.Tuning.Strategy = ""
+		add("warning", "tuning.strategy", "unknown strategy %q, using race", s)
+	}
+	if n := c.Consensus.MinAgree; n < 0 || n > consensusMaxAgree {
+		c.Consensus.MinAgree = clampInt(n, 0, consensusMaxAgree)
+		add("warning", "consensus.minAgree", "out of range, clamped: %d -> %d", n, c.Consensus.MinAgree)
+	}
+	if err := validateLabels(c.Labels); err != nil {
+		add("error", "labels", "%v; instance labels dropped", err)
+		c.Labels = nil
+	}
+	if err := validateHashChecks(c.HashChecks); err != nil {
+		add("error", "hashChecks", "%v; hash checks disabled", err)
+		c.HashChecks = HashCheckConfig{}
+	}
+	if err := validateExecutorSim(c.Executor); err != nil && c.Executor.Enabled {
+		add("error", "executorSim", "%v; executor simulator disabled", err)
+		c.Executor.Enabled = false
+	}
+	if err := validateExplorerTemplate(c.Explorer.BlockURL); err != nil {
+		add("error", "explorer.blockUrl", "%v; deep links disabled", err)
+		c.Explorer.BlockURL = ""
+	}
+	for name := range c.Features {
+		if _, ok := findFeature(name); !ok {
+			add("warning", "features."+name, "unknown feature flag ignored")
+		}
+	}
+
+	// invalid sources are disabled rather than polled into an error loop
+	for i, sc := range c.Sources {
+		field := fmt.Sprintf("sources[%d]", i)
+		if sc.ID == "" {
+			add("warning", field+".id", "missing id")
+		}
+		if _, err := sanitizeSource(sc); err != nil {
+			if sc.Enabled {
+				c.Sources[i].Enabled = false
+				add("error", field, "invalid (%v); source disabled", err)
+			} else {
+				add("warning", field, "invalid (%v)", err)
+			}
+		}
+	}
+	for i, d := range c.Derived {
+		if _, err := parseDerived(d.Expr); err != nil || !derivedName.MatchString(d.Name) {
+			add("warning", fmt.Sprintf("derived[%d]", i), "invalid name or expression (%v); not computed", err)
+		}
+	}
+	if n := c.Consensus.MinAgree; n > 1 {
+		enabled := 0
+		for _, sc := range c.Sources {
+			if sc.Enabled && sc.ID != "" {
+				enabled++
+			}
+		}
+		if n > enabled {
+			add("error", "consensus.minAgree", "needs %d agreeing sources but only %d enabled; no block can be accepted", n, enabled)
+		}
+	}
+	if sameNode(c.Audit.NodeURL, defaultNodeURL) && c.Audit.NodeURL != "" {
+		add("warning", "audit.nodeUrl", "same node as the default source; TronGrid blocks will not be audited")
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return levelRank(issues[i].Level) < levelRank(issues[j].Level) })
+	return issues
+}
+
+func levelRank(l string) int {
+	switch l {
+	case "error":
+		return 0
+	case "warning":
+		return 1
+	}
+	return 2
+}
+
+// diagnoseRuntime adds checks that need subsystems initialised (GeoIP, simulator)
+func diagnoseRuntime(c Config) []ConfigIssue {
+	var issues []ConfigIssue
+	if strings.TrimSpace(c.GeoIP.MMDBPath) != "" && !geoEnabled() {
+		issues = append(issues, ConfigIssue{Level: "error", Field: "geoip.mmdbPath", Message: "database failed to load; GeoIP gate disabled"})
+	}
+	if c.Simulator.Enabled && !simulatorActive() {
+		issues = append(issues, ConfigIssue{Level: "error", Field: "simulator.scenario", Message: "scenario failed to load; simulator off"})
+	}
+	for i, sc := range c.Sources {
+		for _, n := range secretRefs(sc) {
+			if !secretExists(n) {
+				issues = append(issues, ConfigIssue{Level: "warning", Field: fmt.Sprintf("sources[%d]", i),
+					Message: fmt.Sprintf("secret %s not set; requests from %s will fail", n, sc.ID)})
+			}
+		}
+	}
+	return issues
+}
+
+func setDiagnostics(issues []ConfigIssue) {
+	diagMu.Lock()
+	diagIssues = issues
+	diagAt = time.Now().UTC().Format(time.RFC3339)
+	diagMu.Unlock()
+
+	n := map[string]int{}
+	for _, is := range issues {
+		n[is.Level]++
+		if is.Level == "error" {
+			logger.Printf("CONFIG_INVALID field=%s msg=%q", is.Field, is.Message)
+		}
+	}
+	logger.Printf("CONFIG_DIAGNOSTICS errors=%d warnings=%d info=%d", n["error"], n["warning"], n["info"])
+}
+
+// GET /api/admin/config/diagnostics
+func apiConfigDiagnostics(w http.ResponseWriter, r *http.Request) {
+	diagMu.Lock()
+	out := map[string]any{"checkedAt": diagAt, "issues": append([]ConfigIssue{}, diagIssues...)}
+	diagMu.Unlock()
+	mustJSON(w, 200, out)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	公共 TRON 节点自动发现 + 基准测试（给不熟悉节点的用户做初始配置）
+	- 对内置的公共节点列表各请求 discoverSamples 次，测本机延迟 / 成功率
+	- 一致性：相对最高高度的落后块数；同高度 hash 与多数不一致记为 forked
+	- 一键把表现最好的几个写成 Config.Sources
+	- POST ?async=1 作为后台任务运行（jobs.go），进度按已测完的节点数
+*/
+
+const (
+	discoverSamples = 3
+	discoverGap     = 400 * time.Millisecond
+)
+
+type publicEndpoint struct {
+	Name   string
+	Preset string
+	URL    string
+}
+
+// curated, keyless public endpoints
+var publicEndpoints = []publicEndpoint{
+	{Name: "trongrid-public", Preset: "trongrid", URL: "https://api.trongrid.io/wallet/getnowblock"},
+	{Name: "publicnode", Preset: "tron-fullnode", URL: "https://tron-rpc.publicnode.com/wallet/getnowblock"},
+	{Name: "tronstack", Preset: "tron-fullnode", URL: "https://api.tronstack.io/wallet/getnowblock"},
+	{Name: "ankr-public-rpc", Preset: "ankr-evm-rpc", URL: "https://rpc.ankr.com/tron_jsonrpc"},
+}
+
+type DiscoverResult struct {
+	Name      string  `json:"name"`
+	Preset    string  `json:"preset"`
+	URL       string  `json:"url"`
+	OK        int     `json:"ok"`
+	Samples   int     `json:"samples"`
+	LatencyMS float64 `json:"latencyMs"` // median of successful samples
+	Height    int64   `json:"height"`    // last height seen
+	LagBlocks int64   `json:"lagBlocks"` // behind the best endpoint
+	Forked    bool    `json:"forked"`    // hash disagreed with the majority at the same height
+	LastError string  `json:"lastError,omitempty"`
+	Score     float64 `json:"score"` // higher is better
+}
+
+var (
+	discoverMu   sync.Mutex
+	discoverLast []DiscoverResult
+	discoverAt   string
+	discoverBusy bool
+)
+
+func discoverSource(ep publicEndpoint) SourceConfig {
+	p, _ := findPreset(ep.Preset)
+	// keyless: {apiKey} headers render empty and are not sent
+	return applyPreset(SourceConfig{Name: ep.Name, URL: ep.URL}, p)
+}
+
+// runDiscovery probes every public endpoint concurrently; progress (may be nil) is
+// called as endpoints finish, a cancelled ctx stops sampling
+func runDiscovery(ctx context.Context, progress func(done, total int)) []DiscoverResult {
+	client := &http.Client{Timeout: 6 * time.Second}
+	var doneMu sync.Mutex
+	done := 0
+	results := make([]DiscoverResult, len(publicEndpoints))
+	hashes := make([]map[int64]string, len(publicEndpoints))
+
+	var wg sync.WaitGroup
+	for i, ep := range publicEndpoints {
+		wg.Add(1)
+		go func(i int, ep publicEndpoint) {
+			defer wg.Done()
+			defer func() {
+				if progress != nil {
+					doneMu.Lock()
+					done++
+					progress(done, len(publicEndpoints))
+					doneMu.Unlock()
+				}
+			}()
+			sc := discoverSource(ep)
+			res := DiscoverResult{Name: ep.Name, Preset: ep.Preset, URL: ep.URL, Samples: discoverSamples}
+			seen := map[int64]string{}
+			var lat []float64
+			for n := 0; n < discoverSamples && ctx.Err() == nil; n++ {
+				if n > 0 {
+					time.Sleep(discoverGap)
+				}
+				start := time.Now()
+				h, hash, _, err := fetchSource(ctx, client, sc)
+				if err != nil {
+					res.LastError = err.Error()
+					continue
+				}
+				res.OK++
+				lat = append(lat, float64(time.Since(start).Microseconds())/1000)
+				res.Height = h
+				seen[h] = strings.ToLower(hash)
+			}
+			if len(lat) > 0 {
+				sort.Float64s(lat)
+				res.LatencyMS = lat[len(lat)/2]
+			}
+			results[i], hashes[i] = res, seen
+		}(i, ep)
+	}
+	wg.Wait()
+
+	// consistency: lag vs best height, hash vs majority at shared heights
+	var best int64
+	votes := map[int64]map[string]int{}
+	for i := range results {
+		if results[i].Height > best {
+			best = results[i].Height
+		}
+		for h, x := range hashes[i] {
+			if votes[h] == nil {
+				votes[h] = map[string]int{}
+			}
+			votes[h][x]++
+		}
+	}
+	for i := range results {
+		r := &results[i]
+		if r.OK == 0 {
+			continue
+		}
+		r.LagBlocks = best - r.Height
+		for h, x := range hashes[i] {
+			for other, n := range votes[h] {
+				if other != x && n > votes[h][x] {
+					r.Forked = true
+				}
+			}
+		}
+		// success first, then freshness, then speed
+		r.Score = float64(r.OK)/float64(r.Samples)*100 - float64(r.LagBlocks)*5 - r.LatencyMS/100
+		if r.Forked {
+			r.Score -= 100
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+// GET  /api/sources/discover -> last benchmark
+// POST /api/sources/discover -> run benchmark now (takes a few seconds; ?async=1 runs it as a job)
+func apiDiscover(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		discoverMu.Lock()
+		out := map[string]any{"checkedAt": discoverAt, "results": discoverLast, "running": discoverBusy}
+		discoverMu.Unlock()
+		mustJSON(w, 200, out)
+	case "POST":
+		if !featureOn(featSourceDiscovery) {
+			httpError(w, r, "feature "+featSourceDiscovery+" is off", http.StatusConflict)
+			return
+		}
+		discoverMu.Lock()
+		if discoverBusy {
+			discoverMu.Unlock()
+			httpError(w, r, "discovery already running", http.StatusConflict)
+			return
+		}
+		discoverBusy = true
+		discoverMu.Unlock()
+
+		rid := requestID(r)
+		run := func(ctx context.Context, progress func(done, total int)) map[string]any {
+			res := runDiscovery(ctx, progress)
+			at := time.Now().UTC().Format(time.RFC3339)
+			discoverMu.Lock()
+			if ctx.Err() == nil {
+				discoverLast, discoverAt = res, at
+			}
+			discoverBusy = false
+			discoverMu.Unlock()
+			logger.Printf("SOURCE_DISCOVERY endpoints=%d best=%s rid=%s", len(res), discoverBest(res), rid)
+			return map[string]any{"ok": true, "checkedAt": at, "results": res}
+		}
+		if asyncRequested(r) {
+			acceptJob(w, startJob("discover", rid, func(ctx context.Context, j *jobHandle) (any, error) {
+				out := run(ctx, func(done, total int) { j.progress(done, total, "endpoints probed") })
+				return out, ctx.Err()
+			}))
+			return
+		}
+		mustJSON(w, 200, run(context.Background(), nil))
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+func discoverBest(res []DiscoverResult) string {
+	if len(res) == 0 || res[0].OK == 0 {
+		return "-"
+	}
+	return res[0].Name
+}
+
+// POST /api/sources/discover/adopt {"top":2} or {"names":["publicnode"]}
+// creates enabled source configs from the last benchmark (existing URLs are skipped)
+func apiDiscoverAdopt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Top   int      `json:"top"`
+		Names []string `json:"names"`
+	}
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	discoverMu.Lock()
+	last := append([]DiscoverResult(nil), discoverLast...)
+	discoverMu.Unlock()
+	if len(last) == 0 {
+		httpError(w, r, "run discovery first", http.StatusConflict)
+		return
+	}
+
+	var pick []DiscoverResult
+	if len(req.Names) > 0 {
+		for _, res := range last {
+			for _, n := range req.Names {
+				if strings.EqualFold(res.Name, strings.TrimSpace(n)) {
+					pick = append(pick, res)
+				}
+			}
+		}
+	} else {
+		top := req.Top
+		if top <= 0 {
+			top = 2
+		}
+		top = clamp(top, 1, len(last))
+		for _, res := range last[:top] {
+			if res.OK > 0 && !res.Forked {
+				pick = append(pick, res)
+			}
+		}
+	}
+	if len(pick) == 0 {
+		httpError(w, r, "no healthy endpoint selected", http.StatusBadRequest)
+		return
+	}
+
+	cfgMu.Lock()
+	var created []SourceConfig
+	for _, res := range pick {
+		dup := false
+		for _, cur := range cfg.Sources {
+			dup = dup || strings.EqualFold(cur.URL, res.URL)
+		}
+		if dup {
+			continue
+		}
+		sc := discoverSource(publicEndpoint{Name: res.Name, Preset: res.Preset, URL: res.URL})
+		sc.Enabled = true
+		id, _ := randHex(4)
+		sc.ID = "src-" + id
+		cfg.Sources = append(cfg.Sources, sc)
+		created = append(created, sc)
+	}
+	if len(created) > 0 {
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	cfgMu.Unlock()
+
+	for _, sc := range created {
+		logger.Printf("SOURCE_UPSERT id=%s name=%q preset=%s enabled=true via=discovery rid=%s", sc.ID, sc.Name, sc.Preset, requestID(r))
+	}
+	tryStartListener()
+	if created == nil {
+		created = []SourceConfig{}
+	}
+	mustJSON(w, 200, map[string]any{"ok": true, "created": created})
+}
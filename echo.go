@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+/*
+	WS 心跳回声（客户端可选，用来区分“信号晚到”是服务端慢还是网络慢）
+	- 客户端发 {"type":"echo","id":"任意","clientTs":毫秒,"rttMs":上一次测得的往返}
+	- 服务端立即回 {"type":"echo","id":...,"clientTs":...,"serverTs":毫秒}
+	  （订阅了 topics 的客户端收到信封 {"topic":"echo","data":{...}}，不占用序号）
+	- 客户端用 收到时间 − clientTs 得到往返时间，下一次回声带上 rttMs；
+	  serverTs − clientTs − rttMs/2 为客户端时钟偏差（正数 = 客户端时钟慢）
+	- GET /api/admin/ws/clients 每个客户端的 rtt 字段：最近一次、最小、平滑均值、样本数、时钟偏差
+	- 每个连接最多 echoMinGap 一次，过快的回声直接忽略
+*/
+
+const (
+	topicEcho  = "echo"
+	echoMinGap = 500 * time.Millisecond
+	echoMaxRTT = 60000 // ms; larger reports are ignored
+)
+
+type echoMsg struct {
+	Type     string  `json:"type"`
+	ID       string  `json:"id,omitempty"`
+	ClientTs int64   `json:"clientTs"`
+	ServerTs int64   `json:"serverTs,omitempty"`
+	RTTMS    float64 `json:"rttMs,omitempty"`
+}
+
+// EchoRTT is the per-client round trip summary (ms)
+type EchoRTT struct {
+	LastMS   float64 `json:"lastMs"`
+	MinMS    float64 `json:"minMs"`
+	AvgMS    float64 `json:"avgMs"` // EWMA, alpha 0.2
+	Samples  int     `json:"samples"`
+	SkewMS   float64 `json:"skewMs"` // server clock - client clock, from the last sample
+	LastEcho string  `json:"lastEcho"`
+}
+
+// echoState lives on wsConn, guarded by wsMu
+type echoState struct {
+	last     time.Time
+	prevSent int64 // serverTs - clientTs of the previous reply
+	rtt      EchoRTT
+}
+
+// handleClientEcho answers {"type":"echo"} and records the RTT the client reports
+func handleClientEcho(c *wsConn, payload []byte) {
+	var m echoMsg
+	if json.Unmarshal(payload, &m) != nil || m.Type != topicEcho {
+		return
+	}
+	now := time.Now()
+	wsMu.Lock()
+	st := &c.echo
+	if now.Sub(st.last) < echoMinGap {
+		wsMu.Unlock()
+		return
+	}
+	st.last = now
+	if m.RTTMS > 0 && m.RTTMS <= echoMaxRTT && st.rtt.LastEcho != "" {
+		r := &st.rtt
+		r.LastMS = m.RTTMS
+		if r.Samples == 0 || m.RTTMS < r.MinMS {
+			r.MinMS = m.RTTMS
+		}
+		if r.Samples == 0 {
+			r.AvgMS = m.RTTMS
+		} else {
+			r.AvgMS += 0.2 * (m.RTTMS - r.AvgMS)
+		}
+		r.Samples++
+		r.SkewMS = float64(st.prevSent) - m.RTTMS/2
+	}
+	reply := echoMsg{Type: topicEcho, ID: m.ID, ClientTs: m.ClientTs, ServerTs: now.UnixMilli()}
+	st.prevSent = reply.ServerTs - m.ClientTs
+	st.rtt.LastEcho = now.UTC().Format(time.RFC3339Nano)
+	envelope := c.envelope
+	wsMu.Unlock()
+
+	var b []byte
+	if envelope {
+		b, _ = json.Marshal(wsEnvelope{Topic: topicEcho, Data: reply})
+	} else {
+		b, _ = json.Marshal(reply)
+	}
+	c.mu.Lock()
+	err := wsWriteText(c.c, b)
+	c.mu.Unlock()
+	if err != nil {
+		c.Close()
+		return
+	}
+	c.sent.Add(uint64(len(b)))
+}
+
+// echoSummary: nil until the client reported at least one RTT (caller holds wsMu)
+func echoSummary(c *wsConn) *EchoRTT {
+	if c.echo.rtt.Samples == 0 {
+		return nil
+	}
+	r := c.echo.rtt
+	return &r
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	一个源配置多个端点（同一服务商的备用域名 / 多个自建节点）
+	- urls：url 之后按顺序尝试的备用端点（最多 endpointsMax 个），{apiKey} / {{secret:...}} 规则与 url 相同
+	- 连接失败（DNS、拒绝连接、TLS 握手、超时）=> 同一次请求里立即换下一个端点，全部失败才算这个源失败；
+	  HTTP 错误状态码、响应解析失败不换端点（节点在线，问题不在连通性）
+	- 记住最后成功的端点，后续请求先走它；停留在备用端点超过 endpointRetry 后先试一次主端点
+	- 推送源：订阅连接建立失败时同样依次尝试
+	- 按高度查询（byNumUrl）只用自己的地址；urls 不能与 dns.pinIp 同时使用
+	- 诊断（/api/sources/test、compare）用 probeSourceDoc：按当前端点顺序尝试，但不记录切换、不写日志
+	- 切换写 SOURCE_ENDPOINT_FAILOVER；GET /api/sources 的 endpoints 字段：每个源当前使用的端点序号（0 = url）
+*/
+
+const (
+	endpointsMax  = 5
+	endpointRetry = 5 * time.Minute
+)
+
+type endpointState struct {
+	active int
+	since  time.Time // on active since (or since the last primary retry)
+}
+
+var (
+	epMu     sync.Mutex
+	epStates = map[string]*endpointState{}
+)
+
+// connError marks a request that never reached the node; only these move to the next endpoint
+type connError struct{ err error }
+
+func (e *connError) Error() string { return e.err.Error() }
+func (e *connError) Unwrap() error { return e.err }
+
+func sourceEndpoints(sc SourceConfig) []string {
+	return append([]string{sc.URL}, sc.URLs...)
+}
+
+func validateEndpoints(sc *SourceConfig) error {
+	var urls []string
+	for _, raw := range sc.URLs {
+		if raw = strings.TrimSpace(raw); raw != "" {
+			urls = append(urls, raw)
+		}
+	}
+	sc.URLs = urls
+	if len(urls) == 0 {
+		return nil
+	}
+	if len(urls) > endpointsMax {
+		return fmt.Errorf("at most %d extra urls", endpointsMax)
+	}
+	if sc.DNS != nil && sc.DNS.PinIP != "" {
+		return errors.New("urls cannot be combined with dns.pinIp")
+	}
+	schemes := []string{"http", "https"}
+	if sc.Kind == sourceKindPush {
+		schemes = []string{"ws", "wss"}
+	}
+	for i, raw := range urls {
+		u, err := url.Parse(strings.ReplaceAll(raw, "{apiKey}", "k"))
+		if err != nil || (u.Scheme != schemes[0] && u.Scheme != schemes[1]) || u.Host == "" {
+			return fmt.Errorf("urls[%d] must be a %s(s) URL", i, schemes[0])
+		}
+		if strings.Contains(raw, "<") {
+			return fmt.Errorf("urls[%d] still contains a <placeholder>", i)
+		}
+	}
+	return nil
+}
+
+// endpointOrder: indexes to try for this request, the last good one first
+func endpointOrder(sc SourceConfig, n int, now time.Time) []int {
+	epMu.Lock()
+	defer epMu.Unlock()
+	st := epStates[sc.ID]
+	start := 0
+	if st != nil && st.active < n {
+		start = st.active
+		if start != 0 && now.Sub(st.since) > endpointRetry {
+			start, st.since = 0, now // give the primary another chance
+		}
+	}
+	order := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		order = append(order, (start+i)%n)
+	}
+	return order
+}
+
+// probeSourceDoc walks the endpoints like fetchSourceDoc, starting from the
+// active one, but records nothing: diagnostics must not move the live poller
+func probeSourceDoc(ctx context.Context, client *http.Client, sc SourceConfig) (any, error) {
+	eps := sourceEndpoints(sc)
+	if len(eps) == 1 {
+		return fetchEndpointDoc(ctx, client, sc)
+	}
+	start := activeEndpoint(sc.ID, len(eps))
+	var errs []error
+	for i := range eps {
+		q := sc
+		q.URL = eps[(start+i)%len(eps)]
+		doc, err := fetchEndpointDoc(ctx, client, q)
+		var ce *connError
+		if !errors.As(err, &ce) || ctx.Err() != nil {
+			return doc, err
+		}
+		errs = append(errs, fmt.Errorf("endpoint %d: %w", (start+i)%len(eps), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// activeEndpoint: index requests currently start from; read-only (no primary retry)
+func activeEndpoint(id string, n int) int {
+	epMu.Lock()
+	defer epMu.Unlock()
+	if st := epStates[id]; st != nil && st.active < n {
+		return st.active
+	}
+	return 0
+}
+
+// endpointUsed records the endpoint that reached the node
+func endpointUsed(id string, idx int, cause error) {
+	epMu.Lock()
+	defer epMu.Unlock()
+	st := epStates[id]
+	if st == nil {
+		st = &endpointState{}
+		epStates[id] = st
+	}
+	if st.active == idx {
+		return
+	}
+	msg := ""
+	if cause != nil {
+		msg = cause.Error()
+	}
+	logger.Printf("SOURCE_ENDPOINT_FAILOVER id=%s from=%d to=%d err=%q", id, st.active, idx, msg)
+	st.active, st.since = idx, time.Now()
+}
+
+// fetchSourceDoc runs the request against the source's endpoints until one is reachable
+func fetchSourceDoc(ctx context.Context, client *http.Client, sc SourceConfig) (any, error) {
+	eps := sourceEndpoints(sc)
+	if len(eps) == 1 {
+		return fetchEndpointDoc(ctx, client, sc)
+	}
+	var errs []error
+	var last error
+	for _, idx := range endpointOrder(sc, len(eps), time.Now()) {
+		q := sc
+		q.URL = eps[idx]
+		doc, err := fetchEndpointDoc(ctx, client, q)
+		if ctx.Err() != nil {
+			return nil, err // cancelled by the caller: no verdict on this endpoint
+		}
+		var ce *connError
+		if errors.As(err, &ce) {
+			errs = append(errs, fmt.Errorf("endpoint %d: %w", idx, err))
+			last = err
+			continue
+		}
+		endpointUsed(sc.ID, idx, last)
+		return doc, err
+	}
+	return nil, errors.Join(errs...)
+}
+
+func endpointSnapshot() map[string]int {
+	epMu.Lock()
+	defer epMu.Unlock()
+	out := make(map[string]int, len(epStates))
+	for id, st := range epStates {
+		out[id] = st.active
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointFailoverState(t *testing.T) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0) // SOURCE_ENDPOINT_FAILOVER
+	}
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close() // connection refused from here on
+	live := fakeNode{height: 101, hash: "bb"}.source(t, "live", 0)
+
+	tests := []struct {
+		name       string
+		fetch      func(context.Context, *http.Client, SourceConfig) (any, error)
+		wantActive int // -1: no state recorded
+	}{
+		{"probe records nothing", probeSourceDoc, -1},
+		{"fetch switches to the fallback", fetchSourceDoc, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := SourceConfig{ID: "ep/" + tt.name, Method: "POST", URL: deadURL, URLs: []string{live.URL}, HeightPath: "n", HashPath: "h"}
+			t.Cleanup(func() { resetSourceState(sc.ID) })
+			doc, err := tt.fetch(context.Background(), sourceClient(sc), sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h, _, _, err := mapSourceResponse(sc, doc); err != nil || h != 101 {
+				t.Fatalf("height %d, %v", h, err)
+			}
+			epMu.Lock()
+			st := epStates[sc.ID]
+			epMu.Unlock()
+			got := -1
+			if st != nil {
+				got = st.active
+			}
+			if got != tt.wantActive {
+				t.Errorf("active endpoint %d, want %d", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestSourceEndpointFingerprint(t *testing.T) {
+	base := SourceConfig{ID: "s", Method: "POST", URL: "https://a.example/x", URLs: []string{"https://b.example/x"}, HeightPath: "n", HashPath: "h"}
+	tests := []struct {
+		name      string
+		edit      func(sc *SourceConfig)
+		wantReset bool
+	}{
+		{"url", func(sc *SourceConfig) { sc.URL = "https://c.example/x" }, true},
+		{"fallback url", func(sc *SourceConfig) { sc.URLs = []string{"https://c.example/x"} }, true},
+		{"fallback added", func(sc *SourceConfig) { sc.URLs = append(sc.URLs, "https://c.example/x") }, true},
+		{"fallbacks removed", func(sc *SourceConfig) { sc.URLs = nil }, true},
+		{"mapping only", func(sc *SourceConfig) { sc.HeightPath = "m" }, false},
+		{"priority only", func(sc *SourceConfig) { sc.Priority = 2 }, false},
+	}
+	for _, tt := range tests {
+		cur := base
+		cur.URLs = append([]string(nil), base.URLs...)
+		tt.edit(&cur)
+		if got := sourceEndpoint(cur) != sourceEndpoint(base); got != tt.wantReset {
+			t.Errorf("%s: reset %v, want %v", tt.name, got, tt.wantReset)
+		}
+	}
+}
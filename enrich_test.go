@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnrichFetch(t *testing.T) {
+	var gotKey, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, gotPath = r.Header.Get("X-Key"), r.URL.Path
+		fmt.Fprint(w, `{"fee":7,"txs":[1,2,3],"meta":{"a":1},"name":"blk"}`)
+	}))
+	defer srv.Close()
+
+	keys := []string{"key-one", "key-two"}
+	sc := SourceConfig{
+		ID: "enrich/test", Method: "POST", URL: srv.URL, APIKey: keys[0], APIKeys: keys[1:],
+		Headers: map[string]string{"X-Key": "{apiKey}"},
+		Enrich: &SourceEnrich{URL: srv.URL + "/info/{height}", Fields: map[string]string{
+			"fee": "fee", "txCount": "txs", "meta": "meta", "name": "name", "missing": "nope",
+		}},
+	}
+	t.Cleanup(func() {
+		kpMu.Lock()
+		delete(kpPools, sc.ID)
+		kpMu.Unlock()
+	})
+	pickKey(sc.ID, keys, keyRotationRR, time.Now()) // the live poller has used the pool
+
+	usage := func() map[string]uint64 {
+		kpMu.Lock()
+		defer kpMu.Unlock()
+		out := map[string]uint64{}
+		for k, u := range kpPools[sc.ID].usage {
+			out[k] = u.Requests
+		}
+		return out
+	}
+	before := usage()
+
+	// the test path: the key the poller would use next, without taking it
+	key := peekKey(sc.ID, keys, keyRotationRR, time.Now())
+	fields, err := enrichFetch(sc, key, 42, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"fee": "7", "txCount": 3, "name": "blk"}
+	for k, v := range fields {
+		fields[k] = fmt.Sprint(v)
+	}
+	for k, v := range want {
+		want[k] = fmt.Sprint(v)
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if gotKey != key || gotPath != "/info/42" {
+		t.Errorf("request key %q path %q, want %q /info/42", gotKey, gotPath, key)
+	}
+	if after := usage(); !reflect.DeepEqual(after, before) {
+		t.Errorf("key pool changed by a test fetch: %v -> %v", before, after)
+	}
+	if next := pickKey(sc.ID, keys, keyRotationRR, time.Now()); next != key {
+		t.Errorf("live rotation moved: next key %q, peeked %q", next, key)
+	}
+}
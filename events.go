@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/*
+	日志事件目录（稳定事件码）
+	- 每个日志事件名（行首 UPPER_SNAKE token）对应一个稳定码，如 SRC-001 / RUN-002
+	- 文案可以改，码不变；下游按 code= 解析，不再依赖文本
+	- 码由 eventWriter 自动注入日志行；WS system 事件同样带 code
+	- 码一经发布不得复用或改义，新事件只追加
+*/
+
+type EventDef struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Level       string `json:"level"` // "major"|"warn"|"info"
+	Description string `json:"description"`
+}
+
+var eventCatalog = []EventDef{
+	// SYS: process lifecycle
+	{"SYS-001", "SYSTEM_START", "info", "process started"},
+	{"SYS-002", "SYSTEM_STOP", "info", "clean shutdown, report written"},
+	{"SYS-003", "ABNORMAL_RESTART", "warn", "previous run left running.lock behind"},
+	{"SYS-004", "LAST_SHUTDOWN", "info", "previous clean shutdown report loaded"},
+	{"SYS-005", "SHUTDOWN_REPORT_ERROR", "warn", "could not write last_shutdown.json"},
+	{"SYS-006", "HTTP_LISTEN", "info", "HTTP listener bound"},
+	{"SYS-007", "SERVER_ERROR", "warn", "HTTP listener exited"},
+	{"SYS-008", "SYSTEM_SETUP_DONE", "info", "initial admin account created"},
+	{"SYS-009", "WARM_START", "info", "last known good block loaded for the status page"},
+	{"SYS-010", "RECOVERY_LISTEN", "info", "loopback recovery listener started"},
+	{"SYS-011", "RECOVERY_DISABLED", "info", "loopback recovery listener turned off by config"},
+	{"SYS-012", "RECOVERY_ERROR", "warn", "loopback recovery listener could not start"},
+	{"SYS-013", "ACCESS_LOG_ERROR", "warn", "combined-format access log file could not be opened"},
+
+	// CFG: configuration
+	{"CFG-001", "CONFIG_LOAD_ERROR", "warn", "config.json unreadable, defaults used"},
+	{"CFG-002", "CONFIG_INVALID", "warn", "startup diagnostics found an error"},
+	{"CFG-003", "CONFIG_DIAGNOSTICS", "info", "startup diagnostics summary"},
+	{"CFG-004", "RELOAD", "info", "component config reloaded"},
+	{"CFG-005", "RULES_UPDATED", "info", "state machine rules changed"},
+	{"CFG-006", "APIKEYS_UPDATED", "info", "TronGrid API keys changed"},
+	{"CFG-007", "FEATURES_UPDATED", "info", "feature flags changed"},
+	{"CFG-008", "EXPLORER_UPDATED", "info", "explorer link template changed"},
+	{"CFG-009", "CONFIRMATIONS_UPDATED", "info", "confirmation depth changed"},
+	{"CFG-010", "TUNING_UPDATED", "info", "listener tick or source interval changed at runtime"},
+	{"CFG-011", "CONSENSUS_UPDATED", "info", "cross-source consensus quorum changed"},
+	{"CFG-012", "HASH_CHECKS_UPDATED", "info", "hash quality checks changed"},
+	{"CFG-013", "LABELS_UPDATED", "info", "instance labels changed"},
+	{"CFG-014", "POWER_SAVE_UPDATED", "info", "idle power saving settings changed"},
+	{"CFG-015", "SIGNING_UPDATED", "info", "signal signing toggled or key rotated"},
+	{"CFG-016", "REPLAY_GUARD_UPDATED", "info", "admin replay protection settings changed"},
+	{"CFG-017", "TOKEN_RATE_UPDATED", "info", "consumer token rate limit settings changed"},
+	{"CFG-018", "PREFS_UPDATED", "info", "operator saved web panel preferences"},
+	{"CFG-019", "SECRET_UPDATED", "info", "secret added or rotated; push sources using it reconnect"},
+	{"CFG-020", "SECRET_DELETED", "info", "secret removed from the store"},
+	{"CFG-021", "DERIVED_UPDATED", "info", "derived status field added or changed"},
+	{"CFG-022", "DERIVED_DELETED", "info", "derived status field removed"},
+
+	// SRC: block sources
+	{"SRC-001", "RATE_PROFILE_SWITCH", "info", "source rate profile changed"},
+	{"SRC-002", "BLOCK_FETCH_ERROR", "warn", "block fetch failed"},
+	{"SRC-003", "SOURCE_UPSERT", "info", "source added or updated"},
+	{"SRC-004", "SOURCE_DELETE", "info", "source deleted"},
+	{"SRC-005", "SOURCE_DISCOVERY", "info", "public endpoint discovery finished"},
+	{"SRC-006", "PUSH_SOURCE_START", "info", "push subscription started"},
+	{"SRC-007", "PUSH_SOURCE_STOP", "info", "push subscription stopped"},
+	{"SRC-008", "PUSH_SOURCE_ERROR", "warn", "push subscription failed, retrying"},
+	{"SRC-009", "PUSH_SOURCE_DROP", "warn", "pushed block dropped, listener busy"},
+	{"SRC-010", "DEGRADED_ENTER", "major", "all sources failing, serving stale data"},
+	{"SRC-011", "DEGRADED_RECOVER", "info", "fetch succeeded after degraded mode"},
+	{"SRC-012", "BACKFILL_DONE", "info", "missed heights fetched and judged"},
+	{"SRC-013", "BACKFILL_SKIPPED", "warn", "height gap too large to backfill"},
+	{"SRC-014", "BACKFILL_UNAVAILABLE", "warn", "no source can fetch blocks by height"},
+	{"SRC-015", "BACKFILL_ERROR", "warn", "missed height could not be fetched"},
+	{"SRC-016", "CONSENSUS_CONFLICT", "warn", "sources reported different hashes for one height"},
+	{"SRC-017", "SOURCE_HEALTH_DEGRADED", "warn", "source scored low or flapped, skipped for a while"},
+	{"SRC-018", "SOURCE_HEALTH_RESTORED", "info", "degraded source back in rotation"},
+	{"SRC-019", "BREAKER_OPEN", "warn", "source circuit opened after consecutive errors"},
+	{"SRC-020", "BREAKER_HALF_OPEN", "info", "source circuit letting one probe through"},
+	{"SRC-021", "BREAKER_CLOSED", "info", "source circuit closed, probe succeeded"},
+	{"SRC-022", "RATE_ADAPT", "info", "adaptive limiter changed a source's rate"},
+	{"SRC-023", "SOURCE_BACKOFF", "info", "transient source error, next attempt delayed"},
+	{"SRC-024", "SOURCE_TLS_ERROR", "warn", "source TLS files could not be loaded, requests fail"},
+	{"SRC-025", "RATE_OVERRIDE_SET", "info", "temporary source rate override applied"},
+	{"SRC-026", "RATE_OVERRIDE_EXPIRED", "info", "temporary source rate override ran out, config rate back"},
+	{"SRC-027", "RATE_OVERRIDE_CLEARED", "info", "temporary source rate override removed by an admin"},
+	{"SRC-028", "SOURCES_RELOADED", "info", "source edit applied live; per-source state reset for changed endpoints"},
+	{"SRC-029", "SOURCE_QUOTA_WARNING", "warn", "source used most of its daily / monthly request budget"},
+	{"SRC-030", "SOURCE_QUOTA_EXHAUSTED", "warn", "source request budget used up, skipped until the next day / month"},
+	{"SRC-031", "SOURCE_DETECT", "info", "response mapping suggested from a sample request"},
+	{"SRC-032", "SOURCE_MAINTENANCE_START", "info", "source entered a scheduled maintenance window and is skipped"},
+	{"SRC-033", "SOURCE_MAINTENANCE_END", "info", "source left its maintenance window and is used again"},
+	{"SRC-034", "SOURCE_RATE_LIMITED", "warn", "provider answered 429 (or 503 with Retry-After); paused for exactly the Retry-After when given"},
+	{"SRC-035", "SOURCE_PHASE_LOCKED", "info", "phase-mode source learned when blocks appear; polls now follow the 3s cadence"},
+	{"SRC-036", "SOURCE_ENDPOINT_FAILOVER", "warn", "source switched endpoint after a connection failure (or back to its primary)"},
+	{"SRC-037", "BLOCK_ENRICH_FAILED", "warn", "source enrich request failed; block processed without extra fields"},
+	{"SRC-038", "RACE_SECOND_MISMATCH", "warn", "race runner-up returned a different hash at the winning height (tuning.raceKeepSecond)"},
+	{"SRC-039", "STICKY_SOURCE_SELECTED", "info", "sticky strategy: race winner is now polled alone"},
+	{"SRC-040", "STICKY_SOURCE_RELEASED", "info", "sticky source failed, became unavailable or slowed down; next tick races again"},
+
+	// RUN: listener and state machine
+	{"RUN-001", "LISTENER_LOOP_START", "info", "listener loop started"},
+	{"RUN-002", "LISTENER_LOOP_STOP", "info", "listener loop stopped"},
+	{"RUN-003", "DROP_BLOCK_INVALID_HASH", "warn", "block dropped, hash unusable"},
+	{"RUN-004", "HIT_ARMED", "info", "HIT waiting for base+offset"},
+	{"RUN-005", "HIT_MISS", "info", "HIT target block did not match"},
+	{"RUN-006", "LISTENER_TICK_RESET", "info", "listener tick applied without restart"},
+	{"RUN-007", "HASH_QUARANTINED", "warn", "block hash failed quality checks, held for review"},
+	{"RUN-008", "POWER_MODE", "info", "polling switched between active and idle"},
+	{"RUN-009", "WATCH_ONLY", "info", "no ON/OFF rule enabled, state machine idle"},
+	{"RUN-010", "JOB_STARTED", "info", "long-running operation started as a background job"},
+	{"RUN-011", "JOB_FINISHED", "info", "background job ended (done / failed / cancelled)"},
+	{"RUN-012", "JOB_CANCELLED", "info", "background job cancelled by an operator"},
+	{"RUN-013", "JUDGE_DEBOUNCED", "info", "short ON/OFF flip smoothed away before the state machine"},
+	{"RUN-014", "BLOCK_ARRIVAL_STALL", "major", "no new block for alertFactor x cadence; chain halt or stuck feed"},
+	{"RUN-015", "BLOCK_ARRIVAL_RESUMED", "info", "new block arrived after an arrival stall"},
+
+	// SIG: emitted signals
+	{"SIG-001", "ON_SIGNAL", "info", "ON signal emitted"},
+	{"SIG-002", "OFF_SIGNAL", "info", "OFF signal emitted"},
+	{"SIG-003", "HIT_SIGNAL", "info", "HIT signal emitted"},
+
+	// WS: clients
+	{"WS-001", "WS_CLIENT_CONNECTED", "info", "WebSocket client connected"},
+	{"WS-002", "WS_CLIENT_DISCONNECTED", "info", "WebSocket client disconnected"},
+
+	// SEC: auth and access
+	{"SEC-001", "AUTH_FAIL", "warn", "login failed"},
+	{"SEC-002", "AUTH_BAN", "warn", "IP banned after repeated failures"},
+	{"SEC-003", "AUTH_UNBAN", "info", "IP ban lifted"},
+	{"SEC-004", "ADMIN_RATE_LIMITED", "warn", "admin API request rate limited"},
+	{"SEC-005", "TOKEN_PROVISIONED", "info", "access token issued"},
+	{"SEC-006", "GEO_BLOCKED", "info", "request rejected by GeoIP gate"},
+	{"SEC-007", "GEOIP_LOADED", "info", "GeoIP database loaded"},
+	{"SEC-008", "GEOIP_LOAD_ERROR", "warn", "GeoIP database failed to load"},
+	{"SEC-009", "REPLAY_REJECTED", "warn", "admin mutation refused: missing, stale, replayed or bad signature"},
+	{"SEC-010", "TOKENS_BULK_PROVISIONED", "info", "batch of named access tokens issued or imported"},
+	{"SEC-011", "TOKEN_RATE_WARNING", "info", "consumer token nearing its per-minute request limit; clients notified"},
+	{"SEC-012", "TOKEN_RATE_LIMITED", "warn", "consumer token over its per-minute request limit (429)"},
+	{"SEC-013", "RECOVERY_ACTION", "warn", "lockout recovery operation run from the loopback listener"},
+
+	// DAT: persistence and housekeeping
+	{"DAT-001", "DAILY_LOAD_ERROR", "warn", "daily counters unreadable"},
+	{"DAT-002", "DAILY_SAVE_ERROR", "warn", "daily counters not saved"},
+	{"DAT-003", "DAILY_ROLLOVER", "info", "daily counters rolled over"},
+	{"DAT-004", "RETENTION_RECLAIMED", "info", "retention removed old files"},
+	{"DAT-005", "WATCHDOG_RSS_HIGH", "major", "memory above watchdog limit"},
+	{"DAT-006", "WATCHDOG_RSS_RECOVERED", "info", "memory back under watchdog limit"},
+	{"DAT-007", "WATCHDOG_DISK_LOW", "major", "free disk below watchdog limit"},
+	{"DAT-008", "WATCHDOG_DISK_RECOVERED", "info", "free disk back above watchdog limit"},
+	{"DAT-009", "WATCHDOG_PERSISTENCE", "warn", "persistence paused or resumed"},
+	{"DAT-010", "WATCHDOG_EMERGENCY_COMPACT", "warn", "emergency compaction ran"},
+	{"DAT-011", "INBOX_SAVE_ERROR", "warn", "notification inbox not saved"},
+	{"DAT-012", "INBOX_READ", "info", "inbox items marked as read"},
+	{"DAT-013", "INBOX_LOAD_ERROR", "warn", "notification inbox unreadable, starting empty"},
+	{"DAT-014", "ACCESS_LOAD_ERROR", "warn", "access analytics unreadable, starting empty"},
+	{"DAT-015", "ACCESS_SAVE_ERROR", "warn", "access analytics not saved"},
+	{"DAT-016", "LAST_GOOD_SAVE_ERROR", "warn", "last known good snapshot could not be written"},
+	{"DAT-017", "HISTORY_IMPORTED", "info", "daily counters and signal history merged from an export"},
+	{"DAT-018", "SIGNING_KEY_ERROR", "warn", "signing key unreadable, signal sent unsigned"},
+	{"DAT-019", "QUOTA_LOAD_ERROR", "warn", "source quota usage unreadable, counting from zero"},
+	{"DAT-020", "QUOTA_SAVE_ERROR", "warn", "source quota usage not saved"},
+	{"DAT-021", "SECRETS_LOAD_ERROR", "error", "secrets.json unreadable; {{secret:...}} references fail"},
+	{"DAT-022", "ROLLUP_LOAD_ERROR", "warn", "stats rollups unreadable; tiers start empty"},
+	{"DAT-023", "ROLLUP_SAVE_ERROR", "warn", "stats rollups not saved"},
+
+	// AUD: judge audit
+	{"AUD-001", "AUDIT_MISMATCH", "major", "judged block differs from audit node"},
+	{"AUD-002", "AUDIT_ERROR", "warn", "audit sample could not be checked"},
+
+	// TST: simulator and chaos
+	{"TST-001", "SIM_LOADED", "info", "simulator scenario loaded"},
+	{"TST-002", "SIM_LOAD_ERROR", "warn", "simulator scenario failed to load"},
+	{"TST-003", "SIM_DONE", "info", "simulator scenario finished"},
+	{"TST-004", "SIM_STOPPED", "info", "simulator stopped"},
+	{"TST-005", "CHAOS_ENABLED", "warn", "chaos injection enabled"},
+	{"TST-006", "CHAOS_SET", "info", "chaos settings changed"},
+	{"TST-007", "CHAOS_CLEARED", "info", "chaos injection cleared"},
+	{"TST-008", "CHAOS_GAP_START", "info", "chaos block gap started"},
+	{"TST-009", "EXECUTOR_SIM_UPDATED", "info", "executor simulator settings changed"},
+}
+
+var eventCodes = func() map[string]string {
+	m := make(map[string]string, len(eventCatalog))
+	for _, e := range eventCatalog {
+		m[e.Name] = e.Code
+	}
+	return m
+}()
+
+func eventCode(name string) string { return eventCodes[name] }
+
+// eventWriter appends "code=XXX-nnn" to each log line with a known event token
+// and hands inbox-worthy events to the notification inbox (inbox.go):
+// "2006/01/02 15:04:05.000000 [MAJOR ]EVENT_NAME ..." -> "... EVENT_NAME ... code=SRC-010".
+// The text before it stays as written, so line-prefix matchers such as the
+// fail2ban failregex "AUTH_FAIL ip=<HOST>" keep working.
+type eventWriter struct{ w io.Writer }
+
+func (ew eventWriter) Write(p []byte) (int, error) {
+	line, ok := withEventCode(p)
+	if !ok {
+		return ew.w.Write(p)
+	}
+	if _, err := ew.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func withEventCode(p []byte) ([]byte, bool) {
+	// skip date and time fields
+	i := 0
+	for f := 0; f < 2; f++ {
+		j := bytes.IndexByte(p[i:], ' ')
+		if j < 0 {
+			return nil, false
+		}
+		i += j + 1
+	}
+	msgStart := i
+	major := bytes.HasPrefix(p[i:], []byte("MAJOR "))
+	if major {
+		i += len("MAJOR ")
+	}
+	end := i
+	for end < len(p) && (p[end] == '_' || (p[end] >= 'A' && p[end] <= 'Z') || (p[end] >= '0' && p[end] <= '9')) {
+		end++
+	}
+	event := string(p[i:end])
+	code := eventCode(event)
+	if code == "" {
+		return nil, false
+	}
+	inboxCapture(event, major, strings.TrimSpace(string(p[msgStart:])))
+	body := bytes.TrimRight(p, "\n")
+	out := make([]byte, 0, len(p)+len(code)+7)
+	out = append(out, body...)
+	out = append(out, " code="...)
+	out = append(out, code...)
+	out = append(out, p[len(body):]...)
+	return out, true
+}
+
+// GET /api/events/catalog[?prefix=SRC]
+func apiEventCatalog(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("prefix")))
+	out := []EventDef{}
+	for _, e := range eventCatalog {
+		if prefix == "" || strings.HasPrefix(e.Code, prefix) {
+			out = append(out, e)
+		}
+	}
+	mustJSON(w, 200, map[string]any{"events": out})
+}
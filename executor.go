@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	下游执行器模拟（端到端演练）
+	- 进程内订阅信号流：每个广播出去的信号都交给模拟执行器
+	- 按配置的人工延迟（latencyMs ± jitterMs）回执，按 failRate 概率模拟执行失败
+	- 统计往返时间（信号广播 → 模拟回执）p50/p90/p99 与成功 / 失败次数
+	- 不接触真实执行系统；默认关闭，配置即时生效
+*/
+
+const (
+	execQueueSize = 256
+	execRecentMax = 20
+)
+
+type ExecutorSimConfig struct {
+	Enabled   bool    `json:"enabled"`
+	LatencyMS int     `json:"latencyMs"`
+	JitterMS  int     `json:"jitterMs"`
+	FailRate  float64 `json:"failRate"` // 0..1
+}
+
+type ExecResult struct {
+	Type    string  `json:"type"`
+	Height  int64   `json:"height"`
+	OK      bool    `json:"ok"`
+	RTTMS   float64 `json:"rttMs"`
+	Error   string  `json:"error,omitempty"`
+	TimeISO string  `json:"time"`
+}
+
+type ExecStats struct {
+	Received uint64       `json:"received"`
+	Acked    uint64       `json:"acked"`
+	Failed   uint64       `json:"failed"`
+	Dropped  uint64       `json:"dropped"` // queue full
+	RTT      LatencyStats `json:"rtt"`
+	Recent   []ExecResult `json:"recent"` // newest first
+}
+
+type execJob struct {
+	s    Signal
+	sent time.Time
+}
+
+var (
+	execC = make(chan execJob, execQueueSize)
+
+	execMu     sync.Mutex
+	execRing   latencyRing
+	execStats  = ExecStats{Recent: []ExecResult{}}
+	execRandMu sync.Mutex
+	execRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+var errExecSimulated = errors.New("simulated execution failure")
+
+func executorSettings() ExecutorSimConfig {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Executor
+}
+
+func validateExecutorSim(ec ExecutorSimConfig) error {
+	if ec.LatencyMS < 0 || ec.LatencyMS > 60000 || ec.JitterMS < 0 || ec.JitterMS > 60000 {
+		return errors.New("latencyMs/jitterMs must be 0..60000")
+	}
+	if ec.FailRate < 0 || ec.FailRate > 1 {
+		return errors.New("failRate must be 0..1")
+	}
+	return nil
+}
+
+// executorOffer is the simulator's subscription to the signal stream
+func executorOffer(s Signal) {
+	if !executorSettings().Enabled {
+		return
+	}
+	select {
+	case execC <- execJob{s: s, sent: time.Now()}:
+	default:
+		execMu.Lock()
+		execStats.Dropped++
+		execMu.Unlock()
+	}
+}
+
+func executorLoop() {
+	for job := range execC {
+		go executeSimulated(job, executorSettings())
+	}
+}
+
+func executeSimulated(job execJob, ec ExecutorSimConfig) {
+	execRandMu.Lock()
+	delay := time.Duration(ec.LatencyMS) * time.Millisecond
+	if ec.JitterMS > 0 {
+		delay += time.Duration(execRand.Intn(2*ec.JitterMS+1)-ec.JitterMS) * time.Millisecond
+	}
+	fail := execRand.Float64() < ec.FailRate
+	execRandMu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	rtt := time.Since(job.sent)
+	res := ExecResult{
+		Type:    job.s.Type,
+		Height:  job.s.Height,
+		OK:      !fail,
+		RTTMS:   float64(rtt.Microseconds()) / 1000,
+		TimeISO: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if fail {
+		res.Error = errExecSimulated.Error()
+	}
+
+	execMu.Lock()
+	defer execMu.Unlock()
+	execStats.Received++
+	if fail {
+		execStats.Failed++
+	} else {
+		execStats.Acked++
+		execRing.add(res.RTTMS)
+	}
+	execStats.Recent = append([]ExecResult{res}, execStats.Recent...)
+	if len(execStats.Recent) > execRecentMax {
+		execStats.Recent = execStats.Recent[:execRecentMax]
+	}
+}
+
+func executorSnapshot() ExecStats {
+	execMu.Lock()
+	defer execMu.Unlock()
+	st := execStats
+	st.RTT = execRing.stats()
+	st.Recent = append([]ExecResult{}, execStats.Recent...)
+	return st
+}
+
+// GET    /api/admin/executor -> config + round-trip stats
+// POST   /api/admin/executor {"enabled":true,"latencyMs":50,"jitterMs":20,"failRate":0.05}
+// DELETE /api/admin/executor -> reset stats
+func apiExecutor(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"config": executorSettings(), "stats": executorSnapshot()})
+	case "POST":
+		var ec ExecutorSimConfig
+		if err := readJSON(r, &ec); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateExecutorSim(ec); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.Executor = ec
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		logger.Printf("EXECUTOR_SIM_UPDATED enabled=%v latencyMs=%d jitterMs=%d failRate=%.3f rid=%s",
+			ec.Enabled, ec.LatencyMS, ec.JitterMS, ec.FailRate, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "config": ec})
+	case "DELETE":
+		execMu.Lock()
+		execRing = latencyRing{}
+		execStats = ExecStats{Recent: []ExecResult{}}
+		execMu.Unlock()
+		mustJSON(w, 200, map[string]any{"ok": true})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/*
+	运行时功能开关（按实例）
+	- 新的高风险子系统先挂在开关后面，逐步开启、出问题可即时关闭，无需重新部署
+	- Config.Features 只保存与默认值不同的覆盖项
+*/
+
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Default     bool   `json:"default"`
+	Description string `json:"description"`
+}
+
+const (
+	featGenericSources  = "generic-sources"
+	featJudgeAudit      = "judge-audit"
+	featSourceDiscovery = "source-discovery"
+	featPushSources     = "push-sources"
+	featGapBackfill     = "gap-backfill"
+	featSourceHealth    = "source-health"
+	featSourceBreaker   = "source-breaker"
+)
+
+var featureFlags = []FeatureFlag{
+	{Name: featGenericSources, Default: true, Description: "poll Config.Sources instead of TronGrid + apiKeys when any are enabled"},
+	{Name: featJudgeAudit, Default: true, Description: "periodic judge sampling audit against audit.nodeUrl"},
+	{Name: featSourceDiscovery, Default: true, Description: "allow probing public endpoints from /api/sources/discover"},
+	{Name: featPushSources, Default: false, Description: "run kind=push WebSocket subscriptions alongside polled sources"},
+	{Name: featGapBackfill, Default: true, Description: "fetch skipped heights by number when the chain height jumps"},
+	{Name: featSourceHealth, Default: true, Description: "skip polled sources whose rolling health score drops or that flap"},
+	{Name: featSourceBreaker, Default: true, Description: "open a per-source circuit after consecutive errors, retry with half-open probes"},
+	{Name: featAccessLogCLF, Default: false, Description: "write every HTTP request to logs/YYYY-MM-DD.access.log in Apache Combined Log Format"},
+}
+
+func findFeature(name string) (FeatureFlag, bool) {
+	for _, f := range featureFlags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FeatureFlag{}, false
+}
+
+// featureOn: config override, else the flag's default (unknown flags are off)
+func featureOn(name string) bool {
+	f, ok := findFeature(name)
+	if !ok {
+		return false
+	}
+	cfgMu.RLock()
+	v, set := cfg.Features[name]
+	cfgMu.RUnlock()
+	if set {
+		return v
+	}
+	return f.Default
+}
+
+type FeatureState struct {
+	FeatureFlag
+	Enabled bool `json:"enabled"`
+}
+
+func featureStates() []FeatureState {
+	out := make([]FeatureState, 0, len(featureFlags))
+	for _, f := range featureFlags {
+		out = append(out, FeatureState{FeatureFlag: f, Enabled: featureOn(f.Name)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// GET  /api/admin/features
+// POST /api/admin/features {"judge-audit": false, ...}  (applies immediately)
+func apiFeatures(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"features": featureStates()})
+	case "POST":
+		var in map[string]bool
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		for name := range in {
+			if _, ok := findFeature(name); !ok {
+				httpError(w, r, "unknown feature: "+name, http.StatusBadRequest)
+				return
+			}
+		}
+
+		cfgMu.Lock()
+		if cfg.Features == nil {
+			cfg.Features = map[string]bool{}
+		}
+		var changes []string
+		for name, on := range in {
+			f, _ := findFeature(name)
+			if on == f.Default {
+				delete(cfg.Features, name) // back to default
+			} else {
+				cfg.Features[name] = on
+			}
+			changes = append(changes, name+"="+boolStr(on))
+		}
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+
+		sort.Strings(changes)
+		logger.Printf("FEATURES_UPDATED %s rid=%s", strings.Join(changes, " "), requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "features": featureStates()})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+	GeoIP（可选）：读取本地 MaxMind MMDB（GeoLite2-Country / City 均可）
+	- 纯标准库实现 MMDB 格式解析，只取 country.iso_code
+	- 未配置 mmdbPath 时完全关闭
+	- 损坏的文件：截断 => 报错；嵌套 / 指针链深度上限 mmdbMaxDepth（指针环不会爆栈）
+*/
+
+type GeoIPConfig struct {
+	MMDBPath       string   `json:"mmdbPath"`
+	AllowCountries []string `json:"allowCountries"` // non-empty: only these ISO codes pass the external gate
+	BlockCountries []string `json:"blockCountries"`
+}
+
+var (
+	geoMu sync.RWMutex
+	geoDB *mmdbReader
+)
+
+var mmdbMetaMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+type mmdbReader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+}
+
+func openMMDB(path string) (*mmdbReader, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.LastIndex(b, mmdbMetaMarker)
+	if i < 0 {
+		return nil, errors.New("mmdb: metadata marker not found")
+	}
+	metaStart := uint(i + len(mmdbMetaMarker))
+	d := mmdbDecoder{buf: b[metaStart:]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb: metadata: %w", err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("mmdb: bad metadata")
+	}
+
+	r := &mmdbReader{buf: b}
+	r.nodeCount = mmdbUint(meta["node_count"])
+	r.recordSize = mmdbUint(meta["record_size"])
+	r.ipVersion = mmdbUint(meta["ip_version"])
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("mmdb: unsupported record size %d", r.recordSize)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	r.dataStart = treeSize + 16
+	if r.dataStart > metaStart {
+		return nil, errors.New("mmdb: corrupt search tree size")
+	}
+
+	// IPv4 lookups in an IPv6 tree start after 96 zero bits
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+func (r *mmdbReader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// country returns the ISO code for ip ("" if not found)
+func (r *mmdbReader) country(ip net.IP) (string, error) {
+	var key []byte
+	node := uint(0)
+	if v4 := ip.To4(); v4 != nil {
+		key = v4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.ipVersion == 4 {
+			return "", nil
+		}
+		key = ip.To16()
+	}
+
+	for i := 0; i < len(key)*8 && node < r.nodeCount; i++ {
+		bit := uint(key[i>>3]>>(7-uint(i&7))) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return "", nil
+	}
+	if node < r.nodeCount {
+		return "", errors.New("mmdb: invalid tree")
+	}
+	off := node - r.nodeCount - 16
+	d := mmdbDecoder{buf: r.buf[r.dataStart:]}
+	v, _, err := d.decode(off)
+	if err != nil {
+		return "", err
+	}
+	rec, _ := v.(map[string]any)
+	for _, k := range []string{"country", "registered_country"} {
+		if c, ok := rec[k].(map[string]any); ok {
+			if iso, ok := c["iso_code"].(string); ok && iso != "" {
+				return iso, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// mmdbMaxDepth bounds nesting (maps, arrays and pointers); a pointer cycle in a
+// corrupt file hits it instead of recursing until the stack overflows
+const mmdbMaxDepth = 64
+
+type mmdbDecoder struct {
+	buf   []byte
+	depth int // decode calls in progress
+}
+
+func (d *mmdbDecoder) byteAt(off uint) (byte, error) {
+	if off >= uint(len(d.buf)) {
+		return 0, errors.New("mmdb: unexpected end of data")
+	}
+	return d.buf[off], nil
+}
+
+func (d *mmdbDecoder) slice(off, n uint) ([]byte, error) {
+	if off+n > uint(len(d.buf)) {
+		return nil, errors.New("mmdb: unexpected end of data")
+	}
+	return d.buf[off : off+n], nil
+}
+
+// left: bytes after off, an upper bound on the entries a map or array can hold
+func (d *mmdbDecoder) left(off uint) uint {
+	if off >= uint(len(d.buf)) {
+		return 0
+	}
+	return uint(len(d.buf)) - off
+}
+
+// decode returns the value at off and the offset right after it
+func (d *mmdbDecoder) decode(off uint) (any, uint, error) {
+	if d.depth >= mmdbMaxDepth {
+		return nil, 0, errors.New("mmdb: data nested too deep")
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	ctrl, err := d.byteAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	off++
+	typ := uint(ctrl >> 5)
+
+	if typ == 1 { // pointer
+		ss := uint(ctrl>>3) & 3
+		vvv := uint(ctrl & 7)
+		b, err := d.slice(off, ss+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		var p uint
+		switch ss {
+		case 0:
+			p = vvv<<8 | uint(b[0])
+		case 1:
+			p = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 2:
+			p = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		default:
+			p = uint(binary.BigEndian.Uint32(b))
+		}
+		v, _, err := d.decode(p)
+		return v, off + ss + 1, err
+	}
+
+	if typ == 0 { // extended
+		x, err := d.byteAt(off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off++
+		typ = 7 + uint(x)
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		b, err := d.slice(off, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		off += n
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	switch typ {
+	case 2: // utf8 string
+		b, err := d.slice(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return string(b), off + size, nil
+	case 3: // double
+		b, err := d.slice(off, 8)
+		if err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), off + 8, nil
+	case 4: // bytes
+		b, err := d.slice(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return b, off + size, nil
+	case 5, 6, 9, 10: // uint16/32/64/128 (128 truncated; unused for country)
+		b, err := d.slice(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		return v, off + size, nil
+	case 8: // int32
+		b, err := d.slice(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		var v uint32
+		for _, x := range b {
+			v = v<<8 | uint32(x)
+		}
+		return int64(int32(v)), off + size, nil
+	case 7: // map
+		m := make(map[string]any, min(size, d.left(off))) // size comes from the file
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			ks, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("mmdb: non-string map key")
+			}
+			v, next2, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[ks] = v
+			off = next2
+		}
+		return m, off, nil
+	case 11: // array
+		a := make([]any, 0, min(size, d.left(off)))
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			off = next
+		}
+		return a, off, nil
+	case 14: // boolean (value in size)
+		return size != 0, off, nil
+	case 15: // float
+		b, err := d.slice(off, 4)
+		if err != nil {
+			return nil, 0, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), off + 4, nil
+	default:
+		return nil, 0, fmt.Errorf("mmdb: unsupported data type %d", typ)
+	}
+}
+
+func mmdbUint(v any) uint {
+	switch x := v.(type) {
+	case uint64:
+		return uint(x)
+	case int64:
+		return uint(x)
+	}
+	return 0
+}
+
+// ---------- helpers used by the auth gate / logs ----------
+
+func loadGeoIP(path string) {
+	path = strings.TrimSpace(path)
+	geoMu.Lock()
+	defer geoMu.Unlock()
+	geoDB = nil
+	if path == "" {
+		return
+	}
+	r, err := openMMDB(path)
+	if err != nil {
+		logger.Printf("GEOIP_LOAD_ERROR path=%s err=%v", path, err)
+		return
+	}
+	geoDB = r
+	logger.Printf("GEOIP_LOADED path=%s nodes=%d", path, r.nodeCount)
+}
+
+func geoEnabled() bool {
+	geoMu.RLock()
+	defer geoMu.RUnlock()
+	return geoDB != nil
+}
+
+// geoCountry: ISO code for remoteAddr, "" when disabled/unknown
+func geoCountry(remoteAddr string) string {
+	geoMu.RLock()
+	db := geoDB
+	geoMu.RUnlock()
+	if db == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	c, err := db.country(ip)
+	if err != nil {
+		return ""
+	}
+	return c
+}
+
+// geoAllowed applies allow/block lists; unknown country fails a non-empty allowlist
+func geoAllowed(country string, gc GeoIPConfig) bool {
+	for _, b := range gc.BlockCountries {
+		if country != "" && strings.EqualFold(strings.TrimSpace(b), country) {
+			return false
+		}
+	}
+	if len(gc.AllowCountries) == 0 {
+		return true
+	}
+	for _, a := range gc.AllowCountries {
+		if country != "" && strings.EqualFold(strings.TrimSpace(a), country) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mmdb data section encoding helpers (control byte: type<<5 | size)
+func mmStr(s string) []byte { return append([]byte{0x40 | byte(len(s))}, s...) }
+
+func mmMap(kv ...[]byte) []byte {
+	return append([]byte{0xE0 | byte(len(kv)/2)}, bytes.Join(kv, nil)...)
+}
+
+func mmArray(items ...[]byte) []byte {
+	return append([]byte{byte(len(items)), 11 - 7}, bytes.Join(items, nil)...)
+}
+
+func mmUint16(v uint16) []byte { return []byte{0xA2, byte(v >> 8), byte(v)} }
+
+func TestMMDBDecode(t *testing.T) {
+	nested := func(depth int) []byte {
+		b := mmStr("x")
+		for i := 0; i < depth; i++ {
+			b = mmArray(b)
+		}
+		return b
+	}
+	tests := []struct {
+		name    string
+		buf     []byte
+		off     uint
+		want    any
+		wantErr string
+	}{
+		{name: "string", buf: mmStr("DE"), want: "DE"},
+		{name: "map", buf: mmMap(mmStr("iso_code"), mmStr("DE")), want: map[string]any{"iso_code": "DE"}},
+		{name: "uint16", buf: mmUint16(443), want: uint64(443)},
+		{name: "bool", buf: []byte{0x01, 14 - 7}, want: true},
+		{name: "pointer", buf: append(mmStr("DE"), 0x20, 0x00), off: 3, want: "DE"},
+		{name: "nested within limit", buf: nested(10), want: nil},
+
+		{name: "empty", buf: nil, wantErr: "unexpected end"},
+		{name: "truncated string", buf: mmStr("DE")[:2], wantErr: "unexpected end"},
+		{name: "truncated map value", buf: mmMap(mmStr("iso_code"), mmStr("DE"))[:10], wantErr: "unexpected end"},
+		{name: "truncated size", buf: []byte{0x5D}, wantErr: "unexpected end"},
+		{name: "truncated pointer", buf: []byte{0x28, 0x00}, wantErr: "unexpected end"},
+		{name: "truncated extended type", buf: []byte{0x01}, wantErr: "unexpected end"},
+		{name: "huge array header", buf: []byte{0x1F, 11 - 7, 0xFF, 0xFF, 0xFF}, wantErr: "unexpected end"},
+		{name: "non-string key", buf: mmMap(mmUint16(1), mmStr("DE")), wantErr: "non-string map key"},
+
+		{name: "pointer to itself", buf: []byte{0x20, 0x00}, wantErr: "nested too deep"},
+		{name: "map value points at the map", buf: mmMap(mmStr("k"), []byte{0x20, 0x00}), wantErr: "nested too deep"},
+		{name: "two pointers in a loop", buf: []byte{0x20, 0x02, 0x20, 0x00}, wantErr: "nested too deep"},
+		{name: "nested beyond limit", buf: nested(mmdbMaxDepth), wantErr: "nested too deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := mmdbDecoder{buf: tt.buf}
+			v, _, err := d.decode(tt.off)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("got %#v, want %#v", v, tt.want)
+			}
+			if d.depth != 0 {
+				t.Errorf("depth %d after decode", d.depth)
+			}
+		})
+	}
+}
+
+// writeMMDB builds a one-node IPv4 tree whose records both point at data
+func writeMMDB(t *testing.T, data, meta []byte) string {
+	t.Helper()
+	ptr := []byte{0x00, 0x00, 1 + 16} // node_count + 16 + offset 0
+	var b []byte
+	b = append(b, ptr...)
+	b = append(b, ptr...)
+	b = append(b, make([]byte, 16)...)
+	b = append(b, data...)
+	b = append(b, mmdbMetaMarker...)
+	b = append(b, meta...)
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMMDBCountry(t *testing.T) {
+	meta := mmMap(
+		mmStr("node_count"), mmUint16(1),
+		mmStr("record_size"), mmUint16(24),
+		mmStr("ip_version"), mmUint16(4),
+	)
+	tests := []struct {
+		name    string
+		data    []byte
+		meta    []byte
+		want    string
+		wantErr string
+	}{
+		{name: "country", data: mmMap(mmStr("country"), mmMap(mmStr("iso_code"), mmStr("DE"))), meta: meta, want: "DE"},
+		{name: "registered country", data: mmMap(mmStr("registered_country"), mmMap(mmStr("iso_code"), mmStr("NL"))), meta: meta, want: "NL"},
+		{name: "no country", data: mmMap(mmStr("city"), mmStr("x")), meta: meta, want: ""},
+		{name: "cyclic record", data: mmMap(mmStr("country"), []byte{0x20, 0x00}), meta: meta, wantErr: "nested too deep"},
+		{name: "truncated metadata", data: mmStr("x"), meta: meta[:len(meta)-2], wantErr: "metadata"},
+		{name: "cyclic metadata", data: mmStr("x"), meta: []byte{0x20, 0x00}, wantErr: "metadata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := openMMDB(writeMMDB(t, tt.data, tt.meta))
+			var got string
+			if err == nil {
+				got, err = r.country(net.ParseIP("203.0.113.7"))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("country = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	区块哈希质量检查（可插拔）
+	- hashChecks.checks 选择启用的检查项（为空 = 关闭，保持原行为）：
+	  hex      仅含十六进制字符（允许 0x 前缀）
+	  length   长度等于 hashChecks.length（默认 64，不含 0x）
+	  entropy  至少 hashChecks.minDistinct 种不同字符（默认 4），拦截全 0 / 重复填充
+	- 未通过的区块不进入判定，放入隔离列表（最近 quarantineMax 条）供人工复核：GET/DELETE /api/admin/hashchecks
+	- 起因：某网关短暂返回截断的哈希，按“末位”判定的规则产生了错误信号
+*/
+
+const (
+	quarantineMax          = 200
+	hashCheckDefaultLength = 64
+	hashCheckDefaultMinDis = 4
+)
+
+type HashCheckConfig struct {
+	Checks      []string `json:"checks"`      // names from hashCheckRegistry
+	Length      int      `json:"length"`      // 0 = 64
+	MinDistinct int      `json:"minDistinct"` // 0 = 4
+}
+
+func (hc HashCheckConfig) withDefaults() HashCheckConfig {
+	if hc.Length <= 0 {
+		hc.Length = hashCheckDefaultLength
+	}
+	if hc.MinDistinct <= 0 {
+		hc.MinDistinct = hashCheckDefaultMinDis
+	}
+	return hc
+}
+
+type hashCheck struct {
+	Name        string                                   `json:"name"`
+	Description string                                   `json:"description"`
+	fn          func(h string, hc HashCheckConfig) error // h: lower-case, 0x stripped
+}
+
+var hashCheckRegistry = []hashCheck{
+	{Name: "hex", Description: "hexadecimal characters only (0x prefix allowed)", fn: checkHexCharset},
+	{Name: "length", Description: "exact length, without 0x (hashChecks.length)", fn: checkHashLength},
+	{Name: "entropy", Description: "at least hashChecks.minDistinct distinct characters", fn: checkHashEntropy},
+}
+
+func findHashCheck(name string) (hashCheck, bool) {
+	for _, c := range hashCheckRegistry {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return hashCheck{}, false
+}
+
+func checkHexCharset(h string, _ HashCheckConfig) error {
+	for i := 0; i < len(h); i++ {
+		if _, ok := hexCharType(h[i]); !ok {
+			return fmt.Errorf("non-hex character %q at %d", h[i], i)
+		}
+	}
+	return nil
+}
+
+func checkHashLength(h string, hc HashCheckConfig) error {
+	if len(h) != hc.Length {
+		return fmt.Errorf("length %d, want %d", len(h), hc.Length)
+	}
+	return nil
+}
+
+func checkHashEntropy(h string, hc HashCheckConfig) error {
+	seen := map[byte]bool{}
+	for i := 0; i < len(h); i++ {
+		seen[h[i]] = true
+	}
+	if len(seen) < hc.MinDistinct {
+		return fmt.Errorf("%d distinct characters, want >= %d", len(seen), hc.MinDistinct)
+	}
+	return nil
+}
+
+func validateHashChecks(hc HashCheckConfig) error {
+	for _, name := range hc.Checks {
+		if _, ok := findHashCheck(name); !ok {
+			return fmt.Errorf("unknown check %q", name)
+		}
+	}
+	if hc.Length < 0 || hc.Length > 256 {
+		return errors.New("length must be 0..256")
+	}
+	if hc.MinDistinct < 0 || hc.MinDistinct > 16 {
+		return errors.New("minDistinct must be 0..16")
+	}
+	return nil
+}
+
+func hashCheckSettings() HashCheckConfig {
+	cfgMu.RLock()
+	hc := cfg.HashChecks
+	hc.Checks = append([]string(nil), hc.Checks...)
+	cfgMu.RUnlock()
+	return hc.withDefaults()
+}
+
+// runHashChecks returns one message per failed check; nil when the hash is usable
+func runHashChecks(hash string, hc HashCheckConfig) []string {
+	h := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hash)), "0x")
+	var failed []string
+	for _, name := range hc.Checks {
+		c, ok := findHashCheck(name)
+		if !ok {
+			continue
+		}
+		if err := c.fn(h, hc); err != nil {
+			failed = append(failed, c.Name+": "+err.Error())
+		}
+	}
+	return failed
+}
+
+type QuarantinedBlock struct {
+	Height  int64    `json:"height"`
+	Hash    string   `json:"hash"`
+	Source  string   `json:"source,omitempty"`
+	Failed  []string `json:"failed"`
+	TimeISO string   `json:"time"`
+}
+
+var (
+	quarantineMu sync.Mutex
+	quarantine   = []QuarantinedBlock{} // newest first
+)
+
+// hashQuarantined runs the enabled checks; a failing block is parked for review instead of judged
+func hashQuarantined(height int64, hash string, tr *latencyTrace) bool {
+	hc := hashCheckSettings()
+	if len(hc.Checks) == 0 {
+		return false
+	}
+	failed := runHashChecks(hash, hc)
+	if len(failed) == 0 {
+		return false
+	}
+	q := QuarantinedBlock{Height: height, Hash: hash, Source: sourceOf(tr), Failed: failed, TimeISO: time.Now().UTC().Format(time.RFC3339)}
+	quarantineMu.Lock()
+	quarantine = append([]QuarantinedBlock{q}, quarantine...)
+	if len(quarantine) > quarantineMax {
+		quarantine = quarantine[:quarantineMax]
+	}
+	quarantineMu.Unlock()
+	logger.Printf("HASH_QUARANTINED height=%d hash=%q source=%s failed=%q", height, hash, q.Source, strings.Join(failed, "; "))
+	return true
+}
+
+func quarantineCount() int {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	return len(quarantine)
+}
+
+// GET    /api/admin/hashchecks -> config + available checks + quarantine list
+// POST   /api/admin/hashchecks {"checks":["hex","length","entropy"],"length":64,"minDistinct":4}
+// DELETE /api/admin/hashchecks[?height=N] -> clear the quarantine (or one height) after review
+func apiHashChecks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		quarantineMu.Lock()
+		list := append([]QuarantinedBlock{}, quarantine...)
+		quarantineMu.Unlock()
+		mustJSON(w, 200, map[string]any{"config": hashCheckSettings(), "available": hashCheckRegistry, "quarantine": list})
+	case "POST":
+		var hc HashCheckConfig
+		if err := readJSON(r, &hc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateHashChecks(hc); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.HashChecks = hc
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		logger.Printf("HASH_CHECKS_UPDATED checks=%s length=%d minDistinct=%d rid=%s",
+			strings.Join(hc.Checks, ","), hc.Length, hc.MinDistinct, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "config": hc.withDefaults()})
+	case "DELETE":
+		height := int64(queryInt(r, "height", 0, 0, 1<<62))
+		quarantineMu.Lock()
+		kept := quarantine[:0]
+		removed := 0
+		for _, q := range quarantine {
+			if height != 0 && q.Height != height {
+				kept = append(kept, q)
+				continue
+			}
+			removed++
+		}
+		quarantine = kept
+		quarantineMu.Unlock()
+		mustJSON(w, 200, map[string]any{"ok": true, "removed": removed})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
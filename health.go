@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+	源健康评分与自动降级
+	- 每个轮询源保留最近 healthWindow 次请求的结果（成功 / 失败 + 耗时）
+	- score = 成功率 × 100 × 延迟系数（平均耗时 ≤ 500ms 为 1，≥ 5000ms 为 0.5，中间线性）
+	- 样本数 ≥ minSamples 且（score < minScore 或 成功/失败来回切换 ≥ flapTransitions 次）=> 降级
+	  降级期间（degradeMinutes）不再轮询该源；到期后清空窗口重新评分
+	- 全部源都处于降级时仍照常轮询，避免自己把自己“关掉”
+	- 受 feature flag "source-health" 控制；评分见 GET /api/sources 的 health 字段
+*/
+
+const (
+	healthWindow        = 50
+	healthFastLatencyMS = 500.0
+	healthSlowLatencyMS = 5000.0
+)
+
+type SourceHealthConfig struct {
+	MinScore        float64 `json:"minScore"`        // 0 = 50
+	MinSamples      int     `json:"minSamples"`      // 0 = 10
+	FlapTransitions int     `json:"flapTransitions"` // 0 = 10
+	DegradeMinutes  int     `json:"degradeMinutes"`  // 0 = 5
+}
+
+func (hc SourceHealthConfig) withDefaults() SourceHealthConfig {
+	if hc.MinScore <= 0 {
+		hc.MinScore = 50
+	}
+	if hc.MinSamples <= 0 {
+		hc.MinSamples = 10
+	}
+	if hc.MinSamples > healthWindow {
+		hc.MinSamples = healthWindow
+	}
+	if hc.FlapTransitions <= 0 {
+		hc.FlapTransitions = 10
+	}
+	if hc.DegradeMinutes <= 0 {
+		hc.DegradeMinutes = 5
+	}
+	return hc
+}
+
+func sourceHealthSettings() SourceHealthConfig {
+	cfgMu.RLock()
+	hc := cfg.SourceHealth
+	cfgMu.RUnlock()
+	return hc.withDefaults()
+}
+
+type healthSample struct {
+	ok        bool
+	latencyMS float64
+}
+
+type sourceHealthState struct {
+	samples       []healthSample // oldest first, at most healthWindow
+	degradedUntil time.Time
+}
+
+// SourceHealth is one row of the "health" map in GET /api/sources
+type SourceHealth struct {
+	Score         float64 `json:"score"`
+	Samples       int     `json:"samples"`
+	Errors        int     `json:"errors"`
+	AvgLatencyMS  float64 `json:"avgLatencyMs"`
+	Transitions   int     `json:"transitions"` // ok<->error flips in the window
+	Degraded      bool    `json:"degraded"`
+	DegradedUntil string  `json:"degradedUntil,omitempty"`
+}
+
+var (
+	healthMu     sync.Mutex
+	healthStates = map[string]*sourceHealthState{}
+)
+
+func (st *sourceHealthState) summary(now time.Time) SourceHealth {
+	h := SourceHealth{Samples: len(st.samples), Score: 100}
+	if len(st.samples) > 0 {
+		var sum float64
+		for i, s := range st.samples {
+			if !s.ok {
+				h.Errors++
+			}
+			sum += s.latencyMS
+			if i > 0 && s.ok != st.samples[i-1].ok {
+				h.Transitions++
+			}
+		}
+		h.AvgLatencyMS = sum / float64(len(st.samples))
+		factor := 1.0
+		if h.AvgLatencyMS > healthFastLatencyMS {
+			factor = 1 - 0.5*min((h.AvgLatencyMS-healthFastLatencyMS)/(healthSlowLatencyMS-healthFastLatencyMS), 1)
+		}
+		h.Score = float64(len(st.samples)-h.Errors) / float64(len(st.samples)) * 100 * factor
+	}
+	if now.Before(st.degradedUntil) {
+		h.Degraded = true
+		h.DegradedUntil = st.degradedUntil.UTC().Format(time.RFC3339)
+	}
+	return h
+}
+
+// healthRecord adds one fetch outcome and degrades the source when it misbehaves
+func healthRecord(id string, err error, took time.Duration) {
+	hc := sourceHealthSettings()
+	now := time.Now()
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	st := healthStates[id]
+	if st == nil {
+		st = &sourceHealthState{}
+		healthStates[id] = st
+	}
+	if !st.degradedUntil.IsZero() && !now.Before(st.degradedUntil) {
+		st.degradedUntil = time.Time{}
+		st.samples = nil // fresh start after the penalty
+		logger.Printf("SOURCE_HEALTH_RESTORED id=%s", id)
+	}
+	st.samples = append(st.samples, healthSample{ok: err == nil, latencyMS: float64(took.Microseconds()) / 1000})
+	if len(st.samples) > healthWindow {
+		st.samples = st.samples[len(st.samples)-healthWindow:]
+	}
+	if !st.degradedUntil.IsZero() || len(st.samples) < hc.MinSamples {
+		return
+	}
+	h := st.summary(now)
+	if h.Score < hc.MinScore || h.Transitions >= hc.FlapTransitions {
+		st.degradedUntil = now.Add(time.Duration(hc.DegradeMinutes) * time.Minute)
+		logger.Printf("SOURCE_HEALTH_DEGRADED id=%s score=%.1f errors=%d/%d transitions=%d avgLatencyMs=%.0f minutes=%d",
+			id, h.Score, h.Errors, h.Samples, h.Transitions, h.AvgLatencyMS, hc.DegradeMinutes)
+	}
+}
+
+// healthySources drops degraded sources; if every source is degraded all are kept
+func healthySources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	if !featureOn(featSourceHealth) {
+		return srcs
+	}
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if st := healthStates[sc.ID]; st != nil && now.Before(st.degradedUntil) {
+			continue
+		}
+		out = append(out, sc)
+	}
+	if len(out) == 0 {
+		return srcs
+	}
+	return out
+}
+
+// fetchSourceTimed: one fetch of sc with chaos injection and health / stats / breaker / limiter / backoff accounting
+func fetchSourceTimed(ctx context.Context, sc SourceConfig) sourceResult {
+	return fetchSourceTimedAt(ctx, sc, 0)
+}
+
+// fetchSourceTimedAt: num > 0 runs the by-height request for num (backfill); the
+// accounting is the same, only the block phase ignores those old blocks
+func fetchSourceTimedAt(ctx context.Context, sc SourceConfig, num int64) sourceResult {
+	res := sourceResult{id: sc.ID}
+	start := time.Now()
+	quotaRecord(sc, start)
+	if res.err = chaosBeforeFetch(sc.ID); res.err == nil {
+		if num > 0 {
+			res.height, res.hash, res.timeISO, res.err = fetchSourceByNum(ctx, sourceClient(sc), sc, num)
+		} else if next, ok := heightHintNext(sc, start); ok {
+			res.height, res.hash, res.timeISO, res.err = fetchHinted(ctx, sourceClient(sc), sc, next)
+		} else {
+			res.height, res.hash, res.timeISO, res.err = fetchSource(ctx, sourceClient(sc), sc)
+		}
+	}
+	took := time.Since(start)
+	if res.err != nil && ctx.Err() != nil {
+		// cut short by the race (lost, or a race deadline): says nothing about the source
+		res.err = errFetchCancelled
+		sourceStatsCancelled(sc.ID)
+		return res
+	}
+	if num == 0 {
+		phaseRecord(sc, res, start.Add(took))
+	}
+	err := res.err
+	if errors.Is(err, errNoNewBlock) {
+		err = nil // the source answered; there is just nothing newer yet
+	}
+	healthRecord(sc.ID, err, took)
+	sourceStatsRecord(sc.ID, err, took)
+	breakerRecord(sc.ID, err)
+	limiterRecord(sc.ID, err)
+	backoffRecord(sc.ID, err)
+	return res
+}
+
+func healthSnapshot() map[string]SourceHealth {
+	now := time.Now()
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	out := make(map[string]SourceHealth, len(healthStates))
+	for id, st := range healthStates {
+		out[id] = st.summary(now)
+	}
+	return out
+}
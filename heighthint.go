@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	按高度条件拉取（节点只返回比已知高度更新的区块，重复块在源头就被丢掉）
+	- 源配置 heightHint=true（需要 byNumUrl / byNumBody）：轮询时不再请求“最新区块”，
+	  而是用按高度查询请求 上一块高度+1（getblockbynum {"num":N} / eth_getBlockByNumber N）
+	- 节点返回空（{} / result:null）或更低的高度 => “还没有新块”：不算失败，不影响健康分、熔断、退避，
+	  也不会计入降级；本轮直接跳过
+	- 只在最近 hintMaxAge 内见过实时区块时才带高度；启动、长时间无新块、落后追赶失败时
+	  自动改回普通“最新区块”请求重新同步（模拟器区块和预热快照不算）
+*/
+
+const hintMaxAge = 30 * time.Second
+
+// errNoNewBlock: the hinted request answered, but the next height is not produced yet
+var errNoNewBlock = errors.New("no newer block")
+
+var (
+	hintMu  sync.Mutex
+	hintTop int64     // highest live height this run
+	hintAt  time.Time // when hintTop was seen
+)
+
+// heightHintSeen records a live (non-simulated) block
+func heightHintSeen(height int64) {
+	hintMu.Lock()
+	defer hintMu.Unlock()
+	if height > hintTop {
+		hintTop = height
+		hintAt = time.Now()
+	}
+}
+
+// heightHintNext returns the height to ask sc for; ok=false = plain "latest" request
+func heightHintNext(sc SourceConfig, now time.Time) (int64, bool) {
+	if !sc.HeightHint || sc.ByNumURL == "" {
+		return 0, false
+	}
+	hintMu.Lock()
+	defer hintMu.Unlock()
+	if hintTop <= 0 || now.Sub(hintAt) > hintMaxAge {
+		return 0, false
+	}
+	return hintTop + 1, true
+}
+
+// fetchHinted asks sc for height next; an empty or older answer is errNoNewBlock
+func fetchHinted(ctx context.Context, client *http.Client, sc SourceConfig, next int64) (int64, string, string, error) {
+	q := byNumRequest(sc, next)
+	q.APIKey = pickKey(sc.ID, sourceKeys(sc), sc.KeyRotation, time.Now())
+	doc, err := fetchSourceDoc(ctx, client, q)
+	reportKey(sc.ID, q.APIKey, err)
+	if err != nil {
+		return 0, "", "", err
+	}
+	height, hash, timeISO, err := mapSourceResponse(q, doc)
+	if err != nil || height < next {
+		return 0, "", "", errNoNewBlock
+	}
+	txMetaRecord(q, hash, doc)
+	return height, hash, timeISO, nil
+}
+
+// noNewBlockIn: called when no source had a block; true if one of them answered "not yet"
+func noNewBlockIn(errs []error) bool {
+	for _, err := range errs {
+		if errors.Is(err, errNoNewBlock) {
+			return true
+		}
+	}
+	return false
+}
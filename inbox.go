@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	管理员通知收件箱（data/inbox.json）
+	- 自动收集 MAJOR 事件与系统通知（由 eventWriter 从日志行捕获，无需逐处调用）
+	- 已读 / 未读状态；未读数显示在 /api/status（inboxUnread）
+	- 最多保留 inboxMax 条，超出丢弃最旧的
+*/
+
+const inboxMax = 200
+
+// system notices that are not MAJOR but worth an operator's attention
+var inboxNotices = map[string]bool{
+	"ABNORMAL_RESTART":        true,
+	"CONFIG_INVALID":          true,
+	"DEGRADED_RECOVER":        true,
+	"BLOCK_ARRIVAL_RESUMED":   true,
+	"WATCHDOG_RSS_RECOVERED":  true,
+	"WATCHDOG_DISK_RECOVERED": true,
+	"WATCHDOG_PERSISTENCE":    true,
+	"AUTH_BAN":                true,
+	"GEOIP_LOAD_ERROR":        true,
+	"SIM_LOAD_ERROR":          true,
+}
+
+type InboxItem struct {
+	ID      uint64 `json:"id"`
+	Code    string `json:"code"`
+	Event   string `json:"event"`
+	Major   bool   `json:"major"`
+	Message string `json:"message"`
+	TimeISO string `json:"time"`
+	Read    bool   `json:"read"`
+}
+
+type inboxFile struct {
+	NextID uint64      `json:"nextId"`
+	Items  []InboxItem `json:"items"` // newest first
+}
+
+var (
+	inboxPath = filepath.Join(dataDir, "inbox.json")
+
+	inboxMu    sync.Mutex
+	inbox      = inboxFile{NextID: 1}
+	inboxDirty bool
+)
+
+// loadInbox must run before anything logs an inbox event; it cannot log itself
+// failures are reported on the first flush instead
+func loadInbox() error {
+	b, err := os.ReadFile(inboxPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var f inboxFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	if f.NextID == 0 {
+		f.NextID = 1
+	}
+	inboxMu.Lock()
+	inbox = f
+	inboxMu.Unlock()
+	return nil
+}
+
+// inboxCapture is called by eventWriter for every log line (logger lock held: must not log)
+func inboxCapture(event string, major bool, msg string) {
+	if !major && !inboxNotices[event] {
+		return
+	}
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+	it := InboxItem{
+		ID:      inbox.NextID,
+		Code:    eventCode(event),
+		Event:   event,
+		Major:   major,
+		Message: msg,
+		TimeISO: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	inbox.NextID++
+	inbox.Items = append([]InboxItem{it}, inbox.Items...)
+	if len(inbox.Items) > inboxMax {
+		inbox.Items = inbox.Items[:inboxMax]
+	}
+	inboxDirty = true
+}
+
+func inboxUnread() int {
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+	n := 0
+	for _, it := range inbox.Items {
+		if !it.Read {
+			n++
+		}
+	}
+	return n
+}
+
+func flushInbox() {
+	if persistPaused.Load() {
+		return
+	}
+	inboxMu.Lock()
+	if !inboxDirty {
+		inboxMu.Unlock()
+		return
+	}
+	b, err := json.MarshalIndent(inbox, "", "  ")
+	inboxDirty = false
+	inboxMu.Unlock()
+	if err != nil {
+		return
+	}
+
+	tmp := inboxPath + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, inboxPath)
+	}
+	if err != nil {
+		logger.Printf("INBOX_SAVE_ERROR: %v", err)
+		inboxMu.Lock()
+		inboxDirty = true
+		inboxMu.Unlock()
+	}
+}
+
+func inboxLoop() {
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for range t.C {
+		flushInbox()
+	}
+}
+
+// GET  /api/admin/inbox[?unread=1&limit=50]
+// POST /api/admin/inbox/read {"ids":[1,2]} | {"all":true}
+func apiInbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "1"
+	limit := queryInt(r, "limit", 50, 1, inboxMax)
+
+	inboxMu.Lock()
+	out := []InboxItem{}
+	unread := 0
+	for _, it := range inbox.Items {
+		if !it.Read {
+			unread++
+		}
+		if (unreadOnly && it.Read) || len(out) >= limit {
+			continue
+		}
+		out = append(out, it)
+	}
+	inboxMu.Unlock()
+	mustJSON(w, 200, map[string]any{"unread": unread, "items": out})
+}
+
+func apiInboxRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var in struct {
+		IDs []uint64 `json:"ids"`
+		All bool     `json:"all"`
+	}
+	if err := readJSON(r, &in); err != nil {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids := map[uint64]bool{}
+	for _, id := range in.IDs {
+		ids[id] = true
+	}
+
+	inboxMu.Lock()
+	marked := 0
+	for i := range inbox.Items {
+		if !inbox.Items[i].Read && (in.All || ids[inbox.Items[i].ID]) {
+			inbox.Items[i].Read = true
+			marked++
+		}
+	}
+	if marked > 0 {
+		inboxDirty = true
+	}
+	inboxMu.Unlock()
+	flushInbox()
+
+	strIDs := make([]string, 0, len(in.IDs))
+	for _, id := range in.IDs {
+		strIDs = append(strIDs, strconv.FormatUint(id, 10))
+	}
+	logger.Printf("INBOX_READ marked=%d all=%v ids=%s rid=%s", marked, in.All, strings.Join(strIDs, ","), requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "marked": marked, "unread": inboxUnread()})
+}
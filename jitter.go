@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+/*
+	出块到达间隔统计与异常告警
+	- 每个新的实时区块（去重后、非模拟）记录与上一块的到达间隔（跨多块时按块数平均）
+	- 统计最近 latencyWindow 个间隔：p50 / p90 / p99 / max、均值、抖动（标准差）、
+	  按节奏倍数分桶的分布；见 GET /api/latency 的 arrival 字段
+	- 超过 alertFactor × cadenceMs（默认 3 × 3000ms）没有新高度 => MAJOR BLOCK_ARRIVAL_STALL
+	  （链停摆或数据源卡在旧块上；进入收件箱），下一块到达时写 BLOCK_ARRIVAL_RESUMED，边沿触发
+	- 未监听、省电空闲、降级（已有 DEGRADED_ENTER）、模拟器运行时暂停统计与告警，
+	  恢复后的第一块不计间隔
+	- jitter.disabled=true 关闭告警（统计照常）
+*/
+
+const (
+	jitterCadenceDefault = 3000
+	jitterFactorDefault  = 3.0
+)
+
+type JitterConfig struct {
+	CadenceMS   int     `json:"cadenceMs"`   // expected block interval; 0 = 3000
+	AlertFactor float64 `json:"alertFactor"` // stall alert after factor x cadence; 0 = 3
+	Disabled    bool    `json:"disabled"`    // alerts off, statistics still kept
+}
+
+func (jc JitterConfig) withDefaults() JitterConfig {
+	if jc.CadenceMS <= 0 {
+		jc.CadenceMS = jitterCadenceDefault
+	}
+	if jc.AlertFactor <= 1 {
+		jc.AlertFactor = jitterFactorDefault
+	}
+	return jc
+}
+
+func (jc JitterConfig) threshold() time.Duration {
+	return time.Duration(float64(jc.CadenceMS)*jc.AlertFactor) * time.Millisecond
+}
+
+func jitterSettings() JitterConfig {
+	cfgMu.RLock()
+	jc := cfg.Jitter
+	cfgMu.RUnlock()
+	return jc.withDefaults()
+}
+
+// histogram upper bounds in multiples of the cadence; the last bucket is open
+var arrivalBuckets = []float64{0.5, 0.9, 1.1, 1.5, 2, 3}
+
+type ArrivalBucket struct {
+	LE    string `json:"le"` // upper bound in ms, "+Inf" for the last
+	Count int    `json:"count"`
+}
+
+type ArrivalStats struct {
+	CadenceMS   int             `json:"cadenceMs"`
+	ThresholdMS int64           `json:"thresholdMs"`
+	Intervals   LatencyStats    `json:"intervals"`
+	MeanMS      float64         `json:"meanMs"`
+	JitterMS    float64         `json:"jitterMs"` // standard deviation
+	Histogram   []ArrivalBucket `json:"histogram"`
+	Anomalies   int             `json:"anomalies"` // intervals above the threshold
+	Stalls      int             `json:"stalls"`
+	Stalled     bool            `json:"stalled"`
+	SinceLastMS int64           `json:"sinceLastMs,omitempty"`
+	LastHeight  int64           `json:"lastHeight,omitempty"`
+	Paused      bool            `json:"paused"`
+}
+
+var (
+	// guarded by latMu with the latency rings
+	arrRing      latencyRing
+	arrHeight    int64
+	arrLast      time.Time // last arrival; zero after a pause (next block sets no interval)
+	arrSince     time.Time // stall clock
+	arrStalled   bool
+	arrPaused    bool
+	arrAnomalies int
+	arrStalls    int
+)
+
+// arrivalRecord: a live block was accepted
+func arrivalRecord(height int64, at time.Time) {
+	jc := jitterSettings()
+	latMu.Lock()
+	defer latMu.Unlock()
+	if height <= arrHeight || arrPaused {
+		return
+	}
+	if !arrLast.IsZero() && arrHeight > 0 {
+		gap := at.Sub(arrLast)
+		arrRing.add(float64(gap.Microseconds()) / 1000 / float64(height-arrHeight))
+		if gap > jc.threshold() {
+			arrAnomalies++
+		}
+	}
+	if arrStalled {
+		arrStalled = false
+		logger.Printf("BLOCK_ARRIVAL_RESUMED height=%d gapMs=%d", height, at.Sub(arrSince).Milliseconds())
+	}
+	arrHeight, arrLast, arrSince = height, at, at
+}
+
+// arrivalPaused: stall alerts would only restate why nothing arrives
+func arrivalPaused() bool {
+	rtMu.Lock()
+	listening := rt.Listening
+	rtMu.Unlock()
+	stale, _, _ := degradeState()
+	return !listening || stale || simulatorActive() || currentPowerMode() == powerIdle
+}
+
+func arrivalCheck(now time.Time) {
+	jc := jitterSettings()
+	paused := arrivalPaused()
+	latMu.Lock()
+	defer latMu.Unlock()
+	arrPaused = paused
+	if paused {
+		arrLast, arrSince, arrStalled = time.Time{}, time.Time{}, false
+		return
+	}
+	if arrSince.IsZero() {
+		arrSince = now
+		return
+	}
+	if since := now.Sub(arrSince); !arrStalled && !jc.Disabled && since > jc.threshold() {
+		arrStalled = true
+		arrStalls++
+		logger.Printf("MAJOR BLOCK_ARRIVAL_STALL sinceMs=%d thresholdMs=%d lastHeight=%d", since.Milliseconds(), jc.threshold().Milliseconds(), arrHeight)
+	}
+}
+
+func arrivalLoop() {
+	for {
+		time.Sleep(time.Second)
+		arrivalCheck(time.Now())
+	}
+}
+
+func arrivalSnapshot() ArrivalStats {
+	jc := jitterSettings()
+	latMu.Lock()
+	defer latMu.Unlock()
+	st := ArrivalStats{
+		CadenceMS: jc.CadenceMS, ThresholdMS: jc.threshold().Milliseconds(),
+		Intervals: arrRing.stats(), Anomalies: arrAnomalies, Stalls: arrStalls,
+		Stalled: arrStalled, LastHeight: arrHeight, Paused: arrPaused,
+	}
+	if !arrSince.IsZero() {
+		st.SinceLastMS = time.Since(arrSince).Milliseconds()
+	}
+
+	counts := make([]int, len(arrivalBuckets)+1)
+	var sum, sq float64
+	for _, v := range arrRing.buf[:arrRing.n] {
+		sum += v
+		sq += v * v
+		i := 0
+		for i < len(arrivalBuckets) && v > arrivalBuckets[i]*float64(jc.CadenceMS) {
+			i++
+		}
+		counts[i]++
+	}
+	if n := float64(arrRing.n); n > 0 {
+		st.MeanMS = sum / n
+		st.JitterMS = math.Sqrt(max(sq/n-st.MeanMS*st.MeanMS, 0))
+	}
+	for i, c := range counts {
+		le := "+Inf"
+		if i < len(arrivalBuckets) {
+			le = strconv.Itoa(int(arrivalBuckets[i] * float64(jc.CadenceMS)))
+		}
+		st.Histogram = append(st.Histogram, ArrivalBucket{LE: le, Count: c})
+	}
+	return st
+}
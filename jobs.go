@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	后台任务（耗时操作不再占着 HTTP 请求）
+	- 支持的操作加 ?async=1 后立即返回 202 {"jobId":...}，在后台执行：
+	    POST /api/sources/discover?async=1        公共节点基准测试
+	    GET  /api/admin/sources/compare?async=1   全部源对比
+	    GET  /api/admin/history/export?async=1    历史导出
+	- GET    /api/admin/jobs            任务列表（不含结果）
+	- GET    /api/admin/jobs/{id}       状态与进度（done / total / message）
+	- GET    /api/admin/jobs/{id}/result 完成后取结果（未完成 409）
+	- DELETE /api/admin/jobs/{id}       取消（需确认）；任务在下一个检查点退出
+	- GET    /sse/jobs?id=...           进度推送（event: job），任务结束后关闭
+	- 只在内存中保留最近 jobsKeep 个；结束超过 jobsTTL 的自动清理；重启后不保留
+*/
+
+const (
+	jobsKeep = 50
+	jobsTTL  = time.Hour
+
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+type Job struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	State    string `json:"state"`
+	Done     int    `json:"done"`
+	Total    int    `json:"total"`
+	Message  string `json:"message,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Started  string `json:"started"`
+	Finished string `json:"finished,omitempty"`
+	Rev      int    `json:"rev"` // bumped on every change (SSE)
+
+	result   any
+	cancel   context.CancelFunc
+	finished time.Time
+}
+
+// jobFunc does the work; report progress through j.progress, stop when ctx is done
+type jobFunc func(ctx context.Context, j *jobHandle) (any, error)
+
+// jobHandle is what a running job sees of itself
+type jobHandle struct{ id string }
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*Job{}
+)
+
+func (h *jobHandle) progress(done, total int, msg string) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if j := jobs[h.id]; j != nil && j.State == jobRunning {
+		j.Done, j.Total, j.Message = done, total, msg
+		j.Rev++
+	}
+}
+
+func pruneJobsLocked(now time.Time) {
+	var ended []*Job
+	for id, j := range jobs {
+		if j.State == jobRunning {
+			continue
+		}
+		if now.Sub(j.finished) > jobsTTL {
+			delete(jobs, id)
+			continue
+		}
+		ended = append(ended, j)
+	}
+	if len(jobs) <= jobsKeep {
+		return
+	}
+	sort.Slice(ended, func(a, b int) bool { return ended[a].finished.Before(ended[b].finished) })
+	for _, j := range ended {
+		if len(jobs) <= jobsKeep {
+			break
+		}
+		delete(jobs, j.ID)
+	}
+}
+
+// startJob runs fn in the background and returns the job id
+func startJob(kind, rid string, fn jobFunc) string {
+	id, _ := randHex(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{ID: id, Kind: kind, State: jobRunning, Started: time.Now().UTC().Format(time.RFC3339), cancel: cancel}
+
+	jobsMu.Lock()
+	pruneJobsLocked(time.Now())
+	jobs[id] = j
+	jobsMu.Unlock()
+	logger.Printf("JOB_STARTED id=%s kind=%s rid=%s", id, kind, rid)
+
+	go func() {
+		start := time.Now()
+		res, err := fn(ctx, &jobHandle{id: id})
+		cancel()
+
+		jobsMu.Lock()
+		j.finished = time.Now()
+		j.Finished = j.finished.UTC().Format(time.RFC3339)
+		switch {
+		case j.State == jobCancelled:
+		case err != nil:
+			j.State, j.Error = jobFailed, err.Error()
+		default:
+			j.State, j.result = jobDone, res
+		}
+		j.Rev++
+		state := j.State
+		jobsMu.Unlock()
+		logger.Printf("JOB_FINISHED id=%s kind=%s state=%s ms=%d", id, kind, state, time.Since(start).Milliseconds())
+	}()
+	return id
+}
+
+// asyncRequested: ?async=1 on a job-capable endpoint
+func asyncRequested(r *http.Request) bool {
+	v := r.URL.Query().Get("async")
+	return v == "1" || v == "true"
+}
+
+func acceptJob(w http.ResponseWriter, id string) {
+	w.Header().Set("Location", "/api/admin/jobs/"+id)
+	mustJSON(w, http.StatusAccepted, map[string]any{"ok": true, "jobId": id})
+}
+
+func jobSnapshot(id string) (Job, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j := jobs[id]
+	if j == nil {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// GET    /api/admin/jobs
+// GET    /api/admin/jobs/{id}
+// GET    /api/admin/jobs/{id}/result
+// DELETE /api/admin/jobs/{id}
+func apiJobs(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/jobs"), "/")
+	id, sub, _ := strings.Cut(rest, "/")
+
+	if id == "" {
+		if r.Method != "GET" {
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+			return
+		}
+		jobsMu.Lock()
+		pruneJobsLocked(time.Now())
+		out := make([]Job, 0, len(jobs))
+		for _, j := range jobs {
+			out = append(out, *j)
+		}
+		jobsMu.Unlock()
+		sort.Slice(out, func(a, b int) bool { return out[a].Started > out[b].Started })
+		mustJSON(w, 200, map[string]any{"jobs": out})
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && sub == "":
+		j, ok := jobSnapshot(id)
+		if !ok {
+			httpError(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		mustJSON(w, 200, j)
+	case r.Method == "GET" && sub == "result":
+		jobsMu.Lock()
+		j := jobs[id]
+		var state string
+		var res any
+		if j != nil {
+			state, res = j.State, j.result
+		}
+		jobsMu.Unlock()
+		switch {
+		case j == nil:
+			httpError(w, r, "not found", http.StatusNotFound)
+		case state != jobDone:
+			httpError(w, r, "job is "+state, http.StatusConflict)
+		default:
+			mustJSON(w, 200, res)
+		}
+	case r.Method == "DELETE" && sub == "":
+		jobsMu.Lock()
+		j := jobs[id]
+		running := j != nil && j.State == jobRunning
+		if running {
+			j.State = jobCancelled
+			j.Rev++
+			j.cancel()
+		}
+		jobsMu.Unlock()
+		if j == nil {
+			httpError(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		if !running {
+			httpError(w, r, "job already finished", http.StatusConflict)
+			return
+		}
+		logger.Printf("JOB_CANCELLED id=%s rid=%s", id, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+	case sub != "" && sub != "result":
+		http.NotFound(w, r)
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /sse/jobs?id=... -> event: job on every change, closes when the job ends
+func sseJobs(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if _, ok := jobSnapshot(id); !ok {
+		httpError(w, r, "not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, r, "no flusher", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	t := time.NewTicker(500 * time.Millisecond)
+	defer t.Stop()
+	last := -1
+	for {
+		j, ok := jobSnapshot(id)
+		if !ok {
+			return // pruned
+		}
+		if j.Rev != last {
+			last = j.Rev
+			b, _ := json.Marshal(j)
+			fmt.Fprintf(w, "id: %d\nevent: job\ndata: %s\n\n", j.Rev, b)
+			flusher.Flush()
+		}
+		if j.State != jobRunning {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+	极简 JSONPath：只支持 "$.a.b[0].c" 这种点号 + 下标取值
+	（足够覆盖各家 getnowblock / eth_getBlockByNumber 的响应）
+*/
+
+func jsonPathLookup(doc any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return doc, nil
+	}
+	cur := doc
+	for _, part := range strings.Split(path, ".") {
+		name, idx := part, []int(nil)
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name = part[:i]
+			for _, seg := range strings.Split(part[i:], "[")[1:] {
+				n, err := strconv.Atoi(strings.TrimSuffix(seg, "]"))
+				if err != nil || !strings.HasSuffix(seg, "]") {
+					return nil, fmt.Errorf("jsonpath: bad index in %q", part)
+				}
+				idx = append(idx, n)
+			}
+		}
+		if name != "" {
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("jsonpath: %q is not an object", name)
+			}
+			if cur, ok = m[name]; !ok {
+				return nil, fmt.Errorf("jsonpath: %q not found", name)
+			}
+		}
+		for _, n := range idx {
+			a, ok := cur.([]any)
+			if !ok || n < 0 || n >= len(a) {
+				return nil, fmt.Errorf("jsonpath: index %d out of range in %q", n, part)
+			}
+			cur = a[n]
+		}
+	}
+	return cur, nil
+}
+
+// jsonInt accepts JSON numbers, decimal strings and "0x" hex strings (EVM RPC)
+func jsonInt(v any) (int64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.Int64()
+	case float64:
+		if x != math.Trunc(x) {
+			return 0, errors.New("jsonpath: not an integer")
+		}
+		return int64(x), nil
+	case string:
+		s := strings.TrimSpace(x)
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			return strconv.ParseInt(s[2:], 16, 64)
+		}
+		return strconv.ParseInt(s, 10, 64)
+	}
+	return 0, fmt.Errorf("jsonpath: unexpected %T for integer", v)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeDoc(t *testing.T, s string) any {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	doc := decodeDoc(t, `{"a":{"b":[{"c":1},{"c":[7,8]}]},"n":null}`)
+	tests := []struct {
+		path    string
+		want    string // JSON of the value
+		wantErr bool
+	}{
+		{"a.b[0].c", "1", false},
+		{"$.a.b[0].c", "1", false},
+		{" $.a.b[1].c[1] ", "8", false},
+		{"n", "<nil>", false},
+		{"a.x", "", true},
+		{"a.b[2]", "", true},
+		{"a.b[-1]", "", true},
+		{"a.b[x]", "", true},
+		{"a.b[0", "", true},
+		{"a.b.c", "", true}, // b is an array
+	}
+	for _, tt := range tests {
+		v, err := jsonPathLookup(doc, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if err == nil {
+			if got := jsonString(v); got != tt.want {
+				t.Errorf("%q = %s, want %s", tt.path, got, tt.want)
+			}
+		}
+	}
+}
+
+func jsonString(v any) string {
+	if v == nil {
+		return "<nil>"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestJSONInt(t *testing.T) {
+	tests := []struct {
+		in      any
+		want    int64
+		wantErr bool
+	}{
+		{json.Number("123"), 123, false},
+		{float64(42), 42, false},
+		{float64(4.5), 0, true},
+		{"77", 77, false},
+		{"0x4d2", 1234, false},
+		{"0X4D2", 1234, false},
+		{"0xzz", 0, true},
+		{true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := jsonInt(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("jsonInt(%#v) = %d, %v; want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// sample responses for every response shape a preset maps
+var presetSamples = map[string]string{
+	"block_header.": `{"blockID":"0000000003a1b2c3aa","block_header":{"raw_data":{"number":60928707,"timestamp":1700000000000}},"transactions":[]}`,
+	"result.":       `{"jsonrpc":"2.0","id":1,"result":{"number":"0x3a1b2c3","hash":"0x0000000003a1b2c3aa","timestamp":"0x6553f100","transactions":[]}}`,
+	"params.":       `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x1","result":{"number":"0x3a1b2c3","hash":"0x0000000003a1b2c3aa","timestamp":"0x6553f100"}}}`,
+}
+
+func TestSourcePresetPaths(t *testing.T) {
+	for _, p := range sourcePresets {
+		t.Run(p.Name, func(t *testing.T) {
+			var sample string
+			for prefix, s := range presetSamples {
+				if strings.HasPrefix(p.HeightPath, prefix) {
+					sample = s
+				}
+			}
+			if sample == "" {
+				t.Fatalf("no sample response for heightPath %q", p.HeightPath)
+			}
+			height, hash, timeISO, err := mapSourceResponse(p.SourceConfig, decodeDoc(t, sample))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height != 60928707 || hash != "0000000003a1b2c3aa" {
+				t.Errorf("got %d/%s, want 60928707/0000000003a1b2c3aa", height, hash)
+			}
+			if timeISO != "2023-11-14T22:13:20Z" {
+				t.Errorf("time = %s (timePath %q)", timeISO, p.TimePath)
+			}
+			if p.TxPath != "" {
+				if _, err := jsonPathLookup(decodeDoc(t, sample), p.TxPath); err != nil {
+					t.Errorf("txPath: %v", err)
+				}
+			}
+		})
+	}
+}
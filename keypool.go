@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	API Key 池（按源轮换）
+	- 一个源可带多个 key（apiKey + apiKeys），TronGrid 默认源使用 Config.APIKeys
+	- keyRotation：""/"round-robin" 每次请求轮换；"failover" 一直用当前 key，出错才换
+	- 401/429 => 该 key 冷却 keyCooldown（429 带 Retry-After 且更长时按 Retry-After），期间跳过；全部冷却时用最早恢复的那个
+	- ratePerKey：限速档按每个 key 计算，源的总速率 = rps × key 数
+	- 每个 key 记录请求数 / 错误数 / 限流次数，GET /api/sources 返回 keyUsage（已脱敏）
+*/
+
+const (
+	keyCooldown       = 60 * time.Second
+	keyRotationRR     = "round-robin"
+	keyRotationFailov = "failover"
+)
+
+// httpStatusError keeps the status code of a non-200 node response
+type httpStatusError struct {
+	Code       int
+	Body       string
+	RetryAfter time.Duration // from a 429/503 Retry-After header; 0 = none
+}
+
+func (e *httpStatusError) Error() string { return fmt.Sprintf("http %d: %s", e.Code, e.Body) }
+
+// maxRetryAfter caps what a provider can ask for; longer pauses are left to backoff / breaker
+const maxRetryAfter = time.Hour
+
+// statusError reads (part of) a non-200 body and the Retry-After header
+func statusError(resp *http.Response) *httpStatusError {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	he := &httpStatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		he.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return he
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if n, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
+
+// retryAfterOf: the pause a provider asked for with err; 0 = none
+func retryAfterOf(err error) time.Duration {
+	var he *httpStatusError
+	if errors.As(err, &he) {
+		return he.RetryAfter
+	}
+	return 0
+}
+
+func isKeyRejected(err error) bool {
+	var he *httpStatusError
+	return errors.As(err, &he) && (he.Code == http.StatusUnauthorized || he.Code == http.StatusTooManyRequests)
+}
+
+type KeyUsage struct {
+	Key          string `json:"key"` // redacted
+	Requests     uint64 `json:"requests"`
+	Errors       uint64 `json:"errors"`
+	Rejected     uint64 `json:"rejected"` // 401/429
+	LastUsed     string `json:"lastUsed,omitempty"`
+	CoolingUntil string `json:"coolingUntil,omitempty"`
+
+	cooling time.Time
+}
+
+type keyPoolState struct {
+	cur   int
+	usage map[string]*KeyUsage
+}
+
+var (
+	kpMu    sync.Mutex
+	kpPools = map[string]*keyPoolState{} // source id (or "trongrid") -> state
+)
+
+// sourceKeys: apiKey first, then apiKeys, without blanks or duplicates
+func sourceKeys(sc SourceConfig) []string {
+	return sanitizeKeyList(append([]string{sc.APIKey}, sc.APIKeys...))
+}
+
+func sanitizeKeyList(in []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, k := range in {
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+// pickKey chooses the key for the next request of pool; "" when keys is empty
+func pickKey(pool string, keys []string, mode string, now time.Time) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	kpMu.Lock()
+	defer kpMu.Unlock()
+	st := kpPools[pool]
+	if st == nil {
+		st = &keyPoolState{usage: map[string]*KeyUsage{}}
+		kpPools[pool] = st
+	}
+	if mode != keyRotationFailov {
+		st.cur++
+	}
+	best := st.choose(keys, st.cur, now)
+	st.cur = best
+	key := keys[best]
+	u := st.usage[key]
+	if u == nil {
+		u = &KeyUsage{Key: redactKey(key)}
+		st.usage[key] = u
+	}
+	u.Requests++
+	u.LastUsed = now.UTC().Format(time.RFC3339)
+	return key
+}
+
+// peekKey returns the key pickKey would choose next without counting a
+// request or advancing the rotation; "" when keys is empty
+func peekKey(pool string, keys []string, mode string, now time.Time) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	kpMu.Lock()
+	defer kpMu.Unlock()
+	st := kpPools[pool]
+	if st == nil {
+		st = &keyPoolState{}
+	}
+	from := st.cur
+	if mode != keyRotationFailov {
+		from++
+	}
+	return keys[st.choose(keys, from, now)]
+}
+
+// choose: first key from index from that is not cooling, else the one that recovers first
+func (st *keyPoolState) choose(keys []string, from int, now time.Time) int {
+	best, bestUntil := -1, time.Time{}
+	for i := 0; i < len(keys); i++ {
+		j := (from + i) % len(keys)
+		u := st.usage[keys[j]]
+		if u == nil || !now.Before(u.cooling) {
+			return j
+		}
+		if best < 0 || u.cooling.Before(bestUntil) {
+			best, bestUntil = j, u.cooling
+		}
+	}
+	return best
+}
+
+// reportKey records the outcome; rejected keys cool down and the pool moves on
+func reportKey(pool, key string, err error) {
+	if key == "" || err == nil {
+		return
+	}
+	kpMu.Lock()
+	defer kpMu.Unlock()
+	st := kpPools[pool]
+	if st == nil || st.usage[key] == nil {
+		return
+	}
+	u := st.usage[key]
+	u.Errors++
+	if isKeyRejected(err) {
+		u.Rejected++
+		u.cooling = time.Now().Add(max(keyCooldown, retryAfterOf(err)))
+		u.CoolingUntil = u.cooling.UTC().Format(time.RFC3339)
+		st.cur++ // failover mode: next pick starts from the following key
+	}
+}
+
+func redactKey(k string) string {
+	if len(k) > 6 {
+		return k[:6] + "..."
+	}
+	return k
+}
+
+// keyUsageSnapshot: pool -> per-key usage, keys redacted
+func keyUsageSnapshot() map[string][]KeyUsage {
+	kpMu.Lock()
+	defer kpMu.Unlock()
+	now := time.Now()
+	out := make(map[string][]KeyUsage, len(kpPools))
+	for pool, st := range kpPools {
+		rows := make([]KeyUsage, 0, len(st.usage))
+		for _, u := range st.usage {
+			row := *u
+			if !now.Before(u.cooling) {
+				row.CoolingUntil = ""
+			}
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+		out[pool] = rows
+	}
+	return out
+}
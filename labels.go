@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+	标签（key=value）
+	- Config.labels：本实例 / 状态机的标签；sources[].labels：每个源的标签（同名时源覆盖实例）
+	- 附加到信号与区块事件（labels 字段）、ON/OFF/HIT 日志行
+	- WS 订阅可按标签过滤：?label=team:a&label=env:prod（全部匹配才推送；无标签的主题不受影响）
+*/
+
+const (
+	labelsMax     = 16
+	labelValueMax = 64
+)
+
+var labelKeyRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,31}$`)
+
+func validateLabels(m map[string]string) error {
+	if len(m) > labelsMax {
+		return fmt.Errorf("at most %d labels", labelsMax)
+	}
+	for k, v := range m {
+		if !labelKeyRe.MatchString(k) {
+			return fmt.Errorf("label key %q: letters, digits, _ . - (max 32, not starting with a digit)", k)
+		}
+		if len(v) > labelValueMax || strings.ContainsAny(v, "\r\n") {
+			return errors.New("label " + k + ": value too long or multi-line")
+		}
+	}
+	return nil
+}
+
+// signalLabels: instance labels overlaid with the labels of the block's source
+func signalLabels(source string) map[string]string {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	var out map[string]string
+	set := func(m map[string]string) {
+		for k, v := range m {
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[k] = v
+		}
+	}
+	set(cfg.Labels)
+	for _, sc := range cfg.Sources {
+		if sc.ID == source {
+			set(sc.Labels)
+		}
+	}
+	return out
+}
+
+func copyLabels(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// formatLabels: stable "k=v,k=v" for log lines
+func formatLabels(m map[string]string) string {
+	parts := make([]string, 0, len(m))
+	for k, v := range m {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// logLabels: " labels=..." suffix, empty when there are none
+func logLabels(m map[string]string) string {
+	if len(m) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" labels=%q", formatLabels(m))
+}
+
+// labeled is implemented by stream payloads that carry labels
+type labeled interface {
+	labelSet() map[string]string
+}
+
+func (s Signal) labelSet() map[string]string     { return s.Labels }
+func (b BlockEvent) labelSet() map[string]string { return b.Labels }
+
+// parseLabelFilter: repeated ?label=key:value; malformed entries are ignored
+func parseLabelFilter(q url.Values) map[string]string {
+	var out map[string]string
+	for _, raw := range q["label"] {
+		k, v, ok := strings.Cut(raw, ":")
+		k = strings.TrimSpace(k)
+		if !ok || !labelKeyRe.MatchString(k) {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[k] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// labelsMatch: every filter pair present in have; payloads that carry no labels always pass
+func labelsMatch(filter map[string]string, v any) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	l, ok := v.(labeled)
+	if !ok {
+		return true
+	}
+	have := l.labelSet()
+	for k, want := range filter {
+		if have[k] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// GET  /api/admin/labels
+// POST /api/admin/labels {"labels":{"team":"a","env":"prod"}}  (replaces instance labels)
+func apiLabels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		cfgMu.RLock()
+		inst := copyLabels(cfg.Labels)
+		bySource := map[string]map[string]string{}
+		for _, sc := range cfg.Sources {
+			if len(sc.Labels) > 0 {
+				bySource[sc.ID] = copyLabels(sc.Labels)
+			}
+		}
+		cfgMu.RUnlock()
+		mustJSON(w, 200, map[string]any{"labels": inst, "sources": bySource})
+	case "POST":
+		var in struct {
+			Labels map[string]string `json:"labels"`
+		}
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateLabels(in.Labels); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.Labels = in.Labels
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		logger.Printf("LABELS_UPDATED labels=%q rid=%s", formatLabels(in.Labels), requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "labels": in.Labels})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	端到端延迟测量
+	区块时间戳 → 来源响应 → 去重接受 → ON/OFF 判定 → WS 广播完成 →（可选）客户端回执
+	每个阶段保留最近 latencyWindow 个样本，输出 p50/p90/p99/max（毫秒）
+
+	客户端回执（可选）：收到 block/signal 后回发 {"type":"ack","height":N}
+*/
+
+const (
+	latencyWindow = 500
+	ackHeights    = 256 // how many recent heights can be acked
+)
+
+const (
+	stageSourceDelay = "source_delay" // block timestamp -> source response
+	stageFetch       = "fetch"        // request sent -> response
+	stageAccept      = "accept"       // response -> dedupe accepted
+	stageJudge       = "judge"        // accepted -> ON/OFF judged
+	stageBroadcast   = "broadcast"    // judged -> block/signal WS writes done
+	stageTotal       = "total"        // block timestamp -> broadcast done
+	stageClientEcho  = "client_echo"  // broadcast done -> client ack received
+)
+
+var latencyStages = []string{
+	stageSourceDelay, stageFetch, stageAccept, stageJudge, stageBroadcast, stageTotal, stageClientEcho,
+}
+
+// latencyTrace follows one block through the pipeline (nil-safe)
+type latencyTrace struct {
+	Source     string // block origin (charts source win-rate)
+	BlockTime  time.Time
+	FetchStart time.Time
+	Response   time.Time
+	Accepted   time.Time
+	Judged     time.Time
+}
+
+type latencyRing struct {
+	buf  [latencyWindow]float64
+	n    int
+	next int
+}
+
+func (r *latencyRing) add(ms float64) {
+	r.buf[r.next] = ms
+	r.next = (r.next + 1) % latencyWindow
+	if r.n < latencyWindow {
+		r.n++
+	}
+}
+
+type LatencyStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+func (r *latencyRing) stats() LatencyStats {
+	if r.n == 0 {
+		return LatencyStats{}
+	}
+	v := append([]float64(nil), r.buf[:r.n]...)
+	sort.Float64s(v)
+	pct := func(p float64) float64 { return v[int(p*float64(len(v)-1)+0.5)] }
+	return LatencyStats{Count: r.n, P50: pct(0.50), P90: pct(0.90), P99: pct(0.99), Max: v[len(v)-1]}
+}
+
+var (
+	latMu    sync.Mutex
+	latRings = map[string]*latencyRing{}
+
+	// height -> broadcast-done time, for client acks
+	latSent      = map[int64]time.Time{}
+	latSentOrder []int64
+)
+
+func recordLatency(stage string, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	latMu.Lock()
+	defer latMu.Unlock()
+	r := latRings[stage]
+	if r == nil {
+		r = &latencyRing{}
+		latRings[stage] = r
+	}
+	r.add(float64(d.Microseconds()) / 1000)
+}
+
+// finish records all stages once the block's broadcasts are done
+func (tr *latencyTrace) finish(height int64, sent time.Time) {
+	if tr == nil || tr.Response.IsZero() { // nil or backfilled (not timed)
+		return
+	}
+	if !tr.BlockTime.IsZero() {
+		recordLatency(stageSourceDelay, tr.Response.Sub(tr.BlockTime))
+		recordLatency(stageTotal, sent.Sub(tr.BlockTime))
+	}
+	recordLatency(stageFetch, tr.Response.Sub(tr.FetchStart))
+	recordLatency(stageAccept, tr.Accepted.Sub(tr.Response))
+	recordLatency(stageJudge, tr.Judged.Sub(tr.Accepted))
+	recordLatency(stageBroadcast, sent.Sub(tr.Judged))
+
+	latMu.Lock()
+	if _, ok := latSent[height]; !ok {
+		latSentOrder = append(latSentOrder, height)
+		if len(latSentOrder) > ackHeights {
+			delete(latSent, latSentOrder[0])
+			latSentOrder = latSentOrder[1:]
+		}
+	}
+	latSent[height] = sent
+	latMu.Unlock()
+}
+
+// handleClientAck parses {"type":"ack","height":N} from a WS client
+func handleClientAck(payload []byte) {
+	var m struct {
+		Type   string `json:"type"`
+		Height int64  `json:"height"`
+	}
+	if json.Unmarshal(payload, &m) != nil || m.Type != "ack" {
+		return
+	}
+	latMu.Lock()
+	sent, ok := latSent[m.Height]
+	latMu.Unlock()
+	if ok {
+		recordLatency(stageClientEcho, time.Since(sent))
+	}
+}
+
+// GET /api/latency -> per-stage percentiles (ms) + block arrival intervals (jitter.go)
+func apiLatency(w http.ResponseWriter, r *http.Request) {
+	out := make(map[string]LatencyStats, len(latencyStages))
+	latMu.Lock()
+	for _, st := range latencyStages {
+		if rr := latRings[st]; rr != nil {
+			out[st] = rr.stats()
+		} else {
+			out[st] = LatencyStats{}
+		}
+	}
+	latMu.Unlock()
+	mustJSON(w, 200, map[string]any{"window": latencyWindow, "stages": out, "arrival": arrivalSnapshot()})
+}
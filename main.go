@@ -17,8 +17,11 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -36,8 +39,9 @@ import (
 	- ON/OFF 判定：hash 最后两位 “字母/数字 类型异或”
 	- 状态机：waitingReverse（触发后需先见反向状态才能重新计数）
 	- 信号广播：/ws 服务器端 WS 广播（不缓存、不重试、不确认）
+	  可选 ?topics=signal,status,block（或子协议 tron-signal.topics）同时订阅状态与区块
 	- SSE：/sse/status 推最新块信息给页面
-	- 重启：运行态强制清零（不恢复任何历史状态）
+	- 重启：运行态强制清零（不恢复任何历史状态）；唯一例外是按北京时间切日的每日计数 data/daily.json
 */
 
 const (
@@ -45,7 +49,7 @@ const (
 	dataDir      = "data"
 	configPath   = "data/config.json"
 	logDir       = "logs"
-	logRetention = 3 // days
+	logRetention = 3 // days (default; see Config.Retention)
 
 	ringSize = 50
 
@@ -59,13 +63,132 @@ const (
 // ---------- Config / Models ----------
 
 type Config struct {
+	// bumped on every save that changes the effective config (see configHash)
+	Version uint64 `json:"version"`
+
 	Web WebCred `json:"web"`
 
 	APIKeys []string `json:"apiKeys"`
 
+	// generic block sources; empty = TronGrid with APIKeys
+	Sources []SourceConfig `json:"sources"`
+
 	Rules Rules `json:"rules"`
 
 	Access AccessControl `json:"access"`
+
+	Session SessionCookie `json:"session"`
+
+	// nonce + timestamp signatures on admin mutations (replayguard.go)
+	ReplayGuard ReplayGuardConfig `json:"replayGuard"`
+
+	// per-token request limit for external consumers + soft warnings (tokenrate.go)
+	TokenRate TokenRateConfig `json:"tokenRate"`
+
+	// loopback-only lockout recovery endpoints (recovery.go)
+	Recovery RecoveryConfig `json:"recovery"`
+
+	// gzip/deflate for JSON / CSV / text responses (compress.go)
+	Compression CompressionConfig `json:"compression"`
+
+	// block arrival interval statistics and stall alerts (jitter.go)
+	Jitter JitterConfig `json:"jitter"`
+
+	// admin-defined computed status fields (derived.go)
+	Derived []DerivedField `json:"derived,omitempty"`
+
+	GeoIP GeoIPConfig `json:"geoip"`
+
+	AutoBan AutoBanConfig `json:"autoBan"`
+
+	// empty = single ":8080" listener with the admin policy
+	Listeners []ListenerConfig `json:"listeners"`
+
+	Retention RetentionConfig `json:"retention"`
+
+	Watchdog WatchdogConfig `json:"watchdog"`
+
+	Explorer ExplorerConfig `json:"explorer"`
+
+	Audit AuditConfig `json:"audit"`
+
+	Backfill BackfillConfig `json:"backfill"`
+
+	// judge a block only once the chain is N blocks past it (0 = immediately); see confirm.go
+	Confirmations int `json:"confirmations"`
+
+	// drills only: in-process mock of the downstream executor (executor.go)
+	Executor ExecutorSimConfig `json:"executorSim"`
+
+	// require N sources to agree on height+hash (consensus.go); 0/1 = first-to-return
+	Consensus ConsensusConfig `json:"consensus"`
+
+	// rolling per-source score, flapping sources are skipped for a while (health.go)
+	SourceHealth SourceHealthConfig `json:"sourceHealth"`
+
+	// exponential backoff with jitter on transient source errors (backoff.go)
+	Backoff BackoffConfig `json:"backoff"`
+
+	// per-source circuit breaker with half-open probes (breaker.go)
+	Breaker BreakerConfig `json:"breaker"`
+
+	// slow polling down while no WS/SSE consumer is connected (power.go)
+	PowerSave PowerSaveConfig `json:"powerSave"`
+
+	// instance labels attached to signals and block events (labels.go)
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// hash sanity checks before judging (hashcheck.go); empty = off
+	HashChecks HashCheckConfig `json:"hashChecks"`
+
+	// Ed25519 signatures on outbound signals (signing.go)
+	Signing SigningConfig `json:"signing"`
+
+	// live-tunable listener tick (tuning.go)
+	Tuning TuningConfig `json:"tuning"`
+
+	// feature flag overrides (see features.go); absent = flag default
+	Features map[string]bool `json:"features"`
+
+	// QA only: replace real block sources with a scripted scenario
+	Simulator SimulatorConfig `json:"simulator"`
+}
+
+// ExplorerConfig: block explorer deep links, e.g. "https://tronscan.org/#/block/{height}"
+// placeholders: {height} {hash}; empty = no explorerUrl field
+type ExplorerConfig struct {
+	BlockURL string `json:"blockUrl"`
+}
+
+// ListenerConfig: one HTTP(S) listener with its own auth policy
+type ListenerConfig struct {
+	Addr    string `json:"addr"`
+	TLSCert string `json:"tlsCert"` // both set => HTTPS
+	TLSKey  string `json:"tlsKey"`
+	Policy  string `json:"policy"` // "admin" (default) | "consumer" | "token"
+}
+
+const (
+	policyAdmin    = "admin"    // full UI + APIs; WS via session, whitelist or token
+	policyConsumer = "consumer" // WS only, via whitelist or token
+	policyToken    = "token"    // WS only, token required (no whitelist bypass)
+)
+
+// AutoBanConfig: ban an IP for BanMinutes after MaxFailures auth failures within WindowMinutes
+type AutoBanConfig struct {
+	Enabled       bool `json:"enabled"`
+	MaxFailures   int  `json:"maxFailures"`   // default 5
+	WindowMinutes int  `json:"windowMinutes"` // default 10
+	BanMinutes    int  `json:"banMinutes"`    // default 30
+}
+
+// SessionCookie controls the TSID cookie; zero value keeps the historical defaults
+// (no Secure flag, SameSite=Lax, host-only, no server-side expiry).
+type SessionCookie struct {
+	Secure     bool   `json:"secure"`     // set behind HTTPS reverse proxies
+	SameSite   string `json:"sameSite"`   // "lax"|"strict"|"none" (none forces Secure)
+	Domain     string `json:"domain"`     // empty = host-only
+	TTLMinutes int    `json:"ttlMinutes"` // 0 = until logout/restart
 }
 
 type WebCred struct {
@@ -77,13 +200,16 @@ type WebCred struct {
 
 type AccessControl struct {
 	IPWhitelist []string          `json:"ipWhitelist"`
-	Tokens      map[string]uint64 `json:"tokens"` // token -> usage count
+	Tokens      map[string]uint64 `json:"tokens"`               // token -> usage count
+	TokenNames  map[string]string `json:"tokenNames,omitempty"` // token -> consumer name (bulk provisioning)
 }
 
 type Rules struct {
 	On  ThresholdRule `json:"on"`
 	Off ThresholdRule `json:"off"`
 	Hit HitRule       `json:"hit"`
+
+	Debounce int `json:"debounce,omitempty"` // flips must last K blocks to reach the machine (debounce.go); 0 = off
 }
 
 type ThresholdRule struct {
@@ -104,6 +230,48 @@ type Status struct {
 	LastTimeISO   string `json:"lastTimeISO"`
 	Reconnects    uint64 `json:"reconnects"`
 	ConnectedKeys int    `json:"connectedKeys"`
+
+	Today DailyCounters `json:"today"` // 今日计数（北京时间 0 点切换，重启不清零）
+
+	// all sources failing (or warm start): lastHeight/lastHash are the last known block
+	Stale           bool    `json:"stale"`
+	StaleAgeSeconds float64 `json:"staleAgeSeconds,omitempty"`
+	StaleReason     string  `json:"staleReason,omitempty"` // sources_down | degraded | warm_start
+
+	ConfigVersion uint64 `json:"configVersion"`
+	ConfigHash    string `json:"configHash"`
+
+	// previous run's exit report (data/last_shutdown.json)
+	LastShutdown *ShutdownReport `json:"lastShutdown,omitempty"`
+
+	InboxUnread int `json:"inboxUnread"`
+
+	// blocks waiting for Config.Confirmations
+	PendingConfirm int `json:"pendingConfirm,omitempty"`
+
+	// blocks held back by hash quality checks, awaiting review
+	Quarantined int `json:"quarantined,omitempty"`
+
+	// "active" | "idle" when power saving is on (power.go)
+	PowerMode string `json:"powerMode,omitempty"`
+
+	// lastHeight/lastHash come from the previous run's snapshot (warmstart.go)
+	WarmStart bool `json:"warmStart,omitempty"`
+
+	// no ON/OFF rule enabled: blocks only, no signals
+	WatchOnly bool `json:"watchOnly,omitempty"`
+
+	// runner phase for dashboards (degrade.go): running | fail_wait | paused
+	Phase               string  `json:"phase"`
+	NextRetry           string  `json:"nextRetry,omitempty"` // fail_wait: next probe (RFC 3339)
+	NextRetryInSeconds  float64 `json:"nextRetryInSeconds,omitempty"`
+	ConsecutiveFailures int     `json:"consecutiveFailures"`
+
+	// poll race winners over the last hour (races.go)
+	Races *RaceSummary `json:"races,omitempty"`
+
+	// admin-defined fields computed from the values above (derived.go)
+	Derived map[string]float64 `json:"derived,omitempty"`
 }
 
 // Signal broadcast to trading program
@@ -113,6 +281,22 @@ type Signal struct {
 	BaseHeight int64  `json:"baseHeight"` // trigger base height (for HIT: trigger base)
 	State      string `json:"state"`      // "ON"|"OFF" (for HIT: the state observed at t+x)
 	TimeISO    string `json:"time"`       // ISO timestamp
+
+	ExplorerURL string `json:"explorerUrl,omitempty"` // block at Height
+
+	// instance + source labels (labels.go)
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// config identity at emit time (drift detection)
+	ConfigVersion uint64 `json:"configVersion"`
+	ConfigHash    string `json:"configHash"`
+
+	// Ed25519 signature when signing is on (signing.go); simulated signals are never signed
+	KeyID string `json:"keyId,omitempty"`
+	Sig   string `json:"sig,omitempty"`
+
+	// from the scenario simulator (simulator.go), not the chain
+	Simulated bool `json:"simulated,omitempty"`
 }
 
 // ---------- Globals (runtime state must be reset every boot) ----------
@@ -121,9 +305,9 @@ var (
 	cfgMu sync.RWMutex
 	cfg   Config
 
-	// sessions: token -> username
+	// sessions: token -> session
 	sessMu   sync.Mutex
-	sessions = map[string]string{}
+	sessions = map[string]session{}
 
 	// runtime: forced reset every start
 	rtMu sync.Mutex
@@ -135,7 +319,7 @@ var (
 
 	// sse subscribers
 	sseMu   sync.Mutex
-	sseSubs = map[chan Status]struct{}{}
+	sseSubs = map[chan sseEvent]*sseSub{}
 
 	// logger
 	logger *log.Logger
@@ -168,6 +352,9 @@ type RuntimeState struct {
 
 	// listening
 	Listening bool
+
+	// no ON/OFF rule can trigger: blocks are judged and streamed, state machine idle
+	WatchOnly bool
 }
 
 type ringBuffer struct {
@@ -231,37 +418,22 @@ func rotateLogs() (*os.File, error) {
 	if err != nil {
 		return nil, err
 	}
+	activeLogPath = path
 
-	// cleanup old logs
-	entries, err := os.ReadDir(logDir)
-	if err == nil {
-		cutoff := time.Now().AddDate(0, 0, -logRetention)
-		for _, e := range entries {
-			if e.IsDir() {
-				continue
-			}
-			// parse date prefix
-			fn := e.Name()
-			if !strings.HasSuffix(fn, ".log") || len(fn) < len("2006-01-02.log") {
-				continue
-			}
-			ds := strings.TrimSuffix(fn, ".log")
-			t, parseErr := time.Parse("2006-01-02", ds)
-			if parseErr != nil {
-				continue
-			}
-			if t.Before(cutoff) {
-				_ = os.Remove(filepath.Join(logDir, fn))
-			}
-		}
-	}
+	// cleanup old logs (default policy; the compactor re-applies the configured one)
+	pruneLogs(logRetention)
 
 	return f, nil
 }
 
 func loadConfig() (Config, error) {
+	return loadConfigFile(configPath)
+}
+
+// loadConfigFile decodes the config at path (missing = defaults)
+func loadConfigFile(path string) (Config, error) {
 	var c Config
-	b, err := os.ReadFile(configPath)
+	b, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// defaults
@@ -279,7 +451,18 @@ func loadConfig() (Config, error) {
 	return c, nil
 }
 
+// saveConfigLocked persists c (always the global cfg; caller holds cfgMu).
+// The version is bumped only when the effective config hash changes, so
+// token usage counters don't churn it.
 func saveConfigLocked(c Config) error {
+	if h := configHash(c); h != cfgHashSaved {
+		if cfgHashSaved != "" {
+			c.Version++
+			cfg.Version = c.Version
+		}
+		cfgHashSaved = h
+	}
+
 	tmp := configPath + ".tmp"
 	b, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
@@ -291,6 +474,29 @@ func saveConfigLocked(c Config) error {
 	return os.Rename(tmp, configPath)
 }
 
+var cfgHashSaved string // guarded by cfgMu
+
+// configHash: sha256 (first 16 hex) over the effective config, excluding
+// instance-local parts (web credentials, token usage counts, version)
+func configHash(c Config) string {
+	c.Version = 0
+	c.Web = WebCred{}
+	toks := make(map[string]uint64, len(c.Access.Tokens))
+	for t := range c.Access.Tokens {
+		toks[t] = 0
+	}
+	c.Access.Tokens = toks
+	b, _ := json.Marshal(c)
+	return sha256Hex(string(b))[:16]
+}
+
+// configIdentity returns (version, hash) of the live config
+func configIdentity() (uint64, string) {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Version, configHash(cfg)
+}
+
 func randHex(n int) (string, error) {
 	buf := make([]byte, n)
 	if _, err := rand.Read(buf); err != nil {
@@ -304,6 +510,57 @@ func sha256Hex(s string) string {
 	return hex.EncodeToString(h[:])
 }
 
+// ---------- Request ID ----------
+
+type ctxKey int
+
+const (
+	ctxKeyRequestID ctxKey = iota
+	ctxKeyListenerPolicy
+)
+
+// withRequestID accepts a sane inbound X-Request-ID (or generates one),
+// stores it in the request context and echoes it on the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rid := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if !validRequestID(rid) {
+			rid, _ = randHex(8)
+		}
+		w.Header().Set("X-Request-ID", rid)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyRequestID, rid)))
+	})
+}
+
+func validRequestID(s string) bool {
+	if s == "" || len(s) > 64 {
+		return false
+	}
+	for _, c := range s {
+		ok := c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	rid, _ := r.Context().Value(ctxKeyRequestID).(string)
+	return rid
+}
+
+// httpError is http.Error plus the request id, so users can quote it in support requests
+func httpError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if rid := requestID(r); rid != "" {
+		msg += " (request_id=" + rid + ")"
+	}
+	http.Error(w, msg, code)
+}
+
 func mustJSON(w http.ResponseWriter, code int, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
@@ -326,6 +583,16 @@ func readJSON(r *http.Request, dst any) error {
 
 // ---------- Auth ----------
 
+type session struct {
+	User    string
+	Expires time.Time // zero = no expiry
+	SignKey string    // HMAC key for signed admin mutations (replayguard.go)
+}
+
+func (s session) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
+
 func isLoggedIn(r *http.Request) bool {
 	c, err := r.Cookie("TSID")
 	if err != nil || c.Value == "" {
@@ -333,10 +600,61 @@ func isLoggedIn(r *http.Request) bool {
 	}
 	sessMu.Lock()
 	defer sessMu.Unlock()
-	_, ok := sessions[c.Value]
+	s, ok := sessions[c.Value]
+	if ok && s.expired(time.Now()) {
+		delete(sessions, c.Value)
+		return false
+	}
 	return ok
 }
 
+// hasActiveSession drops expired sessions and reports whether any remain (listener gate)
+func hasActiveSession() bool {
+	now := time.Now()
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	for id, s := range sessions {
+		if s.expired(now) {
+			delete(sessions, id)
+		}
+	}
+	return len(sessions) > 0
+}
+
+func sameSiteMode(v string) http.SameSite {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// sessionCookie builds the TSID cookie from config; maxAge<0 deletes it
+func sessionCookie(value string, maxAge int) *http.Cookie {
+	cfgMu.RLock()
+	sc := cfg.Session
+	cfgMu.RUnlock()
+
+	c := &http.Cookie{
+		Name:     "TSID",
+		Value:    value,
+		Path:     "/",
+		Domain:   sc.Domain,
+		HttpOnly: true,
+		Secure:   sc.Secure,
+		SameSite: sameSiteMode(sc.SameSite),
+		MaxAge:   maxAge,
+	}
+	// browsers reject SameSite=None without Secure
+	if c.SameSite == http.SameSiteNoneMode {
+		c.Secure = true
+	}
+	return c
+}
+
 func requireLogin(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cfgMu.RLock()
@@ -359,6 +677,93 @@ func requireLogin(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ---------- Admin mutation guard ----------
+
+const (
+	adminMutationsPerMinute = 20
+)
+
+var (
+	adminRLMu sync.Mutex
+	adminRL   = map[string][]time.Time{} // client ip -> recent mutation times
+)
+
+// requireAdmin = login + adminGuard; use for every endpoint that can change state
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireLogin(adminGuard(next))
+}
+
+// adminGuard applies to non-GET requests: per-IP rate limit, and an explicit
+// confirmation for destructive ones (DELETE, or handlers wrapped by requireConfirm).
+func adminGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			next(w, r)
+			return
+		}
+		if wait, ok := adminRateAllow(remoteIP(r)); !ok {
+			logger.Printf("ADMIN_RATE_LIMITED ip=%s path=%s rid=%s", remoteIP(r), r.URL.Path, requestID(r))
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			httpError(w, r, "too many admin requests", http.StatusTooManyRequests)
+			return
+		}
+		if r.Method == "DELETE" && !confirmed(r) {
+			httpError(w, r, "confirm required (?confirm=yes or X-Confirm: yes)", http.StatusPreconditionRequired)
+			return
+		}
+		if err := replayCheck(r); err != nil {
+			logger.Printf("REPLAY_REJECTED ip=%s method=%s path=%s reason=%q rid=%s", remoteIP(r), r.Method, r.URL.Path, err.Error(), requestID(r))
+			httpError(w, r, "request signature: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireConfirm marks a non-DELETE handler (reset/wipe) as destructive
+func requireConfirm(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" && !confirmed(r) {
+			httpError(w, r, "confirm required (?confirm=yes or X-Confirm: yes)", http.StatusPreconditionRequired)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func confirmed(r *http.Request) bool {
+	v := r.Header.Get("X-Confirm")
+	if v == "" {
+		v = r.URL.Query().Get("confirm")
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// adminRateAllow: sliding window of one minute; returns wait time when denied
+func adminRateAllow(ip string) (time.Duration, bool) {
+	now := time.Now()
+	cutoff := now.Add(-time.Minute)
+
+	adminRLMu.Lock()
+	defer adminRLMu.Unlock()
+	kept := adminRL[ip][:0]
+	for _, t := range adminRL[ip] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= adminMutationsPerMinute {
+		adminRL[ip] = kept
+		return kept[0].Sub(cutoff), false
+	}
+	adminRL[ip] = append(kept, now)
+	return 0, true
+}
+
 func setupPage(w http.ResponseWriter, r *http.Request) {
 	cfgMu.RLock()
 	initialized := cfg.Web.Initialized
@@ -383,19 +788,19 @@ func setupPage(w http.ResponseWriter, r *http.Request) {
 
 func setupSubmit(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad form", http.StatusBadRequest)
+		httpError(w, r, "bad form", http.StatusBadRequest)
 		return
 	}
 	u := strings.TrimSpace(r.FormValue("u"))
 	p := r.FormValue("p")
 	if u == "" || p == "" {
-		http.Error(w, "username/password required", http.StatusBadRequest)
+		httpError(w, r, "username/password required", http.StatusBadRequest)
 		return
 	}
 
 	salt, err := randHex(16)
 	if err != nil {
-		http.Error(w, "rand failed", http.StatusInternalServerError)
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
 		return
 	}
 	hash := sha256Hex(salt + ":" + p)
@@ -413,7 +818,7 @@ func setupSubmit(w http.ResponseWriter, r *http.Request) {
 		HashHex:     hash,
 	}
 	if err := saveConfigLocked(cfg); err != nil {
-		http.Error(w, "save config failed", http.StatusInternalServerError)
+		httpError(w, r, "save config failed", http.StatusInternalServerError)
 		return
 	}
 	logger.Println("SYSTEM_SETUP_DONE")
@@ -443,7 +848,7 @@ func loginPage(w http.ResponseWriter, r *http.Request) {
 
 func loginSubmit(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad form", http.StatusBadRequest)
+		httpError(w, r, "bad form", http.StatusBadRequest)
 		return
 	}
 	u := strings.TrimSpace(r.FormValue("u"))
@@ -459,31 +864,47 @@ func loginSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if u != web.Username {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		authFailed(r, "login_user")
+		httpError(w, r, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 	hash := sha256Hex(web.SaltHex + ":" + p)
 	if hash != web.HashHex {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		authFailed(r, "login_password")
+		httpError(w, r, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	sid, err := randHex(24)
 	if err != nil {
-		http.Error(w, "rand failed", http.StatusInternalServerError)
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
+		return
+	}
+
+	cfgMu.RLock()
+	ttl := time.Duration(cfg.Session.TTLMinutes) * time.Minute
+	cfgMu.RUnlock()
+	signKey, err := randHex(32)
+	if err != nil {
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
 		return
 	}
+	sess := session{User: u, SignKey: signKey}
+	maxAge := 0
+	if ttl > 0 {
+		sess.Expires = time.Now().Add(ttl)
+		maxAge = int(ttl / time.Second)
+	}
+
 	sessMu.Lock()
-	sessions[sid] = u
+	// rotate: never keep a pre-login session id alive (fixation)
+	if old, err := r.Cookie("TSID"); err == nil && old.Value != "" {
+		delete(sessions, old.Value)
+	}
+	sessions[sid] = sess
 	sessMu.Unlock()
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "TSID",
-		Value:    sid,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	http.SetCookie(w, sessionCookie(sid, maxAge))
 
 	// login gate satisfied -> attempt start listener if keys available
 	tryStartListener()
@@ -498,32 +919,15 @@ func logout(w http.ResponseWriter, r *http.Request) {
 		delete(sessions, c.Value)
 		sessMu.Unlock()
 	}
-	http.SetCookie(w, &http.Cookie{Name: "TSID", Value: "", Path: "/", MaxAge: -1})
+	http.SetCookie(w, sessionCookie("", -1))
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
 // ---------- Access control (optional; used for external HTTP only) ----------
 
 func ipAllowed(remoteAddr string, whitelist []string) bool {
-	host, _, err := net.SplitHostPort(remoteAddr)
-	if err != nil {
-		host = remoteAddr
-	}
-	ip := net.ParseIP(host)
-	if ip == nil {
-		return false
-	}
-	for _, w := range whitelist {
-		w = strings.TrimSpace(w)
-		if w == "" {
-			continue
-		}
-		// exact match (simple)
-		if ip.String() == w {
-			return true
-		}
-	}
-	return false
+	_, ok := whitelistMatch(net.ParseIP(hostOnly(remoteAddr)), whitelist)
+	return ok
 }
 
 func tokenOK(r *http.Request) (string, bool) {
@@ -545,16 +949,34 @@ func externalGuard(next http.HandlerFunc) http.HandlerFunc {
 		// internal pages should already be protected by login; this is for external APIs if you want
 		cfgMu.RLock()
 		whitelist := append([]string(nil), cfg.Access.IPWhitelist...)
+		geo := cfg.GeoIP
 		cfgMu.RUnlock()
 
-		if ipAllowed(r.RemoteAddr, whitelist) {
+		if listenerPolicy(r) != policyToken && ipAllowed(r.RemoteAddr, whitelist) {
 			next(w, r)
 			return
 		}
 
+		if geoEnabled() {
+			if country := geoCountry(r.RemoteAddr); !geoAllowed(country, geo) {
+				logger.Printf("GEO_BLOCKED remote=%s country=%q path=%s rid=%s", r.RemoteAddr, country, r.URL.Path, requestID(r))
+				httpError(w, r, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
 		tok, ok := tokenOK(r)
 		if !ok {
-			http.Error(w, "token required", http.StatusUnauthorized)
+			reason := "token_missing"
+			if tok != "" {
+				reason = "token_invalid"
+			}
+			authFailed(r, reason)
+			httpError(w, r, "token required", http.StatusUnauthorized)
+			return
+		}
+
+		if !tokenRateCheck(w, r, tok) {
 			return
 		}
 
@@ -567,6 +989,282 @@ func externalGuard(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// wsGuard: admin session (admin listeners only), or external consumer via IP whitelist / token
+func wsGuard(next http.HandlerFunc) http.HandlerFunc {
+	guarded := externalGuard(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if listenerPolicy(r) == policyAdmin && isLoggedIn(r) {
+			next(w, r)
+			return
+		}
+		guarded(w, r)
+	}
+}
+
+// ---------- Token provisioning (deep link + QR) ----------
+
+const provisionScheme = "tronsignal://provision"
+
+// requestBaseURL: scheme://host as seen by the client (honors reverse proxy header)
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); p == "http" || p == "https" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host
+}
+
+func provisionLink(server, token string) string {
+	q := url.Values{}
+	q.Set("server", server)
+	q.Set("token", token)
+	q.Set("ws", "/ws")
+	return provisionScheme + "?" + q.Encode()
+}
+
+// POST /api/admin/tokens/provision {"server": optional override}
+func apiProvisionToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Server string `json:"server"`
+	}
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	server := strings.TrimRight(strings.TrimSpace(req.Server), "/")
+	if server == "" {
+		server = requestBaseURL(r)
+	}
+
+	tok, err := randHex(16)
+	if err != nil {
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
+		return
+	}
+
+	cfgMu.Lock()
+	cfg.Access.Tokens[tok] = 0
+	if err := saveConfigLocked(cfg); err != nil {
+		delete(cfg.Access.Tokens, tok)
+		cfgMu.Unlock()
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	cfgMu.Unlock()
+
+	logger.Printf("TOKEN_PROVISIONED token=%s... rid=%s", tok[:6], requestID(r))
+
+	mustJSON(w, 200, map[string]any{
+		"ok":    true,
+		"token": tok,
+		"link":  provisionLink(server, tok),
+		"qr":    "/api/admin/tokens/qr?" + url.Values{"token": {tok}, "server": {server}}.Encode(),
+	})
+}
+
+// GET /api/admin/tokens/qr?token=...&server=... -> image/svg+xml
+func apiTokenQR(w http.ResponseWriter, r *http.Request) {
+	tok := strings.TrimSpace(r.URL.Query().Get("token"))
+	cfgMu.RLock()
+	_, ok := cfg.Access.Tokens[tok]
+	cfgMu.RUnlock()
+	if tok == "" || !ok {
+		httpError(w, r, "unknown token", http.StatusNotFound)
+		return
+	}
+	server := strings.TrimRight(strings.TrimSpace(r.URL.Query().Get("server")), "/")
+	if server == "" {
+		server = requestBaseURL(r)
+	}
+
+	q, err := qrEncode(provisionLink(server, tok))
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, q.SVG())
+}
+
+// ---------- Auth failures / auto-ban ----------
+
+type banEntry struct {
+	Failures []time.Time // within window
+	Until    time.Time   // zero = not banned
+	Reason   string
+}
+
+var (
+	banMu sync.Mutex
+	bans  = map[string]*banEntry{} // ip -> entry (runtime only)
+)
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func autoBanSettings() (AutoBanConfig, []string) {
+	cfgMu.RLock()
+	ab := cfg.AutoBan
+	wl := append([]string(nil), cfg.Access.IPWhitelist...)
+	cfgMu.RUnlock()
+	if ab.MaxFailures <= 0 {
+		ab.MaxFailures = 5
+	}
+	if ab.WindowMinutes <= 0 {
+		ab.WindowMinutes = 10
+	}
+	if ab.BanMinutes <= 0 {
+		ab.BanMinutes = 30
+	}
+	return ab, wl
+}
+
+// authFailed logs one fail2ban-friendly line and feeds the auto-ban counter.
+// fail2ban failregex: AUTH_FAIL ip=<HOST>  (the event code is appended at the end of the line)
+func authFailed(r *http.Request, reason string) {
+	ip := remoteIP(r)
+	logger.Printf("AUTH_FAIL ip=%s reason=%s path=%s rid=%s", ip, reason, r.URL.Path, requestID(r))
+
+	ab, wl := autoBanSettings()
+	if !ab.Enabled || ipAllowed(r.RemoteAddr, wl) {
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(ab.WindowMinutes) * time.Minute)
+
+	banMu.Lock()
+	defer banMu.Unlock()
+	e := bans[ip]
+	if e == nil {
+		e = &banEntry{}
+		bans[ip] = e
+	}
+	kept := e.Failures[:0]
+	for _, t := range e.Failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	e.Failures = append(kept, now)
+	if len(e.Failures) >= ab.MaxFailures && !e.Until.After(now) {
+		e.Until = now.Add(time.Duration(ab.BanMinutes) * time.Minute)
+		e.Reason = "auto"
+		e.Failures = nil
+		logger.Printf("AUTH_BAN ip=%s until=%s reason=auto", ip, e.Until.UTC().Format(time.RFC3339))
+	}
+}
+
+func isBanned(ip string) bool {
+	now := time.Now()
+	banMu.Lock()
+	defer banMu.Unlock()
+	e := bans[ip]
+	if e == nil {
+		return false
+	}
+	if e.Until.IsZero() || now.After(e.Until) {
+		if len(e.Failures) == 0 {
+			delete(bans, ip)
+		}
+		return false
+	}
+	return true
+}
+
+// withBanGuard rejects banned IPs before any handler runs
+func withBanGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isBanned(remoteIP(r)) {
+			httpError(w, r, "banned", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type BanInfo struct {
+	IP       string `json:"ip"`
+	Until    string `json:"until,omitempty"`
+	Failures int    `json:"failures"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// GET list / POST {"ip","minutes"} manual ban / DELETE ?ip= (or ?all=1) unban
+func apiBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		now := time.Now()
+		banMu.Lock()
+		out := make([]BanInfo, 0, len(bans))
+		for ip, e := range bans {
+			bi := BanInfo{IP: ip, Failures: len(e.Failures)}
+			if e.Until.After(now) {
+				bi.Until = e.Until.UTC().Format(time.RFC3339)
+				bi.Reason = e.Reason
+			}
+			out = append(out, bi)
+		}
+		banMu.Unlock()
+		sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+		mustJSON(w, 200, map[string]any{"bans": out})
+
+	case "POST":
+		var req struct {
+			IP      string `json:"ip"`
+			Minutes int    `json:"minutes"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ip := net.ParseIP(strings.TrimSpace(req.IP))
+		if ip == nil {
+			httpError(w, r, "invalid ip", http.StatusBadRequest)
+			return
+		}
+		if req.Minutes <= 0 {
+			ab, _ := autoBanSettings()
+			req.Minutes = ab.BanMinutes
+		}
+		until := time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+		banMu.Lock()
+		bans[ip.String()] = &banEntry{Until: until, Reason: "manual"}
+		banMu.Unlock()
+		logger.Printf("AUTH_BAN ip=%s until=%s reason=manual rid=%s", ip, until.UTC().Format(time.RFC3339), requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+
+	case "DELETE":
+		q := r.URL.Query()
+		banMu.Lock()
+		n := 0
+		if q.Get("all") == "1" {
+			n = len(bans)
+			bans = map[string]*banEntry{}
+		} else if ip := strings.TrimSpace(q.Get("ip")); ip != "" {
+			if _, ok := bans[ip]; ok {
+				delete(bans, ip)
+				n = 1
+			}
+		}
+		banMu.Unlock()
+		logger.Printf("AUTH_UNBAN count=%d rid=%s", n, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "removed": n})
+
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
 // ---------- Web UI static ----------
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
@@ -581,6 +1279,24 @@ func staticHandler() http.Handler {
 // ---------- API endpoints ----------
 
 func apiStatus(w http.ResponseWriter, r *http.Request) {
+	mustJSON(w, 200, currentStatus())
+}
+
+// currentStatus snapshots runtime state for /api/status, SSE and WS
+func currentStatus() Status {
+	st := runtimeStatus()
+	st.Derived = derivedValues(st)
+	return st
+}
+
+func runtimeStatus() Status {
+	ver, hash := configIdentity()
+	stale, age, staleReason := degradeState()
+	failures, nextRetry := degradeRetry()
+	cfgMu.RLock()
+	watchOnly := !cfg.Rules.machineEnabled()
+	cfgMu.RUnlock()
+
 	rtMu.Lock()
 	defer rtMu.Unlock()
 
@@ -591,8 +1307,52 @@ func apiStatus(w http.ResponseWriter, r *http.Request) {
 		LastTimeISO:   isoOrEmpty(rt.LastTime),
 		Reconnects:    atomic.LoadUint64(&reconnects),
 		ConnectedKeys: currentKeyCount(),
+		Today:         dailySnapshot(),
+
+		Stale:           stale,
+		StaleAgeSeconds: age,
+		StaleReason:     staleReason,
+
+		ConfigVersion: ver,
+		ConfigHash:    hash,
+
+		LastShutdown: lastShutdownReport(),
+
+		InboxUnread: inboxUnread(),
+
+		PendingConfirm: pendingConfirmations(),
+
+		Quarantined: quarantineCount(),
+
+		PowerMode: currentPowerMode(),
+
+		WatchOnly: watchOnly,
+
+		Races: raceSummary(),
+
+		Phase:               phaseRunning,
+		ConsecutiveFailures: failures,
 	}
-	mustJSON(w, 200, st)
+	switch {
+	case !st.Listening:
+		st.Phase = phasePaused
+	case !nextRetry.IsZero():
+		st.Phase = phaseFailWait
+		st.NextRetry = nextRetry.UTC().Format(time.RFC3339)
+		st.NextRetryInSeconds = max(time.Until(nextRetry).Seconds(), 0)
+	}
+	// until the first live block: last known good block from the previous run
+	if lg := warmSnapshot(); lg != nil && st.LastHeight == 0 {
+		st.LastHeight, st.LastHash, st.LastTimeISO = lg.Height, lg.Hash, lg.TimeISO
+		st.Stale, st.WarmStart = true, true
+		if st.StaleReason == "" {
+			st.StaleReason = staleWarmStart
+		}
+		if t, err := time.Parse(time.RFC3339Nano, lg.TimeISO); err == nil {
+			st.StaleAgeSeconds = time.Since(t).Seconds()
+		}
+	}
+	return st
 }
 
 func apiGetAPIKeys(w http.ResponseWriter, r *http.Request) {
@@ -606,36 +1366,21 @@ func apiSetAPIKeys(w http.ResponseWriter, r *http.Request) {
 		APIKeys []string `json:"apiKeys"`
 	}
 	if err := readJSON(r, &req); err != nil {
-		http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	keys := make([]string, 0, 3)
-	seen := map[string]struct{}{}
-	for _, k := range req.APIKeys {
-		k = strings.TrimSpace(k)
-		if k == "" {
-			continue
-		}
-		if _, ok := seen[k]; ok {
-			continue
-		}
-		seen[k] = struct{}{}
-		keys = append(keys, k)
-		if len(keys) >= 3 {
-			break
-		}
-	}
+	keys := sanitizeAPIKeys(req.APIKeys)
 
 	cfgMu.Lock()
 	cfg.APIKeys = keys
 	if err := saveConfigLocked(cfg); err != nil {
 		cfgMu.Unlock()
-		http.Error(w, "save failed", http.StatusInternalServerError)
+		httpError(w, r, "save failed", http.StatusInternalServerError)
 		return
 	}
 	cfgMu.Unlock()
 
-	logger.Printf("APIKEYS_UPDATED count=%d", len(keys))
+	logger.Printf("APIKEYS_UPDATED count=%d rid=%s", len(keys), requestID(r))
 
 	// hot-update listener start/stop
 	tryStartListener()
@@ -646,6 +1391,27 @@ func apiSetAPIKeys(w http.ResponseWriter, r *http.Request) {
 	mustJSON(w, 200, map[string]any{"ok": true, "apiKeys": keys})
 }
 
+// sanitizeAPIKeys: trim, dedupe, max 3
+func sanitizeAPIKeys(in []string) []string {
+	keys := make([]string, 0, 3)
+	seen := map[string]struct{}{}
+	for _, k := range in {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+		if len(keys) >= 3 {
+			break
+		}
+	}
+	return keys
+}
+
 func apiGetRules(w http.ResponseWriter, r *http.Request) {
 	cfgMu.RLock()
 	defer cfgMu.RUnlock()
@@ -655,38 +1421,281 @@ func apiGetRules(w http.ResponseWriter, r *http.Request) {
 func apiSetRules(w http.ResponseWriter, r *http.Request) {
 	var rr Rules
 	if err := readJSON(r, &rr); err != nil {
-		http.Error(w, "bad json: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rr = sanitizeRules(rr)
+
+	cfgMu.Lock()
+	cfg.Rules = rr
+	if err := saveConfigLocked(cfg); err != nil {
+		cfgMu.Unlock()
+		httpError(w, r, "save failed", http.StatusInternalServerError)
 		return
 	}
-	// sanitize
+	cfgMu.Unlock()
+
+	logger.Printf("RULES_UPDATED on=(%v,%d) off=(%v,%d) hit=(%v,expect=%s,offset=%d) debounce=%d rid=%s",
+		rr.On.Enabled, rr.On.Threshold, rr.Off.Enabled, rr.Off.Threshold, rr.Hit.Enabled, rr.Hit.Expect, rr.Hit.Offset, rr.Debounce, requestID(r))
+
+	mustJSON(w, 200, map[string]any{"ok": true, "rules": rr})
+}
+
+func sanitizeRules(rr Rules) Rules {
 	rr.On.Threshold = clamp(rr.On.Threshold, 0, 20)
 	rr.Off.Threshold = clamp(rr.Off.Threshold, 0, 20)
 	rr.Hit.Offset = clamp(rr.Hit.Offset, 1, 20)
+	rr.Debounce = clamp(rr.Debounce, 0, maxDebounce)
 	rr.Hit.Expect = strings.ToUpper(strings.TrimSpace(rr.Hit.Expect))
 	if rr.Hit.Expect != "ON" && rr.Hit.Expect != "OFF" {
 		rr.Hit.Expect = "ON"
 	}
+	return rr
+}
+
+// machineEnabled: false when neither ON nor OFF can ever trigger (HIT only arms after a trigger)
+func (rr Rules) machineEnabled() bool {
+	return (rr.On.Enabled && rr.On.Threshold > 0) || (rr.Off.Enabled && rr.Off.Threshold > 0)
+}
+
+// ---------- Block explorer deep links ----------
+
+func apiGetExplorer(w http.ResponseWriter, r *http.Request) {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	mustJSON(w, 200, cfg.Explorer)
+}
+
+func apiSetExplorer(w http.ResponseWriter, r *http.Request) {
+	var ec ExplorerConfig
+	if err := readJSON(r, &ec); err != nil {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ec.BlockURL = strings.TrimSpace(ec.BlockURL)
+	if err := validateExplorerTemplate(ec.BlockURL); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	cfgMu.Lock()
-	cfg.Rules = rr
+	cfg.Explorer = ec
 	if err := saveConfigLocked(cfg); err != nil {
 		cfgMu.Unlock()
-		http.Error(w, "save failed", http.StatusInternalServerError)
+		httpError(w, r, "save failed", http.StatusInternalServerError)
 		return
 	}
 	cfgMu.Unlock()
 
-	logger.Printf("RULES_UPDATED on=(%v,%d) off=(%v,%d) hit=(%v,expect=%s,offset=%d)",
-		rr.On.Enabled, rr.On.Threshold, rr.Off.Enabled, rr.Off.Threshold, rr.Hit.Enabled, rr.Hit.Expect, rr.Hit.Offset)
+	logger.Printf("EXPLORER_UPDATED blockUrl=%q rid=%s", ec.BlockURL, requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "explorer": ec})
+}
+
+func validateExplorerTemplate(tpl string) error {
+	if tpl == "" {
+		return nil
+	}
+	if !strings.Contains(tpl, "{height}") && !strings.Contains(tpl, "{hash}") {
+		return errors.New("blockUrl must contain {height} or {hash}")
+	}
+	u, err := url.Parse(strings.NewReplacer("{height}", "1", "{hash}", "0").Replace(tpl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("blockUrl must be an http(s) URL")
+	}
+	return nil
+}
+
+// explorerURL renders the configured template; {hash} templates need a hash
+func explorerURL(height int64, hash string) string {
+	cfgMu.RLock()
+	tpl := cfg.Explorer.BlockURL
+	cfgMu.RUnlock()
+	if tpl == "" || (hash == "" && strings.Contains(tpl, "{hash}")) {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{height}", strconv.FormatInt(height, 10),
+		"{hash}", url.PathEscape(hash),
+	).Replace(tpl)
+}
+
+// ---------- Targeted reload (from persisted config) ----------
+
+// POST /api/admin/reload/{apikeys|rules|access|geoip|sources|tuning}
+// Re-reads data/config.json and rebuilds only that component; reports field-level changes.
+// sources: the listener picks the list up on its next tick (state of changed endpoints reset);
+// tuning: the poll policy (tick, strategy, race deadlines) applies from the next tick.
+func apiReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	component := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/reload"), "/")
+
+	disk, err := loadConfig()
+	if err != nil {
+		httpError(w, r, "config invalid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var before, after any
+	switch component {
+	case "apikeys":
+		keys := sanitizeAPIKeys(disk.APIKeys)
+		cfgMu.Lock()
+		before = cfg.APIKeys
+		cfg.APIKeys = keys
+		cfgMu.Unlock()
+		after = keys
+
+		tryStartListener()
+		if len(keys) == 0 {
+			stopListener()
+		}
+
+	case "rules":
+		rr := sanitizeRules(disk.Rules)
+		cfgMu.Lock()
+		before = cfg.Rules
+		cfg.Rules = rr
+		cfgMu.Unlock()
+		after = rr
+
+	case "access":
+		for _, ip := range disk.Access.IPWhitelist {
+			if ip = strings.TrimSpace(ip); ip != "" && net.ParseIP(ip) == nil {
+				httpError(w, r, "access.ipWhitelist: invalid ip "+strconv.Quote(ip), http.StatusBadRequest)
+				return
+			}
+		}
+		cfgMu.Lock()
+		before = redactAccess(cfg.Access)
+		cfg.Access = disk.Access
+		cfgMu.Unlock()
+		after = redactAccess(disk.Access)
+
+	case "geoip":
+		cfgMu.Lock()
+		before = cfg.GeoIP
+		cfg.GeoIP = disk.GeoIP
+		cfgMu.Unlock()
+		after = disk.GeoIP
+		loadGeoIP(disk.GeoIP.MMDBPath)
+
+	case "sources":
+		srcs, err := sanitizeSources(disk.Sources)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		prev := append([]SourceConfig(nil), cfg.Sources...)
+		cfg.Sources = srcs
+		cfgMu.Unlock()
+		before, after = redactSources(prev), redactSources(srcs)
+
+		sourcesChanged(prev, requestID(r))
+		tryStartListener()
+
+	case "tuning":
+		if err := validateTuning(disk.Tuning); err != nil {
+			httpError(w, r, "tuning: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		before = cfg.Tuning
+		oldTick := cfg.Tuning.tick()
+		cfg.Tuning = disk.Tuning
+		newTick := cfg.Tuning.tick()
+		cfgMu.Unlock()
+		after = disk.Tuning
+
+		if newTick != oldTick {
+			signalTick(newTick)
+		}
+
+	default:
+		httpError(w, r, "unknown component (apikeys|rules|access|geoip|sources|tuning)", http.StatusNotFound)
+		return
+	}
+
+	changes := diffJSON(component, before, after)
+	logger.Printf("RELOAD component=%s changes=%d rid=%s", component, len(changes), requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "component": component, "changes": changes})
+}
+
+// redactAccess keeps only a token prefix so reload reports don't leak secrets
+func redactAccess(a AccessControl) AccessControl {
+	out := AccessControl{IPWhitelist: a.IPWhitelist, Tokens: make(map[string]uint64, len(a.Tokens))}
+	short := func(t string) string {
+		if len(t) > 6 {
+			return t[:6] + "..."
+		}
+		return t
+	}
+	for t, n := range a.Tokens {
+		out.Tokens[short(t)] = n
+	}
+	if len(a.TokenNames) > 0 {
+		out.TokenNames = make(map[string]string, len(a.TokenNames))
+		for t, name := range a.TokenNames {
+			out.TokenNames[short(t)] = name
+		}
+	}
+	return out
+}
+
+// diffJSON flattens both values through their JSON form and lists changed leaves
+func diffJSON(prefix string, before, after any) []string {
+	a, b := map[string]string{}, map[string]string{}
+	flattenJSON(prefix, toJSONValue(before), a)
+	flattenJSON(prefix, toJSONValue(after), b)
+
+	var out []string
+	for k, v := range a {
+		if nv, ok := b[k]; !ok {
+			out = append(out, k+": "+v+" -> (removed)")
+		} else if nv != v {
+			out = append(out, k+": "+v+" -> "+nv)
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			out = append(out, k+": (added) -> "+v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func toJSONValue(v any) any {
+	b, _ := json.Marshal(v)
+	var out any
+	_ = json.Unmarshal(b, &out)
+	return out
+}
 
-	mustJSON(w, 200, map[string]any{"ok": true, "rules": rr})
+func flattenJSON(prefix string, v any, out map[string]string) {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, vv := range x {
+			flattenJSON(prefix+"."+k, vv, out)
+		}
+	case []any:
+		for i, vv := range x {
+			flattenJSON(prefix+"["+strconv.Itoa(i)+"]", vv, out)
+		}
+	default:
+		b, _ := json.Marshal(x)
+		out[prefix] = string(b)
+	}
 }
 
 // ---------- SSE status ----------
 
 func sseStatus(w http.ResponseWriter, r *http.Request) {
 	if !isLoggedIn(r) {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		httpError(w, r, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
@@ -695,13 +1704,23 @@ func sseStatus(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "no flusher", http.StatusInternalServerError)
+		httpError(w, r, "no flusher", http.StatusInternalServerError)
 		return
 	}
 
-	ch := make(chan Status, 8)
+	sub := &sseSub{shaper: newStreamShaper(r.URL.Query()), cursor: streamCursor{}}
+
+	// initial push (always sent, and counts as the first shaped event);
+	// a snapshot, not a new event, so it carries the current seq
+	sub.shaper.allow(topicStatus, time.Now())
+	meta := currentStreamSeq(topicStatus)
+	sub.cursor.advance(topicStatus, meta.Seq)
+	writeSSE(w, currentStatus(), meta)
+	flusher.Flush()
+
+	ch := make(chan sseEvent, 8)
 	sseMu.Lock()
-	sseSubs[ch] = struct{}{}
+	sseSubs[ch] = sub
 	sseMu.Unlock()
 
 	defer func() {
@@ -711,59 +1730,58 @@ func sseStatus(w http.ResponseWriter, r *http.Request) {
 		close(ch)
 	}()
 
-	// initial push
-	rtMu.Lock()
-	st := Status{
-		Listening:     rt.Listening,
-		LastHeight:    rt.LastHeight,
-		LastHash:      rt.LastHash,
-		LastTimeISO:   isoOrEmpty(rt.LastTime),
-		Reconnects:    atomic.LoadUint64(&reconnects),
-		ConnectedKeys: currentKeyCount(),
-	}
-	rtMu.Unlock()
-	writeSSE(w, st)
-	flusher.Flush()
-
 	notify := r.Context().Done()
 	for {
 		select {
 		case <-notify:
 			return
-		case s := <-ch:
-			writeSSE(w, s)
+		case ev := <-ch:
+			writeSSE(w, ev.st, ev.meta)
 			flusher.Flush()
 		}
 	}
 }
 
-func writeSSE(w io.Writer, st Status) {
+// sseSub is one SSE connection; shaping and seq tracking happen at broadcast
+// time (under sseMu) so a slow-consumer drop shows up as a prev_seq gap
+type sseSub struct {
+	shaper *streamShaper
+	cursor streamCursor
+}
+
+type sseEvent struct {
+	st   Status
+	meta streamMeta
+}
+
+func writeSSE(w io.Writer, st Status, meta streamMeta) {
 	b, _ := json.Marshal(st)
+	fmt.Fprintf(w, "id: %d\n", meta.Seq)
 	fmt.Fprintf(w, "event: status\n")
-	fmt.Fprintf(w, "data: %s\n\n", string(b))
+	fmt.Fprintf(w, "data: %s\n\n", string(withStreamMeta(b, meta)))
 }
 
 func broadcastStatus() {
-	rtMu.Lock()
-	st := Status{
-		Listening:     rt.Listening,
-		LastHeight:    rt.LastHeight,
-		LastHash:      rt.LastHash,
-		LastTimeISO:   isoOrEmpty(rt.LastTime),
-		Reconnects:    atomic.LoadUint64(&reconnects),
-		ConnectedKeys: currentKeyCount(),
-	}
-	rtMu.Unlock()
+	st := currentStatus()
+	meta := nextStreamSeq(topicStatus)
 
 	sseMu.Lock()
-	defer sseMu.Unlock()
-	for ch := range sseSubs {
+	now := time.Now()
+	for ch, sub := range sseSubs {
+		if !sub.shaper.allow(topicStatus, now) {
+			continue
+		}
+		m := meta
+		m.PrevSeq = sub.cursor.advance(topicStatus, meta.Seq)
 		select {
-		case ch <- st:
+		case ch <- sseEvent{st: st, meta: m}:
 		default:
-			// drop if slow
+			// drop if slow (the client sees the gap in prev_seq)
 		}
 	}
+	sseMu.Unlock()
+
+	broadcastWSMeta(topicStatus, st, meta)
 }
 
 // ---------- Block polling (listener) ----------
@@ -783,16 +1801,14 @@ func currentKeyCount() int {
 func tryStartListener() {
 	// start only if initialized+loggedIn gate satisfied (at least one active session) and keys>=1
 	cfgMu.RLock()
-	keysOK := len(cfg.APIKeys) >= 1
+	keysOK := len(cfg.APIKeys) >= 1 || simulatorActive()
 	cfgMu.RUnlock()
+	keysOK = keysOK || (featureOn(featGenericSources) && len(enabledSources()) > 0)
 	if !keysOK {
 		return
 	}
 	// login gate: if any active session exists
-	sessMu.Lock()
-	hasSession := len(sessions) > 0
-	sessMu.Unlock()
-	if !hasSession {
+	if !hasActiveSession() {
 		return
 	}
 
@@ -828,58 +1844,174 @@ type tronNowBlockResp struct {
 func listenerLoop() {
 	logger.Println("LISTENER_LOOP_START")
 
-	ticker := time.NewTicker(pollInterval)
+	tick := baseTick()
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
-	client := &http.Client{Timeout: 8 * time.Second}
-
 	for {
 		select {
 		case <-listenerStopC:
 			logger.Println("LISTENER_LOOP_STOP")
 			return
+		case d := <-tickResetC:
+			tick = d
+			ticker.Reset(d) // runtime state is untouched
+			logger.Printf("LISTENER_TICK_RESET tickMs=%d", d.Milliseconds())
+		case <-phaseWakeC:
+			// phase-mode sources (phase.go) may need the next wake-up before the base tick
+			if d := phaseWake(baseTick(), time.Now()); d != tick {
+				tick = d
+				ticker.Reset(d)
+			}
 		case <-ticker.C:
+			if d := phaseWake(baseTick(), time.Now()); d != tick {
+				tick = d
+				ticker.Reset(d)
+			}
 			cfgMu.RLock()
 			keys := append([]string(nil), cfg.APIKeys...)
 			rules := cfg.Rules
 			cfgMu.RUnlock()
+			var srcs []SourceConfig
+			if featureOn(featGenericSources) {
+				srcs = enabledSources()
+			}
 
 			// if keys empty or no active session => not allowed to listen (gate)
-			sessMu.Lock()
-			hasSession := len(sessions) > 0
-			sessMu.Unlock()
-			if len(keys) == 0 || !hasSession {
+			if (len(keys) == 0 && len(srcs) == 0 && !simulatorActive()) || !hasActiveSession() {
+				syncPushSources(nil)
 				rtMu.Lock()
 				rt.Listening = false
 				rtMu.Unlock()
 				continue
 			}
+			syncPushSources(srcs)
 			rtMu.Lock()
 			rt.Listening = true
 			rtMu.Unlock()
 
-			// pick a key (round-robin by time)
-			key := keys[int(time.Now().UnixNano()%int64(len(keys)))]
-			height, hash, tISO, err := fetchNowBlock(client, defaultNodeURL, key)
+			tr := &latencyTrace{FetchStart: time.Now(), Source: sourceTronGrid}
+			height, hash, tISO, simulated, err := simulatorNext()
+			if simulated && errors.Is(err, errSimDone) {
+				continue
+			}
+			if !simulated && !degradeShouldFetch(tr.FetchStart) {
+				continue // degraded: probing at a reduced rate
+			}
+			if !simulated && !powerShouldFetch(tr.FetchStart) {
+				continue // nobody is listening: keep-alive rate only
+			}
+			if simulated {
+				tr.Source = sourceSimulator
+			} else if strategy := scheduleStrategy(); len(srcs) > 0 && strategy != strategyRace && consensusMin() <= 1 {
+				var fetched bool
+				tr.Source, height, hash, tISO, fetched, err = fetchScheduled(strategy, srcs, tr.FetchStart)
+				if !fetched {
+					continue // no source due under the strategy
+				}
+			} else if len(srcs) > 0 {
+				due := dueSources(availableSources(srcs, tr.FetchStart), tr.FetchStart)
+				if len(due) == 0 {
+					continue // every source is resting under its rate profile
+				}
+				if need := consensusMin(); need > 1 {
+					var ab agreedBlock
+					var agreed bool
+					ab, agreed, err = pollConsensus(due, need)
+					if err == nil && !agreed {
+						degradeSuccess()
+						continue // waiting for more sources to agree
+					}
+					tr.Source, height, hash, tISO = ab.source, ab.height, ab.hash, ab.timeISO
+				} else {
+					tr.Source, height, hash, tISO, err = fetchAny(due)
+				}
+			} else {
+				if !backoffReady(sourceTronGrid, tr.FetchStart) || !limiterReady(sourceTronGrid, tr.FetchStart) {
+					continue
+				}
+				// pick a key (round-robin, rejected keys cool down)
+				key := pickKey(sourceTronGrid, keys, "", tr.FetchStart)
+				err = chaosBeforeFetch(defaultNodeURL)
+				if err == nil {
+					height, hash, tISO, err = fetchNowBlock(sourceClient(SourceConfig{ID: sourceTronGrid}), defaultNodeURL, key)
+					reportKey(sourceTronGrid, key, err)
+				}
+				sourceStatsRecord(sourceTronGrid, err, time.Since(tr.FetchStart))
+				limiterRecord(sourceTronGrid, err)
+				backoffRecord(sourceTronGrid, err)
+			}
+			if errors.Is(err, errNoNewBlock) {
+				degradeSuccess()
+				continue // height hint: nothing newer than the last block yet
+			}
 			if err != nil {
 				atomic.AddUint64(&reconnects, 1)
 				logger.Printf("BLOCK_FETCH_ERROR: %v", err)
+				if !simulated {
+					degradeFailure(err)
+				}
 				continue
 			}
+			if !simulated {
+				degradeSuccess()
+			}
+			acceptBlock(tr, height, hash, tISO, simulated, rules)
 
-			// update status first (but still need dedupe)
-			rtMu.Lock()
-			rt.LastHeight = height
-			rt.LastHash = hash
-			rt.LastTime = parseISOOrNow(tISO)
-			rtMu.Unlock()
-			broadcastStatus()
-
-			processBlock(height, hash, parseISOOrNow(tISO), rules)
+		case pb := <-pushC:
+			if !hasActiveSession() {
+				continue
+			}
+			cfgMu.RLock()
+			rules := cfg.Rules
+			cfgMu.RUnlock()
+			degradeSuccess()
+			if need := consensusMin(); need > 1 {
+				ab, ok := consensusVoteFor(pb.source, pb.height, pb.hash, pb.timeISO, need)
+				if !ok {
+					continue
+				}
+				pb.height, pb.hash, pb.timeISO = ab.height, ab.hash, ab.timeISO
+			}
+			tr := &latencyTrace{FetchStart: pb.received, Source: pb.source}
+			acceptBlock(tr, pb.height, pb.hash, pb.timeISO, false, rules)
 		}
 	}
 }
 
+// acceptBlock: shared tail for polled, pushed and simulated blocks
+func acceptBlock(tr *latencyTrace, height int64, hash, tISO string, simulated bool, rules Rules) {
+	if chaosSkipBlock(height) {
+		return
+	}
+	tr.Response = time.Now()
+	if !simulated {
+		tr.BlockTime = parseISOOrNow(tISO)
+	}
+
+	// update status first (but still need dedupe); a block at or behind the
+	// tip from a lagging source (any strategy, pushes too) must not rewind it
+	rtMu.Lock()
+	if !simulated && (height < rt.LastHeight || (height == rt.LastHeight && hash == rt.LastHash)) {
+		rtMu.Unlock()
+		return
+	}
+	rt.LastHeight = height
+	rt.LastHash = hash
+	rt.LastTime = parseISOOrNow(tISO)
+	rtMu.Unlock()
+	warmDone()
+	broadcastStatus()
+
+	// missed heights go through the judge first, in order
+	if !simulated {
+		heightHintSeen(height)
+		arrivalRecord(height, tr.Response)
+		backfillGap(height, tr.Source, rules)
+	}
+	confirmBlock(height, hash, tISO, rules, tr)
+}
+
 func fetchNowBlock(client *http.Client, nodeURL, apiKey string) (height int64, hash string, timeISO string, err error) {
 	url := strings.TrimRight(nodeURL, "/") + "/wallet/getnowblock"
 	req, _ := http.NewRequest("POST", url, bytes.NewReader([]byte("{}")))
@@ -895,8 +2027,7 @@ func fetchNowBlock(client *http.Client, nodeURL, apiKey string) (height int64, h
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
-		return 0, "", "", fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+		return 0, "", "", statusError(resp)
 	}
 
 	var out tronNowBlockResp
@@ -951,7 +2082,7 @@ func hexCharType(c byte) (string, bool) {
 
 // ---------- Core processing pipeline ----------
 
-func processBlock(height int64, hash string, t time.Time, rules Rules) {
+func processBlock(height int64, hash string, t time.Time, rules Rules, tr *latencyTrace) {
 	// Step 2: dedupe (height+hash)
 	key := fmt.Sprintf("%d:%s", height, hash)
 
@@ -965,25 +2096,106 @@ func processBlock(height int64, hash string, t time.Time, rules Rules) {
 	}
 	rt.Ring.add(key)
 	rtMu.Unlock()
+	if tr != nil {
+		tr.Accepted = time.Now()
+	}
 
-	// Step 3: judge ON/OFF
+	// Step 3: judge ON/OFF (malformed hashes are quarantined for review)
+	if hashQuarantined(height, hash, tr) {
+		return
+	}
+	fields := enrichBlock(sourceOf(tr), height, hash)
 	state, ok := blockStateByHash(hash)
 	if !ok {
 		logger.Printf("DROP_BLOCK_INVALID_HASH height=%d hash=%q", height, hash)
 		return
 	}
+	if tr != nil {
+		tr.Judged = time.Now()
+	}
+
+	// simulated blocks are streamed (marked) but stay out of charts and counters
+	sim := sourceOf(tr) == sourceSimulator
+	if !sim {
+		chartRecordBlock(height, hash, state, tr)
+	}
+
+	labels := signalLabels(sourceOf(tr))
+	broadcastWS(topicBlock, BlockEvent{
+		Height:  height,
+		Hash:    hash,
+		State:   state,
+		TimeISO: t.UTC().Format(time.RFC3339Nano),
+
+		ExplorerURL: explorerURL(height, hash),
+
+		Labels: labels,
+
+		Tx: txMetaFor(hash),
+
+		Fields: fields,
+
+		Simulated: sim,
+	})
+
+	// watch-only: judged block is recorded and streamed, no signal path
+	if !rules.machineEnabled() {
+		enterWatchOnly()
+		if !sim {
+			dailyRecordBlock(nil)
+		}
+		tr.finish(height, time.Now())
+		return
+	}
 
-	// Step 4 + 5: state machine + optional hit
-	signals := evaluateStateMachine(height, state, t, rules)
+	// Step 4 + 5: state machine + optional hit (through the debounce filter)
+	var signals []Signal
+	for _, b := range debounceFeed(debounceBlock{height: height, hash: hash, state: state, t: t}, rules.Debounce) {
+		for _, s := range evaluateStateMachine(b.height, b.state, b.t, rules, labels, sim) {
+			if s.Height == b.height {
+				s.ExplorerURL = explorerURL(b.height, b.hash)
+			}
+			s.Simulated = sim
+			signals = append(signals, s)
+		}
+	}
+	if !sim {
+		dailyRecordBlock(signals)
+	}
 	for _, s := range signals {
+		s.Labels = labels
+		if !sim {
+			chartRecordSignal(s)
+		}
 		broadcastSignal(s)
 	}
+	tr.finish(height, time.Now())
+}
+
+// enterWatchOnly clears the state machine so re-enabling a rule starts like a fresh boot
+func enterWatchOnly() {
+	rtMu.Lock()
+	defer rtMu.Unlock()
+	if rt.WatchOnly {
+		return
+	}
+	rt.WatchOnly = true
+	debounceResetLocked()
+	rt.OnCounter, rt.OffCounter = 0, 0
+	rt.WaitingReverse, rt.LastTriggered, rt.BaseHeight = false, "", 0
+	rt.HitWaiting = false
+	logger.Printf("WATCH_ONLY enabled=true")
 }
 
-func evaluateStateMachine(height int64, state string, t time.Time, rules Rules) []Signal {
+func evaluateStateMachine(height int64, state string, t time.Time, rules Rules, labels map[string]string, simulated bool) []Signal {
 	rtMu.Lock()
 	defer rtMu.Unlock()
 
+	if rt.WatchOnly {
+		rt.WatchOnly = false
+		logger.Printf("WATCH_ONLY enabled=false")
+	}
+
 	// 初始状态：waitingReverse=true
 	// 为了让“解除等待”有明确反向：若从未触发过，则默认 LastTriggered="ON"（要求先看到 OFF 才开始计数）
 	if rt.LastTriggered == "" {
@@ -1002,9 +2214,12 @@ func evaluateStateMachine(height int64, state string, t time.Time, rules Rules)
 				State:      state,
 				TimeISO:    t.UTC().Format(time.RFC3339Nano),
 			})
-			logger.Printf("HIT_SIGNAL height=%d base=%d state=%s", height, rt.HitBase, state)
+			logger.Printf("HIT_SIGNAL height=%d base=%d state=%s%s", height, rt.HitBase, state, logLabels(labels))
 		} else {
 			logger.Printf("HIT_MISS height=%d base=%d got=%s expect=%s", height, rt.HitBase, state, rt.HitExpect)
+			if !simulated {
+				dailyRecordHitMiss()
+			}
 		}
 		// end hit regardless
 		rt.HitWaiting = false
@@ -1055,7 +2270,7 @@ func evaluateStateMachine(height int64, state string, t time.Time, rules Rules)
 				TimeISO:    t.UTC().Format(time.RFC3339Nano),
 			}
 			out = append(out, s)
-			logger.Printf("ON_SIGNAL height=%d", height)
+			logger.Printf("ON_SIGNAL height=%d%s", height, logLabels(labels))
 
 			// arm hit
 			armHitLocked(height, rules)
@@ -1089,7 +2304,7 @@ func evaluateStateMachine(height int64, state string, t time.Time, rules Rules)
 				TimeISO:    t.UTC().Format(time.RFC3339Nano),
 			}
 			out = append(out, s)
-			logger.Printf("OFF_SIGNAL height=%d", height)
+			logger.Printf("OFF_SIGNAL height=%d%s", height, logLabels(labels))
 
 			armHitLocked(height, rules)
 		}
@@ -1152,6 +2367,113 @@ type wsConn struct {
 	c   net.Conn
 	mu  sync.Mutex
 	dead atomic.Bool
+
+	// topics this client subscribed to; envelope=false keeps the legacy
+	// raw Signal JSON (signal topic only)
+	topics   map[string]bool
+	envelope bool
+
+	remote      string
+	country     string // GeoIP, empty when disabled/unknown
+	connectedAt time.Time
+
+	// access analytics (access.go)
+	token string
+	sent  atomic.Uint64
+
+	shaper *streamShaper     // guarded by wsMu
+	labels map[string]string // ?label=k:v filter on labeled payloads
+	cursor streamCursor      // guarded by wsMu; prev_seq per topic (streamseq.go)
+	echo   echoState         // guarded by wsMu; heartbeat RTT (echo.go)
+}
+
+// WSClientInfo is one row of GET /api/admin/ws/clients
+type WSClientInfo struct {
+	Remote      string   `json:"remote"`
+	Country     string   `json:"country,omitempty"`
+	Topics      []string `json:"topics"`
+	ConnectedAt string   `json:"connectedAt"`
+	RTT         *EchoRTT `json:"rtt,omitempty"`
+}
+
+func apiWSClients(w http.ResponseWriter, r *http.Request) {
+	wsMu.Lock()
+	out := make([]WSClientInfo, 0, len(wsClients))
+	for c := range wsClients {
+		if c.dead.Load() {
+			continue
+		}
+		out = append(out, WSClientInfo{
+			Remote:      c.remote,
+			Country:     c.country,
+			Topics:      sortedKeys(c.topics),
+			ConnectedAt: isoOrEmpty(c.connectedAt),
+			RTT:         echoSummary(c),
+		})
+	}
+	wsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ConnectedAt < out[j].ConnectedAt })
+	mustJSON(w, 200, map[string]any{"clients": out})
+}
+
+const (
+	topicSignal = "signal"
+	topicStatus = "status"
+	topicBlock  = "block"
+
+	// optional subprotocol: client opts into enveloped multi-topic messages
+	wsSubprotocol = "tron-signal.topics"
+)
+
+// wsEnvelope wraps non-legacy WS messages: {"topic":"status","data":{...},"seq":N,"prev_seq":M,"emitted_at":"..."}
+type wsEnvelope struct {
+	Topic string `json:"topic"`
+	Data  any    `json:"data"`
+}
+
+// BlockEvent is pushed on the "block" topic for every accepted (deduped) block
+type BlockEvent struct {
+	Height  int64  `json:"height"`
+	Hash    string `json:"hash"`
+	State   string `json:"state"` // "ON"|"OFF"
+	TimeISO string `json:"time"`
+
+	ExplorerURL string `json:"explorerUrl,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// transaction summary when the source captures it (txmeta.go)
+	Tx *TxMeta `json:"tx,omitempty"`
+
+	// fields from the source's enrich request (enrich.go)
+	Fields map[string]any `json:"fields,omitempty"`
+
+	// from the scenario simulator (simulator.go), not the chain
+	Simulated bool `json:"simulated,omitempty"`
+}
+
+// parseWSTopics: "?topics=signal,status,block"; unknown names are ignored
+func parseWSTopics(raw string) map[string]bool {
+	out := map[string]bool{}
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch t {
+		case topicSignal, topicStatus, topicBlock, topicSystem:
+			out[t] = true
+		}
+	}
+	return out
+}
+
+func wsOffersSubprotocol(r *http.Request) bool {
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if strings.TrimSpace(p) == wsSubprotocol {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (w *wsConn) Close() {
@@ -1161,21 +2483,15 @@ func (w *wsConn) Close() {
 }
 
 func wsHandler(w http.ResponseWriter, r *http.Request) {
-	// Note: WS broadcast is not protected by token/ip in this minimal version.
-	// If you need, add guards here.
-	if !isLoggedIn(r) {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-
+	// auth is done by wsGuard (session / IP whitelist / token)
 	hj, ok := w.(http.Hijacker)
 	if !ok {
-		http.Error(w, "hijack not supported", http.StatusInternalServerError)
+		httpError(w, r, "hijack not supported", http.StatusInternalServerError)
 		return
 	}
 	conn, buf, err := hj.Hijack()
 	if err != nil {
-		http.Error(w, "hijack failed", http.StatusInternalServerError)
+		httpError(w, r, "hijack failed", http.StatusInternalServerError)
 		return
 	}
 
@@ -1186,10 +2502,32 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	accept := wsAcceptKey(key)
 
+	// topic selection: legacy clients (no topics, no subprotocol) get raw signals only
+	subproto := wsOffersSubprotocol(r)
+	rawTopics := r.URL.Query().Get("topics")
+	topics := map[string]bool{topicSignal: true}
+	envelope := false
+	if rawTopics != "" {
+		topics = parseWSTopics(rawTopics)
+		envelope = true
+	} else if subproto {
+		topics = map[string]bool{topicSignal: true, topicStatus: true, topicBlock: true, topicSystem: true}
+		envelope = true
+	}
+
 	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
 		"Upgrade: websocket\r\n" +
 		"Connection: Upgrade\r\n" +
-		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+		"Sec-WebSocket-Accept: " + accept + "\r\n"
+	if subproto {
+		resp += "Sec-WebSocket-Protocol: " + wsSubprotocol + "\r\n"
+	}
+	for _, h := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+		if v := w.Header().Get(h); v != "" { // set by tokenRateCheck
+			resp += h + ": " + v + "\r\n"
+		}
+	}
+	resp += "\r\n"
 
 	if _, err := buf.WriteString(resp); err != nil {
 		_ = conn.Close()
@@ -1200,12 +2538,24 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c := &wsConn{c: conn}
+	c := &wsConn{
+		c:           conn,
+		topics:      topics,
+		envelope:    envelope,
+		remote:      r.RemoteAddr,
+		country:     geoCountry(r.RemoteAddr),
+		connectedAt: time.Now(),
+		shaper:      newStreamShaper(r.URL.Query()),
+		cursor:      streamCursor{},
+		labels:      parseLabelFilter(r.URL.Query()),
+
+		token: requestToken(r),
+	}
 	wsMu.Lock()
 	wsClients[c] = struct{}{}
 	wsMu.Unlock()
 
-	logger.Printf("WS_CLIENT_CONNECTED remote=%s", r.RemoteAddr)
+	logger.Printf("WS_CLIENT_CONNECTED remote=%s country=%q topics=%s rid=%s", r.RemoteAddr, c.country, strings.Join(sortedKeys(topics), ","), requestID(r))
 
 	// read loop to keep connection healthy (discard frames)
 	go func() {
@@ -1215,8 +2565,12 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 			wsMu.Unlock()
 			c.Close()
 			logger.Printf("WS_CLIENT_DISCONNECTED remote=%s", r.RemoteAddr)
+			accessRecordWS(hostOnly(r.RemoteAddr), c.token, c.connectedAt, c.sent.Load())
 		}()
-		_ = wsReadLoop(conn)
+		_ = wsReadLoop(conn, func(p []byte) {
+			handleClientAck(p)
+			handleClientEcho(c, p)
+		})
 	}()
 }
 
@@ -1226,7 +2580,7 @@ func wsAcceptKey(clientKey string) string {
 	return base64.StdEncoding.EncodeToString(h[:])
 }
 
-func wsReadLoop(conn net.Conn) error {
+func wsReadLoop(conn net.Conn, onText func([]byte)) error {
 	br := bufio.NewReader(conn)
 	for {
 		// minimal frame parser (masked client-to-server)
@@ -1283,6 +2637,9 @@ func wsReadLoop(conn net.Conn) error {
 		if op == 0x8 {
 			return io.EOF
 		}
+		if op == 0x1 && onText != nil {
+			onText(payload)
+		}
 		// ping/pong ignored (browser handles)
 	}
 }
@@ -1317,21 +2674,110 @@ func wsWriteText(conn net.Conn, msg []byte) error {
 }
 
 func broadcastSignal(s Signal) {
-	b, _ := json.Marshal(s)
+	s.ConfigVersion, s.ConfigHash = configIdentity()
+	if s.ExplorerURL == "" {
+		s.ExplorerURL = explorerURL(s.Height, "")
+	}
+	if !s.Simulated {
+		signSignal(&s)
+	}
+	broadcastWS(topicSignal, s)
+	executorOffer(s)
+}
+
+// broadcastWS sends v to every client subscribed to topic.
+// Legacy clients receive the raw payload untouched; topic clients receive a
+// wsEnvelope carrying seq/prev_seq/emitted_at.
+func broadcastWS(topic string, v any) {
+	broadcastWSMeta(topic, v, nextStreamSeq(topic))
+}
+
+// broadcastWSMeta is broadcastWS with a seq already assigned (status events
+// share one seq between SSE and WS)
+func broadcastWSMeta(topic string, v any, meta streamMeta) {
+	// a dropped message still advances every cursor, so clients see the gap
+	dropped := chaosDropWS(topic)
+	var raw, env []byte
 
 	wsMu.Lock()
 	defer wsMu.Unlock()
+	now := time.Now()
 	for c := range wsClients {
-		if c.dead.Load() {
+		if c.dead.Load() || !c.topics[topic] || !labelsMatch(c.labels, v) || !c.shaper.allow(topic, now) {
 			continue
 		}
+		m := meta
+		m.PrevSeq = c.cursor.advance(topic, meta.Seq)
+		if dropped {
+			continue
+		}
+		var b []byte
+		if c.envelope {
+			if env == nil {
+				env, _ = json.Marshal(wsEnvelope{Topic: topic, Data: v})
+			}
+			b = withStreamMeta(env, m)
+		} else {
+			if raw == nil {
+				raw, _ = json.Marshal(v)
+			}
+			b = raw
+		}
 		c.mu.Lock()
 		err := wsWriteText(c.c, b)
 		c.mu.Unlock()
 		if err != nil {
 			c.Close()
+			continue
 		}
+		c.sent.Add(uint64(len(b)))
+	}
+}
+
+// streamShaper decimates block/status events per connection:
+// ?every=N keeps every Nth event, ?maxRate=R caps events per second.
+// Signals are never shaped.
+type streamShaper struct {
+	every  uint64
+	minGap time.Duration
+	seen   map[string]uint64
+	last   map[string]time.Time
+}
+
+func newStreamShaper(q url.Values) *streamShaper {
+	s := &streamShaper{every: 1, seen: map[string]uint64{}, last: map[string]time.Time{}}
+	if n, err := strconv.Atoi(q.Get("every")); err == nil && n > 1 {
+		s.every = uint64(clamp(n, 1, 1000))
+	}
+	if f, err := strconv.ParseFloat(q.Get("maxRate"), 64); err == nil && f > 0 && !math.IsInf(f, 0) {
+		s.minGap = time.Duration(float64(time.Second) / f)
+	}
+	return s
+}
+
+func (s *streamShaper) allow(topic string, now time.Time) bool {
+	if s == nil || topic == topicSignal || topic == topicSystem {
+		return true
 	}
+	n := s.seen[topic]
+	s.seen[topic] = n + 1
+	if n%s.every != 0 {
+		return false
+	}
+	if s.minGap > 0 && now.Sub(s.last[topic]) < s.minGap {
+		return false
+	}
+	s.last[topic] = now
+	return true
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
 }
 
 // ---------- main ----------
@@ -1348,6 +2794,7 @@ func resetRuntime() {
 
 	rt.LastTriggered = ""
 	rt.Ring.reset()
+	debounceResetLocked()
 
 	rt.LastHeight = 0
 	rt.LastHash = ""
@@ -1356,6 +2803,9 @@ func resetRuntime() {
 }
 
 func main() {
+	if runCLI() {
+		return
+	}
 	if err := ensureDirs(); err != nil {
 		panic(err)
 	}
@@ -1365,15 +2815,15 @@ func main() {
 		panic(err)
 	}
 	defer lf.Close()
-	logger = log.New(io.MultiWriter(os.Stdout, lf), "", log.LstdFlags|log.Lmicroseconds)
+	// every line carries its stable event code (events.go)
+	logger = log.New(eventWriter{io.MultiWriter(os.Stdout, lf)}, "", log.LstdFlags|log.Lmicroseconds)
+	inboxErr := loadInbox() // before the first inbox-worthy log line
 
-	// abnormal restart marker
-	lockPath := filepath.Join(dataDir, "running.lock")
-	if _, err := os.Stat(lockPath); err == nil {
-		logger.Println("ABNORMAL_RESTART")
-	}
-	_ = os.WriteFile(lockPath, []byte(time.Now().Format(time.RFC3339Nano)), 0o644)
-	defer os.Remove(lockPath)
+	// previous exit report (abnormal restart detected via running.lock)
+	loadLastShutdown()
+	loadLastGood()
+	markRunning()
+	handleShutdownSignals()
 
 	logger.Println("SYSTEM_START")
 
@@ -1382,6 +2832,7 @@ func main() {
 		logger.Printf("CONFIG_LOAD_ERROR: %v", err)
 		// keep default cfg
 	}
+	diag := diagnoseConfig(&loaded, err, configPath)
 	cfgMu.Lock()
 	cfg = loaded
 	// defaults
@@ -1392,11 +2843,52 @@ func main() {
 	if cfg.Rules.Hit.Offset == 0 {
 		cfg.Rules.Hit.Offset = 1
 	}
+	if cfg.Session.TTLMinutes < 0 {
+		cfg.Session.TTLMinutes = 0
+	}
+	cfgHashSaved = configHash(cfg)
 	cfgMu.Unlock()
 
+	cfgMu.RLock()
+	mmdbPath := cfg.GeoIP.MMDBPath
+	cfgMu.RUnlock()
+	loadGeoIP(mmdbPath)
+
 	// runtime must be fully reset every boot
 	resetRuntime()
 
+	cfgMu.RLock()
+	simCfg := cfg.Simulator
+	cfgMu.RUnlock()
+	initSimulator(simCfg)
+
+	loadSecrets()
+
+	cfgMu.RLock()
+	diag = append(diag, diagnoseRuntime(cfg)...)
+	cfgMu.RUnlock()
+	setDiagnostics(diag)
+
+	// daily counters are the one exception: persisted, Beijing-day scoped
+	loadDaily()
+	go dailyLoop()
+	loadRollups()
+	go rollupLoop()
+	if inboxErr != nil {
+		logger.Printf("INBOX_LOAD_ERROR: %v", inboxErr)
+	}
+	go inboxLoop()
+	loadAccess()
+	go accessLoop()
+	loadQuota()
+	go quotaLoop()
+	go executorLoop()
+
+	go retentionLoop()
+	go watchdogLoop()
+	go arrivalLoop()
+	go auditLoop()
+
 	mux := http.NewServeMux()
 
 	// auth pages
@@ -1409,32 +2901,103 @@ func main() {
 	// app
 	mux.HandleFunc("/", requireLogin(indexHandler))
 
-	// APIs (require login)
+	// APIs (require login; state-changing ones go through requireAdmin)
 	mux.HandleFunc("/api/status", requireLogin(apiStatus))
-	mux.HandleFunc("/api/apikey", requireLogin(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/daily", requireLogin(apiDaily))
+	mux.HandleFunc("/api/latency", requireLogin(apiLatency))
+	mux.HandleFunc("/api/stats/rollups", requireLogin(apiRollups))
+	mux.HandleFunc("/api/events/catalog", requireLogin(apiEventCatalog))
+	mux.HandleFunc("/api/charts/", requireLogin(apiCharts))
+	mux.HandleFunc("/api/sources", requireAdmin(apiSources))
+	mux.HandleFunc("/api/sources/stats", requireLogin(apiSourceStats))
+	mux.HandleFunc("/api/sources/presets", requireLogin(apiSourcePresets))
+	mux.HandleFunc("/api/sources/test", requireAdmin(apiSourceTest))
+	mux.HandleFunc("/api/sources/detect", requireAdmin(apiSourceDetect))
+	mux.HandleFunc("/api/admin/sources/compare", requireAdmin(apiSourceCompare))
+	mux.HandleFunc("/api/admin/sources/override", requireAdmin(apiRateOverride))
+	mux.HandleFunc("/api/sources/discover", requireAdmin(apiDiscover))
+	mux.HandleFunc("/api/sources/discover/adopt", requireAdmin(apiDiscoverAdopt))
+	mux.HandleFunc("/api/apikey", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
 			apiGetAPIKeys(w, r)
 		case "POST":
 			apiSetAPIKeys(w, r)
 		default:
-			http.Error(w, "method", http.StatusMethodNotAllowed)
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/rules", requireLogin(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/rules", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
 			apiGetRules(w, r)
 		case "POST":
 			apiSetRules(w, r)
 		default:
-			http.Error(w, "method", http.StatusMethodNotAllowed)
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/admin/explorer", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			apiGetExplorer(w, r)
+		case "POST":
+			apiSetExplorer(w, r)
+		default:
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
 		}
 	}))
 
-	// SSE + WS (require login)
+	// token provisioning (admin)
+	mux.HandleFunc("/api/admin/tokens/provision", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+			return
+		}
+		apiProvisionToken(w, r)
+	}))
+	mux.HandleFunc("/api/admin/tokens/bulk", requireAdmin(apiBulkTokens))
+	mux.HandleFunc("/api/admin/tokens/ratelimit", requireAdmin(apiTokenRate))
+	mux.HandleFunc("/api/admin/prefs", requireAdmin(apiPrefs))
+	mux.HandleFunc("/api/admin/secrets", requireAdmin(apiSecrets))
+	mux.HandleFunc("/api/admin/derived", requireAdmin(apiDerived))
+	mux.HandleFunc("/api/auth/whoami", apiWhoAmI)
+	mux.HandleFunc("/api/admin/whitelist/test", requireLogin(apiWhitelistTest))
+	mux.HandleFunc("/api/admin/tokens/qr", requireLogin(apiTokenQR))
+	mux.HandleFunc("/api/admin/ws/clients", requireLogin(apiWSClients))
+	mux.HandleFunc("/api/admin/bans", requireAdmin(apiBans))
+	mux.HandleFunc("/api/admin/reload/", requireAdmin(apiReload))
+	mux.HandleFunc("/api/admin/retention", requireAdmin(apiRetention))
+	mux.HandleFunc("/api/admin/watchdog", requireLogin(apiWatchdog))
+	mux.HandleFunc("/api/admin/simulator", requireAdmin(apiSimulator))
+	mux.HandleFunc("/api/admin/audit", requireAdmin(apiAudit))
+	mux.HandleFunc("/api/admin/features", requireAdmin(apiFeatures))
+	mux.HandleFunc("/api/admin/config/diagnostics", requireLogin(apiConfigDiagnostics))
+	mux.HandleFunc("/api/admin/inbox", requireAdmin(apiInbox))
+	mux.HandleFunc("/api/admin/inbox/read", requireAdmin(apiInboxRead))
+	mux.HandleFunc("/api/admin/access", requireAdmin(apiAccess))
+	mux.HandleFunc("/api/admin/confirmations", requireAdmin(apiConfirmations))
+	mux.HandleFunc("/api/admin/executor", requireAdmin(apiExecutor))
+	mux.HandleFunc("/api/admin/tuning", requireAdmin(apiTuning))
+	mux.HandleFunc("/api/admin/consensus", requireAdmin(apiConsensus))
+	mux.HandleFunc("/api/admin/hashchecks", requireAdmin(apiHashChecks))
+	mux.HandleFunc("/api/admin/labels", requireAdmin(apiLabels))
+	mux.HandleFunc("/api/admin/power", requireAdmin(apiPower))
+	mux.HandleFunc("/api/admin/history/", requireAdmin(apiHistory))
+	mux.HandleFunc("/api/admin/jobs", requireAdmin(apiJobs))
+	mux.HandleFunc("/api/admin/jobs/", requireAdmin(apiJobs))
+	mux.HandleFunc("/api/signing", requireAdmin(apiSigning))
+	mux.HandleFunc("/api/session/key", requireLogin(apiSessionKey))
+	mux.HandleFunc("/api/admin/replayguard", requireAdmin(apiReplayGuard))
+
+	// failure injection (only in -tags chaos builds)
+	registerChaosRoutes(mux)
+
+	// SSE (require login); WS: login or token / IP whitelist (trading programs)
 	mux.HandleFunc("/sse/status", requireLogin(sseStatus))
-	mux.HandleFunc("/ws", requireLogin(wsHandler))
+	mux.HandleFunc("/sse/jobs", requireLogin(sseJobs))
+	mux.HandleFunc("/ws", wsGuard(wsHandler))
 
 	// static assets (only after login gate)
 	mux.Handle("/app.js", requireLogin(func(w http.ResponseWriter, r *http.Request) {
@@ -1444,16 +3007,90 @@ func main() {
 		http.ServeFile(w, r, filepath.Join("web", "style.css"))
 	}))
 
-	srv := &http.Server{
-		Addr:              listenAddr,
-		Handler:           withSecurityHeaders(mux),
-		ReadHeaderTimeout: 5 * time.Second,
+	handler := withRequestID(withAccessStats(withCompression(withSecurityHeaders(withBanGuard(mux)))))
+
+	cfgMu.RLock()
+	listeners := normalizeListeners(cfg.Listeners)
+	cfgMu.RUnlock()
+
+	startRecoveryListener()
+
+	errC := make(chan error, len(listeners))
+	for _, lc := range listeners {
+		srv := &http.Server{
+			Addr:              lc.Addr,
+			Handler:           withListenerPolicy(lc.Policy, handler),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		tls := lc.TLSCert != "" && lc.TLSKey != ""
+		logger.Printf("HTTP_LISTEN %s policy=%s tls=%v", lc.Addr, lc.Policy, tls)
+		go func(lc ListenerConfig) {
+			var err error
+			if tls {
+				err = srv.ListenAndServeTLS(lc.TLSCert, lc.TLSKey)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Printf("SERVER_ERROR addr=%s: %v", lc.Addr, err)
+			}
+			errC <- err
+		}(lc)
+	}
+	// keep serving while at least one listener is alive
+	for range listeners {
+		<-errC
+	}
+	shutdown("listeners_closed")
+}
+
+// ---------- listeners ----------
+
+func normalizeListeners(in []ListenerConfig) []ListenerConfig {
+	var out []ListenerConfig
+	seen := map[string]bool{}
+	for _, lc := range in {
+		lc.Addr = strings.TrimSpace(lc.Addr)
+		if lc.Addr == "" || seen[lc.Addr] {
+			continue
+		}
+		seen[lc.Addr] = true
+		switch lc.Policy = strings.ToLower(strings.TrimSpace(lc.Policy)); lc.Policy {
+		case policyAdmin, policyConsumer, policyToken:
+		default:
+			lc.Policy = policyAdmin
+		}
+		out = append(out, lc)
 	}
+	if len(out) == 0 {
+		out = []ListenerConfig{{Addr: listenAddr, Policy: policyAdmin}}
+	}
+	return out
+}
+
+// consumer-facing paths reachable on non-admin listeners
+var consumerPaths = map[string]bool{
+	"/ws":              true,
+	"/api/auth/whoami": true,
+}
+
+// withListenerPolicy tags the request with its listener policy and hides
+// everything except consumer endpoints on non-admin listeners.
+func withListenerPolicy(policy string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy != policyAdmin && !consumerPaths[r.URL.Path] {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyListenerPolicy, policy)))
+	})
+}
 
-	logger.Printf("HTTP_LISTEN %s", listenAddr)
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logger.Printf("SERVER_ERROR: %v", err)
+func listenerPolicy(r *http.Request) string {
+	if p, ok := r.Context().Value(ctxKeyListenerPolicy).(string); ok {
+		return p
 	}
+	return policyAdmin
 }
 
 // ---------- headers ----------
@@ -1468,11 +3105,3 @@ func withSecurityHeaders(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-// ---------- unused but ready helpers ----------
-
-// If later you want to guard some external endpoints with IP/token, wrap with externalGuard(handler)
-var _ = externalGuard
-
-// Example graceful shutdown if you want:
-var _ = context.Background
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	按源维护窗口（服务商夜间维护时不去请求，也不记失败）
+	- 每个源可配多个窗口（maintenance）：
+	    周期：{"days":["mon","thu"],"from":"02:00","to":"02:30"}，北京时间，days 留空 = 每天；
+	          to <= from 跨午夜，days 指窗口开始的那天
+	    一次性：{"start":"2026-11-01T00:00:00Z","end":"2026-11-01T04:00:00Z"}（RFC3339）
+	- 窗口内：轮询 / 调度策略 / 补块都跳过该源，推送源断开订阅，窗口结束后自动恢复；
+	  跳过不算失败，不影响健康分、退避、熔断
+	- 进入 / 离开窗口各写一次 SOURCE_MAINTENANCE_START / SOURCE_MAINTENANCE_END
+	- GET /api/sources 的 maintenance 列出当前处于维护中的源
+*/
+
+const maintenanceMaxWindows = 20
+
+type MaintenanceWindow struct {
+	Days  []string `json:"days,omitempty"`  // mon..sun; empty = every day
+	From  string   `json:"from,omitempty"`  // "HH:MM" Beijing time, inclusive
+	To    string   `json:"to,omitempty"`    // "HH:MM", exclusive
+	Start string   `json:"start,omitempty"` // one-off, RFC3339
+	End   string   `json:"end,omitempty"`
+	Note  string   `json:"note,omitempty"`
+}
+
+var (
+	maintMu     sync.Mutex
+	maintActive = map[string]int{} // source id -> window index in force
+
+	weekdays = map[string]time.Weekday{"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday,
+		"wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday}
+)
+
+func validateMaintenance(ws []MaintenanceWindow) error {
+	if len(ws) > maintenanceMaxWindows {
+		return fmt.Errorf("at most %d maintenance windows", maintenanceMaxWindows)
+	}
+	for i, w := range ws {
+		oneOff := w.Start != "" || w.End != ""
+		switch {
+		case oneOff && (w.From != "" || w.To != "" || len(w.Days) > 0):
+			return fmt.Errorf("maintenance[%d]: use either start/end or days/from/to", i)
+		case oneOff:
+			s, err1 := time.Parse(time.RFC3339, w.Start)
+			e, err2 := time.Parse(time.RFC3339, w.End)
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("maintenance[%d]: start and end must be RFC3339", i)
+			}
+			if !e.After(s) {
+				return fmt.Errorf("maintenance[%d]: end must be after start", i)
+			}
+		default:
+			from, err := parseHHMM(w.From)
+			if err != nil {
+				return fmt.Errorf("maintenance[%d]: %w", i, err)
+			}
+			to, err := parseHHMM(w.To)
+			if err != nil {
+				return fmt.Errorf("maintenance[%d]: %w", i, err)
+			}
+			if from == to {
+				return fmt.Errorf("maintenance[%d]: from and to are equal", i)
+			}
+			for _, d := range w.Days {
+				if _, ok := weekdays[strings.ToLower(d)]; !ok {
+					return errors.New("maintenance days must be mon..sun")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w MaintenanceWindow) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, s := range w.Days {
+		if weekdays[strings.ToLower(s)] == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (w MaintenanceWindow) active(now time.Time) bool {
+	if w.Start != "" {
+		s, err1 := time.Parse(time.RFC3339, w.Start)
+		e, err2 := time.Parse(time.RFC3339, w.End)
+		return err1 == nil && err2 == nil && !now.Before(s) && now.Before(e)
+	}
+	from, err1 := parseHHMM(w.From)
+	to, err2 := parseHHMM(w.To)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	bj := now.In(beijing)
+	min := bj.Hour()*60 + bj.Minute()
+	if from < to {
+		return from <= min && min < to && w.onDay(bj.Weekday())
+	}
+	// wraps midnight: the part after midnight belongs to the previous day's window
+	if min >= from {
+		return w.onDay(bj.Weekday())
+	}
+	return min < to && w.onDay(bj.AddDate(0, 0, -1).Weekday())
+}
+
+// maintenanceWindow returns the index of the window in force (-1 = none)
+func maintenanceWindow(sc SourceConfig, now time.Time) int {
+	for i, w := range sc.Maintenance {
+		if w.active(now) {
+			return i
+		}
+	}
+	return -1
+}
+
+// maintenanceSources drops sources inside a maintenance window; logs entering and leaving
+func maintenanceSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	var out []SourceConfig
+	maintMu.Lock()
+	defer maintMu.Unlock()
+	for _, sc := range srcs {
+		idx := maintenanceWindow(sc, now)
+		prev, was := maintActive[sc.ID]
+		switch {
+		case idx >= 0 && (!was || prev != idx):
+			maintActive[sc.ID] = idx
+			logger.Printf("SOURCE_MAINTENANCE_START id=%s window=%d note=%q", sc.ID, idx, sc.Maintenance[idx].Note)
+		case idx < 0 && was:
+			delete(maintActive, sc.ID)
+			logger.Printf("SOURCE_MAINTENANCE_END id=%s", sc.ID)
+		}
+		if idx < 0 {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+// maintenanceSnapshot: source id -> window in force right now
+func maintenanceSnapshot(srcs []SourceConfig, now time.Time) map[string]MaintenanceWindow {
+	out := map[string]MaintenanceWindow{}
+	for _, sc := range srcs {
+		if idx := maintenanceWindow(sc, now); idx >= 0 {
+			out[sc.ID] = sc.Maintenance[idx]
+		}
+	}
+	return out
+}
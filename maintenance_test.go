@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	// Beijing wall clock; 2026-10-12 is a Monday
+	at := func(day, hh, mm int) time.Time { return time.Date(2026, 10, day, hh, mm, 0, 0, beijing) }
+	tests := []struct {
+		name string
+		w    MaintenanceWindow
+		now  time.Time
+		want bool
+	}{
+		{"daily inside", MaintenanceWindow{From: "02:00", To: "02:30"}, at(13, 2, 15), true},
+		{"daily at from", MaintenanceWindow{From: "02:00", To: "02:30"}, at(13, 2, 0), true},
+		{"daily at to", MaintenanceWindow{From: "02:00", To: "02:30"}, at(13, 2, 30), false},
+		{"daily before", MaintenanceWindow{From: "02:00", To: "02:30"}, at(13, 1, 59), false},
+		{"utc input is converted", MaintenanceWindow{From: "02:00", To: "02:30"}, time.Date(2026, 10, 12, 18, 10, 0, 0, time.UTC), true},
+
+		{"weekday match", MaintenanceWindow{Days: []string{"mon"}, From: "02:00", To: "03:00"}, at(12, 2, 30), true},
+		{"weekday other day", MaintenanceWindow{Days: []string{"mon"}, From: "02:00", To: "03:00"}, at(13, 2, 30), false},
+		{"weekday case-insensitive", MaintenanceWindow{Days: []string{"TUE"}, From: "02:00", To: "03:00"}, at(13, 2, 30), true},
+
+		// across midnight: days names the day the window starts
+		{"midnight: evening part", MaintenanceWindow{Days: []string{"mon"}, From: "23:00", To: "01:00"}, at(12, 23, 30), true},
+		{"midnight: morning part, previous day listed", MaintenanceWindow{Days: []string{"mon"}, From: "23:00", To: "01:00"}, at(13, 0, 30), true},
+		{"midnight: morning part of the listed day", MaintenanceWindow{Days: []string{"mon"}, From: "23:00", To: "01:00"}, at(12, 0, 30), false},
+		{"midnight: evening of the next day", MaintenanceWindow{Days: []string{"mon"}, From: "23:00", To: "01:00"}, at(13, 23, 30), false},
+		{"midnight: at to", MaintenanceWindow{Days: []string{"mon"}, From: "23:00", To: "01:00"}, at(13, 1, 0), false},
+		{"midnight: sunday into monday", MaintenanceWindow{Days: []string{"sun"}, From: "22:00", To: "02:00"}, at(12, 1, 0), true},
+		{"midnight daily: gap", MaintenanceWindow{From: "23:00", To: "01:00"}, at(13, 12, 0), false},
+		{"midnight daily: after midnight", MaintenanceWindow{From: "23:00", To: "01:00"}, at(13, 0, 59), true},
+
+		{"one-off inside", MaintenanceWindow{Start: "2026-11-01T00:00:00Z", End: "2026-11-01T04:00:00Z"}, time.Date(2026, 11, 1, 3, 59, 0, 0, time.UTC), true},
+		{"one-off at end", MaintenanceWindow{Start: "2026-11-01T00:00:00Z", End: "2026-11-01T04:00:00Z"}, time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC), false},
+		{"one-off before", MaintenanceWindow{Start: "2026-11-01T00:00:00Z", End: "2026-11-01T04:00:00Z"}, time.Date(2026, 10, 31, 23, 59, 0, 0, time.UTC), false},
+		{"bad times never match", MaintenanceWindow{From: "2:00", To: "25:00"}, at(13, 2, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.active(tt.now); got != tt.want {
+			t.Errorf("%s: active(%s) = %v, want %v", tt.name, tt.now.In(beijing).Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestValidateMaintenance(t *testing.T) {
+	tests := []struct {
+		name string
+		ws   []MaintenanceWindow
+		ok   bool
+	}{
+		{"recurring", []MaintenanceWindow{{Days: []string{"mon", "thu"}, From: "02:00", To: "02:30"}}, true},
+		{"across midnight", []MaintenanceWindow{{From: "23:30", To: "00:30"}}, true},
+		{"one-off", []MaintenanceWindow{{Start: "2026-11-01T00:00:00Z", End: "2026-11-01T04:00:00Z"}}, true},
+		{"mixed kinds", []MaintenanceWindow{{Start: "2026-11-01T00:00:00Z", End: "2026-11-01T04:00:00Z", From: "02:00"}}, false},
+		{"end before start", []MaintenanceWindow{{Start: "2026-11-01T04:00:00Z", End: "2026-11-01T00:00:00Z"}}, false},
+		{"from equals to", []MaintenanceWindow{{From: "02:00", To: "02:00"}}, false},
+		{"bad day", []MaintenanceWindow{{Days: []string{"monday"}, From: "02:00", To: "03:00"}}, false},
+		{"too many", make([]MaintenanceWindow, maintenanceMaxWindows+1), false},
+	}
+	for _, tt := range tests {
+		if err := validateMaintenance(tt.ws); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+)
+
+/*
+	统计与信号历史迁移（换服务器时保持连续）
+	- GET  /api/admin/history/export：每日计数（今日 + 历史）+ 最近的信号记录（图表 / 命中率数据）
+	- POST /api/admin/history/import：把导出的文件合并到本实例
+	  每日计数：本地没有的日期直接加入；同一天逐字段取较大值（重复导入不会翻倍）
+	  信号：按 type + height + baseHeight 去重，按时间排序后并入，超出上限丢弃最旧的
+	- 状态机运行态不迁移（按规则每次启动完全重置）
+*/
+
+const historyExportVersion = 1
+
+type HistoryExport struct {
+	Version    int       `json:"version"`
+	ExportedAt string    `json:"exportedAt"`
+	Daily      dailyFile `json:"daily"`
+	Signals    []Signal  `json:"signals"` // oldest first
+}
+
+func exportHistory() HistoryExport {
+	out := HistoryExport{Version: historyExportVersion, ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	dailyMu.Lock()
+	rolloverLocked(time.Now())
+	out.Daily = dailyFile{Today: daily.Today, History: append([]DailyCounters(nil), daily.History...)}
+	dailyMu.Unlock()
+
+	chartMu.Lock()
+	out.Signals = make([]Signal, 0, len(chartSignals))
+	for _, s := range chartSignals {
+		out.Signals = append(out.Signals, s.Signal)
+	}
+	chartMu.Unlock()
+	return out
+}
+
+// maxCounters: field-wise max, so importing the same file twice changes nothing
+func maxCounters(a, b DailyCounters) DailyCounters {
+	a.Blocks = max(a.Blocks, b.Blocks)
+	a.On = max(a.On, b.On)
+	a.Off = max(a.Off, b.Off)
+	a.Hit = max(a.Hit, b.Hit)
+	a.HitMiss = max(a.HitMiss, b.HitMiss)
+	a.Triggers = max(a.Triggers, b.Triggers)
+	return a
+}
+
+// importDaily merges imported days into the local counters; returns days touched
+func importDaily(in dailyFile) int {
+	days := append([]DailyCounters{in.Today}, in.History...)
+
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	rolloverLocked(time.Now())
+	n := 0
+	for _, d := range days {
+		if d.Date == "" || d.Date > daily.Today.Date {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d.Date); err != nil {
+			continue
+		}
+		n++
+		if d.Date == daily.Today.Date {
+			daily.Today = maxCounters(daily.Today, d)
+			continue
+		}
+		found := false
+		for i := range daily.History {
+			if daily.History[i].Date == d.Date {
+				daily.History[i] = maxCounters(daily.History[i], d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			daily.History = append(daily.History, d)
+		}
+	}
+	sort.Slice(daily.History, func(i, j int) bool { return daily.History[i].Date > daily.History[j].Date })
+	if len(daily.History) > dailyHistoryMax {
+		daily.History = daily.History[:dailyHistoryMax]
+	}
+	if n > 0 {
+		dailyDirty = true
+	}
+	return n
+}
+
+// importSignals merges imported signals into the chart history; returns signals added
+func importSignals(in []Signal) int {
+	type sigKey struct {
+		typ        string
+		height     int64
+		baseHeight int64
+	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	have := make(map[sigKey]bool, len(chartSignals))
+	for _, s := range chartSignals {
+		have[sigKey{s.Type, s.Height, s.BaseHeight}] = true
+	}
+	added := 0
+	for _, s := range in {
+		k := sigKey{s.Type, s.Height, s.BaseHeight}
+		if have[k] || (s.Type != "ON" && s.Type != "OFF" && s.Type != "HIT") {
+			continue
+		}
+		seen, err := time.Parse(time.RFC3339Nano, s.TimeISO)
+		if err != nil {
+			continue
+		}
+		have[k] = true
+		chartSignals = append(chartSignals, chartSignal{Signal: s, Seen: seen})
+		added++
+	}
+	sort.SliceStable(chartSignals, func(i, j int) bool { return chartSignals[i].Seen.Before(chartSignals[j].Seen) })
+	if len(chartSignals) > chartSignalsMax {
+		chartSignals = append(chartSignals[:0], chartSignals[len(chartSignals)-chartSignalsMax:]...)
+	}
+	return added
+}
+
+// GET  /api/admin/history/export (?async=1: as a job, fetch the document from its result)
+// POST /api/admin/history/import  (body: the export document)
+func apiHistory(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/admin/history/export":
+		if r.Method != "GET" {
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+			return
+		}
+		if asyncRequested(r) {
+			acceptJob(w, startJob("history-export", requestID(r), func(ctx context.Context, j *jobHandle) (any, error) {
+				return exportHistory(), nil
+			}))
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="tron-signal-history.json"`)
+		mustJSON(w, 200, exportHistory())
+	case "/api/admin/history/import":
+		if r.Method != "POST" {
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+			return
+		}
+		var in HistoryExport
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.Version != historyExportVersion {
+			httpError(w, r, "unsupported export version", http.StatusBadRequest)
+			return
+		}
+		days := importDaily(in.Daily)
+		added := importSignals(in.Signals)
+		flushDaily()
+		logger.Printf("HISTORY_IMPORTED days=%d signals=%d/%d exportedAt=%s rid=%s", days, added, len(in.Signals), in.ExportedAt, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "days": days, "signals": added, "skipped": len(in.Signals) - added})
+	default:
+		http.NotFound(w, r)
+	}
+}
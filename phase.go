@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	按出块节奏对齐的轮询（TRON 约 3 秒一块）
+	- 源配置 pollMode="phase"：不再按固定间隔均匀轮询，而是学习“区块在该源上可见”的相位，
+	  在预计出块后立即请求
+	- 相位 = 首次看到新块的时间 − 区块所在时隙（有 timePath 用区块时间，否则按 3 秒网格取整）；
+	  取最近 phaseWindow 次的 20 分位，样本不足 phaseMinSamples 前照常均匀轮询
+	- 锁定后：下一次请求 = 上一块时隙 + 3s + 相位 − phaseProbe（稍提前，相位可以向前漂移）；
+	  没拿到新块 => 100ms、200ms、400ms… 最多 1s 间隔重试，直到新块出现（漏块时同样适用）
+	- 请求失败时交给退避 / 熔断处理，相位不再约束，直到下一次成功
+	- 监听循环会在预计时刻提前唤醒（不受 baseTickMs 粒度限制）；限速令牌仍然是上限，
+	  建议 phase 源把 baseRps 设为 0 或配合 maxRps
+	- GET /api/sources 的 phase 字段：是否锁定、相位、样本数、下一次请求时间
+*/
+
+const (
+	pollModePhase = "phase"
+
+	tronSlot        = 3 * time.Second
+	phaseWindow     = 20
+	phaseMinSamples = 5
+	phaseProbe      = 100 * time.Millisecond
+	phaseRetryMin   = 100 * time.Millisecond
+	phaseRetryMax   = time.Second
+	phaseWakeMin    = 50 * time.Millisecond
+)
+
+type phaseState struct {
+	offsets    []time.Duration // receive time - slot, oldest first
+	lastHeight int64
+	next       time.Time // zero = not gating
+	retry      time.Duration
+	locked     bool
+}
+
+type PhaseSummary struct {
+	Locked   bool    `json:"locked"`
+	OffsetMS float64 `json:"offsetMs"`
+	Samples  int     `json:"samples"`
+	NextPoll string  `json:"nextPoll,omitempty"`
+}
+
+var (
+	phaseMu     sync.Mutex
+	phaseStates = map[string]*phaseState{}
+
+	phaseWakeC = make(chan struct{}, 1) // listenerLoop re-arms its ticker when a source's next poll moves
+)
+
+// offset: 20th percentile of recent samples (late polls only ever push samples up)
+func (st *phaseState) offset() time.Duration {
+	s := append([]time.Duration(nil), st.offsets...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/5]
+}
+
+// phaseRecord learns from one fetch of a phase-mode source; recv = when the answer arrived
+func phaseRecord(sc SourceConfig, res sourceResult, recv time.Time) {
+	if sc.PollMode != pollModePhase {
+		return
+	}
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	defer func() {
+		select {
+		case phaseWakeC <- struct{}{}:
+		default:
+		}
+	}()
+	st := phaseStates[sc.ID]
+	if st == nil {
+		st = &phaseState{retry: phaseRetryMin}
+		phaseStates[sc.ID] = st
+	}
+
+	switch {
+	case res.err != nil && !errors.Is(res.err, errNoNewBlock):
+		st.next = time.Time{} // failures: backoff and breaker decide
+
+	case res.err == nil && res.height > st.lastHeight:
+		slot := recv.Truncate(tronSlot)
+		if sc.TimePath != "" {
+			if t, err := time.Parse(time.RFC3339Nano, res.timeISO); err == nil {
+				slot = t
+			}
+		}
+		st.lastHeight = res.height
+		st.retry = phaseRetryMin
+		if off := recv.Sub(slot); off >= 0 && off < 2*tronSlot {
+			st.offsets = append(st.offsets, off)
+			if len(st.offsets) > phaseWindow {
+				st.offsets = st.offsets[1:]
+			}
+		}
+		if len(st.offsets) < phaseMinSamples {
+			st.next = time.Time{}
+			return
+		}
+		off := st.offset()
+		if !st.locked {
+			st.locked = true
+			logger.Printf("SOURCE_PHASE_LOCKED id=%s offsetMs=%d samples=%d", sc.ID, off.Milliseconds(), len(st.offsets))
+		}
+		st.next = slot.Add(tronSlot + off - phaseProbe)
+		if st.next.Before(recv) {
+			st.next = recv.Add(phaseRetryMin)
+		}
+
+	default: // answered, nothing newer yet
+		if st.locked {
+			st.next = recv.Add(st.retry)
+			st.retry = min(st.retry*2, phaseRetryMax)
+		}
+	}
+}
+
+// phaseSources drops phase-mode sources whose next expected block is not due yet
+func phaseSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if st := phaseStates[sc.ID]; sc.PollMode == pollModePhase && st != nil && now.Before(st.next) {
+			continue
+		}
+		out = append(out, sc)
+	}
+	return out
+}
+
+// phaseWake: how long the listener may sleep; base unless a phase source is due sooner
+func phaseWake(base time.Duration, now time.Time) time.Duration {
+	d := base
+	srcs := enabledSources()
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	for _, sc := range srcs {
+		st := phaseStates[sc.ID]
+		if sc.PollMode != pollModePhase || st == nil || !st.next.After(now) {
+			continue // not gating, or already due on this tick
+		}
+		d = min(d, max(st.next.Sub(now), phaseWakeMin))
+	}
+	return d
+}
+
+func phaseSnapshot() map[string]PhaseSummary {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	out := make(map[string]PhaseSummary, len(phaseStates))
+	for id, st := range phaseStates {
+		s := PhaseSummary{Locked: st.locked, Samples: len(st.offsets)}
+		if len(st.offsets) > 0 {
+			s.OffsetMS = float64(st.offset().Microseconds()) / 1000
+		}
+		if !st.next.IsZero() {
+			s.NextPoll = st.next.UTC().Format(time.RFC3339Nano)
+		}
+		out[id] = s
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	空闲省电模式（按消费者在线情况）
+	- powerSave.enabled 时：没有任何 WS / SSE 连接 => idle，轮询降到每 idleSeconds 一次（默认 30s）保活
+	- 有消费者连上后，下一个节拍立即恢复正常轮询（active）
+	- 只影响轮询源与 TronGrid；推送源订阅照常；模拟器不受影响
+	- 切换写日志 POWER_MODE；当前模式见 /api/status 的 powerMode，配置：GET/POST /api/admin/power
+*/
+
+const (
+	powerActive = "active"
+	powerIdle   = "idle"
+)
+
+type PowerSaveConfig struct {
+	Enabled     bool `json:"enabled"`
+	IdleSeconds int  `json:"idleSeconds"` // keep-alive interval while idle; 0 = 30
+}
+
+func (pc PowerSaveConfig) withDefaults() PowerSaveConfig {
+	if pc.IdleSeconds <= 0 {
+		pc.IdleSeconds = 30
+	}
+	if pc.IdleSeconds > 3600 {
+		pc.IdleSeconds = 3600
+	}
+	return pc
+}
+
+func powerSaveSettings() PowerSaveConfig {
+	cfgMu.RLock()
+	pc := cfg.PowerSave
+	cfgMu.RUnlock()
+	return pc.withDefaults()
+}
+
+var (
+	powerMu        sync.Mutex
+	powerMode      = powerActive
+	powerLastFetch time.Time
+)
+
+// consumersOnline: any live WS client or SSE subscriber
+func consumersOnline() bool {
+	sseMu.Lock()
+	n := len(sseSubs)
+	sseMu.Unlock()
+	if n > 0 {
+		return true
+	}
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	for c := range wsClients {
+		if !c.dead.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// powerShouldFetch is checked every tick; while idle only keep-alive fetches pass
+func powerShouldFetch(now time.Time) bool {
+	pc := powerSaveSettings()
+	mode := powerActive
+	if pc.Enabled && !consumersOnline() {
+		mode = powerIdle
+	}
+
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	if mode != powerMode {
+		logger.Printf("POWER_MODE mode=%s idleSeconds=%d", mode, pc.IdleSeconds)
+		powerMode = mode
+	}
+	if mode == powerIdle && now.Sub(powerLastFetch) < time.Duration(pc.IdleSeconds)*time.Second {
+		return false
+	}
+	powerLastFetch = now
+	return true
+}
+
+// currentPowerMode: "" when power saving is off
+func currentPowerMode() string {
+	if !powerSaveSettings().Enabled {
+		return ""
+	}
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	return powerMode
+}
+
+// GET  /api/admin/power
+// POST /api/admin/power {"enabled":true,"idleSeconds":30}
+func apiPower(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"config": powerSaveSettings(), "mode": currentPowerMode(), "consumers": consumersOnline()})
+	case "POST":
+		var pc PowerSaveConfig
+		if err := readJSON(r, &pc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if pc.IdleSeconds < 0 || pc.IdleSeconds > 3600 {
+			httpError(w, r, "idleSeconds must be 0..3600", http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.PowerSave = pc
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		pc = pc.withDefaults()
+		logger.Printf("POWER_SAVE_UPDATED enabled=%v idleSeconds=%d rid=%s", pc.Enabled, pc.IdleSeconds, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "config": pc})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+/*
+	面板偏好（服务端保存，换设备登录也一样）
+	- 按登录用户名保存在 data/prefs.json：主题、面板卡片布局（折叠 / 顺序）、默认筛选条件
+	- GET /api/admin/prefs 取当前用户的偏好；POST 整体替换
+	- 卡片 / 筛选的键：字母数字 - _ .，最多 prefsMaxItems 项；值最长 200 字符
+*/
+
+const prefsMaxItems = 50
+
+var (
+	prefsPath = filepath.Join(dataDir, "prefs.json")
+	prefsKey  = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+	prefsMu sync.Mutex
+)
+
+type UIPrefs struct {
+	Theme     string            `json:"theme"`               // "" = dark | "light"
+	Collapsed []string          `json:"collapsed,omitempty"` // card ids
+	Order     []string          `json:"order,omitempty"`     // card ids, first = top
+	Filters   map[string]string `json:"filters,omitempty"`   // view -> default filter
+	Updated   string            `json:"updated,omitempty"`
+}
+
+func validatePrefs(p UIPrefs) error {
+	if p.Theme != "" && p.Theme != "dark" && p.Theme != "light" {
+		return errors.New("theme must be dark or light")
+	}
+	if len(p.Collapsed) > prefsMaxItems || len(p.Order) > prefsMaxItems || len(p.Filters) > prefsMaxItems {
+		return fmt.Errorf("at most %d collapsed / order / filters entries", prefsMaxItems)
+	}
+	for _, id := range append(append([]string{}, p.Collapsed...), p.Order...) {
+		if !prefsKey.MatchString(id) {
+			return fmt.Errorf("bad card id %q", id)
+		}
+	}
+	for k, v := range p.Filters {
+		if !prefsKey.MatchString(k) || len(v) > 200 {
+			return fmt.Errorf("bad filter %q", k)
+		}
+	}
+	return nil
+}
+
+// loadPrefsLocked: prefsMu held; a missing file is an empty store
+func loadPrefsLocked() (map[string]UIPrefs, error) {
+	all := map[string]UIPrefs{}
+	b, err := os.ReadFile(prefsPath)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func sessionUser(r *http.Request) string {
+	c, err := r.Cookie("TSID")
+	if err != nil {
+		return ""
+	}
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	return sessions[c.Value].User
+}
+
+// GET  /api/admin/prefs
+// POST /api/admin/prefs {"theme":"light","collapsed":["sources"],"order":[],"filters":{"inbox":"unread"}}
+func apiPrefs(w http.ResponseWriter, r *http.Request) {
+	user := sessionUser(r)
+	switch r.Method {
+	case "GET":
+		prefsMu.Lock()
+		all, err := loadPrefsLocked()
+		prefsMu.Unlock()
+		if err != nil {
+			httpError(w, r, "prefs unreadable: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mustJSON(w, 200, all[user])
+	case "POST":
+		var p UIPrefs
+		if err := readJSON(r, &p); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validatePrefs(p); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Updated = time.Now().UTC().Format(time.RFC3339)
+
+		prefsMu.Lock()
+		defer prefsMu.Unlock()
+		all, err := loadPrefsLocked()
+		if err != nil {
+			all = map[string]UIPrefs{} // unreadable store: start over rather than lock the operator out
+		}
+		all[user] = p
+		b, _ := json.MarshalIndent(all, "", "  ")
+		tmp := prefsPath + ".tmp"
+		err = os.WriteFile(tmp, b, 0o644)
+		if err == nil {
+			err = os.Rename(tmp, prefsPath)
+		}
+		if err != nil {
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		logger.Printf("PREFS_UPDATED user=%s theme=%s rid=%s", user, p.Theme, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "prefs": p})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
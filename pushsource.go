@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	推送型区块源（kind=push）
+	- 连接 JSON-RPC WebSocket，发送 body（默认 eth_subscribe newHeads），每条通知按 JSONPath 映射
+	- 推送到 pushC，由监听循环与轮询源一起处理（同一去重 / 判定路径）
+	- 断线 pushRetryDelay 后重连；受 feature flag "push-sources" 控制
+*/
+
+const (
+	pushRetryDelay  = 5 * time.Second
+	pushIdleTimeout = 60 * time.Second // reconnect when the node goes quiet
+	pushMaxFrame    = 4 << 20
+)
+
+type pushBlock struct {
+	source   string
+	height   int64
+	hash     string
+	timeISO  string
+	received time.Time
+}
+
+var (
+	pushC = make(chan pushBlock, 64)
+
+	pushMu   sync.Mutex
+	pushRuns = map[string]*pushRun{} // source id -> running subscription
+)
+
+type pushRun struct {
+	cfg  SourceConfig
+	stop chan struct{}
+}
+
+func isPushSource(sc SourceConfig) bool { return sc.Kind == sourceKindPush }
+
+// syncPushSources starts/stops subscriptions to match the enabled push sources
+func syncPushSources(srcs []SourceConfig) {
+	want := map[string]SourceConfig{}
+	if featureOn(featPushSources) {
+		for _, sc := range maintenanceSources(srcs, time.Now()) {
+			if isPushSource(sc) {
+				want[sc.ID] = sc
+			}
+		}
+	}
+
+	pushMu.Lock()
+	defer pushMu.Unlock()
+	for id, run := range pushRuns {
+		if sc, ok := want[id]; !ok || configHashOf(sc) != configHashOf(run.cfg) {
+			close(run.stop)
+			delete(pushRuns, id)
+			logger.Printf("PUSH_SOURCE_STOP id=%s", id)
+		}
+	}
+	for id, sc := range want {
+		if _, ok := pushRuns[id]; ok {
+			continue
+		}
+		run := &pushRun{cfg: sc, stop: make(chan struct{})}
+		pushRuns[id] = run
+		go run.loop()
+		logger.Printf("PUSH_SOURCE_START id=%s url=%s", id, redactURL(sc.URL))
+	}
+}
+
+func configHashOf(sc SourceConfig) string {
+	b, _ := json.Marshal(sc)
+	return string(b)
+}
+
+func redactURL(u string) string {
+	return strings.ReplaceAll(u, "{apiKey}", "***")
+}
+
+func (p *pushRun) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *pushRun) loop() {
+	for !p.stopped() {
+		err := p.subscribe()
+		if p.stopped() {
+			return
+		}
+		logger.Printf("PUSH_SOURCE_ERROR id=%s err=%v", p.cfg.ID, err)
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(pushRetryDelay):
+		}
+	}
+}
+
+// subscribe runs one connection until it fails or the run is stopped
+func (p *pushRun) subscribe() error {
+	sc := p.cfg
+	key := pickKey(sc.ID, sourceKeys(sc), sc.KeyRotation, time.Now())
+	fill := strings.NewReplacer("{apiKey}", key)
+	tc, err := sourceTLSConfig(sc.TLS)
+	if err != nil {
+		return err
+	}
+	msg, err := resolveSecrets(fill.Replace(sc.Body))
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string, len(sc.Headers))
+	for k, v := range sc.Headers {
+		if headers[k], err = resolveSecrets(fill.Replace(v)); err != nil {
+			return err
+		}
+	}
+	// endpoints in order until one accepts the upgrade (endpoints.go)
+	eps := sourceEndpoints(sc)
+	var conn net.Conn
+	var br *bufio.Reader
+	var last error
+	for _, idx := range endpointOrder(sc, len(eps), time.Now()) {
+		u, err := resolveSecrets(fill.Replace(eps[idx]))
+		if err != nil {
+			return err
+		}
+		if conn, br, err = wsDial(u, headers, sourceClientKeyOf(sc), tc); err == nil {
+			endpointUsed(sc.ID, idx, last)
+			break
+		}
+		last = err
+	}
+	if conn == nil {
+		reportKey(sc.ID, key, last)
+		return last
+	}
+	reportKey(sc.ID, key, nil)
+	defer conn.Close()
+	go func() {
+		<-p.stop
+		_ = conn.Close()
+	}()
+
+	if err := wsClientWrite(conn, 0x1, []byte(msg)); err != nil {
+		return err
+	}
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(pushIdleTimeout))
+		msg, err := wsClientRead(conn, br)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.UseNumber()
+		var doc any
+		if dec.Decode(&doc) != nil {
+			continue
+		}
+		height, hash, tISO, err := mapSourceResponse(sc, doc)
+		if err != nil {
+			continue // subscription ack or unrelated notification
+		}
+		txMetaRecord(sc, hash, doc)
+		select {
+		case pushC <- pushBlock{source: sc.ID, height: height, hash: hash, timeISO: tISO, received: time.Now()}:
+		default:
+			logger.Printf("PUSH_SOURCE_DROP id=%s height=%d (listener busy)", sc.ID, height)
+		}
+	}
+}
+
+// ---------- minimal WebSocket client (standard library only) ----------
+
+// tc == nil: default verification
+func wsDial(rawURL string, headers map[string]string, k sourceClientKey, tc *tls.Config) (net.Conn, *bufio.Reader, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, nil, fmt.Errorf("ws: unsupported scheme %q", u.Scheme)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), k.connect)
+	defer cancel()
+	conn, err := sourceDialer(k)(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == "wss" {
+		if tc == nil {
+			tc = &tls.Config{}
+		} else {
+			tc = tc.Clone()
+		}
+		tc.ServerName = u.Hostname()
+		tconn := tls.Client(conn, tc)
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tconn
+	}
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n", u.RequestURI(), u.Host)
+	fmt.Fprintf(&req, "Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n", key)
+	for k, v := range headers {
+		if v != "" {
+			fmt.Fprintf(&req, "%s: %s\r\n", k, v)
+		}
+	}
+	req.WriteString("\r\n")
+	_ = conn.SetDeadline(time.Now().Add(8 * time.Second))
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "GET"})
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("ws: handshake failed: %w", &httpStatusError{Code: resp.StatusCode, Body: resp.Status})
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, br, nil
+}
+
+// wsClientWrite sends one masked frame (client-to-server frames must be masked)
+func wsClientWrite(conn net.Conn, op byte, msg []byte) error {
+	var frame bytes.Buffer
+	frame.WriteByte(0x80 | op)
+	n := len(msg)
+	switch {
+	case n <= 125:
+		frame.WriteByte(0x80 | byte(n))
+	case n <= 65535:
+		frame.WriteByte(0x80 | 126)
+		_ = binary.Write(&frame, binary.BigEndian, uint16(n))
+	default:
+		frame.WriteByte(0x80 | 127)
+		_ = binary.Write(&frame, binary.BigEndian, uint64(n))
+	}
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	frame.Write(mask[:])
+	for i, b := range msg {
+		frame.WriteByte(b ^ mask[i%4])
+	}
+	_, err := conn.Write(frame.Bytes())
+	return err
+}
+
+// wsClientRead returns the next complete text/binary message; answers pings
+func wsClientRead(conn net.Conn, br *bufio.Reader) ([]byte, error) {
+	var msg []byte
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, err
+		}
+		fin, op := hdr[0]&0x80 != 0, hdr[0]&0x0f
+		n := uint64(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			var x uint16
+			if err := binary.Read(br, binary.BigEndian, &x); err != nil {
+				return nil, err
+			}
+			n = uint64(x)
+		case 127:
+			if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+				return nil, err
+			}
+		}
+		if n > pushMaxFrame || uint64(len(msg))+n > pushMaxFrame {
+			return nil, errors.New("ws: message too large")
+		}
+		var mask [4]byte
+		masked := hdr[1]&0x80 != 0
+		if masked {
+			if _, err := io.ReadFull(br, mask[:]); err != nil {
+				return nil, err
+			}
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch op {
+		case 0x8: // close
+			return nil, io.EOF
+		case 0x9: // ping
+			if err := wsClientWrite(conn, 0xA, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case 0xA: // pong
+			continue
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+	Minimal QR Code encoder (standard library only)
+	- byte mode, error correction level M, versions 1-10 (<= 213 bytes)
+	- enough for provisioning deep links; output as SVG
+*/
+
+type qrVersionInfo struct {
+	total  int // total codewords
+	ecc    int // ecc codewords per block
+	blocks int
+}
+
+// ECC level M
+var qrVersions = [...]qrVersionInfo{
+	1:  {26, 10, 1},
+	2:  {44, 16, 1},
+	3:  {70, 26, 1},
+	4:  {100, 18, 2},
+	5:  {134, 24, 2},
+	6:  {172, 16, 4},
+	7:  {196, 18, 4},
+	8:  {242, 22, 4},
+	9:  {292, 22, 5},
+	10: {346, 26, 5},
+}
+
+var qrAlignment = [...][]int{
+	1:  nil,
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+type qrCode struct {
+	size    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+func qrEncode(text string) (*qrCode, error) {
+	data := []byte(text)
+
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		info := qrVersions[v]
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		capBits := (info.total - info.ecc*info.blocks) * 8
+		if 4+countBits+len(data)*8 <= capBits {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errors.New("qr: payload too long")
+	}
+	info := qrVersions[version]
+	dataCW := info.total - info.ecc*info.blocks
+
+	// bit stream: mode(0100) + count + bytes + terminator + pad
+	var bits []bool
+	put := func(v uint32, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (v>>uint(i))&1 == 1)
+		}
+	}
+	put(0x4, 4)
+	if version >= 10 {
+		put(uint32(len(data)), 16)
+	} else {
+		put(uint32(len(data)), 8)
+	}
+	for _, b := range data {
+		put(uint32(b), 8)
+	}
+	capBits := dataCW * 8
+	for i := 0; i < 4 && len(bits) < capBits; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := uint32(0xEC); len(bits) < capBits; pad ^= 0xEC ^ 0x11 {
+		put(pad, 8)
+	}
+	cw := make([]byte, dataCW)
+	for i, b := range bits {
+		if b {
+			cw[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+
+	final := qrAddECCAndInterleave(cw, info)
+
+	q := &qrCode{size: version*4 + 17}
+	q.modules = make([][]bool, q.size)
+	q.isFunc = make([][]bool, q.size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, q.size)
+		q.isFunc[i] = make([]bool, q.size)
+	}
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(final)
+
+	best, bestPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		q.applyMask(m)
+		q.drawFormatBits(m)
+		p := q.penalty()
+		if bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+		q.applyMask(m) // undo (xor)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+	return q, nil
+}
+
+func qrAddECCAndInterleave(data []byte, info qrVersionInfo) []byte {
+	numShort := info.blocks - info.total%info.blocks
+	shortLen := info.total / info.blocks // including ecc
+	div := qrRSDivisor(info.ecc)
+
+	var dataBlocks, eccBlocks [][]byte
+	k := 0
+	for i := 0; i < info.blocks; i++ {
+		n := shortLen - info.ecc
+		if i >= numShort {
+			n++
+		}
+		blk := data[k : k+n]
+		k += n
+		dataBlocks = append(dataBlocks, blk)
+		eccBlocks = append(eccBlocks, qrRSRemainder(blk, div))
+	}
+
+	out := make([]byte, 0, info.total)
+	for i := 0; i <= shortLen-info.ecc; i++ {
+		for _, blk := range dataBlocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecc; i++ {
+		for _, blk := range eccBlocks {
+			out = append(out, blk[i])
+		}
+	}
+	return out
+}
+
+func qrGFMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func qrRSDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			res[j] = qrGFMul(res[j], root)
+			if j+1 < degree {
+				res[j] ^= res[j+1]
+			}
+		}
+		root = qrGFMul(root, 0x02)
+	}
+	return res
+}
+
+func qrRSRemainder(data, div []byte) []byte {
+	res := make([]byte, len(div))
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i := range res {
+			res[i] ^= qrGFMul(div[i], factor)
+		}
+	}
+	return res
+}
+
+func (q *qrCode) setFunc(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunc[y][x] = true
+}
+
+func (q *qrCode) drawFunctionPatterns(version int) {
+	for i := 0; i < q.size; i++ {
+		q.setFunc(6, i, i%2 == 0)
+		q.setFunc(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= q.size || y >= q.size {
+					continue
+				}
+				d := qrMaxAbs(dx, dy)
+				q.setFunc(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	pos := qrAlignment[version]
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue // overlaps finder
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunc(pos[i]+dx, pos[j]+dy, qrMaxAbs(dx, dy) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormatBits(0) // reserve; real mask drawn later
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			bit := (bits>>uint(i))&1 == 1
+			a, b := q.size-11+i%3, i/3
+			q.setFunc(a, b, bit)
+			q.setFunc(b, a, bit)
+		}
+	}
+}
+
+// drawFormatBits writes ECC level M (00) + mask with BCH(15,5)
+func (q *qrCode) drawFormatBits(mask int) {
+	data := 0<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunc(8, i, bit(i))
+	}
+	q.setFunc(8, 7, bit(6))
+	q.setFunc(8, 8, bit(7))
+	q.setFunc(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunc(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunc(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunc(8, q.size-15+i, bit(i))
+	}
+	q.setFunc(8, q.size-8, true) // dark module
+}
+
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = q.size - 1 - vert
+				}
+				if !q.isFunc[y][x] && i < len(data)*8 {
+					q.modules[y][x] = (data[i>>3]>>uint(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.isFunc[y][x] {
+				continue
+			}
+			var inv bool
+			switch mask {
+			case 0:
+				inv = (x+y)%2 == 0
+			case 1:
+				inv = y%2 == 0
+			case 2:
+				inv = x%3 == 0
+			case 3:
+				inv = (x+y)%3 == 0
+			case 4:
+				inv = (x/3+y/2)%2 == 0
+			case 5:
+				inv = x*y%2+x*y%3 == 0
+			case 6:
+				inv = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				inv = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if inv {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty: standard rules N1..N4 (runs, 2x2 boxes, finder-like, balance)
+func (q *qrCode) penalty() int {
+	n := q.size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+
+	p := 0
+	for _, tr := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && at(x, y, tr) == at(x-1, y, tr) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= n; x++ {
+				if at(x, y, tr) && !at(x+1, y, tr) && at(x+2, y, tr) && at(x+3, y, tr) &&
+					at(x+4, y, tr) && !at(x+5, y, tr) && at(x+6, y, tr) {
+					before := x >= 4 && !at(x-1, y, tr) && !at(x-2, y, tr) && !at(x-3, y, tr) && !at(x-4, y, tr)
+					after := x+11 <= n && !at(x+7, y, tr) && !at(x+8, y, tr) && !at(x+9, y, tr) && !at(x+10, y, tr)
+					if before || after {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	k := (qrAbs(dark*20-total*10)+total-1)/total - 1
+	p += k * 10
+	return p
+}
+
+// SVG renders the code with a 4-module quiet zone
+func (q *qrCode) SVG() string {
+	const border = 4
+	dim := q.size + border*2
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, dim, dim)
+	b.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String()
+}
+
+func qrAbs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func qrMaxAbs(a, b int) int {
+	a, b = qrAbs(a), qrAbs(b)
+	if a > b {
+		return a
+	}
+	return b
+}
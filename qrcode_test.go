@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQRRSDivisor(t *testing.T) {
+	// generator polynomials from ISO/IEC 18004 annex A, leading 1 omitted
+	tests := []struct {
+		degree int
+		want   []byte
+	}{
+		{7, []byte{127, 122, 154, 164, 11, 68, 117}},
+		{10, []byte{216, 194, 159, 111, 199, 94, 95, 113, 157, 193}},
+	}
+	for _, tt := range tests {
+		if got := qrRSDivisor(tt.degree); !bytes.Equal(got, tt.want) {
+			t.Errorf("qrRSDivisor(%d) = %v, want %v", tt.degree, got, tt.want)
+		}
+	}
+}
+
+func TestQRRSRemainder(t *testing.T) {
+	// "HELLO WORLD", version 1-M (the worked example of the spec tutorials)
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := qrRSRemainder(data, qrRSDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("ecc = %v, want %v", got, want)
+	}
+}
+
+func TestQRFormatBits(t *testing.T) {
+	// format information for level M, masks 0-7 (ISO/IEC 18004 table C.1)
+	want := []string{
+		"101010000010010",
+		"101000100100101",
+		"101111001111100",
+		"101101101001011",
+		"100010111111001",
+		"100000011001110",
+		"100111110010111",
+		"100101010100000",
+	}
+	for mask, bits := range want {
+		q := &qrCode{size: 21}
+		q.modules = make([][]bool, q.size)
+		q.isFunc = make([][]bool, q.size)
+		for i := range q.modules {
+			q.modules[i] = make([]bool, q.size)
+			q.isFunc[i] = make([]bool, q.size)
+		}
+		q.drawFormatBits(mask)
+
+		// second copy: bits 0-7 down the right of the top-right finder's row 8
+		// (x = size-1 ... size-8), bits 8-14 along column 8 at the bottom
+		var got strings.Builder
+		for i := 14; i >= 0; i-- {
+			var dark bool
+			if i < 8 {
+				dark = q.modules[8][q.size-1-i]
+			} else {
+				dark = q.modules[q.size-15+i][8]
+			}
+			if dark {
+				got.WriteByte('1')
+			} else {
+				got.WriteByte('0')
+			}
+		}
+		if got.String() != bits {
+			t.Errorf("mask %d: format bits %s, want %s", mask, got.String(), bits)
+		}
+		if !q.modules[q.size-8][8] {
+			t.Errorf("mask %d: dark module not set", mask)
+		}
+	}
+}
+
+func TestQREncodeVersion(t *testing.T) {
+	tests := []struct {
+		n       int
+		version int // 0 = error
+	}{
+		{1, 1},
+		{14, 1}, // byte mode capacity of 1-M
+		{15, 2},
+		{26, 2},
+		{27, 3},
+		{213, 10},
+		{214, 0},
+	}
+	for _, tt := range tests {
+		q, err := qrEncode(strings.Repeat("a", tt.n))
+		if tt.version == 0 {
+			if err == nil {
+				t.Errorf("%d bytes: want error", tt.n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d bytes: %v", tt.n, err)
+		}
+		if want := tt.version*4 + 17; q.size != want {
+			t.Errorf("%d bytes: size %d, want %d (version %d)", tt.n, q.size, want, tt.version)
+		}
+	}
+}
+
+func TestQREncodeFinders(t *testing.T) {
+	q, err := qrEncode("tron-signal://provision?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 7x7 finder: dark ring, light ring, dark 3x3 core, in all three corners
+	finder := func(x0, y0 int) {
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 7; x++ {
+				ring := max(qrAbs(x-3), qrAbs(y-3))
+				if want := ring != 2; q.modules[y0+y][x0+x] != want {
+					t.Fatalf("finder at (%d,%d): module (%d,%d) = %v", x0, y0, x, y, !want)
+				}
+			}
+		}
+	}
+	finder(0, 0)
+	finder(q.size-7, 0)
+	finder(0, q.size-7)
+	if svg := q.SVG(); !strings.HasPrefix(svg, "<svg") {
+		t.Errorf("SVG() = %.40q", svg)
+	}
+}
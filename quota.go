@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+	源请求额度（付费 key 按日 / 按月计费时用）
+	- 源配置 dailyBudget / monthlyBudget：每日 / 每月最多请求次数，0 = 不限；按北京时间切日 / 切月
+	- 计数：每次轮询请求与回填请求各算一次（推送源的订阅连接不计）
+	- 用到 quotaWarnPercent 时写 SOURCE_QUOTA_WARNING，每个周期一次
+	- 用完后该源在本周期内不再被请求（调度、共识、回填都跳过），写 SOURCE_QUOTA_EXHAUSTED，周期切换后自动恢复
+	- 用量持久化到 data/quota.json（每分钟 + 退出时），重启不清零；GET /api/sources 的 quota 字段查看
+*/
+
+const quotaWarnPercent = 80
+
+var quotaPath = filepath.Join(dataDir, "quota.json")
+
+type QuotaUsage struct {
+	Day        string `json:"day"`   // Beijing date
+	Month      string `json:"month"` // YYYY-MM
+	DayCount   int64  `json:"dayCount"`
+	MonthCount int64  `json:"monthCount"`
+
+	// periods already warned / reported exhausted (one log line each)
+	WarnedDay      string `json:"warnedDay,omitempty"`
+	WarnedMonth    string `json:"warnedMonth,omitempty"`
+	ExhaustedDay   string `json:"exhaustedDay,omitempty"`
+	ExhaustedMonth string `json:"exhaustedMonth,omitempty"`
+}
+
+// QuotaView is one row of the quota field in GET /api/sources
+type QuotaView struct {
+	DailyBudget   int64 `json:"dailyBudget,omitempty"`
+	MonthlyBudget int64 `json:"monthlyBudget,omitempty"`
+	DayCount      int64 `json:"dayCount"`
+	MonthCount    int64 `json:"monthCount"`
+	Exhausted     bool  `json:"exhausted"`
+}
+
+var (
+	quotaMu    sync.Mutex
+	quotaUse   = map[string]*QuotaUsage{}
+	quotaDirty bool
+)
+
+func validateBudgets(sc SourceConfig) error {
+	if sc.DailyBudget < 0 || sc.MonthlyBudget < 0 {
+		return errors.New("dailyBudget / monthlyBudget cannot be negative")
+	}
+	return nil
+}
+
+func quotaMonth(now time.Time) string { return now.In(beijing).Format("2006-01") }
+
+// quotaUsageLocked returns id's counters rolled over to the current day / month
+func quotaUsageLocked(id string, now time.Time) *QuotaUsage {
+	u := quotaUse[id]
+	if u == nil {
+		u = &QuotaUsage{}
+		quotaUse[id] = u
+	}
+	if d := beijingDate(now); u.Day != d {
+		u.Day, u.DayCount = d, 0
+		quotaDirty = true
+	}
+	if m := quotaMonth(now); u.Month != m {
+		u.Month, u.MonthCount = m, 0
+		quotaDirty = true
+	}
+	return u
+}
+
+func quotaSpent(sc SourceConfig, u *QuotaUsage) bool {
+	return (sc.DailyBudget > 0 && u.DayCount >= sc.DailyBudget) ||
+		(sc.MonthlyBudget > 0 && u.MonthCount >= sc.MonthlyBudget)
+}
+
+// quotaRecord counts one request against sc's budgets
+func quotaRecord(sc SourceConfig, now time.Time) {
+	if sc.DailyBudget <= 0 && sc.MonthlyBudget <= 0 {
+		return
+	}
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	u := quotaUsageLocked(sc.ID, now)
+	u.DayCount++
+	u.MonthCount++
+	quotaDirty = true
+	if b := sc.DailyBudget; b > 0 && u.DayCount*100 >= b*quotaWarnPercent && u.WarnedDay != u.Day {
+		u.WarnedDay = u.Day
+		logger.Printf("SOURCE_QUOTA_WARNING id=%s period=day used=%d budget=%d", sc.ID, u.DayCount, b)
+	}
+	if b := sc.MonthlyBudget; b > 0 && u.MonthCount*100 >= b*quotaWarnPercent && u.WarnedMonth != u.Month {
+		u.WarnedMonth = u.Month
+		logger.Printf("SOURCE_QUOTA_WARNING id=%s period=month used=%d budget=%d", sc.ID, u.MonthCount, b)
+	}
+}
+
+// quotaSources drops sources whose daily or monthly budget is used up
+func quotaSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if sc.DailyBudget <= 0 && sc.MonthlyBudget <= 0 {
+			out = append(out, sc)
+			continue
+		}
+		u := quotaUsageLocked(sc.ID, now)
+		if !quotaSpent(sc, u) {
+			out = append(out, sc)
+			continue
+		}
+		if sc.DailyBudget > 0 && u.DayCount >= sc.DailyBudget && u.ExhaustedDay != u.Day {
+			u.ExhaustedDay, quotaDirty = u.Day, true
+			logger.Printf("SOURCE_QUOTA_EXHAUSTED id=%s period=day used=%d budget=%d", sc.ID, u.DayCount, sc.DailyBudget)
+		}
+		if sc.MonthlyBudget > 0 && u.MonthCount >= sc.MonthlyBudget && u.ExhaustedMonth != u.Month {
+			u.ExhaustedMonth, quotaDirty = u.Month, true
+			logger.Printf("SOURCE_QUOTA_EXHAUSTED id=%s period=month used=%d budget=%d", sc.ID, u.MonthCount, sc.MonthlyBudget)
+		}
+	}
+	return out
+}
+
+func quotaSnapshot(srcs []SourceConfig, now time.Time) map[string]QuotaView {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	out := map[string]QuotaView{}
+	for _, sc := range srcs {
+		if sc.DailyBudget <= 0 && sc.MonthlyBudget <= 0 {
+			continue
+		}
+		u := quotaUsageLocked(sc.ID, now)
+		out[sc.ID] = QuotaView{DailyBudget: sc.DailyBudget, MonthlyBudget: sc.MonthlyBudget,
+			DayCount: u.DayCount, MonthCount: u.MonthCount, Exhausted: quotaSpent(sc, u)}
+	}
+	return out
+}
+
+func loadQuota() {
+	b, err := os.ReadFile(quotaPath)
+	if err != nil {
+		return
+	}
+	var m map[string]*QuotaUsage
+	if err := json.Unmarshal(b, &m); err != nil || m == nil {
+		logger.Printf("QUOTA_LOAD_ERROR: %v", err)
+		return
+	}
+	quotaMu.Lock()
+	quotaUse = m
+	quotaMu.Unlock()
+}
+
+func flushQuota() {
+	quotaMu.Lock()
+	if !quotaDirty {
+		quotaMu.Unlock()
+		return
+	}
+	b, err := json.Marshal(quotaUse)
+	quotaDirty = false
+	quotaMu.Unlock()
+	if err != nil {
+		return
+	}
+	tmp := quotaPath + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, quotaPath)
+	}
+	if err != nil {
+		logger.Printf("QUOTA_SAVE_ERROR: %v", err)
+		quotaMu.Lock()
+		quotaDirty = true
+		quotaMu.Unlock()
+	}
+}
+
+func quotaLoop() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for range t.C {
+		flushQuota()
+	}
+}
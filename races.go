@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	轮询竞速胜者统计（最近一小时）
+	- 每次 fetchAny 竞速：谁拿到了新块（胜者）；没有新块 / 全部失败计 noWinner
+	- 平局：胜者返回后 raceTieWindow 内另一个源也送来同一块（高度 + 哈希相同）
+	- 按分钟分桶保留 raceWindowMinutes 分钟，输出每源胜场与胜率（占有胜者的竞速）
+	- GET /api/sources/stats 的 races 字段，状态（/api/status、SSE、WS status）的 races 字段
+	- tuning.raceKeepSecond：赢家之后不取消其余请求，第二个成功结果与赢家比对；
+	  同高度不同哈希写 RACE_SECOND_MISMATCH（secondChecks / secondMismatches）
+	- 与 wins / winShare（自启动累计，含推送源）不同，这里只看轮询竞速
+*/
+
+const (
+	raceWindowMinutes = 60
+	raceTieWindow     = 20 * time.Millisecond
+)
+
+type raceBucket struct {
+	minute   int64 // unix minute
+	wins     map[string]int
+	ties     int
+	noWinner int
+
+	secondChecks     int
+	secondMismatches int
+}
+
+// RaceWinner is one source's share of the races in the window
+type RaceWinner struct {
+	ID      string  `json:"id"`
+	Wins    int     `json:"wins"`
+	WinRate float64 `json:"winRate"` // 0..1 of races with a winner
+}
+
+type RaceSummary struct {
+	WindowMinutes int          `json:"windowMinutes"`
+	Races         int          `json:"races"` // with a winner
+	Ties          int          `json:"ties"`
+	NoWinner      int          `json:"noWinner"`
+	Sources       []RaceWinner `json:"sources"`
+	LastWinner    string       `json:"lastWinner,omitempty"`
+	LastTie       bool         `json:"lastTie,omitempty"`
+
+	// tuning.raceKeepSecond: runner-up answers compared with the winner
+	SecondChecks     int `json:"secondChecks"`
+	SecondMismatches int `json:"secondMismatches"` // same height, different hash
+}
+
+// errFetchCancelled: a race request cut short by a win or a deadline; not a source failure
+var errFetchCancelled = errors.New("cancelled by the race")
+
+var (
+	raceMu      sync.Mutex
+	raceBuckets []*raceBucket // oldest first
+	raceLast    string
+	raceLastTie bool
+)
+
+// raceBucketLocked returns the bucket of now, dropping buckets outside the window
+func raceBucketLocked(now time.Time) *raceBucket {
+	m := now.Unix() / 60
+	for len(raceBuckets) > 0 && raceBuckets[0].minute <= m-raceWindowMinutes {
+		raceBuckets = raceBuckets[1:]
+	}
+	if n := len(raceBuckets); n > 0 && raceBuckets[n-1].minute == m {
+		return raceBuckets[n-1]
+	}
+	b := &raceBucket{minute: m, wins: map[string]int{}}
+	raceBuckets = append(raceBuckets, b)
+	return b
+}
+
+func raceRecordNoWinner() {
+	raceMu.Lock()
+	defer raceMu.Unlock()
+	raceBucketLocked(time.Now()).noWinner++
+}
+
+// raceRecordWin counts the winner, waits briefly for a same-block tie from the
+// sources still in flight (their results arrive on ch), then cancels them; with
+// keepSecond the next answer is awaited and checked against the winner instead
+func raceRecordWin(win sourceResult, ch <-chan sourceResult, pending int, cancel context.CancelFunc, keepSecond bool) {
+	raceMu.Lock()
+	raceBucketLocked(time.Now()).wins[win.id]++
+	raceLast, raceLastTie = win.id, false
+	raceMu.Unlock()
+	if pending == 0 {
+		cancel()
+		return
+	}
+	go func() {
+		defer cancel()
+		tie := time.After(raceTieWindow)
+		for ; pending > 0; pending-- {
+			var res sourceResult
+			select {
+			case res = <-ch:
+			case <-tie:
+				if !keepSecond {
+					return
+				}
+				res = <-ch // bounded by the race deadlines / HTTP timeouts
+				tie = nil
+			}
+			if errors.Is(res.err, errFetchCancelled) {
+				continue
+			}
+			same := res.err == nil && res.height == win.height && res.hash == win.hash
+			raceMu.Lock()
+			b := raceBucketLocked(time.Now())
+			if same && tie != nil {
+				b.ties++
+				if raceLast == win.id {
+					raceLastTie = true
+				}
+			}
+			if keepSecond && res.err == nil {
+				b.secondChecks++
+				if res.height == win.height && res.hash != win.hash {
+					b.secondMismatches++
+					logger.Printf("RACE_SECOND_MISMATCH height=%d winner=%s hash=%s second=%s secondHash=%s", win.height, win.id, win.hash, res.id, res.hash)
+				}
+			}
+			raceMu.Unlock()
+			if same || (keepSecond && res.err == nil) {
+				return
+			}
+		}
+	}()
+}
+
+// raceSummary: nil before the first race
+func raceSummary() *RaceSummary {
+	raceMu.Lock()
+	defer raceMu.Unlock()
+	raceBucketLocked(time.Now())
+	s := &RaceSummary{WindowMinutes: raceWindowMinutes, LastWinner: raceLast, LastTie: raceLastTie}
+	wins := map[string]int{}
+	for _, b := range raceBuckets {
+		for id, n := range b.wins {
+			wins[id] += n
+			s.Races += n
+		}
+		s.Ties += b.ties
+		s.NoWinner += b.noWinner
+		s.SecondChecks += b.secondChecks
+		s.SecondMismatches += b.secondMismatches
+	}
+	if s.Races == 0 && s.NoWinner == 0 {
+		return nil
+	}
+	s.Sources = make([]RaceWinner, 0, len(wins))
+	for id, n := range wins {
+		s.Sources = append(s.Sources, RaceWinner{ID: id, Wins: n, WinRate: float64(n) / float64(s.Races)})
+	}
+	sort.Slice(s.Sources, func(i, j int) bool {
+		if s.Sources[i].Wins != s.Sources[j].Wins {
+			return s.Sources[i].Wins > s.Sources[j].Wins
+		}
+		return s.Sources[i].ID < s.Sources[j].ID
+	})
+	return s
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	按源限速 + 分时段速率档（北京时间）
+	- baseRps：默认每秒请求数（0 = 每个 tick 都请求）
+	- rateProfiles：按时段覆盖，例如白天 1 rps、夜间 0.1 rps，节省服务商额度
+	- 第一个命中的时段生效；to <= from 表示跨零点
+	- 令牌桶：按当前速率补充令牌，最多攒 burst 个（默认 1），允许短时突发
+	- 自适应：设置 maxRps 后，每 adaptWindow 次请求按成功率调整速率
+	  成功率 ≥ 95% => ×1.25（不超过 maxRps）；< 80% => ÷2（不低于时段速率）
+	- HTTP 429：写 SOURCE_RATE_LIMITED；带 Retry-After（秒数或日期，最长 1 小时）时
+	  该源正好暂停这么久，期间不请求（TronGrid 默认源同样适用）
+*/
+
+type RateProfile struct {
+	From string  `json:"from"` // "HH:MM" Beijing time, inclusive
+	To   string  `json:"to"`   // "HH:MM", exclusive
+	RPS  float64 `json:"rps"`
+}
+
+// slack for ticker jitter so 1 rps on a 1s tick never skips a beat
+const limiterSlack = 50 * time.Millisecond
+
+const (
+	adaptWindow    = 10 // outcomes per adjustment
+	adaptUpRatio   = 0.95
+	adaptDownRatio = 0.80
+	adaptUpStep    = 1.25
+	maxBurst       = 100
+
+	profileOverride = -2 // temporary override in force (rateoverride.go)
+)
+
+// tokenBucket is the limiter state of one source
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64 // rps in force, between the profile rate and maxRps
+	floor  float64 // profile rate (after per-key scaling)
+	ceil   float64 // maxRps (after per-key scaling); = floor when not adaptive
+	burst  float64
+	ok     int // outcomes since the last adjustment
+	fail   int
+}
+
+var (
+	limMu      sync.Mutex
+	limBuckets = map[string]*tokenBucket{} // source id -> bucket
+	limProfile = map[string]int{}          // source id -> active profile index (-1 = base)
+	limPause   = map[string]time.Time{}    // source id -> Retry-After pause end
+)
+
+func parseHHMM(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func validateLimiter(sc SourceConfig) error {
+	if sc.Burst < 0 || sc.Burst > maxBurst {
+		return fmt.Errorf("burst must be 0..%d", maxBurst)
+	}
+	if sc.MaxRPS < 0 {
+		return errors.New("maxRps must be >= 0")
+	}
+	if sc.MaxRPS > 0 && sc.BaseRPS <= 0 {
+		return errors.New("maxRps needs a baseRps to ramp from")
+	}
+	return nil
+}
+
+func validateRateProfiles(base float64, ps []RateProfile) error {
+	if base < 0 {
+		return errors.New("baseRps must be >= 0")
+	}
+	for i, p := range ps {
+		if _, err := parseHHMM(p.From); err != nil {
+			return fmt.Errorf("rateProfiles[%d]: %w", i, err)
+		}
+		if _, err := parseHHMM(p.To); err != nil {
+			return fmt.Errorf("rateProfiles[%d]: %w", i, err)
+		}
+		if p.RPS < 0 {
+			return fmt.Errorf("rateProfiles[%d]: rps must be >= 0", i)
+		}
+	}
+	return nil
+}
+
+// effectiveRate returns the rps in force at now and the matching profile index (-1 = base)
+func effectiveRate(sc SourceConfig, now time.Time) (float64, int) {
+	sc, over := rateOverridden(sc, now)
+	rps, idx := profileRate(sc, now)
+	if over {
+		idx = profileOverride
+	}
+	if n := len(sourceKeys(sc)); sc.RatePerKey && n > 1 {
+		rps *= float64(n)
+	}
+	return rps, idx
+}
+
+func profileRate(sc SourceConfig, now time.Time) (float64, int) {
+	bj := now.In(beijing)
+	min := bj.Hour()*60 + bj.Minute()
+	for i, p := range sc.RateProfiles {
+		from, err1 := parseHHMM(p.From)
+		to, err2 := parseHHMM(p.To)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		in := from <= min && min < to
+		if to <= from { // wraps midnight
+			in = min >= from || min < to
+		}
+		if in {
+			return p.RPS, i
+		}
+	}
+	return sc.BaseRPS, -1
+}
+
+// rateCeiling: maxRps scaled like the profile rate; never below floor
+func rateCeiling(sc SourceConfig, floor float64, now time.Time) float64 {
+	sc, _ = rateOverridden(sc, now)
+	ceil := sc.MaxRPS
+	if n := len(sourceKeys(sc)); sc.RatePerKey && n > 1 {
+		ceil *= float64(n)
+	}
+	if ceil < floor {
+		return floor
+	}
+	return ceil
+}
+
+// limitSources keeps the sources whose bucket holds a token now and takes it
+func limitSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	limMu.Lock()
+	defer limMu.Unlock()
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if now.Before(limPause[sc.ID]) {
+			continue // provider asked us to wait
+		}
+		rps, idx := effectiveRate(sc, now)
+		prev, seen := limProfile[sc.ID]
+		if seen && prev != idx && prev != profileOverride && idx != profileOverride {
+			logger.Printf("RATE_PROFILE_SWITCH id=%s profile=%d rps=%g", sc.ID, idx, rps)
+		}
+		limProfile[sc.ID] = idx
+		if rps <= 0 {
+			delete(limBuckets, sc.ID)
+			out = append(out, sc)
+			continue
+		}
+
+		b := limBuckets[sc.ID]
+		burst := float64(max(sc.Burst, 1))
+		if b == nil {
+			b = &tokenBucket{tokens: burst, last: now, rate: rps}
+			limBuckets[sc.ID] = b
+		}
+		if prev != idx || b.floor != rps {
+			b.rate = rps // new profile: ramp again from its rate
+		}
+		b.floor, b.ceil, b.burst = rps, rateCeiling(sc, rps, now), burst
+		b.rate = min(max(b.rate, b.floor), b.ceil)
+
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens < 1-limiterSlack.Seconds()*b.rate {
+			continue
+		}
+		b.tokens--
+		out = append(out, sc)
+	}
+	return out
+}
+
+// limiterReady: false while a Retry-After pause is running
+func limiterReady(id string, now time.Time) bool {
+	limMu.Lock()
+	defer limMu.Unlock()
+	return !now.Before(limPause[id])
+}
+
+func formatPause(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// limiterRecord feeds one fetch outcome to the adaptive controller of the source
+func limiterRecord(id string, err error) {
+	limMu.Lock()
+	defer limMu.Unlock()
+	var he *httpStatusError
+	if errors.As(err, &he) && (he.Code == http.StatusTooManyRequests || he.RetryAfter > 0) {
+		var until time.Time
+		if he.RetryAfter > 0 {
+			until = time.Now().Add(he.RetryAfter)
+			limPause[id] = until
+		}
+		logger.Printf("SOURCE_RATE_LIMITED id=%s status=%d retryAfterMs=%d until=%s", id, he.Code,
+			he.RetryAfter.Milliseconds(), formatPause(until))
+	}
+	b := limBuckets[id]
+	if b == nil || b.ceil <= b.floor {
+		return
+	}
+	if err == nil {
+		b.ok++
+	} else {
+		b.fail++
+	}
+	if b.ok+b.fail < adaptWindow {
+		return
+	}
+	ratio := float64(b.ok) / float64(b.ok+b.fail)
+	b.ok, b.fail = 0, 0
+	old := b.rate
+	switch {
+	case ratio >= adaptUpRatio:
+		b.rate = min(b.rate*adaptUpStep, b.ceil)
+	case ratio < adaptDownRatio:
+		b.rate = max(b.rate/2, b.floor)
+	}
+	if b.rate != old {
+		logger.Printf("RATE_ADAPT id=%s rps=%g->%g successRatio=%.2f", id, old, b.rate, ratio)
+	}
+}
+
+// LimiterState is one row of "limiter" in GET /api/sources
+type LimiterState struct {
+	RPS    float64 `json:"rps"`
+	Floor  float64 `json:"floor"`
+	Ceil   float64 `json:"ceil"`
+	Tokens float64 `json:"tokens"`
+	Burst  float64 `json:"burst"`
+
+	PausedUntil string `json:"pausedUntil,omitempty"` // Retry-After
+}
+
+func limiterSnapshot() map[string]LimiterState {
+	limMu.Lock()
+	defer limMu.Unlock()
+	out := make(map[string]LimiterState, len(limBuckets))
+	for id, b := range limBuckets {
+		out[id] = LimiterState{RPS: b.rate, Floor: b.floor, Ceil: b.ceil, Tokens: b.tokens, Burst: b.burst}
+	}
+	now := time.Now()
+	for id, until := range limPause {
+		if !now.Before(until) {
+			delete(limPause, id)
+			continue
+		}
+		st := out[id]
+		st.PausedUntil = formatPause(until)
+		out[id] = st
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	临时限速覆盖（不写配置）
+	- 服务商事故期间临时改某个源的 baseRps / maxRps，到期自动恢复配置值
+	- 覆盖期间忽略该源的分时段速率档；按 key 扩容（ratePerKey）照常生效
+	- 只在内存中，重启即失效；POST 同一个源会替换之前的覆盖
+	- GET/POST/DELETE /api/admin/sources/override
+*/
+
+const rateOverrideMaxMinutes = 24 * 60
+
+type RateOverride struct {
+	ID      string  `json:"id"`
+	BaseRPS float64 `json:"baseRps"`
+	MaxRPS  float64 `json:"maxRps,omitempty"`
+	Until   string  `json:"until"`
+	Reason  string  `json:"reason,omitempty"`
+
+	until time.Time
+}
+
+var (
+	ovMu        sync.Mutex
+	rateOverIDs = map[string]*RateOverride{}
+)
+
+// rateOverridden returns sc with the active override applied (profiles dropped)
+func rateOverridden(sc SourceConfig, now time.Time) (SourceConfig, bool) {
+	ovMu.Lock()
+	defer ovMu.Unlock()
+	o := rateOverIDs[sc.ID]
+	if o == nil {
+		return sc, false
+	}
+	if !now.Before(o.until) {
+		delete(rateOverIDs, sc.ID)
+		logger.Printf("RATE_OVERRIDE_EXPIRED id=%s", sc.ID)
+		return sc, false
+	}
+	sc.BaseRPS, sc.MaxRPS, sc.RateProfiles = o.BaseRPS, o.MaxRPS, nil
+	return sc, true
+}
+
+func rateOverrideList(now time.Time) []RateOverride {
+	ovMu.Lock()
+	defer ovMu.Unlock()
+	out := []RateOverride{}
+	for id, o := range rateOverIDs {
+		if !now.Before(o.until) {
+			delete(rateOverIDs, id)
+			logger.Printf("RATE_OVERRIDE_EXPIRED id=%s", id)
+			continue
+		}
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// GET    /api/admin/sources/override
+// POST   /api/admin/sources/override {"id":"ankr","baseRps":0.2,"maxRps":0,"minutes":30,"reason":"provider incident"}
+// DELETE /api/admin/sources/override?id=ankr&confirm=yes
+func apiRateOverride(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, map[string]any{"overrides": rateOverrideList(time.Now())})
+	case "POST":
+		var in struct {
+			ID      string  `json:"id"`
+			BaseRPS float64 `json:"baseRps"`
+			MaxRPS  float64 `json:"maxRps"`
+			Minutes int     `json:"minutes"`
+			Reason  string  `json:"reason"`
+		}
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		in.ID = strings.TrimSpace(in.ID)
+		in.Reason = strings.TrimSpace(in.Reason)
+		if in.Minutes < 1 || in.Minutes > rateOverrideMaxMinutes {
+			httpError(w, r, "minutes must be 1..1440", http.StatusBadRequest)
+			return
+		}
+		if len(in.Reason) > 200 || strings.ContainsAny(in.Reason, "\r\n") {
+			httpError(w, r, "reason: one line, max 200 chars", http.StatusBadRequest)
+			return
+		}
+		var sc SourceConfig
+		found := false
+		cfgMu.RLock()
+		for _, cur := range cfg.Sources {
+			if cur.ID == in.ID {
+				sc, found = cur, true
+			}
+		}
+		cfgMu.RUnlock()
+		if !found {
+			httpError(w, r, "unknown source", http.StatusNotFound)
+			return
+		}
+		sc.BaseRPS, sc.MaxRPS = in.BaseRPS, in.MaxRPS
+		if err := validateRateProfiles(sc.BaseRPS, nil); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateLimiter(sc); err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		until := time.Now().Add(time.Duration(in.Minutes) * time.Minute)
+		o := &RateOverride{ID: in.ID, BaseRPS: in.BaseRPS, MaxRPS: in.MaxRPS, Reason: in.Reason,
+			Until: until.UTC().Format(time.RFC3339), until: until}
+		ovMu.Lock()
+		rateOverIDs[in.ID] = o
+		ovMu.Unlock()
+		logger.Printf("RATE_OVERRIDE_SET id=%s baseRps=%g maxRps=%g minutes=%d reason=%q rid=%s",
+			in.ID, in.BaseRPS, in.MaxRPS, in.Minutes, in.Reason, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "override": *o})
+	case "DELETE":
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		ovMu.Lock()
+		_, ok := rateOverIDs[id]
+		delete(rateOverIDs, id)
+		ovMu.Unlock()
+		if !ok {
+			httpError(w, r, "no override for this source", http.StatusNotFound)
+			return
+		}
+		logger.Printf("RATE_OVERRIDE_CLEARED id=%s rid=%s", id, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
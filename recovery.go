@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+	本机应急恢复端口（被自己锁在外面时用，不必手改 config.json）
+	- 默认常开在 127.0.0.1:8089，只接受回环地址；recovery.disabled=true 关闭（加固部署）
+	- 不走登录 / 封禁 / 防重放；每个请求必须带 X-Recovery: yes（浏览器跨站请求带不上自定义头）
+	- 操作（在服务器上用 curl 调用）：
+	    GET  /recovery                 当前账号、封禁数、防重放、监听配置
+	    POST /recovery/password        {"username":"admin","password":"..."} 重设管理员账号并踢掉所有会话
+	    POST /recovery/unban           解除全部 IP 封禁
+	    POST /recovery/replayguard/off 关闭管理接口防重放
+	    POST /recovery/listeners/reset 清空自定义监听，重启后回到默认 :8080 管理端口
+	    POST /recovery/token           新发一个接入令牌
+	- 每个操作写 RECOVERY_ACTION
+*/
+
+const recoveryAddrDefault = "127.0.0.1:8089"
+
+type RecoveryConfig struct {
+	Disabled bool   `json:"disabled"`
+	Addr     string `json:"addr"` // loopback host:port; "" = 127.0.0.1:8089
+}
+
+func (rc RecoveryConfig) withDefaults() RecoveryConfig {
+	if rc.Addr == "" {
+		rc.Addr = recoveryAddrDefault
+	}
+	return rc
+}
+
+func isLoopback(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// recoveryGuard: loopback peer, loopback Host header (no DNS rebinding), X-Recovery header
+func recoveryGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(r.RemoteAddr) || !(isLoopback(r.Host) || strings.HasPrefix(r.Host, "localhost")) {
+			httpError(w, r, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !strings.EqualFold(r.Header.Get("X-Recovery"), "yes") {
+			httpError(w, r, "X-Recovery: yes required", http.StatusPreconditionRequired)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func recoveryPost(fn func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return recoveryGuard(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			httpError(w, r, "method", http.StatusMethodNotAllowed)
+			return
+		}
+		fn(w, r)
+	})
+}
+
+func recoveryStatus(w http.ResponseWriter, r *http.Request) {
+	banMu.Lock()
+	nb := len(bans)
+	banMu.Unlock()
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	mustJSON(w, 200, map[string]any{
+		"username":    cfg.Web.Username,
+		"initialized": cfg.Web.Initialized,
+		"bans":        nb,
+		"replayGuard": cfg.ReplayGuard.Enabled,
+		"listeners":   normalizeListeners(cfg.Listeners),
+	})
+}
+
+func recoveryPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || req.Password == "" {
+		httpError(w, r, "username/password required", http.StatusBadRequest)
+		return
+	}
+	salt, err := randHex(16)
+	if err != nil {
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
+		return
+	}
+	cfgMu.Lock()
+	cfg.Web = WebCred{Initialized: true, Username: req.Username, SaltHex: salt, HashHex: sha256Hex(salt + ":" + req.Password)}
+	err = saveConfigLocked(cfg)
+	cfgMu.Unlock()
+	if err != nil {
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	sessMu.Lock()
+	n := len(sessions)
+	sessions = map[string]session{}
+	sessMu.Unlock()
+	logger.Printf("RECOVERY_ACTION action=password username=%s sessionsDropped=%d rid=%s", req.Username, n, requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "sessionsDropped": n})
+}
+
+func recoveryUnban(w http.ResponseWriter, r *http.Request) {
+	banMu.Lock()
+	n := len(bans)
+	bans = map[string]*banEntry{}
+	banMu.Unlock()
+	logger.Printf("RECOVERY_ACTION action=unban removed=%d rid=%s", n, requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "removed": n})
+}
+
+func recoveryReplayOff(w http.ResponseWriter, r *http.Request) {
+	cfgMu.Lock()
+	cfg.ReplayGuard.Enabled = false
+	err := saveConfigLocked(cfg)
+	cfgMu.Unlock()
+	if err != nil {
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	logger.Printf("RECOVERY_ACTION action=replayguard_off rid=%s", requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true})
+}
+
+func recoveryListenersReset(w http.ResponseWriter, r *http.Request) {
+	cfgMu.Lock()
+	cfg.Listeners = nil
+	err := saveConfigLocked(cfg)
+	cfgMu.Unlock()
+	if err != nil {
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	logger.Printf("RECOVERY_ACTION action=listeners_reset rid=%s", requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "restartRequired": true, "listeners": normalizeListeners(nil)})
+}
+
+func recoveryToken(w http.ResponseWriter, r *http.Request) {
+	tok, err := randHex(16)
+	if err != nil {
+		httpError(w, r, "rand failed", http.StatusInternalServerError)
+		return
+	}
+	cfgMu.Lock()
+	cfg.Access.Tokens[tok] = 0
+	err = saveConfigLocked(cfg)
+	if err != nil {
+		delete(cfg.Access.Tokens, tok)
+	}
+	cfgMu.Unlock()
+	if err != nil {
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	logger.Printf("RECOVERY_ACTION action=token token=%s... rid=%s", tok[:6], requestID(r))
+	mustJSON(w, 200, map[string]any{"ok": true, "token": tok})
+}
+
+// startRecoveryListener serves the recovery endpoints on loopback unless disabled
+func startRecoveryListener() {
+	cfgMu.RLock()
+	rc := cfg.Recovery.withDefaults()
+	cfgMu.RUnlock()
+	if rc.Disabled {
+		logger.Printf("RECOVERY_DISABLED")
+		return
+	}
+	if !isLoopback(rc.Addr) {
+		logger.Printf("RECOVERY_ERROR addr=%s: not a loopback address, recovery listener off", rc.Addr)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/recovery", recoveryGuard(recoveryStatus))
+	mux.HandleFunc("/recovery/password", recoveryPost(recoveryPassword))
+	mux.HandleFunc("/recovery/unban", recoveryPost(recoveryUnban))
+	mux.HandleFunc("/recovery/replayguard/off", recoveryPost(recoveryReplayOff))
+	mux.HandleFunc("/recovery/listeners/reset", recoveryPost(recoveryListenersReset))
+	mux.HandleFunc("/recovery/token", recoveryPost(recoveryToken))
+	srv := &http.Server{Addr: rc.Addr, Handler: withRequestID(mux), ReadHeaderTimeout: 5 * time.Second}
+	logger.Printf("RECOVERY_LISTEN %s", rc.Addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("RECOVERY_ERROR addr=%s: %v", rc.Addr, err)
+		}
+	}()
+}
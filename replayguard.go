@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	管理接口防重放（可选，适合把管理面板暴露在公网时开启）
+	- replayGuard.enabled 时，所有管理变更请求（非 GET）必须带：
+	    X-TS-Timestamp：unix 秒，与服务器时间相差不超过 windowSeconds（默认 300）
+	    X-TS-Nonce：16~64 个字符，窗口内同一会话不可重复
+	    X-TS-Signature：hex(HMAC-SHA256(会话签名密钥, METHOD\nRequestURI\nTimestamp\nNonce\nhex(sha256(body))))
+	- 会话签名密钥登录时生成，只能通过 GET /api/session/key 取得（不在 Cookie 里），面板 JS 自动签名
+	- 拒绝的请求写 REPLAY_REJECTED（带 rid / ip / 原因）
+	- 浏览器 WebCrypto 需要安全上下文（HTTPS 或 localhost）
+*/
+
+const (
+	replayNonceMin = 16
+	replayNonceMax = 64
+	replayBodyMax  = 1 << 20
+)
+
+type ReplayGuardConfig struct {
+	Enabled       bool `json:"enabled"`
+	WindowSeconds int  `json:"windowSeconds"` // 0 = 300
+}
+
+func (rc ReplayGuardConfig) withDefaults() ReplayGuardConfig {
+	if rc.WindowSeconds <= 0 {
+		rc.WindowSeconds = 300
+	}
+	if rc.WindowSeconds > 3600 {
+		rc.WindowSeconds = 3600
+	}
+	return rc
+}
+
+func replayGuardSettings() ReplayGuardConfig {
+	cfgMu.RLock()
+	rc := cfg.ReplayGuard
+	cfgMu.RUnlock()
+	return rc.withDefaults()
+}
+
+var (
+	replayMu     sync.Mutex
+	replayNonces = map[string]time.Time{} // sid + nonce -> forget after
+)
+
+// sessionSignKey: the HMAC key of the request's session ("" when not logged in)
+func sessionSignKey(r *http.Request) (sid, key string) {
+	c, err := r.Cookie("TSID")
+	if err != nil || c.Value == "" {
+		return "", ""
+	}
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	s, ok := sessions[c.Value]
+	if !ok {
+		return "", ""
+	}
+	return c.Value, s.SignKey
+}
+
+func replaySignature(key, method, uri, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, uri, ts, nonce, hex.EncodeToString(sum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// replayCheck verifies timestamp, nonce and signature of a mutation; the body is restored for the handler
+func replayCheck(r *http.Request) error {
+	rc := replayGuardSettings()
+	if !rc.Enabled {
+		return nil
+	}
+	sid, key := sessionSignKey(r)
+	if key == "" {
+		return errors.New("no session signing key")
+	}
+	tsRaw := r.Header.Get("X-TS-Timestamp")
+	nonce := r.Header.Get("X-TS-Nonce")
+	sig := strings.ToLower(r.Header.Get("X-TS-Signature"))
+	if tsRaw == "" || nonce == "" || sig == "" {
+		return errors.New("missing X-TS-Timestamp / X-TS-Nonce / X-TS-Signature")
+	}
+	ts, err := strconv.ParseInt(tsRaw, 10, 64)
+	if err != nil {
+		return errors.New("bad timestamp")
+	}
+	now := time.Now()
+	window := time.Duration(rc.WindowSeconds) * time.Second
+	if d := now.Sub(time.Unix(ts, 0)); d > window || d < -window {
+		return errors.New("timestamp outside window")
+	}
+	if len(nonce) < replayNonceMin || len(nonce) > replayNonceMax {
+		return fmt.Errorf("nonce must be %d..%d chars", replayNonceMin, replayNonceMax)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, replayBodyMax+1))
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(body) > replayBodyMax {
+			return errors.New("body too large")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	want := replaySignature(key, r.Method, r.URL.RequestURI(), tsRaw, nonce, body)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return errors.New("bad signature")
+	}
+
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	for k, until := range replayNonces {
+		if now.After(until) {
+			delete(replayNonces, k)
+		}
+	}
+	k := sid + ":" + nonce
+	if _, seen := replayNonces[k]; seen {
+		return errors.New("nonce already used")
+	}
+	// a timestamp can be up to one window in the future, so keep the nonce for two
+	replayNonces[k] = now.Add(2 * window)
+	return nil
+}
+
+// GET /api/session/key -> {"enabled":bool,"key":"hex"}
+func apiSessionKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	_, key := sessionSignKey(r)
+	w.Header().Set("Cache-Control", "no-store")
+	mustJSON(w, 200, map[string]any{"enabled": replayGuardSettings().Enabled, "key": key})
+}
+
+// GET  /api/admin/replayguard
+// POST /api/admin/replayguard {"enabled":true,"windowSeconds":300}
+func apiReplayGuard(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, replayGuardSettings())
+	case "POST":
+		var rc ReplayGuardConfig
+		if err := readJSON(r, &rc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rc.WindowSeconds < 0 || rc.WindowSeconds > 3600 {
+			httpError(w, r, "windowSeconds must be 0..3600", http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.ReplayGuard = rc
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		rc = rc.withDefaults()
+		logger.Printf("REPLAY_GUARD_UPDATED enabled=%v windowSeconds=%d rid=%s", rc.Enabled, rc.WindowSeconds, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "config": rc})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
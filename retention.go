@@ -0,0 +1,219 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	数据保留 / 压缩
+	- 每个数据集一条保留策略（天），后台每小时执行一次，也可手动触发
+	- 当前数据集：logs（logs/YYYY-MM-DD.log）、daily（data/daily.json 历史）、access（data/access.json）、
+	  rollups（data/rollups.json 的分钟 / 小时 / 天汇总，rollup.go）
+	- 记录每次回收的文件数/条目数/字节数
+*/
+
+const retentionInterval = time.Hour
+
+type RetentionConfig struct {
+	LogsDays  int `json:"logsDays"`  // default logRetention (3)
+	DailyDays int `json:"dailyDays"` // default 90
+
+	AccessDays int `json:"accessDays"` // default 30
+
+	// stats rollup tiers (rollup.go)
+	RollupMinuteHours int `json:"rollupMinuteHours"` // default 24
+	RollupHourDays    int `json:"rollupHourDays"`    // default 30
+	RollupDayDays     int `json:"rollupDayDays"`     // default 365
+}
+
+// DatasetReclaim is the compactor result for one dataset
+type DatasetReclaim struct {
+	Days         int    `json:"days"`
+	LastRemoved  int    `json:"lastRemoved"` // files or entries
+	LastBytes    int64  `json:"lastBytes"`
+	TotalRemoved uint64 `json:"totalRemoved"`
+	TotalBytes   uint64 `json:"totalBytes"`
+}
+
+type RetentionStats struct {
+	LastRun  string                     `json:"lastRun"`
+	Datasets map[string]*DatasetReclaim `json:"datasets"`
+}
+
+var (
+	activeLogPath string // never pruned (still being written)
+
+	retMu    sync.Mutex
+	retStats = RetentionStats{Datasets: map[string]*DatasetReclaim{}}
+)
+
+func retentionPolicy() RetentionConfig {
+	cfgMu.RLock()
+	rc := cfg.Retention
+	cfgMu.RUnlock()
+	return rc.withDefaults()
+}
+
+func (rc RetentionConfig) withDefaults() RetentionConfig {
+	if rc.LogsDays <= 0 {
+		rc.LogsDays = logRetention
+	}
+	if rc.DailyDays <= 0 {
+		rc.DailyDays = 90
+	}
+	if rc.AccessDays <= 0 {
+		rc.AccessDays = 30
+	}
+	if rc.RollupMinuteHours <= 0 {
+		rc.RollupMinuteHours = rollupMinuteHoursDefault
+	}
+	if rc.RollupHourDays <= 0 {
+		rc.RollupHourDays = rollupHourDaysDefault
+	}
+	if rc.RollupDayDays <= 0 {
+		rc.RollupDayDays = rollupDayDaysDefault
+	}
+	return rc
+}
+
+// pruneLogs removes dated log files older than days; returns files and bytes removed
+func pruneLogs(days int) (int, int64) {
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return 0, 0
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	n, freed := 0, int64(0)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		// parse date prefix
+		fn := e.Name()
+		if !strings.HasSuffix(fn, ".log") || len(fn) < len("2006-01-02.log") {
+			continue
+		}
+		t, parseErr := time.Parse("2006-01-02", fn[:len("2006-01-02")])
+		if parseErr != nil || !t.Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(logDir, fn)
+		if path == activeLogPath {
+			continue
+		}
+		var size int64
+		if info, err := e.Info(); err == nil {
+			size = info.Size()
+		}
+		if os.Remove(path) == nil {
+			n++
+			freed += size
+		}
+	}
+	return n, freed
+}
+
+func runRetention() RetentionStats {
+	rc := retentionPolicy()
+
+	logFiles, logBytes := pruneLogs(rc.LogsDays)
+
+	before := fileSize(dailyPath)
+	dailyRemoved := trimDailyHistory(rc.DailyDays)
+	if dailyRemoved > 0 {
+		flushDaily()
+	}
+	dailyBytes := before - fileSize(dailyPath)
+	if dailyBytes < 0 {
+		dailyBytes = 0
+	}
+
+	before = fileSize(accessPath)
+	accessRemoved := trimAccess(rc.AccessDays)
+	if accessRemoved > 0 {
+		flushAccess()
+	}
+	accessBytes := before - fileSize(accessPath)
+	if accessBytes < 0 {
+		accessBytes = 0
+	}
+
+	before = fileSize(rollupPath)
+	rollupRemoved := trimRollups(rc, time.Now())
+	if rollupRemoved > 0 {
+		flushRollups()
+	}
+	rollupBytes := max(before-fileSize(rollupPath), 0)
+
+	retMu.Lock()
+	defer retMu.Unlock()
+	retStats.LastRun = time.Now().UTC().Format(time.RFC3339)
+	record := func(name string, days, removed int, bytes int64) {
+		d := retStats.Datasets[name]
+		if d == nil {
+			d = &DatasetReclaim{}
+			retStats.Datasets[name] = d
+		}
+		d.Days = days
+		d.LastRemoved = removed
+		d.LastBytes = bytes
+		d.TotalRemoved += uint64(removed)
+		d.TotalBytes += uint64(bytes)
+	}
+	record("logs", rc.LogsDays, logFiles, logBytes)
+	record("daily", rc.DailyDays, dailyRemoved, dailyBytes)
+	record("access", rc.AccessDays, accessRemoved, accessBytes)
+	record("rollups", rc.RollupDayDays, rollupRemoved, rollupBytes)
+
+	if logFiles > 0 || dailyRemoved > 0 || accessRemoved > 0 || rollupRemoved > 0 {
+		logger.Printf("RETENTION_RECLAIMED logs=%d(%dB) daily=%d(%dB) access=%d(%dB) rollups=%d(%dB)", logFiles, logBytes, dailyRemoved, dailyBytes, accessRemoved, accessBytes, rollupRemoved, rollupBytes)
+	}
+	return copyRetentionStatsLocked()
+}
+
+func copyRetentionStatsLocked() RetentionStats {
+	out := RetentionStats{LastRun: retStats.LastRun, Datasets: map[string]*DatasetReclaim{}}
+	for k, v := range retStats.Datasets {
+		c := *v
+		out.Datasets[k] = &c
+	}
+	return out
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func retentionLoop() {
+	runRetention()
+	t := time.NewTicker(retentionInterval)
+	defer t.Stop()
+	for range t.C {
+		runRetention()
+	}
+}
+
+// GET: policies + reclaim stats; POST: run the compactor now
+func apiRetention(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		retMu.Lock()
+		st := copyRetentionStatsLocked()
+		retMu.Unlock()
+		mustJSON(w, 200, map[string]any{"policy": retentionPolicy(), "stats": st})
+	case "POST":
+		st := runRetention()
+		mustJSON(w, 200, map[string]any{"ok": true, "policy": retentionPolicy(), "stats": st})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
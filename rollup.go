@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	统计分层汇总（分钟 → 小时 → 天），长期趋势不靠无限增长的原始数据
+	- 分钟点：区块数、ON / OFF / HIT 信号数、源请求数 / 失败数、成功请求平均 / 最大耗时
+	- 每分钟结束时并入所在小时；每小时结束时并入所在天（北京时间切日，与 daily 一致）
+	  => 原始分钟点过期前，它的数据已经在小时 / 天汇总里
+	- 保留期见 Config.Retention：rollupMinuteHours（默认 24）、rollupHourDays（默认 30）、
+	  rollupDayDays（默认 365），由保留任务（retention.go，数据集 rollups）每小时清理
+	- 持久化到 data/rollups.json（有变化时每 10 秒写一次，退出时再写一次），重启不丢
+	- GET /api/stats/rollups?tier=minute|hour|day&limit=N（最新在后；当前未结束的时段也返回）
+*/
+
+const (
+	rollupMinuteHoursDefault = 24
+	rollupHourDaysDefault    = 30
+	rollupDayDaysDefault     = 365
+)
+
+var rollupPath = filepath.Join(dataDir, "rollups.json")
+
+type RollupPoint struct {
+	Start       string  `json:"start"` // RFC 3339, UTC
+	Blocks      uint64  `json:"blocks"`
+	On          uint64  `json:"on"`
+	Off         uint64  `json:"off"`
+	Hit         uint64  `json:"hit"`
+	Fetches     uint64  `json:"fetches"`
+	FetchErrors uint64  `json:"fetchErrors"`
+	AvgFetchMS  float64 `json:"avgFetchMs"` // successful fetches
+	MaxFetchMS  float64 `json:"maxFetchMs"`
+}
+
+// merge folds o into p (same or enclosing period)
+func (p *RollupPoint) merge(o RollupPoint) {
+	okP, okO := p.Fetches-p.FetchErrors, o.Fetches-o.FetchErrors
+	if okP+okO > 0 {
+		p.AvgFetchMS = (p.AvgFetchMS*float64(okP) + o.AvgFetchMS*float64(okO)) / float64(okP+okO)
+	}
+	p.MaxFetchMS = max(p.MaxFetchMS, o.MaxFetchMS)
+	p.Blocks += o.Blocks
+	p.On += o.On
+	p.Off += o.Off
+	p.Hit += o.Hit
+	p.Fetches += o.Fetches
+	p.FetchErrors += o.FetchErrors
+}
+
+type rollupFile struct {
+	Current RollupPoint   `json:"current"` // minute in progress
+	Minute  []RollupPoint `json:"minute"`  // oldest first
+	Hour    []RollupPoint `json:"hour"`
+	Day     []RollupPoint `json:"day"`
+}
+
+var (
+	rollMu    sync.Mutex
+	rollups   rollupFile
+	rollDirty bool
+)
+
+func rollupHourStart(t time.Time) time.Time { return t.Truncate(time.Hour) }
+
+func rollupDayStart(t time.Time) time.Time {
+	b := t.In(beijing)
+	return time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, beijing)
+}
+
+func rollupStamp(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+
+// addToTier merges p into the tier point starting at start (appended when new)
+func addToTier(tier []RollupPoint, start string, p RollupPoint) []RollupPoint {
+	if n := len(tier); n > 0 && tier[n-1].Start == start {
+		tier[n-1].merge(p)
+		return tier
+	}
+	p.Start = start
+	return append(tier, p)
+}
+
+// rollLocked closes the current minute once now is past it
+func rollLocked(now time.Time) {
+	minute := rollupStamp(now.Truncate(time.Minute))
+	cur := rollups.Current
+	if cur.Start == minute {
+		return
+	}
+	if cur.Start != "" {
+		if t, err := time.Parse(time.RFC3339, cur.Start); err == nil {
+			hour := rollupStamp(rollupHourStart(t))
+			if n := len(rollups.Hour); n > 0 && rollups.Hour[n-1].Start != hour {
+				// the previous hour is complete: fold it into its day
+				if ht, err := time.Parse(time.RFC3339, rollups.Hour[n-1].Start); err == nil {
+					rollups.Day = addToTier(rollups.Day, rollupStamp(rollupDayStart(ht)), rollups.Hour[n-1])
+				}
+			}
+			rollups.Minute = append(rollups.Minute, cur)
+			rollups.Hour = addToTier(rollups.Hour, hour, cur)
+		}
+	}
+	rollups.Current = RollupPoint{Start: minute}
+	rollDirty = true
+}
+
+func rollupRecordFetch(err error, took time.Duration) {
+	rollMu.Lock()
+	defer rollMu.Unlock()
+	rollLocked(time.Now())
+	rollDirty = true
+	p := &rollups.Current
+	p.Fetches++
+	if err != nil {
+		p.FetchErrors++
+		return
+	}
+	ms := float64(took.Microseconds()) / 1000
+	ok := p.Fetches - p.FetchErrors
+	p.AvgFetchMS += (ms - p.AvgFetchMS) / float64(ok)
+	p.MaxFetchMS = max(p.MaxFetchMS, ms)
+}
+
+func rollupRecordBlock(signals []Signal) {
+	rollMu.Lock()
+	defer rollMu.Unlock()
+	rollLocked(time.Now())
+	rollDirty = true
+	p := &rollups.Current
+	p.Blocks++
+	for _, s := range signals {
+		switch s.Type {
+		case "ON":
+			p.On++
+		case "OFF":
+			p.Off++
+		case "HIT":
+			p.Hit++
+		}
+	}
+}
+
+// rollupView: the tier with its open period folded in (the API shows live numbers)
+func rollupView(tier string, limit int) []RollupPoint {
+	rollMu.Lock()
+	defer rollMu.Unlock()
+	rollLocked(time.Now())
+	cur := rollups.Current
+	var out []RollupPoint
+	switch tier {
+	case "minute":
+		out = append(append(out, rollups.Minute...), cur)
+	case "hour":
+		out = append(out, rollups.Hour...)
+		if t, err := time.Parse(time.RFC3339, cur.Start); err == nil {
+			out = addToTier(out, rollupStamp(rollupHourStart(t)), cur)
+		}
+	case "day":
+		out = append(out, rollups.Day...)
+		// the newest hour is still open: it reaches the day tier when it closes
+		if n := len(rollups.Hour); n > 0 {
+			if t, err := time.Parse(time.RFC3339, rollups.Hour[n-1].Start); err == nil {
+				out = addToTier(out, rollupStamp(rollupDayStart(t)), rollups.Hour[n-1])
+			}
+		}
+		if t, err := time.Parse(time.RFC3339, cur.Start); err == nil {
+			out = addToTier(out, rollupStamp(rollupDayStart(t)), cur)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// trimRollups drops points past their tier's retention; returns points removed
+func trimRollups(rc RetentionConfig, now time.Time) int {
+	trim := func(tier []RollupPoint, cutoff time.Time) ([]RollupPoint, int) {
+		i := 0
+		for i < len(tier) {
+			t, err := time.Parse(time.RFC3339, tier[i].Start)
+			if err == nil && !t.Before(cutoff) {
+				break
+			}
+			i++
+		}
+		return append([]RollupPoint(nil), tier[i:]...), i
+	}
+	rollMu.Lock()
+	defer rollMu.Unlock()
+	var a, b, c int
+	rollups.Minute, a = trim(rollups.Minute, now.Add(-time.Duration(rc.RollupMinuteHours)*time.Hour))
+	rollups.Hour, b = trim(rollups.Hour, now.AddDate(0, 0, -rc.RollupHourDays))
+	rollups.Day, c = trim(rollups.Day, now.AddDate(0, 0, -rc.RollupDayDays))
+	if a+b+c > 0 {
+		rollDirty = true
+	}
+	return a + b + c
+}
+
+func loadRollups() {
+	b, err := os.ReadFile(rollupPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Printf("ROLLUP_LOAD_ERROR: %v", err)
+		}
+		return
+	}
+	var f rollupFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		logger.Printf("ROLLUP_LOAD_ERROR: %v", err)
+		return
+	}
+	rollMu.Lock()
+	rollups = f
+	rollMu.Unlock()
+}
+
+func flushRollups() {
+	if persistPaused.Load() {
+		return // disk low (watchdog); stays dirty until resumed
+	}
+	rollMu.Lock()
+	if !rollDirty {
+		rollMu.Unlock()
+		return
+	}
+	b, err := json.Marshal(rollups)
+	rollDirty = false
+	rollMu.Unlock()
+	if err != nil {
+		return
+	}
+	tmp := rollupPath + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, rollupPath)
+	}
+	if err != nil {
+		logger.Printf("ROLLUP_SAVE_ERROR: %v", err)
+		rollMu.Lock()
+		rollDirty = true
+		rollMu.Unlock()
+	}
+}
+
+// rollupLoop closes minutes while idle and writes them out
+func rollupLoop() {
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for range t.C {
+		rollMu.Lock()
+		rollLocked(time.Now())
+		rollMu.Unlock()
+		flushRollups()
+	}
+}
+
+// GET /api/stats/rollups?tier=minute|hour|day&limit=N
+func apiRollups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	tier := r.URL.Query().Get("tier")
+	if tier == "" {
+		tier = "hour"
+	}
+	if tier != "minute" && tier != "hour" && tier != "day" {
+		httpError(w, r, "tier must be minute, hour or day", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	rc := retentionPolicy()
+	mustJSON(w, 200, map[string]any{
+		"tier":      tier,
+		"points":    rollupView(tier, limit),
+		"retention": map[string]int{"minuteHours": rc.RollupMinuteHours, "hourDays": rc.RollupHourDays, "dayDays": rc.RollupDayDays},
+	})
+}
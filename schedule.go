@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	多源调度策略（tuning.strategy，POST /api/admin/tuning {"strategy":"primary-fallback"}）
+	- race（默认）：所有到期源同时请求，取最先成功的；
+	  源可设 priority（0 最先），每低一档晚 raceStaggerMs（默认 300ms）发出，
+	  先发出的源都失败时下一档立即发出，已有源成功则后面的不再请求
+	  返回的高度不高于当前最新高度（同一块）时视为“没有新块”，不算赢家，继续等其他源
+	  有赢家后其余在途请求立即取消（平局判定窗口后）；raceDeadlineMs 限制整场竞速，
+	  raceSoftDeadlineMs 限制单个请求（超时即取消，该源本轮不再等待，下一档立即发出）；
+	  被取消的请求不计入健康分、熔断、退避（/api/sources/stats 的 cancelled）；
+	  raceKeepSecond=true 时不取消，等第二个结果与赢家比对（races.secondChecks / secondMismatches）
+	  raceMaxInFlight 限制同时在途的请求数（源很多时避免每个 tick 几十个并发出站请求），
+	  超出的按优先级 / 配置顺序排队，有请求结束才发出；竞速结束时仍在排队的不再发出
+	- primary-fallback：按源列表顺序，第一个启用的轮询源为主源；只在主源失败
+	  （请求出错 / 退避中 / 熔断 / 健康降级）时才依次请求备用源，省付费 key 的额度
+	  主源只是限速未到期时本 tick 直接跳过，不动用备用源
+	- round-robin：每个 tick 只请求一个源，按顺序轮换；该源未到期或失败时顺延到下一个
+	- sticky：只请求上一次竞速的赢家；它失败、不可用（退避 / 熔断 / 健康降级 / 维护）
+	  或平滑耗时超过 stickyMaxMs（默认 1500ms）时，下一次重新让其余到期源竞速，选出新的赢家
+	  （STICKY_SOURCE_SELECTED / STICKY_SOURCE_RELEASED），按量计费的 key 请求量接近单源
+	- 非 race 策略下限速令牌与熔断探测只在真正请求的源上消耗
+	- 多源共识（consensus.minAgree > 1）需要所有源的结果，此时始终按 race 请求
+*/
+
+const (
+	strategyRace            = "race"
+	strategyPrimaryFallback = "primary-fallback"
+	strategyRoundRobin      = "round-robin"
+	strategySticky          = "sticky"
+
+	stickyMaxDefault = 1500 * time.Millisecond
+)
+
+var (
+	rrMu   sync.Mutex
+	rrLast string // source id fetched last in round-robin mode
+
+	stickyMu  sync.Mutex
+	stickyID  string  // sticky mode: source polled alone until it fails or slows down
+	stickyLat float64 // its smoothed fetch time, ms
+)
+
+const raceStaggerDefault = 300 * time.Millisecond
+
+func raceStagger() time.Duration {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Tuning.raceStagger()
+}
+
+// raceBudget: deadlines of one fetchAny race
+type raceBudget struct {
+	total      time.Duration // 0 = none
+	soft       time.Duration // per request; 0 = none
+	keepSecond bool
+
+	maxInFlight int // 0 = no cap
+}
+
+func (tc TuningConfig) raceBudget() raceBudget {
+	return raceBudget{
+		total:       deadlineMS(tc.RaceDeadlineMS),
+		soft:        deadlineMS(tc.RaceSoftDeadlineMS),
+		keepSecond:  tc.RaceKeepSecond,
+		maxInFlight: min(max(tc.RaceMaxInFlight, 0), inFlightMaxN),
+	}
+}
+
+func raceBudgetSettings() raceBudget {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Tuning.raceBudget()
+}
+
+// priorityLevels groups sources by priority, preferred first; config order is kept within a level
+func priorityLevels(srcs []SourceConfig) [][]SourceConfig {
+	sorted := append([]SourceConfig(nil), srcs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+	var out [][]SourceConfig
+	for i, sc := range sorted {
+		if i == 0 || sc.Priority != sorted[i-1].Priority {
+			out = append(out, nil)
+		}
+		out[len(out)-1] = append(out[len(out)-1], sc)
+	}
+	return out
+}
+
+func validStrategy(s string) bool {
+	switch s {
+	case "", strategyRace, strategyPrimaryFallback, strategyRoundRobin, strategySticky:
+		return true
+	}
+	return false
+}
+
+func (tc TuningConfig) strategy() string {
+	if tc.Strategy == "" {
+		return strategyRace
+	}
+	return tc.Strategy
+}
+
+func scheduleStrategy() string {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Tuning.strategy()
+}
+
+// availableSources: poll sources out of maintenance with budget left, not resting after failures (health window, backoff)
+func availableSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	return backoffSources(healthySources(quotaSources(maintenanceSources(pollSources(srcs), now), now), now), now)
+}
+
+// dueSources applies the block phase, limiter and breaker (the last two consume state: tokens, probe slots)
+func dueSources(srcs []SourceConfig, now time.Time) []SourceConfig {
+	return breakerSources(limitSources(phaseSources(srcs, now), now), now)
+}
+
+// fetchScheduled fetches one source at a time in strategy order; fetched=false when nothing was due
+func fetchScheduled(strategy string, srcs []SourceConfig, now time.Time) (winner string, height int64, hash, timeISO string, fetched bool, err error) {
+	avail := availableSources(srcs, now)
+	order := avail
+	switch strategy {
+	case strategySticky:
+		return fetchSticky(avail, now)
+	case strategyPrimaryFallback:
+		if primary := pollSources(srcs); len(primary) > 0 && len(avail) > 0 && avail[0].ID == primary[0].ID {
+			if len(limitSources(phaseSources(avail[:1], now), now)) == 0 {
+				return "", 0, "", "", false, nil // primary healthy, just not due yet
+			}
+			if len(breakerSources(avail[:1], now)) == 0 {
+				order = avail[1:]
+			} else {
+				res := fetchSourceTimed(context.Background(), avail[0])
+				if res.err == nil || errors.Is(res.err, errNoNewBlock) {
+					return res.id, res.height, res.hash, res.timeISO, true, res.err
+				}
+				fetched = true
+				err = fmt.Errorf("%s: %w", res.id, res.err)
+				order = avail[1:]
+			}
+		}
+	case strategyRoundRobin:
+		rrMu.Lock()
+		last := rrLast
+		rrMu.Unlock()
+		start := 0
+		for i, sc := range avail {
+			if sc.ID == last {
+				start = i + 1
+			}
+		}
+		order = append(append([]SourceConfig(nil), avail[start:]...), avail[:start]...)
+	}
+
+	errs := []error{}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, sc := range order {
+		if len(dueSources([]SourceConfig{sc}, now)) == 0 {
+			continue
+		}
+		fetched = true
+		if strategy == strategyRoundRobin {
+			rrMu.Lock()
+			rrLast = sc.ID
+			rrMu.Unlock()
+		}
+		res := fetchSourceTimed(context.Background(), sc)
+		if res.err == nil || errors.Is(res.err, errNoNewBlock) {
+			return res.id, res.height, res.hash, res.timeISO, true, res.err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", res.id, res.err))
+	}
+	return "", 0, "", "", fetched, errors.Join(errs...)
+}
+
+func (tc TuningConfig) stickyMax() time.Duration {
+	if d := deadlineMS(tc.StickyMaxMS); d > 0 {
+		return d
+	}
+	return stickyMaxDefault
+}
+
+func stickyMax() time.Duration {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Tuning.stickyMax()
+}
+
+func stickyRelease(id, reason string) {
+	stickyMu.Lock()
+	defer stickyMu.Unlock()
+	if stickyID != id {
+		return
+	}
+	stickyID, stickyLat = "", 0
+	logger.Printf("STICKY_SOURCE_RELEASED id=%s reason=%q", id, reason)
+}
+
+func stickySelect(id string, took time.Duration) {
+	stickyMu.Lock()
+	defer stickyMu.Unlock()
+	if stickyID == id {
+		return
+	}
+	stickyID, stickyLat = id, float64(took.Milliseconds())
+	logger.Printf("STICKY_SOURCE_SELECTED id=%s", id)
+}
+
+func stickyCurrent() string {
+	stickyMu.Lock()
+	defer stickyMu.Unlock()
+	return stickyID
+}
+
+// fetchSticky polls the sticky source alone; without one (or once it fails) the
+// other due sources race and the winner sticks
+func fetchSticky(avail []SourceConfig, now time.Time) (winner string, height int64, hash, timeISO string, fetched bool, err error) {
+	id := stickyCurrent()
+	var errs []error
+	rest := avail
+	if id != "" {
+		idx := -1
+		for i, sc := range avail {
+			if sc.ID == id {
+				idx = i
+			}
+		}
+		switch {
+		case idx < 0:
+			stickyRelease(id, "unavailable")
+		case len(limitSources(phaseSources(avail[idx:idx+1], now), now)) == 0:
+			return "", 0, "", "", false, nil // sticking, just not due yet
+		case len(breakerSources(avail[idx:idx+1], now)) == 0:
+			stickyRelease(id, "circuit open")
+		default:
+			sc := avail[idx]
+			start := time.Now()
+			res := fetchSourceTimed(context.Background(), sc)
+			took := time.Since(start)
+			if res.err == nil || errors.Is(res.err, errNoNewBlock) {
+				stickyMu.Lock()
+				stickyLat = 0.3*float64(took.Milliseconds()) + 0.7*stickyLat
+				lat := stickyLat
+				stickyMu.Unlock()
+				if limit := stickyMax(); lat > float64(limit.Milliseconds()) {
+					stickyRelease(id, fmt.Sprintf("slow: %.0fms > %dms", lat, limit.Milliseconds()))
+				}
+				return res.id, res.height, res.hash, res.timeISO, true, res.err
+			}
+			stickyRelease(id, res.err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", res.id, res.err))
+			rest = append(append([]SourceConfig(nil), avail[:idx]...), avail[idx+1:]...)
+		}
+	}
+
+	due := dueSources(rest, now)
+	if len(due) == 0 {
+		return "", 0, "", "", len(errs) > 0, errors.Join(errs...)
+	}
+	start := time.Now()
+	winner, height, hash, timeISO, err = fetchAny(due)
+	if err == nil {
+		stickySelect(winner, time.Since(start))
+	} else if len(errs) > 0 && !errors.Is(err, errNoNewBlock) {
+		err = errors.Join(append(errs, err)...)
+	}
+	return winner, height, hash, timeISO, true, err
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	密钥库（API key 不再明文写在 config.json 里）
+	- 源的 url / urls / headers / body / apiKey / byNumUrl / byNumBody 可写 {{secret:NAME}}，
+	  每次请求时从 data/secrets.json（0600）取值替换；轮换只需改一处
+	- 引用了不存在的密钥：该次请求直接失败（错误里写明缺哪个），不会带着空值发出去
+	- GET    /api/admin/secrets            名称 + 脱敏值 + 更新时间 + 被哪些源引用
+	- POST   /api/admin/secrets {"name":"TRONGRID","value":"..."} 新增 / 轮换
+	- DELETE /api/admin/secrets?name=...   删除（仍被引用时 409）
+	- 轮换后引用该密钥的推送源立即重连；轮询源下一次请求即生效
+	- 启动诊断：引用了不存在的密钥 => warning
+*/
+
+var (
+	secretsPath = filepath.Join(dataDir, "secrets.json")
+	secretName  = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	secretRef   = regexp.MustCompile(`\{\{secret:([^}]*)\}\}`)
+
+	secretsMu sync.Mutex
+	secrets   = map[string]storedSecret{}
+)
+
+type storedSecret struct {
+	Value   string `json:"value"`
+	Updated string `json:"updated"`
+}
+
+func loadSecrets() {
+	b, err := os.ReadFile(secretsPath)
+	if err != nil {
+		return
+	}
+	m := map[string]storedSecret{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		logger.Printf("SECRETS_LOAD_ERROR: %v", err)
+		return
+	}
+	secretsMu.Lock()
+	secrets = m
+	secretsMu.Unlock()
+}
+
+// saveSecretsLocked: secretsMu held
+func saveSecretsLocked() error {
+	b, _ := json.MarshalIndent(secrets, "", "  ")
+	tmp := secretsPath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, secretsPath)
+}
+
+// resolveSecrets replaces {{secret:NAME}}; an unknown name is an error, never an empty value
+func resolveSecrets(s string) (string, error) {
+	if !strings.Contains(s, "{{secret:") {
+		return s, nil
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	var missing []string
+	out := secretRef.ReplaceAllStringFunc(s, func(m string) string {
+		name := secretRef.FindStringSubmatch(m)[1]
+		sec, ok := secrets[name]
+		if !ok {
+			missing = append(missing, name)
+			return ""
+		}
+		return sec.Value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("secret %s not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// secretRefs lists the secret names a source refers to
+func secretRefs(sc SourceConfig) []string {
+	parts := []string{sc.URL, sc.Body, sc.APIKey, sc.ByNumURL, sc.ByNumBody}
+	parts = append(parts, sc.APIKeys...)
+	parts = append(parts, sc.URLs...)
+	if sc.Enrich != nil {
+		parts = append(parts, sc.Enrich.URL, sc.Enrich.Body)
+	}
+	for _, v := range sc.Headers {
+		parts = append(parts, v)
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range parts {
+		for _, m := range secretRef.FindAllStringSubmatch(p, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				out = append(out, m[1])
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func secretExists(name string) bool {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	_, ok := secrets[name]
+	return ok
+}
+
+// secretUsers: source ids referring to name
+func secretUsers(name string) []string {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	var out []string
+	for _, sc := range cfg.Sources {
+		for _, n := range secretRefs(sc) {
+			if n == name {
+				out = append(out, sc.ID)
+			}
+		}
+	}
+	return out
+}
+
+// restartPushUsing drops running subscriptions that use name so they reconnect with the new value
+func restartPushUsing(name string) {
+	users := map[string]bool{}
+	for _, id := range secretUsers(name) {
+		users[id] = true
+	}
+	pushMu.Lock()
+	n := 0
+	for id, run := range pushRuns {
+		if users[id] {
+			close(run.stop)
+			delete(pushRuns, id)
+			n++
+		}
+	}
+	pushMu.Unlock()
+	if n == 0 {
+		return
+	}
+	rtMu.Lock()
+	listening := rt.Listening
+	rtMu.Unlock()
+	if listening && featureOn(featGenericSources) {
+		syncPushSources(enabledSources())
+	}
+}
+
+type SecretInfo struct {
+	Name    string   `json:"name"`
+	Value   string   `json:"value"` // redacted
+	Updated string   `json:"updated"`
+	UsedBy  []string `json:"usedBy"`
+}
+
+// GET    /api/admin/secrets
+// POST   /api/admin/secrets {"name":"TRONGRID","value":"..."}
+// DELETE /api/admin/secrets?name=TRONGRID
+func apiSecrets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		secretsMu.Lock()
+		out := make([]SecretInfo, 0, len(secrets))
+		for name, s := range secrets {
+			out = append(out, SecretInfo{Name: name, Value: redactKey(s.Value), Updated: s.Updated})
+		}
+		secretsMu.Unlock()
+		sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+		for i := range out {
+			out[i].UsedBy = secretUsers(out[i].Name)
+		}
+		mustJSON(w, 200, map[string]any{"secrets": out})
+
+	case "POST":
+		var req struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if !secretName.MatchString(req.Name) {
+			httpError(w, r, "name must be 1-64 of A-Z a-z 0-9 _ . -", http.StatusBadRequest)
+			return
+		}
+		if req.Value == "" {
+			httpError(w, r, "value required", http.StatusBadRequest)
+			return
+		}
+		secretsMu.Lock()
+		prev, existed := secrets[req.Name]
+		secrets[req.Name] = storedSecret{Value: req.Value, Updated: time.Now().UTC().Format(time.RFC3339)}
+		err := saveSecretsLocked()
+		if err != nil {
+			if existed {
+				secrets[req.Name] = prev
+			} else {
+				delete(secrets, req.Name)
+			}
+		}
+		secretsMu.Unlock()
+		if err != nil {
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		logger.Printf("SECRET_UPDATED name=%s rotated=%v rid=%s", req.Name, existed, requestID(r))
+		restartPushUsing(req.Name)
+		mustJSON(w, 200, map[string]any{"ok": true, "name": req.Name, "usedBy": secretUsers(req.Name)})
+
+	case "DELETE":
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		if users := secretUsers(name); len(users) > 0 {
+			httpError(w, r, "secret in use by "+strings.Join(users, ", "), http.StatusConflict)
+			return
+		}
+		secretsMu.Lock()
+		prev, ok := secrets[name]
+		delete(secrets, name)
+		var err error
+		if ok {
+			if err = saveSecretsLocked(); err != nil {
+				secrets[name] = prev
+			}
+		}
+		secretsMu.Unlock()
+		if !ok {
+			httpError(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		logger.Printf("SECRET_DELETED name=%s rid=%s", name, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+var errSecretName = errors.New("secret names are 1-64 of A-Z a-z 0-9 _ . -")
+
+// validateSecretRefs: well-formed names only; missing values are a runtime / diagnostics matter
+func validateSecretRefs(sc SourceConfig) error {
+	for _, n := range secretRefs(sc) {
+		if !secretName.MatchString(n) {
+			return fmt.Errorf("{{secret:%s}}: %w", n, errSecretName)
+		}
+	}
+	return nil
+}
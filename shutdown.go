@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+	结构化退出报告（data/last_shutdown.json）
+	- 正常退出（SIGINT/SIGTERM、所有监听端口关闭）时写入：原因、运行时长、最后高度、待命中 HIT 等
+	- 启动时发现 running.lock 残留且没有对应报告 => 生成 reason=abnormal 的报告
+	- 下次启动后通过 /api/status 的 lastShutdown 字段展示
+*/
+
+var (
+	shutdownPath = filepath.Join(dataDir, "last_shutdown.json")
+	lockPath     = filepath.Join(dataDir, "running.lock")
+	startedAt    = time.Now()
+
+	shutdownMu   sync.Mutex
+	lastShutdown *ShutdownReport // previous run, loaded at boot
+	shutdownOnce sync.Once
+)
+
+type ShutdownReport struct {
+	Reason        string  `json:"reason"` // "signal:terminated"|"signal:interrupt"|"listeners_closed"|"abnormal"
+	StartedAt     string  `json:"startedAt"`
+	StoppedAt     string  `json:"stoppedAt,omitempty"` // empty for abnormal (unknown)
+	UptimeSeconds float64 `json:"uptimeSeconds,omitempty"`
+	LastHeight    int64   `json:"lastHeight"`
+	LastHash      string  `json:"lastHash,omitempty"`
+
+	// in-flight state lost on exit
+	PendingHit     bool   `json:"pendingHit"`
+	PendingHitBase int64  `json:"pendingHitBase,omitempty"`
+	PendingExpect  string `json:"pendingExpect,omitempty"`
+	UnflushedDaily bool   `json:"unflushedDaily"`
+	WSClients      int    `json:"wsClients"`
+}
+
+// loadLastShutdown reads the previous report and detects an unclean exit via running.lock
+func loadLastShutdown() {
+	var rep *ShutdownReport
+	if b, err := os.ReadFile(shutdownPath); err == nil {
+		var r ShutdownReport
+		if json.Unmarshal(b, &r) == nil {
+			rep = &r
+		}
+	}
+
+	if b, err := os.ReadFile(lockPath); err == nil {
+		prevStart := strings.TrimSpace(string(b))
+		if rep == nil || rep.StartedAt != prevStart {
+			// lock left behind and no report for that run: it crashed or was killed
+			rep = &ShutdownReport{Reason: "abnormal", StartedAt: prevStart}
+			writeShutdownFile(rep)
+		}
+		logger.Printf("ABNORMAL_RESTART lastStart=%s", prevStart)
+	}
+
+	shutdownMu.Lock()
+	lastShutdown = rep
+	shutdownMu.Unlock()
+	if rep != nil && rep.Reason != "abnormal" {
+		logger.Printf("LAST_SHUTDOWN reason=%s uptimeSeconds=%.0f lastHeight=%d pendingHit=%v",
+			rep.Reason, rep.UptimeSeconds, rep.LastHeight, rep.PendingHit)
+	}
+}
+
+func lastShutdownReport() *ShutdownReport {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	return lastShutdown
+}
+
+func markRunning() {
+	_ = os.WriteFile(lockPath, []byte(startedAt.Format(time.RFC3339Nano)), 0o644)
+}
+
+// shutdown writes the report, flushes counters and removes the lock (once)
+func shutdown(reason string) {
+	shutdownOnce.Do(func() {
+		flushDaily()
+		flushInbox()
+		flushAccess()
+		flushQuota()
+		flushRollups()
+		now := time.Now()
+		rep := &ShutdownReport{
+			Reason:        reason,
+			StartedAt:     startedAt.Format(time.RFC3339Nano),
+			StoppedAt:     now.Format(time.RFC3339Nano),
+			UptimeSeconds: now.Sub(startedAt).Seconds(),
+		}
+		rtMu.Lock()
+		rep.LastHeight, rep.LastHash = rt.LastHeight, rt.LastHash
+		rep.PendingHit = rt.HitWaiting
+		if rt.HitWaiting {
+			rep.PendingHitBase, rep.PendingExpect = rt.HitBase, rt.HitExpect
+		}
+		rtMu.Unlock()
+		dailyMu.Lock()
+		rep.UnflushedDaily = dailyDirty
+		dailyMu.Unlock()
+		wsMu.Lock()
+		rep.WSClients = len(wsClients)
+		wsMu.Unlock()
+
+		saveLastGood()
+		writeShutdownFile(rep)
+		_ = os.Remove(lockPath)
+		logger.Printf("SYSTEM_STOP reason=%s uptimeSeconds=%.0f lastHeight=%d pendingHit=%v",
+			reason, rep.UptimeSeconds, rep.LastHeight, rep.PendingHit)
+	})
+}
+
+func writeShutdownFile(rep *ShutdownReport) {
+	b, _ := json.MarshalIndent(rep, "", "  ")
+	tmp := shutdownPath + ".tmp"
+	err := os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, shutdownPath)
+	}
+	if err != nil {
+		logger.Printf("SHUTDOWN_REPORT_ERROR: %v", err)
+	}
+}
+
+// handleShutdownSignals turns SIGINT/SIGTERM into a clean, reported exit
+func handleShutdownSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		name := "terminated"
+		if sig == os.Interrupt {
+			name = "interrupt"
+		}
+		shutdown("signal:" + name)
+		os.Exit(0)
+	}()
+}
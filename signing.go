@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+	信号签名（Ed25519，可选）
+	- signing.enabled 时每个外发信号带 keyId + sig，下游可用公钥验证信号确实来自本实例
+	- 私钥首次启用时生成，保存在 data/signing_key.json（0600）；公钥：GET /api/signing
+	- 签名内容（UTF-8，换行分隔）：
+	    tron-signal/v1
+	    {type}
+	    {height}
+	    {baseHeight}
+	    {state}
+	    {time}
+	    {keyId}
+	  sig = base64(Ed25519(私钥, 上述字节))；labels / explorerUrl / 流序号不在签名范围内
+	- POST /api/signing {"enabled":true} 开关；{"rotate":true} 换新密钥（旧签名无法再用新公钥验证）
+*/
+
+const signingPrefix = "tron-signal/v1"
+
+var signingKeyPath = filepath.Join(dataDir, "signing_key.json")
+
+type SigningConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+type signingKeyFile struct {
+	Seed    string `json:"seed"` // base64 Ed25519 seed
+	Created string `json:"created"`
+}
+
+var (
+	signMu      sync.Mutex
+	signKey     ed25519.PrivateKey
+	signKeyID   string
+	signCreated string
+)
+
+func signingEnabled() bool {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Signing.Enabled
+}
+
+// signingKeyLocked loads or creates the key; signMu held
+func signingKeyLocked(rotate bool) error {
+	if signKey != nil && !rotate {
+		return nil
+	}
+	var kf signingKeyFile
+	if !rotate {
+		if b, err := os.ReadFile(signingKeyPath); err == nil {
+			if err := json.Unmarshal(b, &kf); err != nil {
+				return fmt.Errorf("signing key: %w", err)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var seed []byte
+	if kf.Seed != "" {
+		var err error
+		if seed, err = base64.StdEncoding.DecodeString(kf.Seed); err != nil || len(seed) != ed25519.SeedSize {
+			return errors.New("signing key: bad seed")
+		}
+	} else {
+		seed = make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err
+		}
+		kf = signingKeyFile{Seed: base64.StdEncoding.EncodeToString(seed), Created: time.Now().UTC().Format(time.RFC3339)}
+		b, _ := json.MarshalIndent(kf, "", "  ")
+		tmp := signingKeyPath + ".tmp"
+		err := os.WriteFile(tmp, b, 0o600)
+		if err == nil {
+			err = os.Rename(tmp, signingKeyPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	signKey = ed25519.NewKeyFromSeed(seed)
+	sum := sha256.Sum256(signKey.Public().(ed25519.PublicKey))
+	signKeyID = hex.EncodeToString(sum[:8])
+	signCreated = kf.Created
+	return nil
+}
+
+func signingPayload(s Signal) []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%d\n%d\n%s\n%s\n%s",
+		signingPrefix, s.Type, s.Height, s.BaseHeight, s.State, s.TimeISO, s.KeyID))
+}
+
+// signSignal fills KeyID + Sig when signing is on; a key error leaves the signal unsigned
+func signSignal(s *Signal) {
+	if !signingEnabled() {
+		return
+	}
+	signMu.Lock()
+	defer signMu.Unlock()
+	if err := signingKeyLocked(false); err != nil {
+		logger.Printf("SIGNING_KEY_ERROR: %v", err)
+		return
+	}
+	s.KeyID = signKeyID
+	s.Sig = base64.StdEncoding.EncodeToString(ed25519.Sign(signKey, signingPayload(*s)))
+}
+
+type SigningInfo struct {
+	Enabled   bool   `json:"enabled"`
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"` // base64, 32 bytes
+	Created   string `json:"created,omitempty"`
+	Payload   string `json:"payload"` // signed fields, newline separated
+}
+
+func signingInfoLocked(enabled bool) SigningInfo {
+	info := SigningInfo{Enabled: enabled, Algorithm: "ed25519",
+		Payload: signingPrefix + "\n{type}\n{height}\n{baseHeight}\n{state}\n{time}\n{keyId}"}
+	if signKey != nil {
+		info.KeyID = signKeyID
+		info.PublicKey = base64.StdEncoding.EncodeToString(signKey.Public().(ed25519.PublicKey))
+		info.Created = signCreated
+	}
+	return info
+}
+
+// GET  /api/signing
+// POST /api/signing {"enabled":true} | {"rotate":true}
+func apiSigning(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		enabled := signingEnabled()
+		signMu.Lock()
+		defer signMu.Unlock()
+		if enabled {
+			if err := signingKeyLocked(false); err != nil {
+				httpError(w, r, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		mustJSON(w, 200, signingInfoLocked(enabled))
+	case "POST":
+		var in struct {
+			Enabled *bool `json:"enabled"`
+			Rotate  bool  `json:"rotate"`
+		}
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		signMu.Lock()
+		defer signMu.Unlock()
+		if in.Rotate || (in.Enabled != nil && *in.Enabled) {
+			if err := signingKeyLocked(in.Rotate); err != nil {
+				httpError(w, r, "signing key: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		cfgMu.Lock()
+		if in.Enabled != nil {
+			cfg.Signing.Enabled = *in.Enabled
+		}
+		enabled := cfg.Signing.Enabled
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		logger.Printf("SIGNING_UPDATED enabled=%v rotated=%v keyId=%s rid=%s", enabled, in.Rotate, signKeyID, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "signing": signingInfoLocked(enabled)})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	确定性模拟器（QA 复现用）
+	- 用 JSON 场景脚本替代真实区块来源：指定 ON/OFF 判定序列、缺口、重复高度、重组
+	- 相同 seed + 脚本 => 完全相同的 height/hash 序列
+	- 每个轮询周期产出一个块；脚本结束后停止（loop=true 则从头重放：
+	  每一轮高度整体后移一个脚本跨度、哈希按轮次重新派生且判定不变，不会被去重吞掉）
+	- 每步的 repeat / random / gap 最多 simStepMax，展开后的块总数最多 simBlocksMax，超出则拒绝（400）
+	- 模拟的区块 / 信号照常推送但带 simulated=true，信号不签名；不计入每日计数、汇总与图表
+	- 停止（DELETE）后状态机清空，最新高度恢复为启动模拟前的值
+
+	场景示例：
+	{"startHeight":1000,"seed":42,"loop":false,"steps":[
+	  {"state":"OFF","repeat":3},{"state":"ON","repeat":5},
+	  {"gap":2},{"duplicate":true},{"reorg":true,"state":"OFF"},{"random":10}
+	]}
+*/
+
+type SimulatorConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Scenario string `json:"scenario"` // path to scenario JSON
+}
+
+type SimScenario struct {
+	StartHeight int64     `json:"startHeight"`
+	Seed        int64     `json:"seed"`
+	Loop        bool      `json:"loop"`
+	Steps       []SimStep `json:"steps"`
+}
+
+// SimStep: exactly one action per step
+type SimStep struct {
+	State     string `json:"state,omitempty"`     // "ON"|"OFF": next height with that judge outcome
+	Repeat    int    `json:"repeat,omitempty"`    // repeat State N times (default 1)
+	Gap       int    `json:"gap,omitempty"`       // skip N heights (no block emitted)
+	Duplicate bool   `json:"duplicate,omitempty"` // re-emit previous height+hash
+	Reorg     bool   `json:"reorg,omitempty"`     // previous height again with a new hash (State optional)
+	Random    int    `json:"random,omitempty"`    // N seeded random outcomes
+}
+
+type simBlock struct {
+	Height int64
+	Hash   string
+}
+
+type simulator struct {
+	sc     SimScenario
+	blocks []simBlock // fully expanded at load (deterministic)
+	pos    int
+	done   bool
+
+	span int64 // heights covered by one pass (loop offset)
+	pass int64
+}
+
+const (
+	simStepMax   = 10000
+	simBlocksMax = 10000
+)
+
+// simTip: the live tip saved when a scenario starts, restored when it stops
+type simTip struct {
+	height int64
+	hash   string
+	t      time.Time
+}
+
+var (
+	simMu    sync.Mutex
+	simRun   *simulator
+	simSaved *simTip // nil when no scenario replaced the live tip
+
+	errSimDone = errors.New("simulator: scenario finished")
+)
+
+// genesis of simulated time; block i is startTime + (height-start)*3s
+var simEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newSimulator(sc SimScenario) (*simulator, error) {
+	if len(sc.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	if sc.StartHeight <= 0 {
+		sc.StartHeight = 1
+	}
+	rng := rand.New(rand.NewSource(sc.Seed))
+	s := &simulator{sc: sc}
+
+	next := sc.StartHeight
+	variant := 0
+	emit := func(h int64, state string) {
+		variant++
+		s.blocks = append(s.blocks, simBlock{Height: h, Hash: simHash(sc.Seed, h, variant, state, rng)})
+	}
+
+	for i, st := range sc.Steps {
+		state := strings.ToUpper(strings.TrimSpace(st.State))
+		if state != "" && state != "ON" && state != "OFF" {
+			return nil, fmt.Errorf("step %d: state must be ON or OFF", i)
+		}
+		if st.Repeat > simStepMax || st.Random > simStepMax || st.Gap > simStepMax {
+			return nil, fmt.Errorf("step %d: repeat, random and gap are limited to %d", i, simStepMax)
+		}
+		switch {
+		case st.Gap > 0:
+			next += int64(st.Gap)
+		case st.Duplicate:
+			if len(s.blocks) == 0 {
+				return nil, fmt.Errorf("step %d: duplicate before any block", i)
+			}
+			s.blocks = append(s.blocks, s.blocks[len(s.blocks)-1])
+		case st.Reorg:
+			if len(s.blocks) == 0 {
+				return nil, fmt.Errorf("step %d: reorg before any block", i)
+			}
+			if state == "" {
+				state = randomState(rng)
+			}
+			emit(s.blocks[len(s.blocks)-1].Height, state)
+		case st.Random > 0:
+			for j := 0; j < st.Random; j++ {
+				emit(next, randomState(rng))
+				next++
+			}
+		case state != "":
+			n := st.Repeat
+			if n < 1 {
+				n = 1
+			}
+			for j := 0; j < n; j++ {
+				emit(next, state)
+				next++
+			}
+		default:
+			return nil, fmt.Errorf("step %d: no action", i)
+		}
+		if len(s.blocks) > simBlocksMax {
+			return nil, fmt.Errorf("step %d: scenario exceeds %d blocks", i, simBlocksMax)
+		}
+	}
+	if len(s.blocks) == 0 {
+		return nil, errors.New("scenario emits no blocks")
+	}
+	s.span = next - sc.StartHeight
+	for _, b := range s.blocks {
+		s.span = max(s.span, b.Height-sc.StartHeight+1)
+	}
+	return s, nil
+}
+
+func randomState(rng *rand.Rand) string {
+	if rng.Intn(2) == 0 {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// simHash builds a 64-hex block id whose last two chars judge to state
+// (mixed letter/digit => ON, same type => OFF; see blockStateByHash)
+func simHash(seed, height int64, variant int, state string, rng *rand.Rand) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%d", seed, height, variant)))
+	h := []byte(hex.EncodeToString(sum[:]))
+	const digits, letters = "0123456789", "abcdef"
+	d := digits[rng.Intn(len(digits))]
+	l := letters[rng.Intn(len(letters))]
+	if state == "ON" {
+		if rng.Intn(2) == 0 {
+			h[62], h[63] = d, l
+		} else {
+			h[62], h[63] = l, d
+		}
+	} else {
+		if rng.Intn(2) == 0 {
+			h[62], h[63] = d, digits[rng.Intn(len(digits))]
+		} else {
+			h[62], h[63] = l, letters[rng.Intn(len(letters))]
+		}
+	}
+	return string(h)
+}
+
+func (s *simulator) next() (int64, string, string, error) {
+	if s.pos >= len(s.blocks) {
+		if !s.sc.Loop {
+			if !s.done {
+				s.done = true
+				logger.Printf("SIM_DONE blocks=%d", len(s.blocks))
+			}
+			return 0, "", "", errSimDone
+		}
+		s.pos = 0
+		s.pass++
+	}
+	b := s.blocks[s.pos]
+	s.pos++
+	if s.pass > 0 {
+		b.Height += s.pass * s.span
+		b.Hash = simPassHash(b.Hash, s.pass)
+	}
+	t := simEpoch.Add(time.Duration(b.Height-s.sc.StartHeight) * 3 * time.Second)
+	return b.Height, b.Hash, t.Format(time.RFC3339Nano), nil
+}
+
+// simPassHash derives the hash of a looped block; the last two chars (the judge
+// outcome) are kept, and duplicates / reorgs within a pass stay what they were
+func simPassHash(hash string, pass int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", hash, pass)))
+	return hex.EncodeToString(sum[:])[:62] + hash[62:]
+}
+
+func loadSimScenario(path string) (SimScenario, error) {
+	var sc SimScenario
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&sc)
+	return sc, err
+}
+
+// initSimulator loads the configured scenario at boot (if enabled)
+func initSimulator(sc SimulatorConfig) {
+	if !sc.Enabled {
+		return
+	}
+	scn, err := loadSimScenario(sc.Scenario)
+	if err == nil {
+		err = startSimulator(scn)
+	}
+	if err != nil {
+		logger.Printf("SIM_LOAD_ERROR path=%s err=%v", sc.Scenario, err)
+	}
+}
+
+func startSimulator(sc SimScenario) error {
+	s, err := newSimulator(sc)
+	if err != nil {
+		return err
+	}
+	simMu.Lock()
+	if simRun == nil {
+		rtMu.Lock()
+		simSaved = &simTip{height: rt.LastHeight, hash: rt.LastHash, t: rt.LastTime}
+		rtMu.Unlock()
+	}
+	simRun = s
+	simMu.Unlock()
+	logger.Printf("SIM_LOADED seed=%d start=%d steps=%d blocks=%d loop=%v",
+		sc.Seed, sc.StartHeight, len(sc.Steps), len(s.blocks), sc.Loop)
+	return nil
+}
+
+func simulatorActive() bool {
+	simMu.Lock()
+	defer simMu.Unlock()
+	return simRun != nil
+}
+
+// simulatorNext: ok=false when no simulator is running (use real sources)
+func simulatorNext() (height int64, hash, timeISO string, ok bool, err error) {
+	simMu.Lock()
+	defer simMu.Unlock()
+	if simRun == nil {
+		return 0, "", "", false, nil
+	}
+	height, hash, timeISO, err = simRun.next()
+	return height, hash, timeISO, true, err
+}
+
+// GET progress / POST scenario JSON (replaces, restarts from step 0) / DELETE stop
+func apiSimulator(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		simMu.Lock()
+		out := map[string]any{"active": simRun != nil}
+		if simRun != nil {
+			out["position"] = simRun.pos
+			out["blocks"] = len(simRun.blocks)
+			out["done"] = simRun.done
+			out["scenario"] = simRun.sc
+		}
+		simMu.Unlock()
+		mustJSON(w, 200, out)
+	case "POST":
+		var sc SimScenario
+		if err := readJSON(r, &sc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := startSimulator(sc); err != nil {
+			httpError(w, r, "invalid scenario: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// a scenario replays from a clean machine state
+		resetRuntime()
+		tryStartListener()
+		mustJSON(w, 200, map[string]any{"ok": true})
+	case "DELETE":
+		simMu.Lock()
+		active, saved := simRun != nil, simSaved
+		simRun, simSaved = nil, nil
+		simMu.Unlock()
+		if active {
+			// drop the scenario's machine state and go back to the live tip
+			resetRuntime()
+			if saved != nil {
+				rtMu.Lock()
+				rt.LastHeight, rt.LastHash, rt.LastTime = saved.height, saved.hash, saved.t
+				rtMu.Unlock()
+			}
+			tryStartListener()
+			broadcastStatus()
+		}
+		logger.Printf("SIM_STOPPED rid=%s", requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
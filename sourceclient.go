@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	每源 HTTP 客户端
+	- timeoutMs：整个请求（含读完响应体）；connectTimeoutMs：TCP 建连；readTimeoutMs：等待响应头
+	  0 = 默认（8000 / 跟随 timeoutMs）
+	- tls：自定义 CA（caFile，追加到系统根证书）、客户端证书（certFile + keyFile）、insecureSkipVerify（仅限自建节点）
+	  证书文件在创建 client 时读取；读取失败时该源每次请求都返回同一错误，修复文件后下次请求自动重试加载
+	- dns：resolver（host[:port]，默认 53 端口）用指定 DNS 服务器解析；pinIp 跳过解析直接连该 IP（二选一）
+	  只改连接地址，Host 头与 TLS 证书校验仍按 URL 里的域名；推送源建连同样生效
+	- 相同设置的源共用一个 client（连接池复用），设置改变后自然换用新 client
+	- 连接池：每个主机最多保留 fetchIdlePerHost 条空闲连接；空闲保活时长 = 最慢轮询间隔的 2 倍（90s ~ 10min），
+	  低频轮询也能复用连接，省掉每次的 TCP / TLS 握手
+	- 压缩：请求带 Accept-Encoding: gzip 并透明解压；源自定义了 Accept-Encoding 头时在 fetchSourceDoc 里手动解 gzip
+	- 轮询、比对、测试、回填都走这里，推送源建连使用 connectTimeoutMs；TronGrid 默认源按默认设置取 client；节点发现仍用固定超时
+*/
+
+const (
+	sourceTimeoutDefaultMS = 8000
+	sourceTimeoutMaxMS     = 60000
+	sourceClientsMax       = 64
+
+	fetchIdlePerHost = 16
+	fetchIdleMin     = 90 * time.Second
+	fetchIdleMax     = 10 * time.Minute
+)
+
+// SourceTLS: optional TLS settings for private nodes (file paths on this host)
+type SourceTLS struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// SourceDNS: resolve the endpoint through a given DNS server, or skip resolution with a pinned IP
+type SourceDNS struct {
+	Resolver string `json:"resolver,omitempty"` // host[:port], port 53 by default
+	PinIP    string `json:"pinIp,omitempty"`
+}
+
+type sourceClientKey struct {
+	timeout, connect, read time.Duration
+	idle                   time.Duration
+	tls                    SourceTLS
+	dns                    SourceDNS
+}
+
+var (
+	srcClientMu sync.Mutex
+	srcClients  = map[sourceClientKey]*http.Client{}
+	srcTLSErrs  = map[string]string{} // last logged TLS load error per source
+)
+
+func validateSourceTimeouts(sc SourceConfig) error {
+	for _, f := range []struct {
+		name string
+		ms   int
+	}{{"timeoutMs", sc.TimeoutMS}, {"connectTimeoutMs", sc.ConnectTimeoutMS}, {"readTimeoutMs", sc.ReadTimeoutMS}} {
+		if f.ms < 0 || f.ms > sourceTimeoutMaxMS {
+			return fmt.Errorf("%s must be 0..%d", f.name, sourceTimeoutMaxMS)
+		}
+	}
+	k := sourceClientKeyOf(sc)
+	if k.connect > k.timeout || k.read > k.timeout {
+		return errors.New("connectTimeoutMs / readTimeoutMs cannot exceed timeoutMs")
+	}
+	return nil
+}
+
+// validateSourceTLS trims the paths and checks that the files load
+func validateSourceTLS(t *SourceTLS) error {
+	if t == nil {
+		return nil
+	}
+	t.CAFile = strings.TrimSpace(t.CAFile)
+	t.CertFile = strings.TrimSpace(t.CertFile)
+	t.KeyFile = strings.TrimSpace(t.KeyFile)
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls.certFile and tls.keyFile must be set together")
+	}
+	_, err := sourceTLSConfig(t)
+	return err
+}
+
+// validateSourceDNS trims the settings and normalizes resolver to host:port
+func validateSourceDNS(d *SourceDNS) error {
+	if d == nil {
+		return nil
+	}
+	d.Resolver = strings.TrimSpace(d.Resolver)
+	d.PinIP = strings.TrimSpace(d.PinIP)
+	if d.Resolver != "" && d.PinIP != "" {
+		return errors.New("dns.resolver and dns.pinIp are mutually exclusive")
+	}
+	if d.PinIP != "" && net.ParseIP(d.PinIP) == nil {
+		return errors.New("dns.pinIp must be an IP address")
+	}
+	if d.Resolver != "" {
+		host, port, err := net.SplitHostPort(d.Resolver)
+		if err != nil {
+			host, port = d.Resolver, "53"
+		}
+		if net.ParseIP(strings.Trim(host, "[]")) == nil {
+			return errors.New("dns.resolver must be an IP address (optionally with :port)")
+		}
+		d.Resolver = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	return nil
+}
+
+// sourceDialer dials with the source's connect timeout and DNS settings
+func sourceDialer(k sourceClientKey) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: k.connect, KeepAlive: 30 * time.Second}
+	if k.dns.Resolver != "" {
+		resolver := k.dns.Resolver
+		d.Resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: k.connect}).DialContext(ctx, network, resolver)
+		}}
+	}
+	pin := k.dns.PinIP
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if pin != "" {
+			if _, port, err := net.SplitHostPort(addr); err == nil {
+				addr = net.JoinHostPort(pin, port)
+			}
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// sourceTLSConfig: nil when t changes nothing
+func sourceTLSConfig(t *SourceTLS) (*tls.Config, error) {
+	if t == nil || *t == (SourceTLS{}) {
+		return nil, nil
+	}
+	tc := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.caFile: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tls.caFile: no PEM certificates found")
+		}
+		tc.RootCAs = pool
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client cert: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// errTransport fails every request (TLS files unusable)
+type errTransport struct{ err error }
+
+func (t errTransport) RoundTrip(*http.Request) (*http.Response, error) { return nil, t.err }
+
+func sourceClientKeyOf(sc SourceConfig) sourceClientKey {
+	ms := func(v, def int) time.Duration {
+		if v <= 0 {
+			v = def
+		}
+		return time.Duration(v) * time.Millisecond
+	}
+	total := ms(sc.TimeoutMS, sourceTimeoutDefaultMS)
+	k := sourceClientKey{
+		timeout: total,
+		connect: ms(sc.ConnectTimeoutMS, int(total.Milliseconds())),
+		read:    ms(sc.ReadTimeoutMS, int(total.Milliseconds())),
+	}
+	if sc.TLS != nil {
+		k.tls = *sc.TLS
+	}
+	if sc.DNS != nil {
+		k.dns = *sc.DNS
+	}
+	return k
+}
+
+// fetchIdleTimeout: keep idle connections for twice the slowest expected poll gap
+func fetchIdleTimeout() time.Duration {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	gap := cfg.Tuning.tick()
+	for _, sc := range cfg.Sources {
+		if sc.Enabled && sc.BaseRPS > 0 {
+			gap = max(gap, time.Duration(float64(time.Second)/sc.BaseRPS))
+		}
+	}
+	if ps := cfg.PowerSave.withDefaults(); ps.Enabled {
+		gap = max(gap, time.Duration(ps.IdleSeconds)*time.Second)
+	}
+	return min(max(2*gap, fetchIdleMin), fetchIdleMax)
+}
+
+// newFetchTransport: pooled keep-alive transport tuned for repeated polling of few hosts
+func newFetchTransport(k sourceClientKey, tc *tls.Config) *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = sourceDialer(k)
+	tr.TLSHandshakeTimeout = k.connect
+	tr.ResponseHeaderTimeout = k.read
+	tr.MaxIdleConnsPerHost = fetchIdlePerHost
+	tr.IdleConnTimeout = k.idle
+	tr.DisableCompression = false
+	if tc != nil {
+		tr.TLSClientConfig = tc
+	}
+	return tr
+}
+
+// sourceClient: the shared client for sc's timeout and TLS settings
+func sourceClient(sc SourceConfig) *http.Client {
+	k := sourceClientKeyOf(sc)
+	k.idle = fetchIdleTimeout()
+	srcClientMu.Lock()
+	defer srcClientMu.Unlock()
+	if c := srcClients[k]; c != nil {
+		return c
+	}
+	tc, err := sourceTLSConfig(sc.TLS)
+	if err != nil {
+		if srcTLSErrs[sc.ID] != err.Error() {
+			srcTLSErrs[sc.ID] = err.Error()
+			logger.Printf("SOURCE_TLS_ERROR id=%s: %v", sc.ID, err)
+		}
+		return &http.Client{Transport: errTransport{err}}
+	}
+	delete(srcTLSErrs, sc.ID)
+	if len(srcClients) >= sourceClientsMax {
+		for old, c := range srcClients {
+			c.CloseIdleConnections()
+			delete(srcClients, old)
+		}
+	}
+	c := &http.Client{Timeout: k.timeout, Transport: newFetchTransport(k, tc)}
+	srcClients[k] = c
+	return c
+}
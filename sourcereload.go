@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+	源变更即时生效
+	- 监听循环每个 tick 都重新读取 cfg.Sources，增删改本来就在下一个 tick 生效；
+	  这里处理按源 ID 保存的运行时状态，避免旧端点的状态套到新端点上
+	- 端点变化（kind / method / url / urls / body / headers / key / tls / dns / 超时）或删除：
+	  清掉该源的健康窗口、退避、熔断、限速桶与请求统计，新端点从干净状态开始
+	- 只改映射路径 / 速率 / 标签等不清状态（限速器本身会按新速率调整）
+	- 推送源立即按新配置重连 / 断开，不等下一个 tick（监听未运行时由监听循环负责）
+	- 写 SOURCES_RELOADED（reset / removed 列出受影响的源 ID）
+	- POST /api/admin/reload/sources 从磁盘配置整体替换源列表（逐个 sanitizeSource，ID 必填且唯一），同样走这里
+*/
+
+// sourceEndpoint: the fields that decide what is fetched and how
+func sourceEndpoint(sc SourceConfig) string {
+	b, _ := json.Marshal(struct {
+		Kind, Method, URL, Body string
+		URLs                    []string
+		Headers                 map[string]string
+		Keys                    []string
+		TLS                     *SourceTLS
+		DNS                     *SourceDNS
+		Timeouts                [3]int
+	}{sc.Kind, sc.Method, sc.URL, sc.Body, sc.URLs, sc.Headers, sourceKeys(sc), sc.TLS, sc.DNS,
+		[3]int{sc.TimeoutMS, sc.ConnectTimeoutMS, sc.ReadTimeoutMS}})
+	return string(b)
+}
+
+// sanitizeSources validates a whole source list (config reload); ids must be set and unique
+func sanitizeSources(in []SourceConfig) ([]SourceConfig, error) {
+	out := make([]SourceConfig, 0, len(in))
+	seen := map[string]bool{}
+	for i, sc := range in {
+		sc, err := sanitizeSource(sc)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		if sc.ID == "" {
+			return nil, fmt.Errorf("sources[%d]: id missing", i)
+		}
+		if seen[sc.ID] {
+			return nil, fmt.Errorf("sources[%d]: duplicate id %q", i, sc.ID)
+		}
+		seen[sc.ID] = true
+		out = append(out, sc)
+	}
+	return out, nil
+}
+
+// redactSources: the list as GET /api/sources shows it (reload reports)
+func redactSources(in []SourceConfig) map[string]SourceConfig {
+	out := make(map[string]SourceConfig, len(in))
+	for _, sc := range in {
+		out[sc.ID] = redactSource(sc)
+	}
+	return out
+}
+
+// resetSourceState forgets everything kept per source id
+func resetSourceState(id string) {
+	healthMu.Lock()
+	delete(healthStates, id)
+	healthMu.Unlock()
+
+	boMu.Lock()
+	delete(boStates, id)
+	boMu.Unlock()
+
+	brMu.Lock()
+	delete(brStates, id)
+	brMu.Unlock()
+
+	limMu.Lock()
+	delete(limBuckets, id)
+	delete(limProfile, id)
+	delete(limPause, id)
+	limMu.Unlock()
+
+	statsMu.Lock()
+	delete(sourceStates, id)
+	statsMu.Unlock()
+
+	phaseMu.Lock()
+	delete(phaseStates, id)
+	phaseMu.Unlock()
+
+	epMu.Lock()
+	delete(epStates, id)
+	epMu.Unlock()
+}
+
+// sourcesChanged applies a source list edit to the running listener; before = list prior to the edit
+func sourcesChanged(before []SourceConfig, rid string) {
+	cfgMu.RLock()
+	after := make(map[string]SourceConfig, len(cfg.Sources))
+	for _, sc := range cfg.Sources {
+		after[sc.ID] = sc
+	}
+	cfgMu.RUnlock()
+
+	var reset, removed []string
+	for _, old := range before {
+		cur, ok := after[old.ID]
+		switch {
+		case !ok:
+			removed = append(removed, old.ID)
+			resetSourceState(old.ID)
+		case sourceEndpoint(cur) != sourceEndpoint(old):
+			reset = append(reset, old.ID)
+			resetSourceState(old.ID)
+		}
+	}
+
+	rtMu.Lock()
+	listening := rt.Listening
+	rtMu.Unlock()
+	if listening && featureOn(featGenericSources) {
+		syncPushSources(enabledSources())
+	}
+
+	logger.Printf("SOURCES_RELOADED sources=%d reset=%s removed=%s rid=%s",
+		len(after), strings.Join(reset, ","), strings.Join(removed, ","), rid)
+}
@@ -0,0 +1,996 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	通用区块源（Config.Sources）
+	- 任意 REST / JSON-RPC 提供商：method + url + body + headers + JSONPath 映射
+	- {apiKey} 占位符在 url / headers / body 中替换
+	- body 为 JSON 数组时按 JSON-RPC 批量请求处理：响应按请求 id 的顺序重排，路径可写 [1].result.hash
+	- 内置预设库（presets）：创建时按名称自动填充，之后仍可编辑
+	- kind=push 的源走 WebSocket 订阅（pushsource.go），其余为轮询源
+	- 有启用的源时，监听循环并发请求所有启用的轮询源（受各自限速档约束），最先返回者胜出；
+	  否则沿用 TronGrid + Config.APIKeys
+*/
+
+type SourceConfig struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Enabled bool              `json:"enabled"`
+	Preset  string            `json:"preset,omitempty"` // preset it was created from (informational)
+	Kind    string            `json:"kind,omitempty"`   // "" = poll (REST), "push" = WebSocket subscription
+	Method  string            `json:"method,omitempty"` // GET|POST (poll)
+	URL     string            `json:"url"`
+	URLs    []string          `json:"urls,omitempty"` // fallback endpoints tried after url (endpoints.go)
+	Body    string            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	APIKey  string            `json:"apiKey,omitempty"`
+
+	// extra keys rotated with APIKey (keypool.go)
+	APIKeys     []string `json:"apiKeys,omitempty"`
+	KeyRotation string   `json:"keyRotation,omitempty"` // "" = round-robin | "failover"
+	RatePerKey  bool     `json:"ratePerKey,omitempty"`  // rps limits apply per key
+
+	HeightPath string `json:"heightPath"`
+	HashPath   string `json:"hashPath"`
+	TimePath   string `json:"timePath,omitempty"` // ms or s epoch; empty = receive time
+
+	// optional transaction summary on block events (txmeta.go)
+	CaptureTxs bool   `json:"captureTxs,omitempty"`
+	TxPath     string `json:"txPath,omitempty"`    // array of transactions (objects or id strings)
+	TxIDField  string `json:"txIdField,omitempty"` // id field of object entries; "" = txID, then hash
+
+	// by-height lookup for gap backfill (backfill.go); {height} / {heightHex} are substituted
+	ByNumMethod string `json:"byNumMethod,omitempty"` // empty = Method
+	ByNumURL    string `json:"byNumUrl,omitempty"`
+	ByNumBody   string `json:"byNumBody,omitempty"`
+
+	// secondary request whose fields are merged into the block record (enrich.go)
+	Enrich *SourceEnrich `json:"enrich,omitempty"`
+
+	// poll with the by-height request for the next height instead of "latest" (heighthint.go)
+	HeightHint bool `json:"heightHint,omitempty"`
+
+	// "" = uniform (rate limiter only), "phase" = timed to the 3s block cadence (phase.go)
+	PollMode string `json:"pollMode,omitempty"`
+
+	// race strategy: lower goes first, each level later waits tuning.raceStaggerMs (schedule.go)
+	Priority int `json:"priority,omitempty"`
+
+	// blocks are already irreversible (solidity node): skip the confirmations delay
+	Confirmed bool `json:"confirmed,omitempty"`
+
+	BaseRPS      float64       `json:"baseRps"` // 0 = every tick
+	RateProfiles []RateProfile `json:"rateProfiles,omitempty"`
+	Burst        int           `json:"burst,omitempty"`  // token bucket size; 0 = 1
+	MaxRPS       float64       `json:"maxRps,omitempty"` // > rate: ramp up while requests succeed
+
+	// request budgets for metered keys (quota.go); 0 = unlimited
+	DailyBudget   int64 `json:"dailyBudget,omitempty"`
+	MonthlyBudget int64 `json:"monthlyBudget,omitempty"`
+
+	// scheduled provider maintenance: the source is skipped, not failed (maintenance.go)
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+
+	// per-source HTTP timeouts (sourceclient.go); 0 = default
+	TimeoutMS        int `json:"timeoutMs,omitempty"`
+	ConnectTimeoutMS int `json:"connectTimeoutMs,omitempty"`
+	ReadTimeoutMS    int `json:"readTimeoutMs,omitempty"`
+
+	// custom CA / client certificate / skip-verify for private nodes (sourceclient.go)
+	TLS *SourceTLS `json:"tls,omitempty"`
+
+	// custom resolver or pinned IP for the endpoint host (sourceclient.go)
+	DNS *SourceDNS `json:"dns,omitempty"`
+
+	// attached to signals and block events from this source (labels.go)
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+const sourceKindPush = "push"
+
+type SourcePreset struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SourceConfig
+}
+
+// built-in response mappings; URL placeholders in <...> must be edited
+var sourcePresets = []SourcePreset{
+	{
+		Name:        "trongrid",
+		Description: "TronGrid /wallet/getnowblock",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://api.trongrid.io/wallet/getnowblock", Body: "{}",
+			Headers:    map[string]string{"TRON-PRO-API-KEY": "{apiKey}"},
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "https://api.trongrid.io/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+	{
+		Name:        "tron-fullnode",
+		Description: "Self-hosted java-tron fullnode HTTP API",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "http://127.0.0.1:8090/wallet/getnowblock", Body: "{}",
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "http://127.0.0.1:8090/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+	{
+		Name:        "tron-solidity",
+		Description: "java-tron solidity API: latest irreversible block (~19 behind the tip)",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "http://127.0.0.1:8091/walletsolidity/getnowblock", Body: "{}",
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "http://127.0.0.1:8091/walletsolidity/getblockbynum", ByNumBody: `{"num":{height}}`,
+			Confirmed: true,
+		},
+	},
+	{
+		Name:        "ankr-evm-rpc",
+		Description: "Ankr TRON JSON-RPC (eth_getBlockByNumber latest)",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://rpc.ankr.com/tron_jsonrpc/{apiKey}",
+			Body:       `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]}`,
+			HeightPath: "result.number", HashPath: "result.hash", TimePath: "result.timestamp", TxPath: "result.transactions",
+			ByNumURL:  "https://rpc.ankr.com/tron_jsonrpc/{apiKey}",
+			ByNumBody: `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["{heightHex}",false]}`,
+		},
+	},
+	{
+		Name:        "ankr-rest",
+		Description: "Ankr TRON HTTP API /wallet/getnowblock",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://rpc.ankr.com/http/tron/{apiKey}/wallet/getnowblock", Body: "{}",
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "https://rpc.ankr.com/http/tron/{apiKey}/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+	{
+		Name:        "getblock",
+		Description: "GetBlock TRON /wallet/getnowblock",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://go.getblock.io/{apiKey}/wallet/getnowblock", Body: "{}",
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "https://go.getblock.io/{apiKey}/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+	{
+		Name:        "nownodes",
+		Description: "NOWNodes TRON /wallet/getnowblock",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://trx.nownodes.io/wallet/getnowblock", Body: "{}",
+			Headers:    map[string]string{"api-key": "{apiKey}"},
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "https://trx.nownodes.io/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+	{
+		Name:        "jsonrpc-ws-newheads",
+		Description: "JSON-RPC WebSocket eth_subscribe newHeads push (replace <endpoint>)",
+		SourceConfig: SourceConfig{
+			Kind: sourceKindPush, URL: "wss://<endpoint>/{apiKey}",
+			Body:       `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`,
+			HeightPath: "params.result.number", HashPath: "params.result.hash", TimePath: "params.result.timestamp",
+		},
+	},
+	{
+		Name:        "quicknode",
+		Description: "QuickNode TRON endpoint (replace <endpoint>)",
+		SourceConfig: SourceConfig{
+			Method: "POST", URL: "https://<endpoint>.tron-mainnet.quiknode.pro/{apiKey}/wallet/getnowblock", Body: "{}",
+			HeightPath: "block_header.raw_data.number", HashPath: "blockID", TimePath: "block_header.raw_data.timestamp", TxPath: "transactions",
+			ByNumURL: "https://<endpoint>.tron-mainnet.quiknode.pro/{apiKey}/wallet/getblockbynum", ByNumBody: `{"num":{height}}`,
+		},
+	},
+}
+
+func findPreset(name string) (SourcePreset, bool) {
+	for _, p := range sourcePresets {
+		if strings.EqualFold(p.Name, strings.TrimSpace(name)) {
+			return p, true
+		}
+	}
+	return SourcePreset{}, false
+}
+
+// applyPreset fills fields left empty in sc; explicit values win
+func applyPreset(sc SourceConfig, p SourcePreset) SourceConfig {
+	sc.Preset = p.Name
+	if sc.Name == "" {
+		sc.Name = p.Name
+	}
+	if sc.Kind == "" {
+		sc.Kind = p.Kind
+	}
+	if sc.Method == "" {
+		sc.Method = p.Method
+	}
+	if sc.URL == "" {
+		sc.URL = p.URL
+	}
+	if sc.Body == "" {
+		sc.Body = p.Body
+	}
+	if sc.Headers == nil && p.Headers != nil {
+		sc.Headers = make(map[string]string, len(p.Headers))
+		for k, v := range p.Headers {
+			sc.Headers[k] = v
+		}
+	}
+	if sc.HeightPath == "" {
+		sc.HeightPath = p.HeightPath
+	}
+	if sc.HashPath == "" {
+		sc.HashPath = p.HashPath
+	}
+	if sc.TimePath == "" {
+		sc.TimePath = p.TimePath
+	}
+	if sc.TxPath == "" {
+		sc.TxPath, sc.TxIDField = p.TxPath, p.TxIDField
+	}
+	if sc.ByNumURL == "" {
+		sc.ByNumMethod, sc.ByNumURL, sc.ByNumBody = p.ByNumMethod, p.ByNumURL, p.ByNumBody
+	}
+	sc.Confirmed = sc.Confirmed || p.Confirmed
+	return sc
+}
+
+func sanitizeSource(sc SourceConfig) (SourceConfig, error) {
+	sc.ID = strings.TrimSpace(sc.ID)
+	sc.Name = strings.TrimSpace(sc.Name)
+	sc.Method = strings.ToUpper(strings.TrimSpace(sc.Method))
+	sc.URL = strings.TrimSpace(sc.URL)
+	sc.APIKey = strings.TrimSpace(sc.APIKey)
+	for i := range sc.APIKeys {
+		sc.APIKeys[i] = strings.TrimSpace(sc.APIKeys[i])
+	}
+	sc.APIKeys = sanitizeKeyList(sc.APIKeys)
+	sc.KeyRotation = strings.ToLower(strings.TrimSpace(sc.KeyRotation))
+	if sc.KeyRotation == keyRotationRR {
+		sc.KeyRotation = ""
+	}
+	if sc.KeyRotation != "" && sc.KeyRotation != keyRotationFailov {
+		return sc, errors.New("keyRotation must be round-robin or failover")
+	}
+	sc.Kind = strings.ToLower(strings.TrimSpace(sc.Kind))
+	u, err := url.Parse(strings.ReplaceAll(sc.URL, "{apiKey}", "k"))
+	switch sc.Kind {
+	case "", "poll":
+		sc.Kind = ""
+		if sc.Method == "" {
+			sc.Method = "POST"
+		}
+		if sc.Method != "GET" && sc.Method != "POST" {
+			return sc, errors.New("method must be GET or POST")
+		}
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return sc, errors.New("url must be an http(s) URL")
+		}
+	case sourceKindPush:
+		sc.Method = ""
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			return sc, errors.New("push source url must be a ws(s) URL")
+		}
+		if strings.TrimSpace(sc.Body) == "" {
+			return sc, errors.New("push source needs a subscribe message in body")
+		}
+	default:
+		return sc, errors.New("kind must be poll or push")
+	}
+	if strings.Contains(sc.URL, "<") || strings.Contains(sc.ByNumURL, "<") {
+		return sc, errors.New("url still contains a <placeholder>")
+	}
+	if err := validateSourceTimeouts(sc); err != nil {
+		return sc, err
+	}
+	if err := validateSourceTLS(sc.TLS); err != nil {
+		return sc, err
+	}
+	if err := validateSourceDNS(sc.DNS); err != nil {
+		return sc, err
+	}
+	if err := validateEndpoints(&sc); err != nil {
+		return sc, err
+	}
+	sc.ByNumMethod = strings.ToUpper(strings.TrimSpace(sc.ByNumMethod))
+	sc.ByNumURL = strings.TrimSpace(sc.ByNumURL)
+	if sc.ByNumURL != "" {
+		bu, err := url.Parse(strings.NewReplacer("{apiKey}", "k", "{height}", "1", "{heightHex}", "0x1").Replace(sc.ByNumURL))
+		if err != nil || (bu.Scheme != "http" && bu.Scheme != "https") || bu.Host == "" {
+			return sc, errors.New("byNumUrl must be an http(s) URL")
+		}
+		if sc.ByNumMethod != "" && sc.ByNumMethod != "GET" && sc.ByNumMethod != "POST" {
+			return sc, errors.New("byNumMethod must be GET or POST")
+		}
+	}
+	if strings.TrimSpace(sc.HeightPath) == "" || strings.TrimSpace(sc.HashPath) == "" {
+		return sc, errors.New("heightPath and hashPath are required")
+	}
+	if err := validateRateProfiles(sc.BaseRPS, sc.RateProfiles); err != nil {
+		return sc, err
+	}
+	if err := validateLimiter(sc); err != nil {
+		return sc, err
+	}
+	if err := validateBudgets(sc); err != nil {
+		return sc, err
+	}
+	if err := validateMaintenance(sc.Maintenance); err != nil {
+		return sc, err
+	}
+	if err := validateEnrich(&sc); err != nil {
+		return sc, err
+	}
+	if err := validateSecretRefs(sc); err != nil {
+		return sc, err
+	}
+	if sc.HeightHint && (sc.Kind == sourceKindPush || sc.ByNumURL == "") {
+		return sc, errors.New("heightHint needs a poll source with byNumUrl")
+	}
+	if sc.Priority < 0 || sc.Priority > 9 {
+		return sc, errors.New("priority must be 0..9")
+	}
+	sc.PollMode = strings.ToLower(strings.TrimSpace(sc.PollMode))
+	if sc.PollMode == "uniform" {
+		sc.PollMode = ""
+	}
+	if sc.PollMode != "" && (sc.PollMode != pollModePhase || sc.Kind == sourceKindPush) {
+		return sc, errors.New("pollMode must be uniform or phase (poll sources only)")
+	}
+	if err := validateLabels(sc.Labels); err != nil {
+		return sc, err
+	}
+	if sc.Name == "" {
+		sc.Name = u.Host
+	}
+	return sc, nil
+}
+
+func enabledSources() []SourceConfig {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	var out []SourceConfig
+	for _, sc := range cfg.Sources {
+		if sc.Enabled {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+func pollSources(srcs []SourceConfig) []SourceConfig {
+	var out []SourceConfig
+	for _, sc := range srcs {
+		if !isPushSource(sc) {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+// sourceURL: node URL used to produce blocks for a chart/audit source name
+func sourceURL(name string) string {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	for _, sc := range cfg.Sources {
+		if sc.ID == name {
+			return sc.URL
+		}
+	}
+	return defaultNodeURL
+}
+
+func redactSource(sc SourceConfig) SourceConfig {
+	sc.APIKey = redactKey(sc.APIKey)
+	if len(sc.APIKeys) > 0 {
+		keys := make([]string, len(sc.APIKeys))
+		for i, k := range sc.APIKeys {
+			keys[i] = redactKey(k)
+		}
+		sc.APIKeys = keys
+	}
+	return sc
+}
+
+// unredactKeys restores keys echoed back redacted ("abcdef...") from the stored source
+func unredactKeys(sc, stored SourceConfig) SourceConfig {
+	known := sourceKeys(stored)
+	restore := func(k, samePos string) string {
+		if !strings.HasSuffix(k, "...") {
+			return k
+		}
+		if redactKey(samePos) == k { // same slot first: redacted prefixes can collide
+			return samePos
+		}
+		for _, full := range known {
+			if redactKey(full) == k {
+				return full
+			}
+		}
+		return k
+	}
+	sc.APIKey = restore(sc.APIKey, stored.APIKey)
+	for i, k := range sc.APIKeys {
+		same := ""
+		if i < len(stored.APIKeys) {
+			same = stored.APIKeys[i]
+		}
+		sc.APIKeys[i] = restore(k, same)
+	}
+	return sc
+}
+
+// ---------- fetching ----------
+
+// fetchSource: one request driven entirely by the config (method/url/body/headers + paths)
+func fetchSource(ctx context.Context, client *http.Client, sc SourceConfig) (height int64, hash string, timeISO string, err error) {
+	sc.APIKey = pickKey(sc.ID, sourceKeys(sc), sc.KeyRotation, time.Now())
+	doc, err := fetchSourceDoc(ctx, client, sc)
+	reportKey(sc.ID, sc.APIKey, err)
+	if err != nil {
+		return 0, "", "", err
+	}
+	if height, hash, timeISO, err = mapSourceResponse(sc, doc); err == nil {
+		txMetaRecord(sc, hash, doc)
+	}
+	return height, hash, timeISO, err
+}
+
+// probeSource is fetchSource for diagnostics: it sends what the poller would
+// send next (key, endpoint) but leaves live state alone. Key rotation, key
+// cooldowns, the endpoint failover record and the tx meta cache are not touched
+func probeSource(ctx context.Context, client *http.Client, sc SourceConfig) (height int64, hash string, timeISO string, err error) {
+	sc.APIKey = peekKey(sc.ID, sourceKeys(sc), sc.KeyRotation, time.Now())
+	doc, err := probeSourceDoc(ctx, client, sc)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return mapSourceResponse(sc, doc)
+}
+
+// fetchEndpointDoc: one request to sc.URL; transport failures come back as *connError
+func fetchEndpointDoc(ctx context.Context, client *http.Client, sc SourceConfig) (any, error) {
+	fill := strings.NewReplacer("{apiKey}", sc.APIKey)
+	var body io.Reader
+	reqBody := ""
+	if sc.Method == "POST" {
+		b, err := resolveSecrets(fill.Replace(sc.Body))
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		body = strings.NewReader(reqBody)
+	}
+	u, err := resolveSecrets(fill.Replace(sc.URL))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, sc.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if sc.Method == "POST" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range sc.Headers {
+		v, err = resolveSecrets(fill.Replace(v))
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp)
+	}
+
+	// a custom Accept-Encoding header turns off the transport's transparent gzip
+	var rd io.Reader = resp.Body
+	if !resp.Uncompressed && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		rd = gz
+	}
+	dec := json.NewDecoder(io.LimitReader(rd, 8<<20))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return batchInOrder(reqBody, doc), nil
+}
+
+// batchInOrder: JSON-RPC batch replies may arrive in any order; line them up with
+// the request ids so paths like "[1].result.hash" always hit the same call
+func batchInOrder(reqBody string, doc any) any {
+	replies, ok := doc.([]any)
+	if !ok || !strings.HasPrefix(strings.TrimSpace(reqBody), "[") {
+		return doc
+	}
+	dec := json.NewDecoder(strings.NewReader(reqBody))
+	dec.UseNumber()
+	var reqs []map[string]any
+	if dec.Decode(&reqs) != nil || len(reqs) != len(replies) {
+		return doc
+	}
+	idOf := func(m map[string]any) string {
+		b, _ := json.Marshal(m["id"])
+		return string(b)
+	}
+	byID := make(map[string]any, len(replies))
+	for _, r := range replies {
+		m, ok := r.(map[string]any)
+		if !ok {
+			return doc
+		}
+		byID[idOf(m)] = r
+	}
+	out := make([]any, len(reqs))
+	for i, q := range reqs {
+		r, ok := byID[idOf(q)]
+		if !ok {
+			return doc // missing or duplicate ids: leave as received
+		}
+		out[i] = r
+	}
+	return out
+}
+
+func mapSourceResponse(sc SourceConfig, doc any) (height int64, hash string, timeISO string, err error) {
+	hv, err := jsonPathLookup(doc, sc.HeightPath)
+	if err != nil {
+		return 0, "", "", err
+	}
+	if height, err = jsonInt(hv); err != nil {
+		return 0, "", "", fmt.Errorf("height: %w", err)
+	}
+	xv, err := jsonPathLookup(doc, sc.HashPath)
+	if err != nil {
+		return 0, "", "", err
+	}
+	s, ok := xv.(string)
+	if !ok {
+		return 0, "", "", errors.New("hash: not a string")
+	}
+	hash = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+
+	t := time.Now()
+	if sc.TimePath != "" {
+		if tv, err := jsonPathLookup(doc, sc.TimePath); err == nil {
+			if ts, err := jsonInt(tv); err == nil && ts > 0 {
+				if ts < 1e12 { // seconds (EVM RPC)
+					ts *= 1000
+				}
+				t = time.UnixMilli(ts)
+			}
+		}
+	}
+	return height, hash, t.UTC().Format(time.RFC3339Nano), nil
+}
+
+type sourceResult struct {
+	id      string
+	height  int64
+	hash    string
+	timeISO string
+	err     error
+}
+
+// fetchAny races all sources; the first new block wins, otherwise all errors are joined.
+// Lower-priority sources start one stagger later per level, or as soon as every
+// source already started has failed; once a source wins the rest are not sent.
+// A block at or below the current tip (same hash) is "no new block", not a winner:
+// the race goes on, and ends with errNoNewBlock if nobody has anything newer.
+// Losers are cancelled after a win (see raceRecordWin); the race and each request
+// are bounded by the tuning deadlines, and at most raceMaxInFlight requests run at once.
+func fetchAny(srcs []SourceConfig) (winner string, height int64, hash string, timeISO string, err error) {
+	levels := priorityLevels(srcs)
+	if len(levels) == 0 {
+		return "", 0, "", "", errors.New("no source due")
+	}
+	rtMu.Lock()
+	tip, tipHash := rt.LastHeight, rt.LastHash
+	rtMu.Unlock()
+	stagger := raceStagger()
+	budget := raceBudgetSettings()
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if budget.total > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), budget.total)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	won := false
+	defer func() {
+		if !won {
+			cancel()
+		}
+	}()
+	ch := make(chan sourceResult, len(srcs))
+	launched, pending := 0, 0 // pending: requests in flight
+	var queue []SourceConfig  // waiting for an in-flight slot (tuning.raceMaxInFlight)
+	var next <-chan time.Time
+	drain := func() {
+		for len(queue) > 0 && (budget.maxInFlight == 0 || pending < budget.maxInFlight) {
+			sc := queue[0]
+			queue = queue[1:]
+			pending++
+			go func(sc SourceConfig) {
+				rctx, done := ctx, context.CancelFunc(func() {})
+				if budget.soft > 0 {
+					rctx, done = context.WithTimeout(ctx, budget.soft)
+				}
+				defer done()
+				ch <- fetchSourceTimed(rctx, sc)
+			}(sc)
+		}
+	}
+	launch := func() {
+		queue = append(queue, levels[launched]...)
+		drain()
+		launched++
+		next = nil
+		if launched < len(levels) {
+			next = time.After(stagger)
+		}
+	}
+	launch()
+
+	var errs []error
+race:
+	for pending > 0 || launched < len(levels) {
+		if pending == 0 {
+			if noNewBlockIn(errs) {
+				break // a preferred source answered and has nothing newer: backups are not asked
+			}
+			launch() // everything sent so far failed: no point waiting out the stagger
+			continue
+		}
+		select {
+		case res := <-ch:
+			pending--
+			if res.err == nil && (res.height < tip || (res.height == tip && res.hash == tipHash)) {
+				res.err = errNoNewBlock
+			}
+			if res.err == nil {
+				won = true
+				raceRecordWin(res, ch, pending, cancel, budget.keepSecond)
+				return res.id, res.height, res.hash, res.timeISO, nil // still queued: never sent
+			}
+			drain()
+			errs = append(errs, fmt.Errorf("%s: %w", res.id, res.err))
+		case <-next:
+			launch()
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("race deadline %s exceeded", budget.total))
+			break race
+		}
+	}
+	raceRecordNoWinner()
+	if noNewBlockIn(errs) {
+		return "", 0, "", "", errNoNewBlock
+	}
+	return "", 0, "", "", errors.Join(errs...)
+}
+
+// fetchAll queries every source and waits for all of them (consensus mode);
+// err is set only when no source answered
+func fetchAll(srcs []SourceConfig) ([]sourceResult, error) {
+	ch := make(chan sourceResult, len(srcs))
+	for _, sc := range srcs {
+		go func(sc SourceConfig) {
+			ch <- fetchSourceTimed(context.Background(), sc)
+		}(sc)
+	}
+	var ok []sourceResult
+	var errs []error
+	for range srcs {
+		res := <-ch
+		if res.err == nil {
+			ok = append(ok, res)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", res.id, res.err))
+	}
+	if len(ok) == 0 {
+		if noNewBlockIn(errs) {
+			return nil, errNoNewBlock
+		}
+		return nil, errors.Join(errs...)
+	}
+	return ok, nil
+}
+
+// ---------- API ----------
+
+// GET /api/sources/presets
+func apiSourcePresets(w http.ResponseWriter, r *http.Request) {
+	mustJSON(w, 200, map[string]any{"presets": sourcePresets})
+}
+
+// GET    /api/sources            -> list (api keys redacted) + rps in force now + per-key usage + health score + circuit state + token buckets + backoff (+ warmHealth right after a restart)
+// POST   /api/sources            -> upsert by id; {"preset":"trongrid","apiKey":"..."} auto-fills the mapping
+// DELETE /api/sources?id=...
+func apiSources(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		now := time.Now()
+		cfgMu.RLock()
+		out := make([]SourceConfig, 0, len(cfg.Sources))
+		rates := make(map[string]float64, len(cfg.Sources))
+		for _, sc := range cfg.Sources {
+			out = append(out, redactSource(sc))
+			rates[sc.ID], _ = effectiveRate(sc, now)
+		}
+		cfgMu.RUnlock()
+		resp := map[string]any{"sources": out, "effectiveRps": rates, "keyUsage": keyUsageSnapshot(), "health": healthSnapshot(), "breaker": breakerSnapshot(), "limiter": limiterSnapshot(), "backoff": backoffSnapshot(), "rateOverrides": rateOverrideList(now), "quota": quotaSnapshot(out, now), "maintenance": maintenanceSnapshot(out, now), "phase": phaseSnapshot(), "endpoints": endpointSnapshot()}
+		if lg := warmSnapshot(); lg != nil {
+			resp["warmHealth"] = lg.Health // previous run, until the first live block
+		}
+		mustJSON(w, 200, resp)
+
+	case "POST":
+		var sc SourceConfig
+		if err := readJSON(r, &sc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sc.Preset != "" {
+			p, ok := findPreset(sc.Preset)
+			if !ok {
+				httpError(w, r, "unknown preset", http.StatusBadRequest)
+				return
+			}
+			sc = applyPreset(sc, p)
+		}
+		sc, err := sanitizeSource(sc)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cfgMu.Lock()
+		before := append([]SourceConfig(nil), cfg.Sources...)
+		idx := -1
+		for i, cur := range cfg.Sources {
+			if sc.ID != "" && cur.ID == sc.ID {
+				idx = i
+			}
+		}
+		if idx >= 0 {
+			sc = unredactKeys(sc, cfg.Sources[idx]) // redacted values echoed back
+			cfg.Sources[idx] = sc
+		} else {
+			if sc.ID == "" {
+				id, _ := randHex(4)
+				sc.ID = "src-" + id
+			}
+			cfg.Sources = append(cfg.Sources, sc)
+		}
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+
+		logger.Printf("SOURCE_UPSERT id=%s name=%q preset=%s enabled=%v rid=%s", sc.ID, sc.Name, sc.Preset, sc.Enabled, requestID(r))
+		sourcesChanged(before, requestID(r))
+		tryStartListener()
+		mustJSON(w, 200, map[string]any{"ok": true, "source": redactSource(sc)})
+
+	case "DELETE":
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		cfgMu.Lock()
+		before := append([]SourceConfig(nil), cfg.Sources...)
+		kept := cfg.Sources[:0]
+		for _, sc := range cfg.Sources {
+			if sc.ID != id {
+				kept = append(kept, sc)
+			}
+		}
+		removed := len(cfg.Sources) - len(kept)
+		cfg.Sources = kept
+		if removed == 0 {
+			cfgMu.Unlock()
+			httpError(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		logger.Printf("SOURCE_DELETE id=%s rid=%s", id, requestID(r))
+		sourcesChanged(before, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true})
+
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /api/sources/test -> same body as POST /api/sources; fetches once without saving.
+// On a mapping error the (truncated) response is returned so the paths can be fixed.
+func apiSourceTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var sc SourceConfig
+	if err := readJSON(r, &sc); err != nil {
+		httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sc.Preset != "" {
+		p, ok := findPreset(sc.Preset)
+		if !ok {
+			httpError(w, r, "unknown preset", http.StatusBadRequest)
+			return
+		}
+		sc = applyPreset(sc, p)
+	}
+	sc, err := sanitizeSource(sc)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isPushSource(sc) {
+		httpError(w, r, "push sources cannot be tested with a single request", http.StatusBadRequest)
+		return
+	}
+	cfgMu.RLock()
+	for _, cur := range cfg.Sources {
+		if cur.ID == sc.ID {
+			sc = unredactKeys(sc, cur)
+		}
+	}
+	cfgMu.RUnlock()
+
+	start := time.Now()
+	doc, err := probeSourceDoc(r.Context(), sourceClient(sc), sc) // a draft may reuse a live id: leave its failover state alone
+	ms := time.Since(start).Milliseconds()
+	if err != nil {
+		httpError(w, r, "fetch: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	height, hash, tISO, err := mapSourceResponse(sc, doc)
+	if err != nil {
+		sample, _ := json.Marshal(doc)
+		if len(sample) > 2048 {
+			sample = append(sample[:2048], "..."...)
+		}
+		mustJSON(w, 422, map[string]any{"ok": false, "error": err.Error(), "latencyMs": ms, "response": string(sample)})
+		return
+	}
+	state, ok := blockStateByHash(hash)
+	if !ok {
+		state = ""
+	}
+	resp := map[string]any{"ok": true, "height": height, "hash": hash, "time": tISO, "state": state, "latencyMs": ms}
+	if sc.CaptureTxs {
+		resp["tx"] = sourceTxMeta(sc, doc)
+	}
+	if sc.Enrich != nil {
+		key := peekKey(sc.ID, sourceKeys(sc), sc.KeyRotation, time.Now())
+		if fields, err := enrichFetch(sc, key, height, hash); err != nil {
+			resp["enrichError"] = err.Error()
+		} else {
+			resp["fields"] = fields
+		}
+	}
+	mustJSON(w, 200, resp)
+}
+
+// SourceCompare is one row of GET /api/admin/sources/compare
+type SourceCompare struct {
+	ID        string `json:"id"`
+	Height    int64  `json:"height,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	Time      string `json:"time,omitempty"`
+	LatencyMS int64  `json:"latencyMs"`
+	Behind    int64  `json:"behind"`           // blocks below the highest reported height
+	Forked    bool   `json:"forked,omitempty"` // another source reports a different hash at the same height
+	Error     string `json:"error,omitempty"`
+}
+
+// GET /api/admin/sources/compare
+// one fresh request to every enabled poll source, side by side; health / breaker / backoff are not touched
+// ?async=1 runs it as a background job (jobs.go)
+func apiSourceCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	srcs := pollSources(enabledSources())
+	if len(srcs) == 0 {
+		httpError(w, r, "no enabled poll sources", http.StatusBadRequest)
+		return
+	}
+	if asyncRequested(r) {
+		acceptJob(w, startJob("compare", requestID(r), func(ctx context.Context, j *jobHandle) (any, error) {
+			return compareSources(srcs, func(done, total int) { j.progress(done, total, "sources answered") }), nil
+		}))
+		return
+	}
+	mustJSON(w, 200, compareSources(srcs, nil))
+}
+
+// compareSources probes every source once in parallel (probeSource: no key
+// cooldowns or other live-state side effects); progress (may be nil) as each answers
+func compareSources(srcs []SourceConfig, progress func(done, total int)) map[string]any {
+	rows := make([]SourceCompare, len(srcs))
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	done := 0
+	for i, sc := range srcs {
+		wg.Add(1)
+		go func(i int, sc SourceConfig) {
+			defer wg.Done()
+			if progress != nil {
+				defer func() {
+					doneMu.Lock()
+					done++
+					progress(done, len(srcs))
+					doneMu.Unlock()
+				}()
+			}
+			start := time.Now()
+			height, hash, tISO, err := probeSource(context.Background(), sourceClient(sc), sc)
+			row := SourceCompare{ID: sc.ID, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				row.Error = err.Error()
+			} else {
+				row.Height, row.Hash, row.Time = height, hash, tISO
+			}
+			rows[i] = row
+		}(i, sc)
+	}
+	wg.Wait()
+
+	var top int64
+	hashes := map[int64]map[string]bool{}
+	for _, row := range rows {
+		if row.Error != "" {
+			continue
+		}
+		top = max(top, row.Height)
+		if hashes[row.Height] == nil {
+			hashes[row.Height] = map[string]bool{}
+		}
+		hashes[row.Height][row.Hash] = true
+	}
+	for i := range rows {
+		if rows[i].Error != "" {
+			continue
+		}
+		rows[i].Behind = top - rows[i].Height
+		rows[i].Forked = len(hashes[rows[i].Height]) > 1
+	}
+	return map[string]any{"topHeight": top, "sources": rows}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNode answers every request with block height/hash (status != 0: that error status)
+type fakeNode struct {
+	height int64
+	hash   string
+	status int
+	delay  time.Duration
+}
+
+func (f fakeNode) source(t *testing.T, id string, priority int) SourceConfig {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(f.delay):
+		case <-r.Context().Done():
+			return
+		}
+		if f.status != 0 {
+			http.Error(w, "down", f.status)
+			return
+		}
+		fmt.Fprintf(w, `{"n":%d,"h":%q}`, f.height, f.hash)
+	}))
+	t.Cleanup(srv.Close)
+	return SourceConfig{ID: id, Enabled: true, Method: "POST", URL: srv.URL, HeightPath: "n", HashPath: "h", Priority: priority}
+}
+
+func setTip(t *testing.T, height int64, hash string) {
+	t.Helper()
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	rtMu.Lock()
+	saved := rt
+	rt.LastHeight, rt.LastHash = height, hash
+	rtMu.Unlock()
+	t.Cleanup(func() {
+		rtMu.Lock()
+		rt = saved
+		rtMu.Unlock()
+	})
+}
+
+func TestFetchAnyTip(t *testing.T) {
+	type node struct {
+		id       string
+		priority int
+		fakeNode
+	}
+	tests := []struct {
+		name       string
+		nodes      []node
+		wantWinner string
+		wantHeight int64
+		wantErr    error // nil: a winner is expected
+	}{
+		{
+			name:       "new block",
+			nodes:      []node{{"a", 0, fakeNode{height: 101, hash: "bb"}}},
+			wantWinner: "a", wantHeight: 101,
+		},
+		{
+			name:    "same block as the tip",
+			nodes:   []node{{"a", 0, fakeNode{height: 100, hash: "aa"}}},
+			wantErr: errNoNewBlock,
+		},
+		{
+			name:    "behind the tip",
+			nodes:   []node{{"a", 0, fakeNode{height: 99, hash: "99"}}},
+			wantErr: errNoNewBlock,
+		},
+		{
+			name:       "tip height with another hash",
+			nodes:      []node{{"a", 0, fakeNode{height: 100, hash: "a2"}}},
+			wantWinner: "a", wantHeight: 100,
+		},
+		{
+			name: "stale answer does not end the race",
+			nodes: []node{
+				{"stale", 0, fakeNode{height: 99, hash: "99"}},
+				{"fresh", 0, fakeNode{height: 101, hash: "bb", delay: 30 * time.Millisecond}},
+			},
+			wantWinner: "fresh", wantHeight: 101,
+		},
+		{
+			name: "stale preferred source: backups are not asked",
+			nodes: []node{
+				{"primary", 0, fakeNode{height: 100, hash: "aa"}},
+				{"backup", 1, fakeNode{height: 101, hash: "bb"}},
+			},
+			wantErr: errNoNewBlock,
+		},
+		{
+			name: "failed preferred source: backup wins",
+			nodes: []node{
+				{"primary", 0, fakeNode{status: http.StatusBadGateway}},
+				{"backup", 1, fakeNode{height: 101, hash: "bb"}},
+			},
+			wantWinner: "backup", wantHeight: 101,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTip(t, 100, "aa")
+			var srcs []SourceConfig
+			for _, n := range tt.nodes {
+				srcs = append(srcs, n.source(t, tt.name+"/"+n.id, n.priority))
+			}
+			winner, height, _, _, err := fetchAny(srcs)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v (winner %q)", err, tt.wantErr, winner)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if winner != tt.name+"/"+tt.wantWinner || height != tt.wantHeight {
+				t.Errorf("winner %s at %d, want %s at %d", winner, height, tt.wantWinner, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestFetchAnyTie(t *testing.T) {
+	setTip(t, 100, "aa")
+	before := 0
+	if s := raceSummary(); s != nil {
+		before = s.Ties
+	}
+	srcs := []SourceConfig{
+		fakeNode{height: 101, hash: "bb"}.source(t, "tie/a", 0),
+		fakeNode{height: 101, hash: "bb"}.source(t, "tie/b", 0),
+	}
+	winner, height, hash, _, err := fetchAny(srcs)
+	if err != nil || height != 101 || hash != "bb" {
+		t.Fatalf("got %s %d/%s, %v", winner, height, hash, err)
+	}
+	// the runner-up arrives inside raceTieWindow and is counted, not treated as a second block
+	deadline := time.Now().Add(time.Second)
+	for {
+		if s := raceSummary(); s != nil && s.Ties > before {
+			if s.LastWinner != winner || !s.LastTie {
+				t.Errorf("last winner %q tie=%v, want %q tie=true", s.LastWinner, s.LastTie, winner)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tie not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func setTuning(t *testing.T, tc TuningConfig) {
+	t.Helper()
+	cfgMu.Lock()
+	saved := cfg.Tuning
+	cfg.Tuning = tc
+	cfgMu.Unlock()
+	t.Cleanup(func() {
+		cfgMu.Lock()
+		cfg.Tuning = saved
+		cfgMu.Unlock()
+	})
+}
+
+func TestFetchAnyDeadlines(t *testing.T) {
+	slow := fakeNode{height: 101, hash: "bb", delay: 2 * time.Second}
+	fresh := fakeNode{height: 101, hash: "bb"}
+	tests := []struct {
+		name       string
+		tuning     TuningConfig
+		nodes      func(t *testing.T) []SourceConfig
+		wantWinner string
+		wantErr    string
+	}{
+		{
+			name:   "race deadline ends a slow race",
+			tuning: TuningConfig{RaceDeadlineMS: 100},
+			nodes: func(t *testing.T) []SourceConfig {
+				return []SourceConfig{slow.source(t, "deadline/slow", 0)}
+			},
+			wantErr: "race deadline",
+		},
+		{
+			name:   "soft deadline hands over to the backup",
+			tuning: TuningConfig{RaceSoftDeadlineMS: 100, RaceStaggerMS: 5000},
+			nodes: func(t *testing.T) []SourceConfig {
+				return []SourceConfig{slow.source(t, "soft/slow", 0), fresh.source(t, "soft/backup", 1)}
+			},
+			wantWinner: "soft/backup",
+		},
+		{
+			name:   "no deadline: the slow source is awaited",
+			tuning: TuningConfig{},
+			nodes: func(t *testing.T) []SourceConfig {
+				return []SourceConfig{fakeNode{height: 101, hash: "bb", delay: 150 * time.Millisecond}.source(t, "none/slow", 0)}
+			},
+			wantWinner: "none/slow",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTip(t, 100, "aa")
+			setTuning(t, tt.tuning)
+			start := time.Now()
+			winner, _, _, _, err := fetchAny(tt.nodes(t))
+			if took := time.Since(start); took > time.Second {
+				t.Errorf("race took %s", took)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || winner != tt.wantWinner {
+				t.Fatalf("winner %q, %v; want %q", winner, err, tt.wantWinner)
+			}
+		})
+	}
+}
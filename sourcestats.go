@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	每源请求统计（GET /api/sources/stats）
+	- 每次抓取记录：请求数 / 错误数 / 限流（HTTP 429）次数 / 最近一次错误
+	- cancelled：竞速中被取消的请求（输给赢家或超出竞速期限），不计入请求数 / 错误数
+	- 成功请求的耗时进滚动窗口（latencyWindow 个样本），输出 p50/p90/p99/max
+	- wins：该源率先送达并被接受的区块数（与 /api/charts/sources 同源）
+	- races：最近一小时轮询竞速的胜者 / 平局统计（races.go）
+	- 进程内计数，重启清零
+*/
+
+type sourceStatsState struct {
+	latency     latencyRing
+	requests    uint64
+	errors      uint64
+	rateLimited uint64
+	cancelled   uint64
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// SourceStats is one row of GET /api/sources/stats
+type SourceStats struct {
+	ID          string       `json:"id"`
+	Requests    uint64       `json:"requests"`
+	Errors      uint64       `json:"errors"`
+	RateLimited uint64       `json:"rateLimited"`
+	Cancelled   uint64       `json:"cancelled"`   // race requests cut short, not counted above
+	SuccessRate float64      `json:"successRate"` // 0..1; 0 before the first request
+	Latency     LatencyStats `json:"latency"`     // successful fetches only, ms
+	Wins        uint64       `json:"wins"`
+	WinShare    float64      `json:"winShare"`
+	LastError   string       `json:"lastError,omitempty"`
+	LastErrorAt string       `json:"lastErrorAt,omitempty"`
+}
+
+var (
+	statsMu      sync.Mutex
+	sourceStates = map[string]*sourceStatsState{}
+)
+
+// sourceStatsRecord counts one fetch attempt of source id
+func sourceStatsRecord(id string, err error, took time.Duration) {
+	rollupRecordFetch(err, took)
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	st := sourceStates[id]
+	if st == nil {
+		st = &sourceStatsState{}
+		sourceStates[id] = st
+	}
+	st.requests++
+	if err == nil {
+		st.latency.add(float64(took.Microseconds()) / 1000)
+		return
+	}
+	st.errors++
+	var he *httpStatusError
+	if errors.As(err, &he) && he.Code == http.StatusTooManyRequests {
+		st.rateLimited++
+	}
+	st.lastError = err.Error()
+	st.lastErrorAt = time.Now()
+}
+
+// sourceStatsCancelled counts a race request cancelled before it answered
+func sourceStatsCancelled(id string) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	st := sourceStates[id]
+	if st == nil {
+		st = &sourceStatsState{}
+		sourceStates[id] = st
+	}
+	st.cancelled++
+}
+
+func sourceStatsSnapshot() []SourceStats {
+	chartMu.Lock()
+	wins := make(map[string]uint64, len(chartWins))
+	var total uint64
+	for id, c := range chartWins {
+		wins[id] = c
+		total += c
+	}
+	chartMu.Unlock()
+
+	statsMu.Lock()
+	out := make([]SourceStats, 0, len(sourceStates))
+	seen := map[string]bool{}
+	for id, st := range sourceStates {
+		s := SourceStats{
+			ID:          id,
+			Requests:    st.requests,
+			Errors:      st.errors,
+			RateLimited: st.rateLimited,
+			Cancelled:   st.cancelled,
+			Latency:     st.latency.stats(),
+			LastError:   st.lastError,
+		}
+		if st.requests > 0 {
+			s.SuccessRate = float64(st.requests-st.errors) / float64(st.requests)
+		}
+		if !st.lastErrorAt.IsZero() {
+			s.LastErrorAt = st.lastErrorAt.UTC().Format(time.RFC3339)
+		}
+		out = append(out, s)
+		seen[id] = true
+	}
+	statsMu.Unlock()
+
+	// push-only sources win blocks without ever being polled
+	for id := range wins {
+		if !seen[id] {
+			out = append(out, SourceStats{ID: id})
+		}
+	}
+	for i := range out {
+		out[i].Wins = wins[out[i].ID]
+		if total > 0 {
+			out[i].WinShare = float64(out[i].Wins) / float64(total)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Wins != out[j].Wins {
+			return out[i].Wins > out[j].Wins
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// GET /api/sources/stats
+func apiSourceStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	mustJSON(w, 200, map[string]any{"sources": sourceStatsSnapshot(), "races": raceSummary()})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+/*
+	推送流序号（丢包检测）
+	- 每个主题（signal / status / block / system）一个递增序号 seq，广播时分配
+	- 每条 SSE / WS 消息附带 seq、prev_seq、emitted_at：
+	  prev_seq = 本连接上一条“应收到”的同主题消息序号（已计入 every / maxRate 抽样），首条为 0
+	  客户端收到的 prev_seq ≠ 自己上次收到的 seq => 中间有消息丢失
+	  emitted_at = 服务端广播时刻（UTC，纳秒），客户端可据此计算投递延迟
+	- 一次 status 事件只分配一个 seq，SSE 与 WS 共用；新 SSE 连接的首帧快照沿用当前 seq，不再递增
+	- 旧版裸 JSON 客户端收到的负载保持原样，不追加这三个字段（只属于 topic 信封）
+*/
+
+type streamMeta struct {
+	Seq       uint64 `json:"seq"`
+	PrevSeq   uint64 `json:"prev_seq"`
+	EmittedAt string `json:"emitted_at"`
+}
+
+var (
+	streamSeqMu sync.Mutex
+	streamSeqs  = map[string]uint64{} // topic -> last assigned seq
+)
+
+// nextStreamSeq assigns the next seq of topic; PrevSeq is filled per connection
+func nextStreamSeq(topic string) streamMeta {
+	streamSeqMu.Lock()
+	streamSeqs[topic]++
+	seq := streamSeqs[topic]
+	streamSeqMu.Unlock()
+	return streamMeta{Seq: seq, EmittedAt: time.Now().UTC().Format(time.RFC3339Nano)}
+}
+
+// currentStreamSeq returns the last seq assigned on topic without advancing it
+// (used for snapshots that are not new events)
+func currentStreamSeq(topic string) streamMeta {
+	streamSeqMu.Lock()
+	seq := streamSeqs[topic]
+	streamSeqMu.Unlock()
+	return streamMeta{Seq: seq, EmittedAt: time.Now().UTC().Format(time.RFC3339Nano)}
+}
+
+// streamCursor: per connection, last seq offered on each topic
+type streamCursor map[string]uint64
+
+// advance records seq as offered to this connection and returns the previous one
+func (c streamCursor) advance(topic string, seq uint64) uint64 {
+	prev := c[topic]
+	c[topic] = seq
+	return prev
+}
+
+// withStreamMeta appends seq/prev_seq/emitted_at to a marshalled JSON object
+func withStreamMeta(obj []byte, m streamMeta) []byte {
+	obj = bytes.TrimRight(obj, " \n")
+	if len(obj) < 2 || obj[len(obj)-1] != '}' {
+		return obj
+	}
+	meta, _ := json.Marshal(m)
+	out := make([]byte, 0, len(obj)+len(meta))
+	out = append(out, obj[:len(obj)-1]...)
+	if len(obj) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, meta[1:]...)
+}
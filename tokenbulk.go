@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+	批量发放接入令牌（给一批交易程序一次性开通）
+	- JSON：{"names":["desk-a","desk-b"],"count":0,"server":""}
+	    names 每个名字生成一个令牌；只给 count 时生成 count 个，名字为 token-001 ...（跳过已用编号）
+	- CSV（Content-Type: text/csv）：每行 name[,token]，首行为 name 表头时跳过；
+	  第二列留空则生成令牌，填写则导入已有令牌（16~128 位字母数字 / - / _）
+	- 响应为 CSV 附件：name,token,link（link 为扫码接入同款深链）
+	- 一次最多 tokenBulkMax 个；名字 1~64 字符、不可重复（含已有令牌的名字）；全部校验通过才写入
+*/
+
+const (
+	tokenBulkMax     = 500
+	tokenNameMax     = 64
+	tokenImportMin   = 16
+	tokenImportMax   = 128
+	tokenBulkCSVType = "text/csv"
+)
+
+type bulkTokenRow struct {
+	Name  string // empty = next free token-NNN
+	Token string // empty = generate
+}
+
+func validTokenName(name string) error {
+	if name == "" || len(name) > tokenNameMax {
+		return fmt.Errorf("name must be 1..%d chars", tokenNameMax)
+	}
+	if strings.ContainsAny(name, "\r\n\t") {
+		return errors.New("name: control characters not allowed")
+	}
+	return nil
+}
+
+func validImportToken(tok string) error {
+	if len(tok) < tokenImportMin || len(tok) > tokenImportMax {
+		return fmt.Errorf("token must be %d..%d chars", tokenImportMin, tokenImportMax)
+	}
+	for _, c := range tok {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return errors.New("token: only letters, digits, - and _")
+		}
+	}
+	return nil
+}
+
+// parseBulkCSV reads name[,token] rows; a leading "name" header is skipped
+func parseBulkCSV(body io.Reader) ([]bulkTokenRow, error) {
+	cr := csv.NewReader(io.LimitReader(body, 1<<20))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var rows []bulkTokenRow
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) > 2 {
+			return nil, fmt.Errorf("line %d: expected name[,token]", line)
+		}
+		row := bulkTokenRow{Name: strings.TrimSpace(rec[0])}
+		if len(rec) == 2 {
+			row.Token = strings.TrimSpace(rec[1])
+		}
+		if line == 1 && strings.EqualFold(row.Name, "name") {
+			continue
+		}
+		if row.Name == "" && row.Token == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// POST /api/admin/tokens/bulk (JSON or text/csv, see above) -> text/csv name,token,link
+func apiBulkTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var rows []bulkTokenRow
+	var server string
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == tokenBulkCSVType {
+		defer r.Body.Close()
+		var err error
+		if rows, err = parseBulkCSV(r.Body); err != nil {
+			httpError(w, r, "bad csv: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		server = r.URL.Query().Get("server")
+	} else {
+		var req struct {
+			Names  []string `json:"names"`
+			Count  int      `json:"count"`
+			Server string   `json:"server"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Names) > 0 && req.Count > 0 {
+			httpError(w, r, "give either names or count", http.StatusBadRequest)
+			return
+		}
+		if req.Count < 0 || req.Count > tokenBulkMax {
+			httpError(w, r, fmt.Sprintf("count must be 0..%d", tokenBulkMax), http.StatusBadRequest)
+			return
+		}
+		for _, n := range req.Names {
+			rows = append(rows, bulkTokenRow{Name: strings.TrimSpace(n)})
+		}
+		for i := 0; i < req.Count; i++ {
+			rows = append(rows, bulkTokenRow{})
+		}
+		server = req.Server
+	}
+	if len(rows) == 0 {
+		httpError(w, r, "no tokens requested", http.StatusBadRequest)
+		return
+	}
+	if len(rows) > tokenBulkMax {
+		httpError(w, r, fmt.Sprintf("at most %d tokens per call", tokenBulkMax), http.StatusBadRequest)
+		return
+	}
+	server = strings.TrimRight(strings.TrimSpace(server), "/")
+	if server == "" {
+		server = requestBaseURL(r)
+	}
+
+	cfgMu.RLock()
+	usedNames := make(map[string]bool, len(cfg.Access.TokenNames))
+	for _, n := range cfg.Access.TokenNames {
+		usedNames[n] = true
+	}
+	usedToks := make(map[string]bool, len(cfg.Access.Tokens))
+	for t := range cfg.Access.Tokens {
+		usedToks[t] = true
+	}
+	cfgMu.RUnlock()
+
+	imported, seq := 0, 0
+	for i := range rows {
+		row := &rows[i]
+		for row.Name == "" {
+			seq++
+			if n := fmt.Sprintf("token-%03d", seq); !usedNames[n] {
+				row.Name = n
+			}
+		}
+		if err := validTokenName(row.Name); err != nil {
+			httpError(w, r, fmt.Sprintf("row %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		if usedNames[row.Name] {
+			httpError(w, r, fmt.Sprintf("row %d: name %q already in use", i+1, row.Name), http.StatusConflict)
+			return
+		}
+		usedNames[row.Name] = true
+		if row.Token != "" {
+			if err := validImportToken(row.Token); err != nil {
+				httpError(w, r, fmt.Sprintf("row %d: %v", i+1, err), http.StatusBadRequest)
+				return
+			}
+			imported++
+		} else {
+			tok, err := randHex(16)
+			if err != nil {
+				httpError(w, r, "rand failed", http.StatusInternalServerError)
+				return
+			}
+			row.Token = tok
+		}
+		if usedToks[row.Token] {
+			httpError(w, r, fmt.Sprintf("row %d: token already exists", i+1), http.StatusConflict)
+			return
+		}
+		usedToks[row.Token] = true
+	}
+
+	cfgMu.Lock()
+	for _, row := range rows {
+		if _, dup := cfg.Access.Tokens[row.Token]; dup {
+			cfgMu.Unlock()
+			httpError(w, r, "token set changed concurrently, retry", http.StatusConflict)
+			return
+		}
+	}
+	if cfg.Access.TokenNames == nil {
+		cfg.Access.TokenNames = map[string]string{}
+	}
+	for _, row := range rows {
+		cfg.Access.Tokens[row.Token] = 0
+		cfg.Access.TokenNames[row.Token] = row.Name
+	}
+	if err := saveConfigLocked(cfg); err != nil {
+		for _, row := range rows {
+			delete(cfg.Access.Tokens, row.Token)
+			delete(cfg.Access.TokenNames, row.Token)
+		}
+		cfgMu.Unlock()
+		httpError(w, r, "save failed", http.StatusInternalServerError)
+		return
+	}
+	cfgMu.Unlock()
+
+	logger.Printf("TOKENS_BULK_PROVISIONED count=%d imported=%d rid=%s", len(rows), imported, requestID(r))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="tokens-%s.csv"`, time.Now().In(beijing).Format("20060102-150405")))
+	w.Header().Set("Cache-Control", "no-store")
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"name", "token", "link"})
+	for _, row := range rows {
+		_ = cw.Write([]string{row.Name, row.Token, provisionLink(server, row.Token)})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	外部令牌限速 + 软警告（tokenRate.requestsPerMinute，0 = 不限，默认关闭）
+	- 按令牌一分钟滑动窗口；IP 白名单放行的请求不计
+	- 每个令牌请求的响应都带：
+	    X-RateLimit-Limit：每分钟上限
+	    X-RateLimit-Remaining：本窗口剩余次数
+	    X-RateLimit-Reset：多少秒后释放下一个名额
+	- 用量达到 warnPercent（默认 80）时，该令牌的 WS 连接（订阅 system 主题的）收到一条
+	  {"type":"rate_limit_warning",...} 提示，每个窗口最多一次；这条是单发消息，不带 seq
+	- 超限返回 429 + Retry-After，写 TOKEN_RATE_LIMITED（每个窗口最多一条）
+*/
+
+const tokenRateWindow = time.Minute
+
+type TokenRateConfig struct {
+	RequestsPerMinute int `json:"requestsPerMinute"` // 0 = unlimited
+	WarnPercent       int `json:"warnPercent"`       // 0 = 80
+}
+
+func (tc TokenRateConfig) withDefaults() TokenRateConfig {
+	if tc.WarnPercent <= 0 || tc.WarnPercent > 100 {
+		tc.WarnPercent = 80
+	}
+	return tc
+}
+
+func tokenRateSettings() TokenRateConfig {
+	cfgMu.RLock()
+	tc := cfg.TokenRate
+	cfgMu.RUnlock()
+	return tc.withDefaults()
+}
+
+// RateLimitNotice is sent on the "system" topic to the clients of one token
+type RateLimitNotice struct {
+	Type         string `json:"type"` // "rate_limit_warning"
+	Code         string `json:"code"`
+	Limit        int    `json:"limit"`
+	Remaining    int    `json:"remaining"`
+	ResetSeconds int    `json:"resetSeconds"`
+	TimeISO      string `json:"time"`
+}
+
+type tokenWindow struct {
+	hits       []time.Time
+	warnedAt   time.Time
+	rejectedAt time.Time
+}
+
+var (
+	tokRLMu sync.Mutex
+	tokRL   = map[string]*tokenWindow{}
+)
+
+type tokenRateResult struct {
+	limit, remaining int
+	reset            time.Duration
+	allowed          bool
+	warn, logReject  bool // first warning / rejection in this window
+}
+
+// tokenRateAllow: sliding window per token (mirrors adminRateAllow)
+func tokenRateAllow(tok string, tc TokenRateConfig, now time.Time) tokenRateResult {
+	cutoff := now.Add(-tokenRateWindow)
+	tokRLMu.Lock()
+	defer tokRLMu.Unlock()
+	for t, w := range tokRL {
+		if len(w.hits) == 0 || !w.hits[len(w.hits)-1].After(cutoff) {
+			delete(tokRL, t) // idle for a full window
+		}
+	}
+	w := tokRL[tok]
+	if w == nil {
+		w = &tokenWindow{}
+		tokRL[tok] = w
+	}
+	kept := w.hits[:0]
+	for _, t := range w.hits {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	w.hits = kept
+
+	res := tokenRateResult{limit: tc.RequestsPerMinute}
+	if len(w.hits) >= tc.RequestsPerMinute {
+		res.reset = w.hits[0].Sub(cutoff)
+		res.logReject = w.rejectedAt.Before(cutoff)
+		if res.logReject {
+			w.rejectedAt = now
+		}
+		return res
+	}
+	w.hits = append(w.hits, now)
+	res.allowed = true
+	res.remaining = tc.RequestsPerMinute - len(w.hits)
+	res.reset = w.hits[0].Sub(cutoff)
+	if len(w.hits)*100 >= tc.RequestsPerMinute*tc.WarnPercent && w.warnedAt.Before(cutoff) {
+		w.warnedAt = now
+		res.warn = true
+	}
+	return res
+}
+
+// sendTokenNotice writes a one-off system message to the WS clients of tok (no stream seq)
+func sendTokenNotice(tok string, v any) int {
+	b, _ := json.Marshal(wsEnvelope{Topic: topicSystem, Data: v})
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	n := 0
+	for c := range wsClients {
+		if c.token != tok || c.dead.Load() || !c.envelope || !c.topics[topicSystem] {
+			continue
+		}
+		c.mu.Lock()
+		err := wsWriteText(c.c, b)
+		c.mu.Unlock()
+		if err != nil {
+			c.Close()
+			continue
+		}
+		c.sent.Add(uint64(len(b)))
+		n++
+	}
+	return n
+}
+
+// tokenRateCheck sets the X-RateLimit-* headers; false = 429 already written
+func tokenRateCheck(w http.ResponseWriter, r *http.Request, tok string) bool {
+	tc := tokenRateSettings()
+	if tc.RequestsPerMinute <= 0 {
+		return true
+	}
+	res := tokenRateAllow(tok, tc, time.Now())
+	resetSec := int(math.Ceil(res.reset.Seconds()))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(resetSec))
+	if !res.allowed {
+		if res.logReject {
+			logger.Printf("TOKEN_RATE_LIMITED token=%s... limit=%d remote=%s rid=%s", tokenPrefix(tok), res.limit, remoteIP(r), requestID(r))
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(resetSec))
+		httpError(w, r, "token rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	if res.warn {
+		n := sendTokenNotice(tok, RateLimitNotice{Type: "rate_limit_warning", Code: eventCode("TOKEN_RATE_WARNING"),
+			Limit: res.limit, Remaining: res.remaining, ResetSeconds: resetSec, TimeISO: time.Now().UTC().Format(time.RFC3339)})
+		logger.Printf("TOKEN_RATE_WARNING token=%s... limit=%d remaining=%d notified=%d rid=%s", tokenPrefix(tok), res.limit, res.remaining, n, requestID(r))
+	}
+	return true
+}
+
+func tokenPrefix(tok string) string {
+	if len(tok) > 6 {
+		return tok[:6]
+	}
+	return tok
+}
+
+// GET  /api/admin/tokens/ratelimit
+// POST /api/admin/tokens/ratelimit {"requestsPerMinute":120,"warnPercent":80}
+func apiTokenRate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, tokenRateSettings())
+	case "POST":
+		var tc TokenRateConfig
+		if err := readJSON(r, &tc); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tc.RequestsPerMinute < 0 || tc.RequestsPerMinute > 100000 {
+			httpError(w, r, "requestsPerMinute must be 0..100000", http.StatusBadRequest)
+			return
+		}
+		if tc.WarnPercent < 0 || tc.WarnPercent > 100 {
+			httpError(w, r, "warnPercent must be 0..100", http.StatusBadRequest)
+			return
+		}
+		cfgMu.Lock()
+		cfg.TokenRate = tc
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+		tc = tc.withDefaults()
+		logger.Printf("TOKEN_RATE_UPDATED requestsPerMinute=%d warnPercent=%d rid=%s", tc.RequestsPerMinute, tc.WarnPercent, requestID(r))
+		mustJSON(w, 200, map[string]any{"ok": true, "config": tc})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
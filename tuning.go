@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	运行时调参（无需重启，状态机不丢失）
+	- baseTickMs：监听循环的基础节拍（默认 1000ms，范围 tickMinMS..tickMaxMS）
+	- 每个源的轮询间隔 intervalMs（写入 baseRps = 1000/intervalMs；0 = 每个节拍）
+	- 多源调度策略 strategy：race | primary-fallback | round-robin（见 schedule.go）
+	- raceStaggerMs：race 模式下每个源优先级档位的延迟（默认 300ms，-1 = 同时发出）
+	- raceMaxInFlight：一场竞速同时在途的源请求上限（0 = 不限，最多 inFlightMaxN）
+	- 每次修改写日志 TUNING_UPDATED（含前后值与 rid），并保留最近 tuningHistoryMax 条供查询
+*/
+
+const (
+	tickMinMS        = 200
+	tickMaxMS        = 10000
+	staggerMaxMS     = 5000
+	deadlineMinMS    = 100
+	inFlightMaxN     = 64
+	deadlineMaxMS    = 30000
+	intervalMaxMS    = 3600000
+	tuningHistoryMax = 50
+)
+
+type TuningConfig struct {
+	BaseTickMS int    `json:"baseTickMs"`         // 0 = pollInterval
+	Strategy   string `json:"strategy,omitempty"` // "" = race
+
+	// race: delay per source priority level (schedule.go); 0 = raceStaggerDefault, -1 = no delay
+	RaceStaggerMS int `json:"raceStaggerMs,omitempty"`
+
+	// race deadlines (schedule.go); 0 = off, only the per-source HTTP timeouts apply
+	RaceDeadlineMS     int  `json:"raceDeadlineMs,omitempty"`     // whole race, staggered levels included
+	RaceSoftDeadlineMS int  `json:"raceSoftDeadlineMs,omitempty"` // one request inside a race
+	RaceKeepSecond     bool `json:"raceKeepSecond,omitempty"`     // losers finish after a win; the next answer is checked against the winner
+
+	// race: simultaneous source requests; the rest wait in priority order (schedule.go); 0 = no cap
+	RaceMaxInFlight int `json:"raceMaxInFlight,omitempty"`
+
+	// sticky strategy: smoothed fetch time that sends the next tick back to a race; 0 = 1500
+	StickyMaxMS int `json:"stickyMaxMs,omitempty"`
+}
+
+type TuningChange struct {
+	Field   string `json:"field"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Remote  string `json:"remote"`
+	RID     string `json:"rid"`
+	TimeISO string `json:"time"`
+}
+
+var (
+	tickResetC = make(chan time.Duration, 1) // listenerLoop picks up a new tick here
+
+	tuningMu      sync.Mutex
+	tuningHistory = []TuningChange{} // newest first
+)
+
+func (tc TuningConfig) tick() time.Duration {
+	if tc.BaseTickMS <= 0 {
+		return pollInterval
+	}
+	return time.Duration(clampInt(tc.BaseTickMS, tickMinMS, tickMaxMS)) * time.Millisecond
+}
+
+func (tc TuningConfig) raceStagger() time.Duration {
+	switch {
+	case tc.RaceStaggerMS < 0:
+		return 0
+	case tc.RaceStaggerMS == 0:
+		return raceStaggerDefault
+	}
+	return time.Duration(min(tc.RaceStaggerMS, staggerMaxMS)) * time.Millisecond
+}
+
+func deadlineMS(ms int) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(clampInt(ms, deadlineMinMS, deadlineMaxMS)) * time.Millisecond
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func baseTick() time.Duration {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.Tuning.tick()
+}
+
+// signalTick hands the new tick to the running loop (latest value wins)
+func signalTick(d time.Duration) {
+	select {
+	case <-tickResetC:
+	default:
+	}
+	tickResetC <- d
+}
+
+func intervalMS(rps float64) int {
+	if rps <= 0 {
+		return 0
+	}
+	return int(1000/rps + 0.5)
+}
+
+type SourceInterval struct {
+	ID                  string  `json:"id"`
+	BaseRPS             float64 `json:"baseRps"`
+	IntervalMS          int     `json:"intervalMs"`          // from baseRps; 0 = every tick
+	EffectiveIntervalMS int     `json:"effectiveIntervalMs"` // rate profile + key pool + adaptive ramp + tick applied
+}
+
+func tuningSnapshot() map[string]any {
+	now := time.Now()
+	buckets := limiterSnapshot()
+	cfgMu.RLock()
+	tick := cfg.Tuning.tick()
+	strategy := cfg.Tuning.strategy()
+	stagger := cfg.Tuning.raceStagger()
+	budget := cfg.Tuning.raceBudget()
+	sticky := cfg.Tuning.stickyMax()
+	srcs := make([]SourceInterval, 0, len(cfg.Sources))
+	for _, sc := range cfg.Sources {
+		rps, _ := effectiveRate(sc, now)
+		if b, ok := buckets[sc.ID]; ok {
+			rps = b.RPS // adaptive limiter may have ramped it
+		}
+		eff := intervalMS(rps)
+		if t := int(tick.Milliseconds()); eff < t {
+			eff = t
+		}
+		srcs = append(srcs, SourceInterval{ID: sc.ID, BaseRPS: sc.BaseRPS, IntervalMS: intervalMS(sc.BaseRPS), EffectiveIntervalMS: eff})
+	}
+	cfgMu.RUnlock()
+
+	tuningMu.Lock()
+	hist := append([]TuningChange{}, tuningHistory...)
+	tuningMu.Unlock()
+	return map[string]any{
+		"baseTickMs":         tick.Milliseconds(),
+		"strategy":           strategy,
+		"raceStaggerMs":      stagger.Milliseconds(),
+		"raceDeadlineMs":     budget.total.Milliseconds(),
+		"raceSoftDeadlineMs": budget.soft.Milliseconds(),
+		"raceKeepSecond":     budget.keepSecond,
+		"raceMaxInFlight":    budget.maxInFlight,
+		"stickyMaxMs":        sticky.Milliseconds(),
+		"stickySource":       stickyCurrent(),
+		"bounds":             map[string]int{"tickMinMs": tickMinMS, "tickMaxMs": tickMaxMS, "intervalMaxMs": intervalMaxMS, "raceStaggerMaxMs": staggerMaxMS, "raceDeadlineMinMs": deadlineMinMS, "raceDeadlineMaxMs": deadlineMaxMS, "raceMaxInFlightMax": inFlightMaxN},
+		"sources":            srcs,
+		"changes":            hist,
+	}
+}
+
+// GET  /api/admin/tuning
+// POST /api/admin/tuning {"baseTickMs":500,"strategy":"race","raceStaggerMs":300,"raceDeadlineMs":2000,"raceSoftDeadlineMs":800,"raceKeepSecond":true,"raceMaxInFlight":4,"sources":{"src-1":2000}}  (intervalMs; 0 = every tick)
+// validateTuning checks a whole TuningConfig (config reload) against the
+// bounds POST /api/admin/tuning enforces field by field
+func validateTuning(tc TuningConfig) error {
+	if tc.BaseTickMS != 0 && (tc.BaseTickMS < tickMinMS || tc.BaseTickMS > tickMaxMS) {
+		return fmt.Errorf("baseTickMs must be 0 or %d..%d", tickMinMS, tickMaxMS)
+	}
+	if !validStrategy(tc.Strategy) {
+		return errors.New("strategy must be race, primary-fallback, round-robin or sticky")
+	}
+	if tc.RaceStaggerMS < -1 || tc.RaceStaggerMS > staggerMaxMS {
+		return fmt.Errorf("raceStaggerMs must be -1..%d", staggerMaxMS)
+	}
+	if tc.RaceMaxInFlight < 0 || tc.RaceMaxInFlight > inFlightMaxN {
+		return fmt.Errorf("raceMaxInFlight must be 0..%d", inFlightMaxN)
+	}
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"raceDeadlineMs", tc.RaceDeadlineMS}, {"raceSoftDeadlineMs", tc.RaceSoftDeadlineMS}, {"stickyMaxMs", tc.StickyMaxMS}} {
+		if f.v != 0 && (f.v < deadlineMinMS || f.v > deadlineMaxMS) {
+			return fmt.Errorf("%s must be 0 or %d..%d", f.name, deadlineMinMS, deadlineMaxMS)
+		}
+	}
+	return nil
+}
+
+func apiTuning(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		mustJSON(w, 200, tuningSnapshot())
+	case "POST":
+		var in struct {
+			BaseTickMS *int           `json:"baseTickMs"`
+			Strategy   *string        `json:"strategy"`
+			Stagger    *int           `json:"raceStaggerMs"`
+			Deadline   *int           `json:"raceDeadlineMs"`
+			Soft       *int           `json:"raceSoftDeadlineMs"`
+			KeepSecond *bool          `json:"raceKeepSecond"`
+			MaxFlight  *int           `json:"raceMaxInFlight"`
+			StickyMax  *int           `json:"stickyMaxMs"`
+			Sources    map[string]int `json:"sources"`
+		}
+		if err := readJSON(r, &in); err != nil {
+			httpError(w, r, "bad json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.BaseTickMS != nil && (*in.BaseTickMS < tickMinMS || *in.BaseTickMS > tickMaxMS) {
+			httpError(w, r, fmt.Sprintf("baseTickMs must be %d..%d", tickMinMS, tickMaxMS), http.StatusBadRequest)
+			return
+		}
+		if in.Strategy != nil && !validStrategy(*in.Strategy) {
+			httpError(w, r, "strategy must be race, primary-fallback, round-robin or sticky", http.StatusBadRequest)
+			return
+		}
+		if in.Stagger != nil && (*in.Stagger < -1 || *in.Stagger > staggerMaxMS) {
+			httpError(w, r, fmt.Sprintf("raceStaggerMs must be -1..%d", staggerMaxMS), http.StatusBadRequest)
+			return
+		}
+		if in.MaxFlight != nil && (*in.MaxFlight < 0 || *in.MaxFlight > inFlightMaxN) {
+			httpError(w, r, fmt.Sprintf("raceMaxInFlight must be 0..%d", inFlightMaxN), http.StatusBadRequest)
+			return
+		}
+		for name, v := range map[string]*int{"raceDeadlineMs": in.Deadline, "raceSoftDeadlineMs": in.Soft, "stickyMaxMs": in.StickyMax} {
+			if v != nil && *v != 0 && (*v < deadlineMinMS || *v > deadlineMaxMS) {
+				httpError(w, r, fmt.Sprintf("%s must be 0 or %d..%d", name, deadlineMinMS, deadlineMaxMS), http.StatusBadRequest)
+				return
+			}
+		}
+		for id, ms := range in.Sources {
+			if ms < 0 || ms > intervalMaxMS {
+				httpError(w, r, fmt.Sprintf("sources.%s: intervalMs must be 0..%d", id, intervalMaxMS), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var changes []TuningChange
+		note := func(field string, before, after any) {
+			b, a := fmt.Sprint(before), fmt.Sprint(after)
+			if b != a {
+				changes = append(changes, TuningChange{Field: field, Before: b, After: a})
+			}
+		}
+
+		cfgMu.Lock()
+		oldTick := cfg.Tuning.tick()
+		if in.BaseTickMS != nil {
+			cfg.Tuning.BaseTickMS = *in.BaseTickMS
+			note("baseTickMs", oldTick.Milliseconds(), *in.BaseTickMS)
+		}
+		if in.Strategy != nil {
+			note("strategy", cfg.Tuning.strategy(), TuningConfig{Strategy: *in.Strategy}.strategy())
+			cfg.Tuning.Strategy = *in.Strategy
+		}
+		if in.Stagger != nil {
+			note("raceStaggerMs", cfg.Tuning.raceStagger().Milliseconds(), TuningConfig{RaceStaggerMS: *in.Stagger}.raceStagger().Milliseconds())
+			cfg.Tuning.RaceStaggerMS = *in.Stagger
+		}
+		if in.Deadline != nil {
+			note("raceDeadlineMs", deadlineMS(cfg.Tuning.RaceDeadlineMS).Milliseconds(), *in.Deadline)
+			cfg.Tuning.RaceDeadlineMS = *in.Deadline
+		}
+		if in.Soft != nil {
+			note("raceSoftDeadlineMs", deadlineMS(cfg.Tuning.RaceSoftDeadlineMS).Milliseconds(), *in.Soft)
+			cfg.Tuning.RaceSoftDeadlineMS = *in.Soft
+		}
+		if in.KeepSecond != nil {
+			note("raceKeepSecond", cfg.Tuning.RaceKeepSecond, *in.KeepSecond)
+			cfg.Tuning.RaceKeepSecond = *in.KeepSecond
+		}
+		if in.MaxFlight != nil {
+			note("raceMaxInFlight", cfg.Tuning.RaceMaxInFlight, *in.MaxFlight)
+			cfg.Tuning.RaceMaxInFlight = *in.MaxFlight
+		}
+		if in.StickyMax != nil {
+			note("stickyMaxMs", cfg.Tuning.stickyMax().Milliseconds(), TuningConfig{StickyMaxMS: *in.StickyMax}.stickyMax().Milliseconds())
+			cfg.Tuning.StickyMaxMS = *in.StickyMax
+		}
+		for id, ms := range in.Sources {
+			idx := -1
+			for i := range cfg.Sources {
+				if cfg.Sources[i].ID == id {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				cfgMu.Unlock()
+				httpError(w, r, "unknown source: "+id, http.StatusNotFound)
+				return
+			}
+			before := cfg.Sources[idx].BaseRPS
+			rps := 0.0
+			if ms > 0 {
+				rps = 1000 / float64(ms)
+			}
+			next := cfg.Sources[idx]
+			next.BaseRPS = rps
+			err := validateRateProfiles(rps, next.RateProfiles)
+			if err == nil {
+				err = validateLimiter(next)
+			}
+			if err != nil {
+				cfgMu.Unlock()
+				httpError(w, r, "sources."+id+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			cfg.Sources[idx].BaseRPS = rps
+			note("sources."+id+".intervalMs", intervalMS(before), ms)
+		}
+		newTick := cfg.Tuning.tick()
+		if err := saveConfigLocked(cfg); err != nil {
+			cfgMu.Unlock()
+			httpError(w, r, "save failed", http.StatusInternalServerError)
+			return
+		}
+		cfgMu.Unlock()
+
+		if newTick != oldTick {
+			signalTick(newTick)
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		tuningMu.Lock()
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+		for i := range changes {
+			c := &changes[i]
+			c.Remote, c.RID, c.TimeISO = remoteIP(r), requestID(r), now
+			logger.Printf("TUNING_UPDATED field=%s before=%s after=%s remote=%s rid=%s", c.Field, c.Before, c.After, c.Remote, c.RID)
+			tuningHistory = append([]TuningChange{*c}, tuningHistory...)
+		}
+		if len(tuningHistory) > tuningHistoryMax {
+			tuningHistory = tuningHistory[:tuningHistoryMax]
+		}
+		tuningMu.Unlock()
+
+		fields := make([]string, 0, len(changes))
+		for _, c := range changes {
+			fields = append(fields, c.Field)
+		}
+		mustJSON(w, 200, map[string]any{"ok": true, "changed": fields, "tuning": tuningSnapshot()})
+	default:
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestValidateTuning(t *testing.T) {
+	tests := []struct {
+		name string
+		tc   TuningConfig
+		ok   bool
+	}{
+		{"defaults", TuningConfig{}, true},
+		{"full", TuningConfig{BaseTickMS: 500, Strategy: strategySticky, RaceStaggerMS: -1, RaceDeadlineMS: 2000, RaceSoftDeadlineMS: 800, RaceMaxInFlight: 4, StickyMaxMS: 1500}, true},
+		{"tick too fast", TuningConfig{BaseTickMS: tickMinMS - 1}, false},
+		{"unknown strategy", TuningConfig{Strategy: "fastest"}, false},
+		{"stagger below -1", TuningConfig{RaceStaggerMS: -2}, false},
+		{"stagger too long", TuningConfig{RaceStaggerMS: staggerMaxMS + 1}, false},
+		{"in flight too many", TuningConfig{RaceMaxInFlight: inFlightMaxN + 1}, false},
+		{"deadline too short", TuningConfig{RaceDeadlineMS: deadlineMinMS - 1}, false},
+		{"soft deadline too long", TuningConfig{RaceSoftDeadlineMS: deadlineMaxMS + 1}, false},
+		{"sticky negative", TuningConfig{StickyMaxMS: -5}, false},
+	}
+	for _, tt := range tests {
+		if err := validateTuning(tt.tc); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestSanitizeSources(t *testing.T) {
+	src := func(id string) SourceConfig {
+		return SourceConfig{ID: id, Method: "POST", URL: "https://node.example/wallet/getnowblock", HeightPath: "n", HashPath: "h"}
+	}
+	bad := src("c")
+	bad.URL = "ftp://node.example/"
+	tests := []struct {
+		name string
+		in   []SourceConfig
+		ok   bool
+	}{
+		{"empty list", nil, true},
+		{"two sources", []SourceConfig{src("a"), src("b")}, true},
+		{"missing id", []SourceConfig{src("a"), src(" ")}, false},
+		{"duplicate id", []SourceConfig{src("a"), src("a")}, false},
+		{"invalid source", []SourceConfig{src("a"), bad}, false},
+	}
+	for _, tt := range tests {
+		if _, err := sanitizeSources(tt.in); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
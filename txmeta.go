@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+)
+
+/*
+	区块交易摘要（可选，按源开启 captureTxs）
+	- 从响应的 txPath 数组取交易数与首 / 尾交易 ID（对象取 txIdField，默认 txID，其次 hash；字符串数组直接当 ID）
+	- 源响应里没有该数组 => 交易数 0（TRON getnowblock 空块不返回 transactions）
+	- 按区块哈希暂存，判定后附在 block 事件的 tx 字段；TronGrid 默认源不采集
+*/
+
+const txMetaMax = 256
+
+type TxMeta struct {
+	Count   int    `json:"count"`
+	FirstID string `json:"firstId,omitempty"`
+	LastID  string `json:"lastId,omitempty"`
+}
+
+var (
+	txMu    sync.Mutex
+	txMeta  = map[string]TxMeta{}
+	txOrder []string // insertion order for eviction
+)
+
+func txIDOf(sc SourceConfig, v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case map[string]any:
+		fields := []string{"txID", "hash"}
+		if sc.TxIDField != "" {
+			fields = []string{sc.TxIDField}
+		}
+		for _, f := range fields {
+			if id, ok := x[f].(string); ok {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// sourceTxMeta summarizes the transaction array of one source response
+func sourceTxMeta(sc SourceConfig, doc any) TxMeta {
+	v, err := jsonPathLookup(doc, sc.TxPath)
+	if err != nil {
+		return TxMeta{}
+	}
+	txs, ok := v.([]any)
+	if !ok || len(txs) == 0 {
+		return TxMeta{}
+	}
+	return TxMeta{Count: len(txs), FirstID: txIDOf(sc, txs[0]), LastID: txIDOf(sc, txs[len(txs)-1])}
+}
+
+// txMetaRecord keeps the summary for hash until the block is judged
+func txMetaRecord(sc SourceConfig, hash string, doc any) {
+	if !sc.CaptureTxs || sc.TxPath == "" {
+		return
+	}
+	m := sourceTxMeta(sc, doc)
+	txMu.Lock()
+	defer txMu.Unlock()
+	if _, ok := txMeta[hash]; !ok {
+		txOrder = append(txOrder, hash)
+	}
+	txMeta[hash] = m
+	for len(txOrder) > txMetaMax {
+		delete(txMeta, txOrder[0])
+		txOrder = txOrder[1:]
+	}
+}
+
+// txMetaFor: nil when no source captured transactions for hash
+func txMetaFor(hash string) *TxMeta {
+	txMu.Lock()
+	defer txMu.Unlock()
+	m, ok := txMeta[hash]
+	if !ok {
+		return nil
+	}
+	return &m
+}
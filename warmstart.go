@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+	“最后已知良好”快照（热启动）
+	- 退出时写 data/last_good.json：最后接受的区块（高度 / 哈希 / 时间）+ 各源健康评分
+	- 启动后、第一个实时区块到来之前，/api/status 直接用快照填充 lastHeight 等字段并标记 stale + warmStart
+	  /api/sources 同时返回 warmHealth（上次运行的健康评分）
+	- 只影响展示：状态机与去重环仍按“每次启动完全重置”的规则从零开始
+*/
+
+var lastGoodPath = filepath.Join(dataDir, "last_good.json")
+
+type LastGood struct {
+	Height  int64                   `json:"height"`
+	Hash    string                  `json:"hash"`
+	TimeISO string                  `json:"time"` // block time
+	SavedAt string                  `json:"savedAt"`
+	Health  map[string]SourceHealth `json:"health,omitempty"`
+}
+
+var (
+	warmMu   sync.Mutex
+	warmSnap *LastGood // nil once a live block arrived
+)
+
+func loadLastGood() {
+	b, err := os.ReadFile(lastGoodPath)
+	if err != nil {
+		return
+	}
+	var lg LastGood
+	if err := json.Unmarshal(b, &lg); err != nil || lg.Height <= 0 {
+		return
+	}
+	warmMu.Lock()
+	warmSnap = &lg
+	warmMu.Unlock()
+	logger.Printf("WARM_START height=%d savedAt=%s", lg.Height, lg.SavedAt)
+}
+
+// saveLastGood runs on shutdown; nothing is written before the first live block
+func saveLastGood() {
+	rtMu.Lock()
+	lg := LastGood{Height: rt.LastHeight, Hash: rt.LastHash, TimeISO: isoOrEmpty(rt.LastTime)}
+	rtMu.Unlock()
+	if lg.Height <= 0 {
+		return // keep the previous snapshot
+	}
+	lg.SavedAt = time.Now().UTC().Format(time.RFC3339)
+	lg.Health = healthSnapshot()
+
+	b, _ := json.MarshalIndent(lg, "", "  ")
+	tmp := lastGoodPath + ".tmp"
+	err := os.WriteFile(tmp, b, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, lastGoodPath)
+	}
+	if err != nil {
+		logger.Printf("LAST_GOOD_SAVE_ERROR: %v", err)
+	}
+}
+
+// warmSnapshot: the boot snapshot while no live block has been accepted yet
+func warmSnapshot() *LastGood {
+	warmMu.Lock()
+	defer warmMu.Unlock()
+	return warmSnap
+}
+
+// warmDone drops the snapshot on the first live block
+func warmDone() {
+	warmMu.Lock()
+	warmSnap = nil
+	warmMu.Unlock()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	资源看门狗
+	- 周期检查 data/ 与 logs/ 所在磁盘剩余空间、进程 RSS
+	- 越过阈值：记录 MAJOR 告警、紧急压缩日志（只留当天），可选暂停持久化写入
+	- 恢复后自动解除（边沿触发，不会每个周期重复告警）
+*/
+
+type WatchdogConfig struct {
+	MinFreeMB        int  `json:"minFreeMB"`        // default 200
+	MaxRSSMB         int  `json:"maxRssMB"`         // 0 = RSS check off
+	IntervalSeconds  int  `json:"intervalSeconds"`  // default 30
+	PausePersistence bool `json:"pausePersistence"` // skip non-critical writes while disk is low
+}
+
+type WatchdogReading struct {
+	Dir    string `json:"dir"`
+	FreeMB int64  `json:"freeMB"` // -1 = unsupported on this platform
+	Low    bool   `json:"low"`
+}
+
+type WatchdogStatus struct {
+	CheckedAt         string            `json:"checkedAt"`
+	Disks             []WatchdogReading `json:"disks"`
+	RSSMB             int64             `json:"rssMB"`
+	RSSHigh           bool              `json:"rssHigh"`
+	PersistencePaused bool              `json:"persistencePaused"`
+}
+
+var (
+	wdMu     sync.Mutex
+	wdStatus WatchdogStatus
+
+	// persistPaused: checked by non-critical writers (daily counters)
+	persistPaused atomic.Bool
+)
+
+func watchdogSettings() WatchdogConfig {
+	cfgMu.RLock()
+	wc := cfg.Watchdog
+	cfgMu.RUnlock()
+	return wc.withDefaults()
+}
+
+func (wc WatchdogConfig) withDefaults() WatchdogConfig {
+	if wc.MinFreeMB <= 0 {
+		wc.MinFreeMB = 200
+	}
+	if wc.IntervalSeconds <= 0 {
+		wc.IntervalSeconds = 30
+	}
+	return wc
+}
+
+func watchdogLoop() {
+	for {
+		wc := watchdogSettings()
+		watchdogCheck(wc)
+		time.Sleep(time.Duration(wc.IntervalSeconds) * time.Second)
+	}
+}
+
+func watchdogCheck(wc WatchdogConfig) {
+	st := WatchdogStatus{CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	diskLow := false
+	for _, dir := range []string{dataDir, logDir} {
+		rd := WatchdogReading{Dir: dir, FreeMB: -1}
+		if free, err := diskFreeBytes(dir); err == nil {
+			rd.FreeMB = int64(free >> 20)
+			rd.Low = rd.FreeMB < int64(wc.MinFreeMB)
+		}
+		diskLow = diskLow || rd.Low
+		st.Disks = append(st.Disks, rd)
+	}
+	st.RSSMB = int64(processRSSBytes() >> 20)
+	st.RSSHigh = wc.MaxRSSMB > 0 && st.RSSMB > int64(wc.MaxRSSMB)
+
+	wdMu.Lock()
+	prev := wdStatus
+	wasLow := false
+	for _, d := range prev.Disks {
+		wasLow = wasLow || d.Low
+	}
+	wdMu.Unlock()
+
+	// edge-triggered alerts
+	if diskLow && !wasLow {
+		for _, d := range st.Disks {
+			if d.Low {
+				logger.Printf("MAJOR WATCHDOG_DISK_LOW dir=%s freeMB=%d minFreeMB=%d", d.Dir, d.FreeMB, wc.MinFreeMB)
+			}
+		}
+		n, freed := pruneLogs(0) // emergency: keep only the active log file
+		logger.Printf("WATCHDOG_EMERGENCY_COMPACT logs=%d bytes=%d", n, freed)
+	} else if !diskLow && wasLow {
+		logger.Println("WATCHDOG_DISK_RECOVERED")
+	}
+	if st.RSSHigh && !prev.RSSHigh {
+		logger.Printf("MAJOR WATCHDOG_RSS_HIGH rssMB=%d maxRssMB=%d", st.RSSMB, wc.MaxRSSMB)
+	} else if !st.RSSHigh && prev.RSSHigh {
+		logger.Println("WATCHDOG_RSS_RECOVERED")
+	}
+
+	pause := diskLow && wc.PausePersistence
+	if persistPaused.Swap(pause) != pause {
+		logger.Printf("WATCHDOG_PERSISTENCE paused=%v", pause)
+	}
+	st.PersistencePaused = pause
+
+	wdMu.Lock()
+	wdStatus = st
+	wdMu.Unlock()
+}
+
+// GET /api/admin/watchdog
+func apiWatchdog(w http.ResponseWriter, r *http.Request) {
+	wdMu.Lock()
+	st := wdStatus
+	wdMu.Unlock()
+	mustJSON(w, 200, map[string]any{"config": watchdogSettings(), "status": st})
+}
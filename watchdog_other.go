@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func diskFreeBytes(dir string) (uint64, error) {
+	return 0, errors.New("disk free check unsupported on " + runtime.GOOS)
+}
+
+// processRSSBytes approximates RSS with memory obtained from the OS by the Go runtime
+func processRSSBytes() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.Sys
+}
@@ -8,12 +8,27 @@ async function apiGet(path) {
   return res.json();
 }
 
+// signedHeaders: X-TS-* headers for admin mutations when replay protection is on
+async function signedHeaders(method, path, bodyText) {
+  const sk = await apiGet("/api/session/key").catch(() => ({}));
+  if (!sk.enabled || !sk.key || !window.crypto || !crypto.subtle) return {};
+  const enc = new TextEncoder();
+  const hex = buf => Array.from(new Uint8Array(buf), b => b.toString(16).padStart(2, "0")).join("");
+  const ts = String(Math.floor(Date.now() / 1000));
+  const nonce = hex(crypto.getRandomValues(new Uint8Array(16)));
+  const bodyHash = hex(await crypto.subtle.digest("SHA-256", enc.encode(bodyText || "")));
+  const key = await crypto.subtle.importKey("raw", enc.encode(sk.key), { name: "HMAC", hash: "SHA-256" }, false, ["sign"]);
+  const sig = hex(await crypto.subtle.sign("HMAC", key, enc.encode([method, path, ts, nonce, bodyHash].join("\n"))));
+  return { "X-TS-Timestamp": ts, "X-TS-Nonce": nonce, "X-TS-Signature": sig };
+}
+
 async function apiPost(path, body) {
+  const text = JSON.stringify(body);
   const res = await fetch(path, {
     method: "POST",
-    headers: { "Content-Type": "application/json" },
+    headers: { "Content-Type": "application/json", ...(await signedHeaders("POST", path, text)) },
     credentials: "include",
-    body: JSON.stringify(body),
+    body: text,
   });
   if (!res.ok) throw new Error(await res.text());
   return res.json();
@@ -52,6 +67,166 @@ async function saveAPIKeys() {
   }
 }
 
+async function apiDelete(path) {
+  const res = await fetch(path, { method: "DELETE", headers: await signedHeaders("DELETE", path, ""), credentials: "include" });
+  if (!res.ok) throw new Error(await res.text());
+  return res.json();
+}
+
+let sourcesCache = [];
+
+async function loadSources() {
+  const [data, presets] = await Promise.all([apiGet("/api/sources"), apiGet("/api/sources/presets")]);
+  const sel = $("src-preset");
+  if (sel.options.length === 1) {
+    for (const p of presets.presets || []) {
+      if (p.kind === "push") continue;
+      const o = document.createElement("option");
+      o.value = p.name;
+      o.textContent = p.name + " — " + (p.description || "");
+      sel.appendChild(o);
+    }
+  }
+  sourcesCache = data.sources || [];
+  renderSources(data.effectiveRps || {});
+}
+
+function renderSources(rps) {
+  const box = $("source-list");
+  box.textContent = "";
+  if (!sourcesCache.length) {
+    box.textContent = "暂无区块源（使用 TronGrid + API Key）";
+    return;
+  }
+  for (const s of sourcesCache) {
+    const row = document.createElement("div");
+    row.className = "row";
+    const label = document.createElement("span");
+    label.textContent = `${s.enabled ? "●" : "○"} ${s.name || s.id} (${s.kind || "poll"} ${s.method || ""} ${s.url}) rps=${rps[s.id] ?? "-"}`;
+    const edit = document.createElement("button");
+    edit.textContent = "编辑";
+    edit.addEventListener("click", () => fillSourceForm(s));
+    const del = document.createElement("button");
+    del.textContent = "删除";
+    del.addEventListener("click", () => deleteSource(s.id));
+    row.append(label, edit, del);
+    box.appendChild(row);
+  }
+}
+
+function fillSourceForm(s) {
+  s = s || {};
+  $("src-id").value = s.id || "";
+  $("src-preset").value = s.preset || "";
+  $("src-name").value = s.name || "";
+  $("src-method").value = s.method || "POST";
+  $("src-apikey").value = s.apiKey || "";
+  $("src-url").value = s.url || "";
+  $("src-body").value = s.body || "";
+  $("src-height-path").value = s.heightPath || "";
+  $("src-hash-path").value = s.hashPath || "";
+  $("src-time-path").value = s.timePath || "";
+  $("src-enabled").checked = s.id ? !!s.enabled : true;
+  $("src-capture-txs").checked = !!s.captureTxs;
+}
+
+function readSourceForm() {
+  const prev = sourcesCache.find(s => s.id === $("src-id").value) || {};
+  return {
+    ...prev,
+    id: $("src-id").value,
+    preset: $("src-preset").value,
+    name: $("src-name").value.trim(),
+    method: $("src-method").value,
+    apiKey: $("src-apikey").value.trim(),
+    url: $("src-url").value.trim(),
+    body: $("src-body").value,
+    heightPath: $("src-height-path").value.trim(),
+    hashPath: $("src-hash-path").value.trim(),
+    timePath: $("src-time-path").value.trim(),
+    enabled: $("src-enabled").checked,
+    captureTxs: $("src-capture-txs").checked,
+  };
+}
+
+async function testSource() {
+  $("src-response").textContent = "";
+  const text = JSON.stringify(readSourceForm());
+  const res = await fetch("/api/sources/test", {
+    method: "POST",
+    headers: { "Content-Type": "application/json", ...(await signedHeaders("POST", "/api/sources/test", text)) },
+    credentials: "include",
+    body: text,
+  });
+  if (res.status === 422) {
+    const out = await res.json();
+    setMsg("msg-source", `映射失败: ${out.error}`, false);
+    $("src-response").textContent = out.response || "";
+    return;
+  }
+  if (!res.ok) {
+    setMsg("msg-source", "测试失败: " + (await res.text()), false);
+    return;
+  }
+  const out = await res.json();
+  const tx = out.tx ? ` · ${out.tx.count} 笔交易` : "";
+  setMsg("msg-source", `高度 ${out.height} · ${out.state || "?"} · ${out.latencyMs}ms${tx}`, true);
+}
+
+async function detectSource() {
+  $("src-response").textContent = "";
+  try {
+    const out = await apiPost("/api/sources/detect", readSourceForm());
+    const sg = out.suggested || {};
+    for (const [id, key] of [["src-height-path", "heightPath"], ["src-hash-path", "hashPath"], ["src-time-path", "timePath"]]) {
+      if (!$(id).value.trim() && sg[key]) $(id).value = sg[key];
+    }
+    const c = out.candidates || {};
+    const list = (name, arr) => `${name}: ` + ((arr || []).map(x => `${x.path}${x.timeUnit ? " (" + x.timeUnit + ")" : ""} = ${x.value}`).join(" | ") || "-");
+    $("src-response").textContent = [list("高度", c.height), list("哈希", c.hash), list("时间", c.time)].join("\n") + (out.response ? "\n\n" + out.response : "");
+    if (out.preview) setMsg("msg-source", `已识别 · 高度 ${out.preview.height} · ${out.latencyMs}ms`, true);
+    else setMsg("msg-source", "未识别出高度 / 哈希，请参考返回内容手动填写", false);
+  } catch (e) {
+    setMsg("msg-source", "识别失败: " + e.message, false);
+  }
+}
+
+async function saveSource() {
+  try {
+    const out = await apiPost("/api/sources", readSourceForm());
+    fillSourceForm(out.source);
+    await loadSources();
+    setMsg("msg-source", "已保存", true);
+  } catch (e) {
+    setMsg("msg-source", "保存失败: " + e.message, false);
+  }
+}
+
+async function deleteSource(id) {
+  try {
+    await apiDelete("/api/sources?id=" + encodeURIComponent(id));
+    if ($("src-id").value === id) fillSourceForm(null);
+    await loadSources();
+  } catch (e) {
+    setMsg("msg-source", "删除失败: " + e.message, false);
+  }
+}
+
+async function loadExplorer() {
+  const data = await apiGet("/api/admin/explorer");
+  $("explorer-url").value = data.blockUrl || "";
+}
+
+async function saveExplorer() {
+  try {
+    const out = await apiPost("/api/admin/explorer", { blockUrl: $("explorer-url").value.trim() });
+    $("explorer-url").value = out.explorer?.blockUrl || "";
+    setMsg("msg-explorer", "已保存", true);
+  } catch (e) {
+    setMsg("msg-explorer", "保存失败: " + e.message, false);
+  }
+}
+
 async function loadRules() {
   const r = await apiGet("/api/rules");
 
@@ -63,10 +238,12 @@ async function loadRules() {
   $("off-threshold").value = (r.off?.threshold ?? 5);
   $("hit-offset").value = (r.hit?.offset ?? 1);
   $("hit-expect").value = (r.hit?.expect ?? "ON");
+  $("debounce").value = (r.debounce ?? 0);
 
   $("on-threshold-val").textContent = $("on-threshold").value;
   $("off-threshold-val").textContent = $("off-threshold").value;
   $("hit-offset-val").textContent = $("hit-offset").value;
+  $("debounce-val").textContent = $("debounce").value;
 }
 
 async function saveRules() {
@@ -83,7 +260,8 @@ async function saveRules() {
       enabled: $("hit-enabled").checked,
       offset: parseInt($("hit-offset").value, 10),
       expect: $("hit-expect").value,
-    }
+    },
+    debounce: parseInt($("debounce").value, 10),
   };
 
   try {
@@ -94,11 +272,104 @@ async function saveRules() {
   }
 }
 
+async function provisionToken() {
+  try {
+    const out = await apiPost("/api/admin/tokens/provision", {});
+    $("provision-token").textContent = out.token;
+    $("provision-link").textContent = out.link;
+    $("provision-qr").src = out.qr;
+    $("provision-out").hidden = false;
+    setMsg("msg-provision", "已生成", true);
+  } catch (e) {
+    setMsg("msg-provision", "生成失败: " + e.message, false);
+  }
+}
+
+async function bulkTokens() {
+  const lines = $("bulk-names").value.split("\n").map(s => s.trim()).filter(Boolean);
+  const count = Number($("bulk-count").value || 0);
+  let body, type;
+  if (lines.some(l => l.includes(","))) {
+    body = lines.join("\n");
+    type = "text/csv";
+  } else {
+    body = JSON.stringify(lines.length ? { names: lines } : { count });
+    type = "application/json";
+  }
+  try {
+    const res = await fetch("/api/admin/tokens/bulk", {
+      method: "POST",
+      headers: { "Content-Type": type, ...(await signedHeaders("POST", "/api/admin/tokens/bulk", body)) },
+      credentials: "include",
+      body,
+    });
+    if (!res.ok) throw new Error((await res.text()).trim());
+    const blob = await res.blob();
+    const a = document.createElement("a");
+    a.href = URL.createObjectURL(blob);
+    a.download = "tokens.csv";
+    a.click();
+    URL.revokeObjectURL(a.href);
+    setMsg("msg-bulk-tokens", "已生成", true);
+  } catch (e) {
+    setMsg("msg-bulk-tokens", "生成失败: " + e.message, false);
+  }
+}
+
 function renderStatus(st) {
-  $("sys-status").textContent = st.listening ? "Listening" : "Idle";
+  const staleLabel = { sources_down: "Sources down", warm_start: "Warm start" }[st.staleReason] || "Degraded";
+  $("sys-status").textContent = st.stale
+    ? staleLabel + " (" + Math.round(st.staleAgeSeconds || 0) + "s stale)"
+    : (st.listening ? "Listening" : "Idle");
   $("ws-reconnect").textContent = String(st.reconnects ?? 0);
   $("last-height").textContent = st.lastHeight ? String(st.lastHeight) : "-";
   $("last-time").textContent = st.lastTimeISO || "-";
+  $("today-triggers").textContent = String(st.today?.triggers ?? 0);
+  $("today-hit").textContent = String(st.today?.hit ?? 0);
+  if (st.inboxUnread !== inboxUnreadSeen) {
+    inboxUnreadSeen = st.inboxUnread;
+    $("inbox-unread").textContent = String(st.inboxUnread ?? 0);
+    loadInbox().catch(() => {});
+  }
+}
+
+let inboxUnreadSeen = null;
+
+async function loadInbox() {
+  const data = await apiGet("/api/admin/inbox?limit=20");
+  const box = $("inbox-list");
+  box.textContent = "";
+  const items = data.items || [];
+  if (!items.length) {
+    box.textContent = "暂无通知";
+    return;
+  }
+  for (const it of items) {
+    const row = document.createElement("div");
+    row.className = "row";
+    const label = document.createElement("span");
+    label.textContent = `${it.read ? "○" : "●"} ${it.time} [${it.code}] ${it.message}`;
+    if (it.major) label.className = "bad";
+    row.appendChild(label);
+    if (!it.read) {
+      const btn = document.createElement("button");
+      btn.textContent = "已读";
+      btn.addEventListener("click", () => markInboxRead({ ids: [it.id] }));
+      row.appendChild(btn);
+    }
+    box.appendChild(row);
+  }
+}
+
+async function markInboxRead(body) {
+  try {
+    const out = await apiPost("/api/admin/inbox/read", body);
+    inboxUnreadSeen = out.unread;
+    $("inbox-unread").textContent = String(out.unread);
+    await loadInbox();
+  } catch (e) {
+    setMsg("msg-inbox", "操作失败: " + e.message, false);
+  }
 }
 
 async function loadStatus() {
@@ -123,16 +394,83 @@ function startSSE() {
   };
 }
 
+// ---------- Panel preferences (stored server-side, /api/admin/prefs) ----------
+
+let prefs = {};
+
+function applyPrefs() {
+  document.documentElement.dataset.theme = prefs.theme || "";
+  $("pref-theme").value = prefs.theme || "";
+  const collapsed = new Set(prefs.collapsed || []);
+  const main = document.querySelector("main.wrap");
+  (prefs.order || []).forEach(id => {
+    const card = document.querySelector(`.card[data-card="${id}"]`);
+    if (card) main.appendChild(card);
+  });
+  document.querySelectorAll(".card[data-card]").forEach(card => {
+    card.classList.toggle("collapsed", collapsed.has(card.dataset.card));
+  });
+}
+
+async function savePrefs() {
+  try {
+    const out = await apiPost("/api/admin/prefs", prefs);
+    prefs = out.prefs;
+  } catch (e) {
+    console.warn("prefs not saved:", e.message);
+  }
+}
+
+async function loadPrefs() {
+  try {
+    prefs = await apiGet("/api/admin/prefs");
+  } catch (e) {
+    prefs = {};
+  }
+  applyPrefs();
+}
+
+function bindPrefs() {
+  $("pref-theme").addEventListener("change", () => {
+    prefs.theme = $("pref-theme").value;
+    applyPrefs();
+    savePrefs();
+  });
+  document.querySelectorAll(".card[data-card] > h2").forEach(h => {
+    h.addEventListener("click", () => {
+      const id = h.parentElement.dataset.card;
+      const collapsed = new Set(prefs.collapsed || []);
+      collapsed.has(id) ? collapsed.delete(id) : collapsed.add(id);
+      prefs.collapsed = [...collapsed];
+      applyPrefs();
+      savePrefs();
+    });
+  });
+}
+
 function init() {
+  bindPrefs();
+  loadPrefs();
   bindRange("on-threshold", "on-threshold-val");
   bindRange("off-threshold", "off-threshold-val");
   bindRange("hit-offset", "hit-offset-val");
+  bindRange("debounce", "debounce-val");
 
   $("btn-save-apikey").addEventListener("click", saveAPIKeys);
   $("btn-save-rules").addEventListener("click", saveRules);
+  $("btn-save-explorer").addEventListener("click", saveExplorer);
+  $("btn-test-source").addEventListener("click", testSource);
+  $("btn-detect-source").addEventListener("click", detectSource);
+  $("btn-inbox-read-all").addEventListener("click", () => markInboxRead({ all: true }));
+  $("btn-save-source").addEventListener("click", saveSource);
+  $("btn-new-source").addEventListener("click", () => fillSourceForm(null));
+  $("btn-provision").addEventListener("click", provisionToken);
+  $("btn-bulk-tokens").addEventListener("click", bulkTokens);
 
   loadAPIKeys();
   loadRules();
+  loadExplorer();
+  loadSources().catch(() => {});
   loadStatus();
   startSSE();
 
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+	接入自检（减少“被锁在外面”的排查）
+	- GET /api/auth/whoami：不需要登录，所有监听端口都开放；说明本次请求按什么放行
+	    authorizedBy：session（管理端口已登录）| whitelist（命中的白名单条目）| token（令牌名）| none
+	    与 /ws 的放行顺序一致；仅令牌端口不看白名单；带了无效令牌照常计入失败次数（防止拿来试令牌）
+	- GET /api/admin/whitelist/test?ip=1.2.3.4 | ?ip=10.0.0.0/24：检查 IP 是否命中白名单；
+	  传 CIDR 时列出落在该网段内的白名单条目（白名单本身只支持单个 IP）
+*/
+
+// whitelistMatch returns the whitelist entry that admits ip
+func whitelistMatch(ip net.IP, whitelist []string) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	for _, w := range whitelist {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		if ip.String() == w || ip.Equal(net.ParseIP(w)) {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+type WhoAmI struct {
+	Remote        string `json:"remote"`
+	Listener      string `json:"listener"`
+	AuthorizedBy  string `json:"authorizedBy"` // session | whitelist | token | none
+	User          string `json:"user,omitempty"`
+	Whitelisted   bool   `json:"whitelisted"`
+	WhitelistRule string `json:"whitelistRule,omitempty"`
+	TokenPresent  bool   `json:"tokenPresent"`
+	TokenValid    bool   `json:"tokenValid"`
+	TokenName     string `json:"tokenName,omitempty"`
+	Country       string `json:"country,omitempty"`
+	GeoBlocked    bool   `json:"geoBlocked,omitempty"`
+}
+
+// GET /api/auth/whoami
+func apiWhoAmI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	cfgMu.RLock()
+	whitelist := append([]string(nil), cfg.Access.IPWhitelist...)
+	names := cfg.Access.TokenNames
+	geo := cfg.GeoIP
+	cfgMu.RUnlock()
+
+	policy := listenerPolicy(r)
+	out := WhoAmI{Remote: remoteIP(r), Listener: policy, AuthorizedBy: "none"}
+	out.WhitelistRule, out.Whitelisted = whitelistMatch(net.ParseIP(hostOnly(r.RemoteAddr)), whitelist)
+	tok, ok := tokenOK(r)
+	out.TokenPresent, out.TokenValid = tok != "", ok
+	if ok {
+		cfgMu.RLock()
+		out.TokenName = names[tok]
+		cfgMu.RUnlock()
+	} else if tok != "" {
+		authFailed(r, "token_invalid")
+	}
+	if geoEnabled() {
+		out.Country = geoCountry(r.RemoteAddr)
+		out.GeoBlocked = !geoAllowed(out.Country, geo)
+	}
+
+	switch {
+	case policy == policyAdmin && isLoggedIn(r):
+		out.AuthorizedBy, out.User = "session", sessionUser(r)
+	case policy != policyToken && out.Whitelisted:
+		out.AuthorizedBy = "whitelist"
+	case out.GeoBlocked:
+	case ok:
+		out.AuthorizedBy = "token"
+	}
+	mustJSON(w, 200, out)
+}
+
+// GET /api/admin/whitelist/test?ip=1.2.3.4 | ?ip=10.0.0.0/24
+func apiWhitelistTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, r, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	in := strings.TrimSpace(r.URL.Query().Get("ip"))
+	cfgMu.RLock()
+	whitelist := append([]string(nil), cfg.Access.IPWhitelist...)
+	cfgMu.RUnlock()
+
+	if ip := net.ParseIP(in); ip != nil {
+		rule, ok := whitelistMatch(ip, whitelist)
+		mustJSON(w, 200, map[string]any{"input": in, "kind": "ip", "matched": ok, "rule": rule})
+		return
+	}
+	_, n, err := net.ParseCIDR(in)
+	if err != nil {
+		httpError(w, r, "ip must be an IP address or CIDR", http.StatusBadRequest)
+		return
+	}
+	inside := []string{}
+	for _, e := range whitelist {
+		if ip := net.ParseIP(strings.TrimSpace(e)); ip != nil && n.Contains(ip) {
+			inside = append(inside, strings.TrimSpace(e))
+		}
+	}
+	mustJSON(w, 200, map[string]any{"input": in, "kind": "cidr", "matched": len(inside) > 0, "entries": inside})
+}